@@ -1,18 +1,21 @@
 package main
 
 import (
+	"billing-engine/internal/billing/delinquency"
+	"billing-engine/internal/billing/disbursement"
+	"billing-engine/internal/billing/eligibility"
 	"billing-engine/internal/billing/repository"
 	"billing-engine/internal/billing/service"
 	"billing-engine/pkg/config"
+	"billing-engine/pkg/consumer"
 	"billing-engine/pkg/database"
 	"billing-engine/pkg/logger"
-	"billing-engine/pkg/producer"
 	"context"
 	"fmt"
-	"github.com/IBM/sarama"
 	"github.com/redis/go-redis/v9"
 	"os"
 	"os/signal"
+	"syscall"
 )
 
 func main() {
@@ -27,16 +30,6 @@ func main() {
 		panic(err)
 	}
 
-	producerConfig := producer.Config{
-		Brokers:      cfg.Kafka.Broker,
-		Topic:        cfg.Kafka.LoanTopic,
-		WriteTimeout: cfg.Kafka.Timeout,
-	}
-	newProducer, err := producer.NewProducer(producerConfig, log)
-	if err != nil {
-		panic(err)
-	}
-
 	redisClient := redis.NewClient(&redis.Options{
 		Addr: fmt.Sprintf("%s:%d", cfg.Cache.Host, cfg.Cache.Port),
 		DB:   cfg.Cache.Database,
@@ -44,42 +37,36 @@ func main() {
 
 	paymentRepository := repository.NewBillingRepositoryProvider(gorm, log)
 	cacheRepository := repository.NewBillingCacheProvider(redisClient, log)
-	billingService := service.NewBillingService(paymentRepository, cacheRepository, newProducer, log)
-
-	saramaConfig := sarama.NewConfig()
-	saramaConfig.Consumer.Return.Errors = true
-	brokers := []string{cfg.Kafka.Broker}
+	policy, err := delinquency.NewPolicy(cfg.Delinquency)
+	if err != nil {
+		panic(err)
+	}
 
-	master, err := sarama.NewConsumer(brokers, saramaConfig)
+	eligibilityPolicy, err := eligibility.NewPolicy(cfg.Eligibility)
 	if err != nil {
 		panic(err)
 	}
 
-	consumer, err := master.ConsumePartition(cfg.Kafka.PaymentTopic, 0, sarama.OffsetOldest)
+	billingService := service.NewBillingService(paymentRepository, cacheRepository, policy, eligibilityPolicy,
+		disbursement.Disburser{}, log)
+
+	consumerConfig := consumer.Config{
+		Brokers:         cfg.Kafka.Broker,
+		Topic:           cfg.Kafka.PaymentTopic,
+		GroupID:         cfg.Kafka.ConsumerGroup,
+		ConsumerName:    "consumer-billing",
+		DeadLetterTopic: cfg.Kafka.PaymentTopic + cfg.Consumer.DeadLetterSuffix,
+	}
+	newConsumer, err := consumer.NewConsumer(consumerConfig, consumer.NewRetryPolicy(cfg.Consumer), billingService, log)
 	if err != nil {
 		panic(err)
 	}
 
-	signals := make(chan os.Signal, 1)
-	signal.Notify(signals, os.Interrupt)
-	doneCh := make(chan struct{})
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-	go func() {
-		for {
-			select {
-			case err := <-consumer.Errors():
-				log.WithField("error", err).Error("consumer error")
-			case msg := <-consumer.Messages():
-				log.WithField("message", string(msg.Value)).Info("received message")
-				err = billingService.ProcessMessage(context.Background(), msg.Value)
-				if err != nil {
-					log.WithField("error", err).Error("failed to process message")
-				}
-			case <-signals:
-				log.Info("interrupt signal received")
-				doneCh <- struct{}{}
-			}
-		}
-	}()
-	<-doneCh
+	err = newConsumer.Run(ctx)
+	if err != nil {
+		panic(err)
+	}
 }
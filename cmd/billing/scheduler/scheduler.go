@@ -0,0 +1,78 @@
+package main
+
+import (
+	"billing-engine/internal/billing/delinquency"
+	"billing-engine/internal/billing/disbursement"
+	"billing-engine/internal/billing/eligibility"
+	"billing-engine/internal/billing/repository"
+	"billing-engine/internal/billing/service"
+	"billing-engine/pkg/config"
+	"billing-engine/pkg/database"
+	"billing-engine/pkg/logger"
+	"context"
+	"fmt"
+	"github.com/redis/go-redis/v9"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+func main() {
+	log := logger.NewZeroLogger("scheduler-billing")
+	cfg, err := config.NewConfig("billing")
+	if err != nil {
+		panic(err)
+	}
+
+	gorm, err := database.NewGormConnection(cfg)
+	if err != nil {
+		panic(err)
+	}
+
+	redisClient := redis.NewClient(&redis.Options{
+		Addr: fmt.Sprintf("%s:%d", cfg.Cache.Host, cfg.Cache.Port),
+		DB:   cfg.Cache.Database,
+	})
+
+	billingRepository := repository.NewBillingRepositoryProvider(gorm, log)
+	cacheRepository := repository.NewBillingCacheProvider(redisClient, log)
+	policy, err := delinquency.NewPolicy(cfg.Delinquency)
+	if err != nil {
+		panic(err)
+	}
+
+	eligibilityPolicy, err := eligibility.NewPolicy(cfg.Eligibility)
+	if err != nil {
+		panic(err)
+	}
+
+	billingService := service.NewBillingService(billingRepository, cacheRepository, policy, eligibilityPolicy,
+		disbursement.Disburser{}, log)
+
+	interval := time.Duration(cfg.Scheduler.Interval) * time.Second
+	if interval <= 0 {
+		interval = time.Hour
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	log.WithField("interval", interval.String()).Info("scheduler started")
+	for {
+		err = billingService.MarkOverdueSchedules(ctx, time.Now())
+		if err != nil {
+			log.WithField("error", err).Error("failed to mark overdue schedules")
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			log.Info("interrupt signal received")
+			return
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"billing-engine/internal/billing/domain"
+	"billing-engine/internal/billing/repository"
+	"billing-engine/pkg/config"
+	"billing-engine/pkg/database"
+	"billing-engine/pkg/logger"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/brianvoe/gofakeit/v7"
+	"strings"
+)
+
+// seeder fills the billing database with fake customers for local testing, the schema is created by the
+// billing api server.
+func main() {
+	total := flag.Int("customers", 100, "number of fake customers to create")
+	flag.Parse()
+
+	log := logger.NewZeroLogger("seeder-billing")
+	cfg, err := config.NewConfig("billing")
+	if err != nil {
+		panic(err)
+	}
+
+	gorm, err := database.NewGormConnection(cfg)
+	if err != nil {
+		panic(err)
+	}
+
+	billingRepository := repository.NewBillingRepositoryProvider(gorm, log)
+	ctx := context.Background()
+
+	created := 0
+	for i := 0; i < *total; i++ {
+		customer := domain.Customer{
+			FirstName:   gofakeit.FirstName(),
+			LastName:    gofakeit.LastName(),
+			Email:       strings.ToLower(gofakeit.Email()),
+			PhoneNumber: fmt.Sprintf("+628%s", gofakeit.Numerify("#########")),
+		}
+
+		_, err = billingRepository.CreateCustomer(ctx, customer)
+		if errors.Is(err, repository.ErrDuplicateEmail) {
+			log.WithField("email", customer.Email).Info("skipping customer with a duplicate email")
+			continue
+		} else if err != nil {
+			panic(err)
+		}
+
+		created++
+	}
+
+	log.WithField("created", created).Info("customers seeded")
+}
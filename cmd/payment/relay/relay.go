@@ -0,0 +1,43 @@
+package main
+
+import (
+	"billing-engine/pkg/config"
+	"billing-engine/pkg/database"
+	"billing-engine/pkg/logger"
+	"billing-engine/pkg/outbox"
+	"billing-engine/pkg/producer"
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// relay sends the events the payment service wrote to its outbox to kafka, the tables are created by the
+// payment api server.
+func main() {
+	log := logger.NewZeroLogger("relay-payment")
+	cfg, err := config.NewConfig("payment")
+	if err != nil {
+		panic(err)
+	}
+
+	gorm, err := database.NewGormConnection(cfg)
+	if err != nil {
+		panic(err)
+	}
+
+	producerConfig := producer.Config{
+		Brokers:      cfg.Kafka.Broker,
+		Topic:        cfg.Kafka.PaymentTopic,
+		WriteTimeout: cfg.Kafka.Timeout,
+	}
+	newProducer, err := producer.NewProducer(producerConfig, log)
+	if err != nil {
+		panic(err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	outbox.NewRelay(gorm, newProducer, cfg.Outbox, log).Run(ctx)
+}
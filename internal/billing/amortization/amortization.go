@@ -0,0 +1,143 @@
+package amortization
+
+import (
+	"billing-engine/pkg/enum"
+	"billing-engine/pkg/money"
+	"fmt"
+	"math"
+)
+
+// Terms describes what is being amortized. AnnualRate is a per-annum rate; flat loans charge it for the
+// InstallmentCount periods of the schedule while the balance-based methods convert it to a rate per
+// installment, both using PeriodsPerYear.
+type Terms struct {
+	Principal        money.Money
+	AnnualRate       float64
+	InstallmentCount int
+	PeriodsPerYear   float64
+}
+
+// Installment is the split of a single scheduled payment.
+type Installment struct {
+	Principal          money.Money
+	Interest           money.Money
+	RemainingPrincipal money.Money
+}
+
+func (i Installment) Amount() (money.Money, error) {
+	return i.Principal.Add(i.Interest)
+}
+
+type Method interface {
+	Installments(terms Terms) ([]Installment, error)
+}
+
+func New(method enum.AmortizationMethod) (Method, error) {
+	switch method {
+	case enum.AmortizationFlat, "":
+		return flat{}, nil
+	case enum.AmortizationAnnuity:
+		return annuity{}, nil
+	case enum.AmortizationDecliningBalance:
+		return decliningBalance{}, nil
+	default:
+		return nil, fmt.Errorf("unknown amortization method %q", method)
+	}
+}
+
+// PeriodsPerYear returns how many installments of the given frequency fit into a year.
+func PeriodsPerYear(frequency enum.Frequency, intervalDays int) float64 {
+	switch frequency {
+	case enum.FrequencyWeekly:
+		return 52
+	case enum.FrequencyBiWeekly:
+		return 26
+	case enum.FrequencyCustom:
+		return 365 / float64(intervalDays)
+	default:
+		return 12
+	}
+}
+
+type flat struct{}
+
+// Installments spreads the principal and the interest for the whole schedule evenly. The interest runs
+// for the InstallmentCount periods, so a weekly schedule is charged for weeks rather than months. Both
+// are split in minor units with the remainder on the final installment, so the schedule adds up exactly.
+func (flat) Installments(terms Terms) ([]Installment, error) {
+	years := float64(terms.InstallmentCount) / terms.PeriodsPerYear
+	totalInterest := terms.Principal.MulRate(terms.AnnualRate * years)
+	principals := terms.Principal.Split(terms.InstallmentCount)
+	interests := totalInterest.Split(terms.InstallmentCount)
+
+	return build(terms, func(_ money.Money, no int) (money.Money, money.Money, error) {
+		return principals[no], interests[no], nil
+	})
+}
+
+type annuity struct{}
+
+func (annuity) Installments(terms Terms) ([]Installment, error) {
+	rate := terms.AnnualRate / terms.PeriodsPerYear
+	n := float64(terms.InstallmentCount)
+
+	factor := 1 / n
+	if rate > 0 {
+		factor = rate / (1 - math.Pow(1+rate, -n))
+	}
+	payment := terms.Principal.MulRate(factor)
+
+	return build(terms, func(remaining money.Money, _ int) (money.Money, money.Money, error) {
+		interest := remaining.MulRate(rate)
+		principal, err := payment.Sub(interest)
+		return principal, interest, err
+	})
+}
+
+type decliningBalance struct{}
+
+func (decliningBalance) Installments(terms Terms) ([]Installment, error) {
+	rate := terms.AnnualRate / terms.PeriodsPerYear
+	principals := terms.Principal.Split(terms.InstallmentCount)
+
+	return build(terms, func(remaining money.Money, no int) (money.Money, money.Money, error) {
+		return principals[no], remaining.MulRate(rate), nil
+	})
+}
+
+// build walks the installments, letting split decide the principal and interest of the zero-based
+// installment no from the principal still outstanding. The final installment always repays
+// whatever principal is left, absorbing any rounding residual.
+func build(terms Terms, split func(remaining money.Money, no int) (principal, interest money.Money, err error)) ([]Installment, error) {
+	installments := make([]Installment, 0, terms.InstallmentCount)
+	remaining := terms.Principal
+
+	for i := 0; i < terms.InstallmentCount; i++ {
+		principal, interest, err := split(remaining, i)
+		if err != nil {
+			return nil, err
+		}
+
+		exceeds, err := principal.GreaterThan(remaining)
+		if err != nil {
+			return nil, err
+		}
+
+		if i == terms.InstallmentCount-1 || exceeds {
+			principal = remaining
+		}
+
+		remaining, err = remaining.Sub(principal)
+		if err != nil {
+			return nil, err
+		}
+
+		installments = append(installments, Installment{
+			Principal:          principal,
+			Interest:           interest,
+			RemainingPrincipal: remaining,
+		})
+	}
+
+	return installments, nil
+}
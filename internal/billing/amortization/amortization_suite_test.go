@@ -0,0 +1,13 @@
+package amortization
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestAmortization(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Amortization Suite")
+}
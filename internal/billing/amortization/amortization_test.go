@@ -0,0 +1,114 @@
+package amortization
+
+import (
+	"billing-engine/pkg/enum"
+	"billing-engine/pkg/money"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func idr(amount int64) money.Money {
+	return money.New(amount, "IDR")
+}
+
+var _ = Describe("Amortization", func() {
+	terms := Terms{
+		Principal:        idr(12000000),
+		AnnualRate:       0.12,
+		InstallmentCount: 12,
+		PeriodsPerYear:   12,
+	}
+
+	sumPrincipal := func(installments []Installment) money.Money {
+		total := idr(0)
+		for _, val := range installments {
+			var err error
+			total, err = total.Add(val.Principal)
+			Expect(err).To(BeNil())
+		}
+		return total
+	}
+
+	It("should reject an unknown method", func() {
+		_, err := New("BALLOON")
+		Expect(err).To(HaveOccurred())
+	})
+
+	Describe("Flat", func() {
+		It("should charge the same interest on every installment", func() {
+			method, err := New(enum.AmortizationFlat)
+			Expect(err).To(BeNil())
+
+			installments, err := method.Installments(terms)
+			Expect(err).To(BeNil())
+			Expect(installments).To(HaveLen(12))
+			for _, val := range installments {
+				Expect(val.Principal).To(Equal(idr(1000000)))
+				Expect(val.Interest).To(Equal(idr(120000)))
+			}
+			Expect(installments[11].RemainingPrincipal).To(Equal(idr(0)))
+		})
+
+		It("should put the rounding residual on the final installment", func() {
+			method, _ := New(enum.AmortizationFlat)
+			installments, err := method.Installments(Terms{
+				Principal:        idr(1000),
+				AnnualRate:       0.1,
+				InstallmentCount: 3,
+				PeriodsPerYear:   3,
+			})
+			Expect(err).To(BeNil())
+
+			total := idr(0)
+			for _, val := range installments {
+				amount, err := val.Amount()
+				Expect(err).To(BeNil())
+				total, err = total.Add(amount)
+				Expect(err).To(BeNil())
+			}
+			Expect(total).To(Equal(idr(1100)))
+			Expect(installments[0].Amount()).To(Equal(idr(366)))
+			Expect(installments[2].Amount()).To(Equal(idr(368)))
+		})
+	})
+
+	Describe("Annuity", func() {
+		It("should keep installments equal while interest declines", func() {
+			method, err := New(enum.AmortizationAnnuity)
+			Expect(err).To(BeNil())
+
+			installments, err := method.Installments(terms)
+			Expect(err).To(BeNil())
+			Expect(installments[0].Interest).To(Equal(idr(120000)))
+			Expect(installments[0].Amount()).To(Equal(idr(1066185)))
+			Expect(installments[5].Amount()).To(Equal(idr(1066185)))
+			Expect(installments[11].Interest.LessThan(installments[10].Interest)).To(BeTrue())
+			Expect(sumPrincipal(installments)).To(Equal(terms.Principal))
+			Expect(installments[11].RemainingPrincipal).To(Equal(idr(0)))
+		})
+	})
+
+	Describe("Declining balance", func() {
+		It("should repay equal principal with interest on the remaining balance", func() {
+			method, err := New(enum.AmortizationDecliningBalance)
+			Expect(err).To(BeNil())
+
+			installments, err := method.Installments(terms)
+			Expect(err).To(BeNil())
+			Expect(installments[0].Principal).To(Equal(idr(1000000)))
+			Expect(installments[0].Interest).To(Equal(idr(120000)))
+			Expect(installments[1].Interest).To(Equal(idr(110000)))
+			Expect(installments[11].Interest).To(Equal(idr(10000)))
+			Expect(sumPrincipal(installments)).To(Equal(terms.Principal))
+		})
+	})
+
+	Describe("PeriodsPerYear", func() {
+		It("should convert frequencies to periods", func() {
+			Expect(PeriodsPerYear(enum.FrequencyWeekly, 0)).To(Equal(float64(52)))
+			Expect(PeriodsPerYear(enum.FrequencyBiWeekly, 0)).To(Equal(float64(26)))
+			Expect(PeriodsPerYear(enum.FrequencyMonthly, 0)).To(Equal(float64(12)))
+			Expect(PeriodsPerYear(enum.FrequencyCustom, 5)).To(Equal(float64(73)))
+		})
+	})
+})
@@ -55,6 +55,202 @@ func (s *BillingHandler) GetPaymentScheduleHandler(c echo.Context) error {
 	return c.JSON(http.StatusOK, response.NewSuccessResponse(result))
 }
 
+func (s *BillingHandler) GetPayoffQuoteHandler(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	loanUUID, err := uuid.Parse(c.Param("loan_id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, response.NewErrorResponse(400, "invalid loan id"))
+	}
+
+	result, err := s.BillingService.GetPayoffQuote(ctx, loanUUID)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, response.NewSuccessResponse(result))
+}
+
+func (s *BillingHandler) ApproveLoanHandler(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	payload := model.ApproveLoanPayload{}
+	if err := c.Bind(&payload); err != nil {
+		return c.JSON(http.StatusBadRequest, response.NewErrorResponse(400, "invalid request body"))
+	}
+
+	if err := c.Validate(payload); err != nil {
+		return err
+	}
+
+	result, err := s.BillingService.ApproveLoan(ctx, payload)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, response.NewSuccessResponse(result))
+}
+
+func (s *BillingHandler) RejectLoanHandler(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	payload := model.RejectLoanPayload{}
+	if err := c.Bind(&payload); err != nil {
+		return c.JSON(http.StatusBadRequest, response.NewErrorResponse(400, "invalid request body"))
+	}
+
+	if err := c.Validate(payload); err != nil {
+		return err
+	}
+
+	result, err := s.BillingService.RejectLoan(ctx, payload)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, response.NewSuccessResponse(result))
+}
+
+func (s *BillingHandler) DisburseLoanHandler(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	payload := model.DisburseLoanPayload{}
+	if err := c.Bind(&payload); err != nil {
+		return c.JSON(http.StatusBadRequest, response.NewErrorResponse(400, "invalid request body"))
+	}
+
+	if err := c.Validate(payload); err != nil {
+		return err
+	}
+
+	result, err := s.BillingService.DisburseLoan(ctx, payload)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, response.NewSuccessResponse(result))
+}
+
+func (s *BillingHandler) TopUpLoanHandler(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	payload := model.TopUpLoanPayload{}
+	if err := c.Bind(&payload); err != nil {
+		return c.JSON(http.StatusBadRequest, response.NewErrorResponse(400, "invalid request body"))
+	}
+
+	if err := c.Validate(payload); err != nil {
+		return err
+	}
+
+	result, err := s.BillingService.TopUpLoan(ctx, payload)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, response.NewSuccessResponse(result))
+}
+
+func (s *BillingHandler) CancelLoanHandler(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	payload := model.CancelLoanPayload{}
+	if err := c.Bind(&payload); err != nil {
+		return c.JSON(http.StatusBadRequest, response.NewErrorResponse(400, "invalid request body"))
+	}
+
+	if err := c.Validate(payload); err != nil {
+		return err
+	}
+
+	result, err := s.BillingService.CancelLoan(ctx, payload)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, response.NewSuccessResponse(result))
+}
+
+func (s *BillingHandler) RestructureLoanHandler(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	payload := model.RestructureLoanPayload{}
+	if err := c.Bind(&payload); err != nil {
+		return c.JSON(http.StatusBadRequest, response.NewErrorResponse(400, "invalid request body"))
+	}
+
+	if err := c.Validate(payload); err != nil {
+		return err
+	}
+
+	result, err := s.BillingService.RestructureLoan(ctx, payload)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, response.NewSuccessResponse(result))
+}
+
+func (s *BillingHandler) GrantPaymentHolidayHandler(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	payload := model.PaymentHolidayPayload{}
+	if err := c.Bind(&payload); err != nil {
+		return c.JSON(http.StatusBadRequest, response.NewErrorResponse(400, "invalid request body"))
+	}
+
+	if err := c.Validate(payload); err != nil {
+		return err
+	}
+
+	result, err := s.BillingService.GrantPaymentHoliday(ctx, payload)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, response.NewSuccessResponse(result))
+}
+
+func (s *BillingHandler) GrantBulkPaymentHolidayHandler(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	payload := model.BulkPaymentHolidayPayload{}
+	if err := c.Bind(&payload); err != nil {
+		return err
+	}
+
+	if err := c.Validate(payload); err != nil {
+		return err
+	}
+
+	result, err := s.BillingService.GrantBulkPaymentHoliday(ctx, payload)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, response.NewSuccessResponse(result))
+}
+
+func (s *BillingHandler) WriteOffLoanHandler(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	payload := model.WriteOffLoanPayload{}
+	if err := c.Bind(&payload); err != nil {
+		return c.JSON(http.StatusBadRequest, response.NewErrorResponse(400, "invalid request body"))
+	}
+
+	if err := c.Validate(payload); err != nil {
+		return err
+	}
+
+	result, err := s.BillingService.WriteOffLoan(ctx, payload)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, response.NewSuccessResponse(result))
+}
+
 func (s *BillingHandler) IsCustomerDelinquentHandler(c echo.Context) error {
 	ctx := c.Request().Context()
 
@@ -91,10 +287,48 @@ func (s *BillingHandler) GetOutstandingBalanceHandler(c echo.Context) error {
 	return c.JSON(http.StatusOK, response.NewSuccessResponse(result))
 }
 
+func (s *BillingHandler) GetCreditLimitHandler(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	customerID := c.Param("customer_id")
+	// convert string to uuid
+	customerUUID, err := uuid.Parse(customerID)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, response.NewErrorResponse(400, "invalid customer id"))
+	}
+
+	result, err := s.BillingService.GetCreditLimit(ctx, customerUUID)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, response.NewSuccessResponse(result))
+}
+
+func (s *BillingHandler) UpdateCreditLimitHandler(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	payload := model.UpdateCreditLimitPayload{}
+	if err := c.Bind(&payload); err != nil {
+		return c.JSON(http.StatusBadRequest, response.NewErrorResponse(400, "invalid request body"))
+	}
+
+	if err := c.Validate(payload); err != nil {
+		return err
+	}
+
+	result, err := s.BillingService.UpdateCreditLimit(ctx, payload)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, response.NewSuccessResponse(result))
+}
+
 func (s *BillingHandler) CreateCustomerHandler(c echo.Context) error {
 	ctx := c.Request().Context()
 
-	payload := model.CreateCustomerPayload{}
+	payload := model.CustomerPayload{}
 	if err := c.Bind(&payload); err != nil {
 		return err
 	}
@@ -111,10 +345,138 @@ func (s *BillingHandler) CreateCustomerHandler(c echo.Context) error {
 	return c.JSON(http.StatusOK, response.NewSuccessResponse(result))
 }
 
+func (s *BillingHandler) UpdateCustomerHandler(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	payload := model.UpdateCustomerPayload{}
+	if err := c.Bind(&payload); err != nil {
+		return c.JSON(http.StatusBadRequest, response.NewErrorResponse(400, "invalid request body"))
+	}
+
+	if err := c.Validate(payload); err != nil {
+		return err
+	}
+
+	result, err := s.BillingService.UpdateCustomer(ctx, payload)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, response.NewSuccessResponse(result))
+}
+
 func (s *BillingHandler) GetCustomerHandler(c echo.Context) error {
 	ctx := c.Request().Context()
 
-	result, err := s.BillingService.GetCustomer(ctx)
+	customerUUID, err := uuid.Parse(c.Param("customer_id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, response.NewErrorResponse(400, "invalid customer id"))
+	}
+
+	result, err := s.BillingService.GetCustomer(ctx, customerUUID)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, response.NewSuccessResponse(result))
+}
+
+func (s *BillingHandler) SearchCustomersHandler(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	payload := model.SearchCustomersPayload{}
+	if err := c.Bind(&payload); err != nil {
+		return err
+	}
+
+	if err := c.Validate(payload); err != nil {
+		return err
+	}
+
+	result, err := s.BillingService.SearchCustomers(ctx, payload)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, response.NewSuccessResponse(result))
+}
+
+func (s *BillingHandler) DeactivateCustomerHandler(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	customerUUID, err := uuid.Parse(c.Param("customer_id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, response.NewErrorResponse(400, "invalid customer id"))
+	}
+
+	result, err := s.BillingService.DeactivateCustomer(ctx, customerUUID)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, response.NewSuccessResponse(result))
+}
+
+func (s *BillingHandler) CreateProductHandler(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	payload := model.ProductPayload{}
+	if err := c.Bind(&payload); err != nil {
+		return err
+	}
+
+	if err := c.Validate(payload); err != nil {
+		return err
+	}
+
+	result, err := s.BillingService.CreateProduct(ctx, payload)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, response.NewSuccessResponse(result))
+}
+
+func (s *BillingHandler) GetProductsHandler(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	result, err := s.BillingService.GetProducts(ctx)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, response.NewSuccessResponse(result))
+}
+
+func (s *BillingHandler) GetProductHandler(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	productUUID, err := uuid.Parse(c.Param("product_id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, response.NewErrorResponse(400, "invalid product id"))
+	}
+
+	result, err := s.BillingService.GetProduct(ctx, productUUID)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, response.NewSuccessResponse(result))
+}
+
+func (s *BillingHandler) UpdateProductHandler(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	payload := model.UpdateProductPayload{}
+	if err := c.Bind(&payload); err != nil {
+		return c.JSON(http.StatusBadRequest, response.NewErrorResponse(400, "invalid request body"))
+	}
+
+	if err := c.Validate(payload); err != nil {
+		return err
+	}
+
+	result, err := s.BillingService.UpdateProduct(ctx, payload)
 	if err != nil {
 		return err
 	}
@@ -122,6 +484,22 @@ func (s *BillingHandler) GetCustomerHandler(c echo.Context) error {
 	return c.JSON(http.StatusOK, response.NewSuccessResponse(result))
 }
 
+func (s *BillingHandler) DeleteProductHandler(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	productUUID, err := uuid.Parse(c.Param("product_id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, response.NewErrorResponse(400, "invalid product id"))
+	}
+
+	err = s.BillingService.DeleteProduct(ctx, productUUID)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, response.NewSuccessResponse(nil))
+}
+
 func NewBillingHandler(svc service.BillingServiceProvider) *BillingHandler {
 	return &BillingHandler{
 		BillingService: svc,
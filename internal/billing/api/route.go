@@ -6,10 +6,32 @@ func (s *BillingHandler) AddRoutes(e *echo.Echo) {
 	loanGroup := e.Group("/loan")
 	loanGroup.POST("", s.CreateLoanHandler)
 	loanGroup.GET("/schedule", s.GetPaymentScheduleHandler)
+	loanGroup.POST("/:loan_id/approve", s.ApproveLoanHandler)
+	loanGroup.POST("/:loan_id/reject", s.RejectLoanHandler)
+	loanGroup.POST("/:loan_id/disburse", s.DisburseLoanHandler)
+	loanGroup.POST("/:loan_id/cancel", s.CancelLoanHandler)
+	loanGroup.POST("/:loan_id/top-up", s.TopUpLoanHandler)
+	loanGroup.GET("/:loan_id/payoff-quote", s.GetPayoffQuoteHandler)
+	loanGroup.POST("/:loan_id/restructure", s.RestructureLoanHandler)
+	loanGroup.POST("/:loan_id/payment-holiday", s.GrantPaymentHolidayHandler)
+	loanGroup.POST("/payment-holiday", s.GrantBulkPaymentHolidayHandler)
+	loanGroup.POST("/:loan_id/write-off", s.WriteOffLoanHandler)
 
 	customerGroup := e.Group("/customer")
 	customerGroup.GET("/:customer_id/delinquent", s.IsCustomerDelinquentHandler)
 	customerGroup.POST("", s.CreateCustomerHandler)
-	customerGroup.GET("", s.GetCustomerHandler)
+	customerGroup.GET("", s.SearchCustomersHandler)
+	customerGroup.GET("/:customer_id", s.GetCustomerHandler)
+	customerGroup.PUT("/:customer_id", s.UpdateCustomerHandler)
+	customerGroup.POST("/:customer_id/deactivate", s.DeactivateCustomerHandler)
 	customerGroup.GET("/:customer_id/outstanding", s.GetOutstandingBalanceHandler)
+	customerGroup.GET("/:customer_id/limit", s.GetCreditLimitHandler)
+	customerGroup.PUT("/:customer_id/limit", s.UpdateCreditLimitHandler)
+
+	productGroup := e.Group("/product")
+	productGroup.POST("", s.CreateProductHandler)
+	productGroup.GET("", s.GetProductsHandler)
+	productGroup.GET("/:product_id", s.GetProductHandler)
+	productGroup.PUT("/:product_id", s.UpdateProductHandler)
+	productGroup.DELETE("/:product_id", s.DeleteProductHandler)
 }
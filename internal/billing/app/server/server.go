@@ -2,13 +2,17 @@ package server
 
 import (
 	"billing-engine/internal/billing/api"
+	"billing-engine/internal/billing/delinquency"
+	"billing-engine/internal/billing/disbursement"
 	"billing-engine/internal/billing/domain"
+	"billing-engine/internal/billing/eligibility"
 	"billing-engine/internal/billing/repository"
 	"billing-engine/internal/billing/service"
 	"billing-engine/pkg/config"
 	"billing-engine/pkg/database"
+	"billing-engine/pkg/inbox"
 	"billing-engine/pkg/logger"
-	"billing-engine/pkg/producer"
+	"billing-engine/pkg/outbox"
 	"context"
 	"fmt"
 	"github.com/go-playground/validator/v10"
@@ -39,7 +43,15 @@ func NewServer(log logger.Logger, cfg *config.Config) (*Server, error) {
 		return nil, err
 	}
 
-	err = gorm.AutoMigrate(&domain.Customer{}, &domain.Loan{}, &domain.Schedule{})
+	err = gorm.AutoMigrate(&domain.Customer{}, &domain.Product{}, &domain.Loan{}, &domain.Schedule{}, &domain.Penalty{},
+		&domain.PayoffQuote{}, &domain.PayoffQuoteLine{}, &domain.LoanStatusHistory{}, &domain.Restructure{},
+		&domain.PaymentHoliday{}, &domain.WriteOff{}, &domain.Recovery{},
+		&domain.Disbursement{}, &domain.Cancellation{}, &outbox.Message{}, &inbox.ProcessedEvent{})
+	if err != nil {
+		return nil, err
+	}
+
+	err = repository.MigrateLoanStatus(gorm)
 	if err != nil {
 		return nil, err
 	}
@@ -49,20 +61,26 @@ func NewServer(log logger.Logger, cfg *config.Config) (*Server, error) {
 		DB:   cfg.Cache.Database,
 	})
 
-	newKafkaConfig := producer.Config{
-		Brokers:      cfg.Kafka.Broker,
-		Topic:        cfg.Kafka.LoanTopic,
-		WriteTimeout: cfg.Kafka.Timeout,
+	newBillingRepository := repository.NewBillingRepositoryProvider(gorm, log)
+	newBillingCache := repository.NewBillingCacheProvider(redisClient, log)
+	policy, err := delinquency.NewPolicy(cfg.Delinquency)
+	if err != nil {
+		return nil, err
+	}
+
+	eligibilityPolicy, err := eligibility.NewPolicy(cfg.Eligibility)
+	if err != nil {
+		return nil, err
 	}
 
-	kafkaProducer, err := producer.NewProducer(newKafkaConfig, log)
+	simulator, err := disbursement.NewSimulator(cfg.Disbursement.SimulatorFile, cfg.Disbursement.SimulatorFailureRate)
 	if err != nil {
 		return nil, err
 	}
 
-	newBillingRepository := repository.NewBillingRepositoryProvider(gorm, log)
-	newBillingCache := repository.NewBillingCacheProvider(redisClient, log)
-	billingService := service.NewBillingService(newBillingRepository, newBillingCache, kafkaProducer, log)
+	disburser := disbursement.NewDisburser(simulator, cfg.Disbursement)
+	billingService := service.NewBillingService(newBillingRepository, newBillingCache, policy,
+		eligibilityPolicy, disburser, log)
 	billingHandler := api.NewBillingHandler(billingService)
 
 	e := echo.New()
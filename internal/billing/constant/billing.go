@@ -1,8 +1,23 @@
 package constant
 
 const (
-	INTEREST_RATE         = 0.1
-	MAX_PAYMENT           = 50
-	CACHE_KEY_DELIQUENCY  = "deliquency:%s"
-	CACHE_KEY_OUTSTANDING = "outstanding:%s"
+	CACHE_KEY_DELIQUENCY  = "deliquency:v3:%s"
+	CACHE_KEY_OUTSTANDING = "outstanding:v4:%s"
+
+	// PAYOFF_QUOTE_VALIDITY_DAYS is how long a payoff quote can be settled after it is issued
+	PAYOFF_QUOTE_VALIDITY_DAYS = 7
+
+	// WRITE_OFF_MIN_DAYS_PAST_DUE is how long the oldest installment of a loan has to be overdue before the loan
+	// can be written off
+	WRITE_OFF_MIN_DAYS_PAST_DUE = 180
+
+	// COOLING_OFF_DAYS is how long after disbursement a borrower can still cancel a loan
+	COOLING_OFF_DAYS = 14
+
+	// DEFAULT_CREDIT_LIMIT is the credit limit, in minor units of the default currency, of a customer who
+	// was not given one
+	DEFAULT_CREDIT_LIMIT = 5000000000
+
+	// DEFAULT_CUSTOMER_PAGE_SIZE is how many customers a search returns when no page size is given
+	DEFAULT_CUSTOMER_PAGE_SIZE = 20
 )
@@ -0,0 +1,13 @@
+package delinquency
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestDelinquency(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Delinquency Suite")
+}
@@ -0,0 +1,174 @@
+package delinquency
+
+import (
+	"billing-engine/internal/billing/domain"
+	"billing-engine/pkg/config"
+	"fmt"
+	"time"
+)
+
+type RuleType string
+
+const (
+	// RuleConsecutiveMisses matches when at least Threshold missed installments follow each other.
+	RuleConsecutiveMisses RuleType = "CONSECUTIVE_MISSES"
+	// RuleTotalMisses matches when at least Threshold installments are missed in total.
+	RuleTotalMisses RuleType = "TOTAL_MISSES"
+	// RuleDaysPastDue matches when the oldest missed installment is more than Threshold days overdue.
+	RuleDaysPastDue RuleType = "DAYS_PAST_DUE"
+)
+
+type Bucket string
+
+const (
+	BucketCurrent Bucket = "CURRENT"
+	Bucket1To30   Bucket = "1-30"
+	Bucket31To60  Bucket = "31-60"
+	Bucket61To90  Bucket = "61-90"
+	BucketOver90  Bucket = "90+"
+)
+
+type Rule struct {
+	Name      string   `json:"name"`
+	Type      RuleType `json:"type"`
+	Threshold int      `json:"threshold"`
+}
+
+// Policy holds the rules in evaluation order, the first rule that matches decides.
+type Policy struct {
+	Rules []Rule
+}
+
+type Result struct {
+	IsDelinquent  bool
+	DaysPastDue   int
+	Bucket        Bucket
+	MatchedRule   *Rule
+	OldestOverdue *domain.Schedule
+}
+
+// DefaultPolicy keeps the original behaviour: two consecutive missed installments.
+func DefaultPolicy() Policy {
+	return Policy{Rules: []Rule{{Name: "two-consecutive-misses", Type: RuleConsecutiveMisses, Threshold: 2}}}
+}
+
+// NewPolicy builds the policy from configuration, falling back to DefaultPolicy when no rule is configured.
+func NewPolicy(cfg config.Delinquency) (Policy, error) {
+	if len(cfg.Rules) == 0 {
+		return DefaultPolicy(), nil
+	}
+
+	var policy Policy
+	for _, rule := range cfg.Rules {
+		ruleType := RuleType(rule.Type)
+		switch ruleType {
+		case RuleConsecutiveMisses, RuleTotalMisses, RuleDaysPastDue:
+		default:
+			return Policy{}, fmt.Errorf("delinquency rule %q has unknown type %q", rule.Name, rule.Type)
+		}
+
+		policy.Rules = append(policy.Rules, Rule{Name: rule.Name, Type: ruleType, Threshold: rule.Threshold})
+	}
+
+	return policy, nil
+}
+
+// Evaluate applies the policy to the missed schedules of a loan, which must be sorted by payment number.
+func (p Policy) Evaluate(missed []domain.Schedule, now time.Time) Result {
+	result := Result{Bucket: BucketCurrent}
+	if len(missed) == 0 {
+		return result
+	}
+
+	oldest := missed[0]
+	result.OldestOverdue = &oldest
+	result.DaysPastDue = DaysPastDue(oldest.PaymentDueDate, now)
+	result.Bucket = BucketFor(result.DaysPastDue)
+
+	for i := range p.Rules {
+		if p.Rules[i].matches(missed, result.DaysPastDue) {
+			result.IsDelinquent = true
+			result.MatchedRule = &p.Rules[i]
+			break
+		}
+	}
+
+	return result
+}
+
+// Worst picks the result that describes a customer with several loans: a delinquent loan outweighs a
+// current one and, between the two, the loan furthest past due wins.
+func Worst(results []Result) Result {
+	worst := Result{Bucket: BucketCurrent}
+	for _, result := range results {
+		if result.IsDelinquent != worst.IsDelinquent {
+			if result.IsDelinquent {
+				worst = result
+			}
+			continue
+		}
+
+		if result.DaysPastDue > worst.DaysPastDue {
+			worst = result
+		}
+	}
+
+	return worst
+}
+
+func (r Rule) matches(missed []domain.Schedule, dpd int) bool {
+	switch r.Type {
+	case RuleConsecutiveMisses:
+		return longestRun(missed) >= r.Threshold
+	case RuleTotalMisses:
+		return len(missed) >= r.Threshold
+	case RuleDaysPastDue:
+		return dpd > r.Threshold
+	default:
+		return false
+	}
+}
+
+// longestRun returns the longest streak of missed schedules with consecutive payment numbers.
+func longestRun(missed []domain.Schedule) int {
+	longest, current := 0, 0
+	for i := range missed {
+		if i > 0 && missed[i].PaymentNo-missed[i-1].PaymentNo == 1 {
+			current++
+		} else {
+			current = 1
+		}
+
+		if current > longest {
+			longest = current
+		}
+	}
+
+	return longest
+}
+
+func BucketFor(dpd int) Bucket {
+	switch {
+	case dpd <= 0:
+		return BucketCurrent
+	case dpd <= 30:
+		return Bucket1To30
+	case dpd <= 60:
+		return Bucket31To60
+	case dpd <= 90:
+		return Bucket61To90
+	default:
+		return BucketOver90
+	}
+}
+
+// DaysPastDue counts the whole calendar days between the due date and now, zero when not yet due.
+func DaysPastDue(dueDate, now time.Time) int {
+	due := time.Date(dueDate.Year(), dueDate.Month(), dueDate.Day(), 0, 0, 0, 0, time.UTC)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	if !today.After(due) {
+		return 0
+	}
+
+	return int(today.Sub(due).Hours() / 24)
+}
@@ -0,0 +1,104 @@
+package delinquency
+
+import (
+	"billing-engine/internal/billing/domain"
+	"billing-engine/pkg/config"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Policy", func() {
+	now := time.Date(2024, 6, 30, 10, 0, 0, 0, time.UTC)
+
+	missed := func(paymentNo int, daysAgo int) domain.Schedule {
+		return domain.Schedule{PaymentNo: paymentNo, PaymentDueDate: now.AddDate(0, 0, -daysAgo)}
+	}
+
+	Describe("NewPolicy", func() {
+		It("should fall back to the default policy when no rule is configured", func() {
+			policy, err := NewPolicy(config.Delinquency{})
+			Expect(err).To(BeNil())
+			Expect(policy).To(Equal(DefaultPolicy()))
+		})
+
+		It("should reject an unknown rule type", func() {
+			_, err := NewPolicy(config.Delinquency{Rules: []config.DelinquencyRule{
+				{Name: "unknown", Type: "MISSED_CALLS", Threshold: 1},
+			}})
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Describe("Evaluate", func() {
+		It("should be current without missed schedules", func() {
+			result := DefaultPolicy().Evaluate(nil, now)
+			Expect(result.IsDelinquent).To(BeFalse())
+			Expect(result.Bucket).To(Equal(BucketCurrent))
+			Expect(result.OldestOverdue).To(BeNil())
+		})
+
+		It("should match consecutive misses", func() {
+			result := DefaultPolicy().Evaluate([]domain.Schedule{missed(3, 14), missed(4, 7)}, now)
+			Expect(result.IsDelinquent).To(BeTrue())
+			Expect(result.MatchedRule.Type).To(Equal(RuleConsecutiveMisses))
+			Expect(result.DaysPastDue).To(Equal(14))
+			Expect(result.Bucket).To(Equal(Bucket1To30))
+			Expect(result.OldestOverdue.PaymentNo).To(Equal(3))
+		})
+
+		It("should not match scattered misses against consecutive rule", func() {
+			result := DefaultPolicy().Evaluate([]domain.Schedule{missed(1, 21), missed(3, 7)}, now)
+			Expect(result.IsDelinquent).To(BeFalse())
+			Expect(result.MatchedRule).To(BeNil())
+			Expect(result.Bucket).To(Equal(Bucket1To30))
+		})
+
+		It("should use the first matching rule in order", func() {
+			policy := Policy{Rules: []Rule{
+				{Name: "three-missed", Type: RuleTotalMisses, Threshold: 3},
+				{Name: "over-30-dpd", Type: RuleDaysPastDue, Threshold: 30},
+			}}
+
+			result := policy.Evaluate([]domain.Schedule{missed(1, 75), missed(3, 14)}, now)
+			Expect(result.IsDelinquent).To(BeTrue())
+			Expect(result.MatchedRule.Name).To(Equal("over-30-dpd"))
+			Expect(result.Bucket).To(Equal(Bucket61To90))
+
+			result = policy.Evaluate([]domain.Schedule{missed(1, 75), missed(3, 14), missed(5, 1)}, now)
+			Expect(result.MatchedRule.Name).To(Equal("three-missed"))
+		})
+	})
+
+	Describe("BucketFor", func() {
+		It("should place days past due in the right bucket", func() {
+			Expect(BucketFor(0)).To(Equal(BucketCurrent))
+			Expect(BucketFor(30)).To(Equal(Bucket1To30))
+			Expect(BucketFor(31)).To(Equal(Bucket31To60))
+			Expect(BucketFor(90)).To(Equal(Bucket61To90))
+			Expect(BucketFor(91)).To(Equal(BucketOver90))
+		})
+	})
+
+	Describe("Worst", func() {
+		It("should be current without any loan", func() {
+			Expect(Worst(nil).Bucket).To(Equal(BucketCurrent))
+		})
+
+		It("should prefer a delinquent loan over one further past due", func() {
+			current := Result{DaysPastDue: 40, Bucket: Bucket31To60}
+			delinquent := Result{IsDelinquent: true, DaysPastDue: 10, Bucket: Bucket1To30}
+
+			Expect(Worst([]Result{current, delinquent})).To(Equal(delinquent))
+			Expect(Worst([]Result{delinquent, current})).To(Equal(delinquent))
+		})
+
+		It("should pick the loan furthest past due between equals", func() {
+			first := Result{IsDelinquent: true, DaysPastDue: 10, Bucket: Bucket1To30}
+			second := Result{IsDelinquent: true, DaysPastDue: 65, Bucket: Bucket61To90}
+
+			Expect(Worst([]Result{first, second})).To(Equal(second))
+		})
+	})
+})
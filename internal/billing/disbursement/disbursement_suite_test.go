@@ -0,0 +1,13 @@
+package disbursement
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestDisbursement(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Disbursement Suite")
+}
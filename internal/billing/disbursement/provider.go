@@ -0,0 +1,77 @@
+package disbursement
+
+import (
+	"billing-engine/pkg/config"
+	"billing-engine/pkg/money"
+	"context"
+	"fmt"
+	"time"
+)
+
+// BankTransferProvider sends money to the account of a borrower. Reference identifies the disbursement, a
+// provider has to treat a repeated reference as the same transfer so retries never pay out twice.
+type BankTransferProvider interface {
+	Transfer(ctx context.Context, request TransferRequest) (TransferResult, error)
+}
+
+type TransferRequest struct {
+	Reference     string      `json:"reference"`
+	BankCode      string      `json:"bank_code"`
+	AccountNumber string      `json:"account_number"`
+	Amount        money.Money `json:"amount"`
+}
+
+type TransferResult struct {
+	TransferID    string    `json:"transfer_id"`
+	TransferredAt time.Time `json:"transferred_at"`
+}
+
+// Disburser retries a failed transfer with an exponential backoff.
+type Disburser struct {
+	Provider    BankTransferProvider
+	MaxAttempts int
+	Backoff     time.Duration
+}
+
+// NewDisburser applies the retry settings of cfg, at least one attempt is always made.
+func NewDisburser(provider BankTransferProvider, cfg config.Disbursement) Disburser {
+	return Disburser{
+		Provider:    provider,
+		MaxAttempts: cfg.MaxAttempts,
+		Backoff:     time.Duration(cfg.BackoffMs) * time.Millisecond,
+	}
+}
+
+// Disburse returns the result of the first successful attempt, or the error of the last one, together with
+// the number of attempts made.
+func (d Disburser) Disburse(ctx context.Context, request TransferRequest) (TransferResult, int, error) {
+	if d.Provider == nil {
+		return TransferResult{}, 0, fmt.Errorf("no bank transfer provider configured")
+	}
+
+	maxAttempts := d.MaxAttempts
+	if maxAttempts < 1 {
+		maxAttempts = 1
+	}
+
+	var lastErr error
+	for attempt := 1; attempt <= maxAttempts; attempt++ {
+		result, err := d.Provider.Transfer(ctx, request)
+		if err == nil {
+			return result, attempt, nil
+		}
+
+		lastErr = err
+		if attempt == maxAttempts {
+			break
+		}
+
+		select {
+		case <-ctx.Done():
+			return TransferResult{}, attempt, ctx.Err()
+		case <-time.After(d.Backoff * time.Duration(1<<(attempt-1))):
+		}
+	}
+
+	return TransferResult{}, maxAttempts, lastErr
+}
@@ -0,0 +1,75 @@
+package disbursement
+
+import (
+	"billing-engine/pkg/money"
+	"context"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Disburser", func() {
+	ctx := context.Background()
+	request := TransferRequest{
+		Reference:     "disbursement-1",
+		BankCode:      "014",
+		AccountNumber: "1234567890",
+		Amount:        money.New(1150000, "IDR"),
+	}
+
+	It("should retry until the transfer goes through", func() {
+		simulator, err := NewSimulator("", 0)
+		Expect(err).To(BeNil())
+		simulator.FailNext(2)
+
+		result, attempts, err := Disburser{Provider: simulator, MaxAttempts: 3}.Disburse(ctx, request)
+		Expect(err).To(BeNil())
+		Expect(attempts).To(Equal(3))
+		Expect(result.TransferID).NotTo(BeEmpty())
+	})
+
+	It("should give up after the last attempt", func() {
+		simulator, _ := NewSimulator("", 0)
+		simulator.FailNext(3)
+
+		_, attempts, err := Disburser{Provider: simulator, MaxAttempts: 2}.Disburse(ctx, request)
+		Expect(err).To(HaveOccurred())
+		Expect(attempts).To(Equal(2))
+	})
+
+	It("should fail without a provider", func() {
+		_, attempts, err := Disburser{}.Disburse(ctx, request)
+		Expect(err).To(HaveOccurred())
+		Expect(attempts).To(BeZero())
+	})
+})
+
+var _ = Describe("Simulator", func() {
+	ctx := context.Background()
+	request := TransferRequest{Reference: "disbursement-1", Amount: money.New(1150000, "IDR")}
+
+	It("should return the same transfer for a repeated reference", func() {
+		simulator, _ := NewSimulator("", 0)
+
+		first, err := simulator.Transfer(ctx, request)
+		Expect(err).To(BeNil())
+		second, err := simulator.Transfer(ctx, request)
+		Expect(err).To(BeNil())
+		Expect(second).To(Equal(first))
+	})
+
+	It("should keep the transfers in the file across restarts", func() {
+		path := filepath.Join(GinkgoT().TempDir(), "disbursements.jsonl")
+		simulator, err := NewSimulator(path, 0)
+		Expect(err).To(BeNil())
+		first, err := simulator.Transfer(ctx, request)
+		Expect(err).To(BeNil())
+
+		restarted, err := NewSimulator(path, 1)
+		Expect(err).To(BeNil())
+		second, err := restarted.Transfer(ctx, request)
+		Expect(err).To(BeNil())
+		Expect(second.TransferID).To(Equal(first.TransferID))
+	})
+})
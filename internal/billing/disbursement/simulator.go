@@ -0,0 +1,109 @@
+package disbursement
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"math/rand"
+	"os"
+	"sync"
+	"time"
+)
+
+// Simulator stands in for a bank on local runs. Transfers are kept in memory and, when a file is given,
+// appended to it as JSON lines so they survive a restart and can be inspected.
+type Simulator struct {
+	mu          sync.Mutex
+	path        string
+	failureRate float64
+	failNext    int
+	transfers   map[string]TransferResult
+}
+
+type simulatedTransfer struct {
+	TransferRequest
+	TransferResult
+}
+
+// NewSimulator loads the transfers already in path, if any. failureRate is the share of transfers that fail,
+// between 0 and 1, to exercise the retries.
+func NewSimulator(path string, failureRate float64) (*Simulator, error) {
+	simulator := &Simulator{path: path, failureRate: failureRate, transfers: map[string]TransferResult{}}
+	if path == "" {
+		return simulator, nil
+	}
+
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return simulator, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var transfer simulatedTransfer
+		err = json.Unmarshal(scanner.Bytes(), &transfer)
+		if err != nil {
+			return nil, fmt.Errorf("reading simulated transfers: %w", err)
+		}
+
+		simulator.transfers[transfer.Reference] = transfer.TransferResult
+	}
+
+	return simulator, scanner.Err()
+}
+
+// FailNext makes the next n transfers fail.
+func (s *Simulator) FailNext(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failNext = n
+}
+
+func (s *Simulator) Transfer(ctx context.Context, request TransferRequest) (TransferResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if result, ok := s.transfers[request.Reference]; ok {
+		return result, nil
+	}
+
+	if s.failNext > 0 || (s.failureRate > 0 && rand.Float64() < s.failureRate) {
+		if s.failNext > 0 {
+			s.failNext--
+		}
+		return TransferResult{}, fmt.Errorf("simulated bank rejected transfer %s", request.Reference)
+	}
+
+	result := TransferResult{TransferID: uuid.New().String(), TransferredAt: time.Now()}
+	if s.path != "" {
+		err := s.append(simulatedTransfer{TransferRequest: request, TransferResult: result})
+		if err != nil {
+			return TransferResult{}, err
+		}
+	}
+
+	s.transfers[request.Reference] = result
+	return result, nil
+}
+
+func (s *Simulator) append(transfer simulatedTransfer) error {
+	file, err := os.OpenFile(s.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	line, err := json.Marshal(transfer)
+	if err != nil {
+		return err
+	}
+
+	_, err = file.Write(append(line, '\n'))
+	return err
+}
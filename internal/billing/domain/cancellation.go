@@ -0,0 +1,29 @@
+package domain
+
+import (
+	"billing-engine/pkg/money"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"time"
+)
+
+// Cancellation settles a loan the borrower backed out of. The borrower returns what was disbursed plus
+// the interest for the days it was held, less what was already paid; a negative balance is refunded.
+type Cancellation struct {
+	CancellationID uuid.UUID   `json:"cancellation_id" gorm:"type:uuid;primaryKey"`
+	LoanID         uuid.UUID   `json:"loan_id" gorm:"type:uuid;uniqueIndex;not null"`
+	Reason         string      `json:"reason"`
+	DaysHeld       int         `json:"days_held"`
+	Disbursed      money.Money `json:"disbursed" gorm:"embedded;embeddedPrefix:disbursed_"`
+	Paid           money.Money `json:"paid" gorm:"embedded;embeddedPrefix:paid_"`
+	Fee            money.Money `json:"fee" gorm:"embedded;embeddedPrefix:fee_"`
+	AmountDue      money.Money `json:"amount_due" gorm:"embedded;embeddedPrefix:amount_due_"`
+	Refund         money.Money `json:"refund" gorm:"embedded;embeddedPrefix:refund_"`
+	CancelledAt    time.Time   `json:"cancelled_at"`
+	AuditLog
+}
+
+func (cancellation *Cancellation) BeforeCreate(tx *gorm.DB) (err error) {
+	cancellation.CancellationID = uuid.New()
+	return cancellation.AuditLog.BeforeCreate(tx)
+}
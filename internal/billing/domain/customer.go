@@ -1,6 +1,7 @@
 package domain
 
 import (
+	"billing-engine/pkg/money"
 	"github.com/google/uuid"
 	"gorm.io/gorm"
 	"time"
@@ -12,8 +13,14 @@ type Customer struct {
 	LastName    string    `json:"last_name"`
 	Email       string    `json:"email" gorm:"uniqueIndex"`
 	PhoneNumber string    `json:"phone_number"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
+	// CreditLimit caps the principal the customer may owe across all loans, customers without one get the
+	// default limit
+	CreditLimit money.Money `json:"credit_limit" gorm:"embedded;embeddedPrefix:credit_limit_"`
+	// DeactivatedAt is set once the customer is deactivated, they keep repaying their loans but cannot take
+	// new ones
+	DeactivatedAt *time.Time `json:"deactivated_at"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
 
 	Loans []Loan `json:"loans" gorm:"foreignKey:CustomerID"`
 	AuditLog
@@ -0,0 +1,30 @@
+package domain
+
+import (
+	"billing-engine/pkg/enum"
+	"billing-engine/pkg/money"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"time"
+)
+
+// Disbursement is the bank transfer that pays out a loan. Amount is the principal less the admin fee, which is
+// kept at disbursement. The DisbursementID is the reference sent to the bank, so a retry is never paid twice.
+type Disbursement struct {
+	DisbursementID    uuid.UUID               `json:"disbursement_id" gorm:"type:uuid;primaryKey"`
+	LoanID            uuid.UUID               `json:"loan_id" gorm:"type:uuid;uniqueIndex;not null"`
+	Amount            money.Money             `json:"amount" gorm:"embedded;embeddedPrefix:amount_"`
+	BankCode          string                  `json:"bank_code"`
+	AccountNumber     string                  `json:"account_number"`
+	Status            enum.DisbursementStatus `json:"status"`
+	Attempts          int                     `json:"attempts"`
+	LastError         string                  `json:"last_error"`
+	ProviderReference string                  `json:"provider_reference"`
+	DisbursedAt       *time.Time              `json:"disbursed_at"`
+	AuditLog
+}
+
+func (disbursement *Disbursement) BeforeCreate(tx *gorm.DB) (err error) {
+	disbursement.DisbursementID = uuid.New()
+	return disbursement.AuditLog.BeforeCreate(tx)
+}
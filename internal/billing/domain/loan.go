@@ -1,21 +1,50 @@
 package domain
 
 import (
+	"billing-engine/pkg/enum"
+	"billing-engine/pkg/money"
 	"github.com/google/uuid"
 	"gorm.io/gorm"
 	"time"
 )
 
 type Loan struct {
-	LoanID          uuid.UUID `json:"loan_id" gorm:"type:uuid;primaryKey"`
-	CustomerID      uuid.UUID `json:"customer_id" gorm:"type:uuid"`
-	PrincipalAmount float64   `json:"principal_amount"`
-	InterestRate    float64   `json:"interest_rate"`
-	StartDate       time.Time `json:"start_date"`
-	EndDate         time.Time `json:"end_date"`
-	IsFinish        bool      `json:"is_finish"`
-
-	Schedules []Schedule `json:"schedules" gorm:"foreignKey:LoanID;references:LoanID"`
+	LoanID             uuid.UUID               `json:"loan_id" gorm:"type:uuid;primaryKey"`
+	CustomerID         uuid.UUID               `json:"customer_id" gorm:"type:uuid"`
+	ProductID          uuid.UUID               `json:"product_id" gorm:"type:uuid"`
+	PrincipalAmount    money.Money             `json:"principal_amount" gorm:"embedded;embeddedPrefix:principal_"`
+	InterestRate       float64                 `json:"interest_rate"`
+	Tenor              int                     `json:"tenor"`
+	InstallmentCount   int                     `json:"installment_count"`
+	AdminFee           money.Money             `json:"admin_fee" gorm:"embedded;embeddedPrefix:admin_fee_"`
+	Frequency          enum.Frequency          `json:"frequency"`
+	IntervalDays       int                     `json:"interval_days"`
+	AmortizationMethod enum.AmortizationMethod `json:"amortization_method"`
+	StartDate          time.Time               `json:"start_date"`
+	EndDate            time.Time               `json:"end_date"`
+	Status             enum.LoanStatus         `json:"status" gorm:"index"`
+	StatusChangedAt    time.Time               `json:"status_changed_at"`
+
+	LateFee          money.Money `json:"late_fee" gorm:"embedded;embeddedPrefix:late_fee_"`
+	DailyPenaltyRate float64     `json:"daily_penalty_rate"`
+	PenaltyCap       money.Money `json:"penalty_cap" gorm:"embedded;embeddedPrefix:penalty_cap_"`
+
+	EarlySettlementFeeRate float64 `json:"early_settlement_fee_rate"`
+
+	// RefinancedLoanID is the loan a top-up settles, RefinancedAmount what it takes to settle it. The amount is
+	// kept out of the disbursement and settles the loan when the top-up is activated.
+	RefinancedLoanID *uuid.UUID  `json:"refinanced_loan_id,omitempty" gorm:"type:uuid;index"`
+	RefinancedAmount money.Money `json:"refinanced_amount" gorm:"embedded;embeddedPrefix:refinanced_"`
+
+	// CreditBalance is what the borrower paid beyond the outstanding balance, it is owed back to them. It is
+	// listed with the outstanding balance of the customer and refunded when the loan is cancelled.
+	CreditBalance money.Money `json:"credit_balance" gorm:"embedded;embeddedPrefix:credit_balance_"`
+
+	Schedules     []Schedule          `json:"schedules" gorm:"foreignKey:LoanID;references:LoanID"`
+	StatusHistory []LoanStatusHistory `json:"status_history,omitempty" gorm:"foreignKey:LoanID;references:LoanID"`
+	WriteOff      *WriteOff           `json:"write_off,omitempty" gorm:"foreignKey:LoanID;references:LoanID"`
+	Recoveries    []Recovery          `json:"recoveries,omitempty" gorm:"foreignKey:LoanID;references:LoanID"`
+	Cancellation  *Cancellation       `json:"cancellation,omitempty" gorm:"foreignKey:LoanID;references:LoanID"`
 	AuditLog
 }
 
@@ -0,0 +1,25 @@
+package domain
+
+import (
+	"billing-engine/pkg/enum"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"time"
+)
+
+// LoanStatusHistory records every status a loan went through. FromStatus is empty for the status the
+// loan was created with.
+type LoanStatusHistory struct {
+	HistoryID  uuid.UUID       `json:"history_id" gorm:"type:uuid;primaryKey"`
+	LoanID     uuid.UUID       `json:"loan_id" gorm:"type:uuid;index;not null"`
+	FromStatus enum.LoanStatus `json:"from_status"`
+	ToStatus   enum.LoanStatus `json:"to_status"`
+	Reason     string          `json:"reason"`
+	ChangedAt  time.Time       `json:"changed_at"`
+	AuditLog
+}
+
+func (history *LoanStatusHistory) BeforeCreate(tx *gorm.DB) (err error) {
+	history.HistoryID = uuid.New()
+	return history.AuditLog.BeforeCreate(tx)
+}
@@ -0,0 +1,28 @@
+package domain
+
+import (
+	"billing-engine/pkg/money"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"time"
+)
+
+// PaymentHoliday records a window in which the installments of a loan were deferred. DeferredInstallments
+// is the number of periods every installment from the window onwards was moved forward by, WaivedPenalty is
+// the unpaid penalty of the deferred installments that was reversed.
+type PaymentHoliday struct {
+	HolidayID            uuid.UUID   `json:"holiday_id" gorm:"type:uuid;primaryKey"`
+	LoanID               uuid.UUID   `json:"loan_id" gorm:"type:uuid;index;not null"`
+	StartDate            time.Time   `json:"start_date"`
+	EndDate              time.Time   `json:"end_date"`
+	Reason               string      `json:"reason"`
+	DeferredInstallments int         `json:"deferred_installments"`
+	WaivedPenalty        money.Money `json:"waived_penalty" gorm:"embedded;embeddedPrefix:waived_penalty_"`
+	GrantedAt            time.Time   `json:"granted_at"`
+	AuditLog
+}
+
+func (holiday *PaymentHoliday) BeforeCreate(tx *gorm.DB) (err error) {
+	holiday.HolidayID = uuid.New()
+	return holiday.AuditLog.BeforeCreate(tx)
+}
@@ -0,0 +1,52 @@
+package domain
+
+import (
+	"billing-engine/pkg/enum"
+	"billing-engine/pkg/money"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"time"
+)
+
+// PayoffQuote is the amount that settles a loan early. Installments due after ValidUntil have their unpaid
+// interest rebated, and the settlement fee is charged on the principal that is not yet due.
+type PayoffQuote struct {
+	QuoteID          uuid.UUID        `json:"quote_id" gorm:"type:uuid;primaryKey"`
+	LoanID           uuid.UUID        `json:"loan_id" gorm:"type:uuid;index;not null"`
+	CustomerID       uuid.UUID        `json:"customer_id" gorm:"type:uuid"`
+	QuoteDate        time.Time        `json:"quote_date"`
+	ValidUntil       time.Time        `json:"valid_until"`
+	PrincipalAmount  money.Money      `json:"principal_amount" gorm:"embedded;embeddedPrefix:principal_"`
+	InterestAmount   money.Money      `json:"interest_amount" gorm:"embedded;embeddedPrefix:interest_"`
+	PenaltyAmount    money.Money      `json:"penalty_amount" gorm:"embedded;embeddedPrefix:penalty_"`
+	InterestRebate   money.Money      `json:"interest_rebate" gorm:"embedded;embeddedPrefix:interest_rebate_"`
+	SettlementFee    money.Money      `json:"settlement_fee" gorm:"embedded;embeddedPrefix:settlement_fee_"`
+	SettlementAmount money.Money      `json:"settlement_amount" gorm:"embedded;embeddedPrefix:settlement_"`
+	Status           enum.QuoteStatus `json:"status"`
+
+	Lines []PayoffQuoteLine `json:"lines" gorm:"foreignKey:QuoteID;references:QuoteID"`
+	AuditLog
+}
+
+func (quote *PayoffQuote) BeforeCreate(tx *gorm.DB) (err error) {
+	quote.QuoteID = uuid.New()
+	return quote.AuditLog.BeforeCreate(tx)
+}
+
+// PayoffQuoteLine is what the quote collects on one open installment.
+type PayoffQuoteLine struct {
+	LineID          uuid.UUID   `json:"line_id" gorm:"type:uuid;primaryKey"`
+	QuoteID         uuid.UUID   `json:"quote_id" gorm:"type:uuid;index;not null"`
+	ScheduleID      uuid.UUID   `json:"schedule_id" gorm:"type:uuid"`
+	PaymentNo       int         `json:"payment_no"`
+	PrincipalAmount money.Money `json:"principal_amount" gorm:"embedded;embeddedPrefix:principal_"`
+	InterestAmount  money.Money `json:"interest_amount" gorm:"embedded;embeddedPrefix:interest_"`
+	PenaltyAmount   money.Money `json:"penalty_amount" gorm:"embedded;embeddedPrefix:penalty_"`
+	InterestRebate  money.Money `json:"interest_rebate" gorm:"embedded;embeddedPrefix:interest_rebate_"`
+	AuditLog
+}
+
+func (line *PayoffQuoteLine) BeforeCreate(tx *gorm.DB) (err error) {
+	line.LineID = uuid.New()
+	return line.AuditLog.BeforeCreate(tx)
+}
@@ -0,0 +1,28 @@
+package domain
+
+import (
+	"billing-engine/pkg/enum"
+	"billing-engine/pkg/money"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"time"
+)
+
+// Penalty is a ledger entry for a charge on an overdue schedule. Entries are only ever added, the total
+// penalty of a schedule is the sum of its entries and what was paid off is kept on the schedule. A charge
+// that is waived is reversed by an entry of the same type with a negative amount.
+type Penalty struct {
+	PenaltyID   uuid.UUID        `json:"penalty_id" gorm:"type:uuid;primaryKey"`
+	LoanID      uuid.UUID        `json:"loan_id" gorm:"type:uuid;index;not null"`
+	ScheduleID  uuid.UUID        `json:"schedule_id" gorm:"type:uuid;index;not null"`
+	Type        enum.PenaltyType `json:"type"`
+	Amount      money.Money      `json:"amount" gorm:"embedded;embeddedPrefix:penalty_"`
+	DaysPastDue int              `json:"days_past_due"`
+	AccrualDate time.Time        `json:"accrual_date"`
+	AuditLog
+}
+
+func (penalty *Penalty) BeforeCreate(tx *gorm.DB) (err error) {
+	penalty.PenaltyID = uuid.New()
+	return penalty.AuditLog.BeforeCreate(tx)
+}
@@ -0,0 +1,42 @@
+package domain
+
+import (
+	"billing-engine/pkg/enum"
+	"billing-engine/pkg/money"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Product is a loan product from the catalog. InterestRate is an annual rate and Tenor is the loan term
+// in months; how the interest is spread over the installments depends on the AmortizationMethod.
+type Product struct {
+	ProductID          uuid.UUID               `json:"product_id" gorm:"type:uuid;primaryKey"`
+	Name               string                  `json:"name" gorm:"uniqueIndex"`
+	InterestRate       float64                 `json:"interest_rate"`
+	Tenor              int                     `json:"tenor"`
+	InstallmentCount   int                     `json:"installment_count"`
+	Frequency          enum.Frequency          `json:"frequency"`
+	IntervalDays       int                     `json:"interval_days"`
+	AmortizationMethod enum.AmortizationMethod `json:"amortization_method"`
+
+	AdminFee     money.Money `json:"admin_fee" gorm:"embedded;embeddedPrefix:admin_fee_"`
+	MinPrincipal money.Money `json:"min_principal" gorm:"embedded;embeddedPrefix:min_principal_"`
+	MaxPrincipal money.Money `json:"max_principal" gorm:"embedded;embeddedPrefix:max_principal_"`
+
+	// penalty rules: LateFee is charged once when an installment is missed, DailyPenaltyRate is charged per
+	// day past due on the overdue amount, and PenaltyCap limits the total penalty of one installment
+	LateFee          money.Money `json:"late_fee" gorm:"embedded;embeddedPrefix:late_fee_"`
+	DailyPenaltyRate float64     `json:"daily_penalty_rate"`
+	PenaltyCap       money.Money `json:"penalty_cap" gorm:"embedded;embeddedPrefix:penalty_cap_"`
+
+	// EarlySettlementFeeRate is charged on the principal that is not yet due when the loan is paid off early
+	EarlySettlementFeeRate float64 `json:"early_settlement_fee_rate"`
+
+	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
+	AuditLog
+}
+
+func (product *Product) BeforeCreate(tx *gorm.DB) (err error) {
+	product.ProductID = uuid.New()
+	return product.AuditLog.BeforeCreate(tx)
+}
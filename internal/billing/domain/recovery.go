@@ -0,0 +1,24 @@
+package domain
+
+import (
+	"billing-engine/pkg/money"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"time"
+)
+
+// Recovery is money collected on a written-off loan. It is recovery income and never touches the schedules,
+// PaymentID is unique so a replayed payment is only booked once.
+type Recovery struct {
+	RecoveryID  uuid.UUID   `json:"recovery_id" gorm:"type:uuid;primaryKey"`
+	LoanID      uuid.UUID   `json:"loan_id" gorm:"type:uuid;index;not null"`
+	PaymentID   uuid.UUID   `json:"payment_id" gorm:"type:uuid;uniqueIndex;not null"`
+	Amount      money.Money `json:"amount" gorm:"embedded;embeddedPrefix:recovered_"`
+	RecoveredAt time.Time   `json:"recovered_at"`
+	AuditLog
+}
+
+func (recovery *Recovery) BeforeCreate(tx *gorm.DB) (err error) {
+	recovery.RecoveryID = uuid.New()
+	return recovery.AuditLog.BeforeCreate(tx)
+}
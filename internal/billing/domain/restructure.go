@@ -0,0 +1,32 @@
+package domain
+
+import (
+	"billing-engine/pkg/money"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"time"
+)
+
+// Restructure records the terms a loan was rescheduled with. The schedules it replaced stay on the loan
+// as CANCELLED and the new ones point back to it.
+type Restructure struct {
+	RestructureID        uuid.UUID   `json:"restructure_id" gorm:"type:uuid;primaryKey"`
+	LoanID               uuid.UUID   `json:"loan_id" gorm:"type:uuid;index;not null"`
+	Reason               string      `json:"reason"`
+	OutstandingPrincipal money.Money `json:"outstanding_principal" gorm:"embedded;embeddedPrefix:outstanding_principal_"`
+	CapitalisedArrears   money.Money `json:"capitalised_arrears" gorm:"embedded;embeddedPrefix:capitalised_arrears_"`
+	NewPrincipal         money.Money `json:"new_principal" gorm:"embedded;embeddedPrefix:new_principal_"`
+	PreviousInterestRate float64     `json:"previous_interest_rate"`
+	InterestRate         float64     `json:"interest_rate"`
+	InstallmentCount     int         `json:"installment_count"`
+	GracePeriods         int         `json:"grace_periods"`
+	RestructuredAt       time.Time   `json:"restructured_at"`
+
+	Schedules []Schedule `json:"schedules" gorm:"foreignKey:RestructureID;references:RestructureID"`
+	AuditLog
+}
+
+func (restructure *Restructure) BeforeCreate(tx *gorm.DB) (err error) {
+	restructure.RestructureID = uuid.New()
+	return restructure.AuditLog.BeforeCreate(tx)
+}
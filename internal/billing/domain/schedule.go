@@ -2,22 +2,47 @@ package domain
 
 import (
 	"billing-engine/pkg/enum"
+	"billing-engine/pkg/money"
 	"github.com/google/uuid"
 	"gorm.io/gorm"
 	"time"
 )
 
 type Schedule struct {
-	ScheduleID     uuid.UUID          `json:"schedule_id" gorm:"type:uuid;primaryKey"`
-	LoanID         uuid.UUID          `json:"loan_id" gorm:"type:uuid;not null"`
-	PaymentNo      int                `json:"payment_no"`
-	PaymentDueDate time.Time          `json:"payment_due_date"`
-	PaymentAmount  float64            `json:"payment_amount"`
-	PaymentStatus  enum.PaymentStatus `json:"payment_status"`
-	IsMissPayment  bool               `json:"is_miss_payment"`
+	ScheduleID         uuid.UUID          `json:"schedule_id" gorm:"type:uuid;primaryKey"`
+	LoanID             uuid.UUID          `json:"loan_id" gorm:"type:uuid;not null"`
+	PaymentNo          int                `json:"payment_no"`
+	PaymentDueDate     time.Time          `json:"payment_due_date"`
+	PaymentAmount      money.Money        `json:"payment_amount" gorm:"embedded;embeddedPrefix:payment_"`
+	PrincipalAmount    money.Money        `json:"principal_amount" gorm:"embedded;embeddedPrefix:principal_"`
+	InterestAmount     money.Money        `json:"interest_amount" gorm:"embedded;embeddedPrefix:interest_"`
+	RemainingPrincipal money.Money        `json:"remaining_principal" gorm:"embedded;embeddedPrefix:remaining_principal_"`
+	PaymentStatus      enum.PaymentStatus `json:"payment_status"`
+	IsMissPayment      bool               `json:"is_miss_payment"`
+	DaysPastDue        int                `json:"days_past_due"`
+
+	// the parts of the installment paid so far, as allocated by the payment service
+	PrincipalPaid money.Money `json:"principal_paid" gorm:"embedded;embeddedPrefix:principal_paid_"`
+	InterestPaid  money.Money `json:"interest_paid" gorm:"embedded;embeddedPrefix:interest_paid_"`
+	PenaltyPaid   money.Money `json:"penalty_paid" gorm:"embedded;embeddedPrefix:penalty_paid_"`
+
+	// RestructureID is set on the schedules generated by a restructure
+	RestructureID *uuid.UUID `json:"restructure_id,omitempty" gorm:"type:uuid;index"`
+
+	Penalties []Penalty `json:"penalties,omitempty" gorm:"foreignKey:ScheduleID;references:ScheduleID"`
 	AuditLog
 }
 
+// UnpaidAmount is what is left of the installment, penalties excluded.
+func (schedule Schedule) UnpaidAmount() (money.Money, error) {
+	paid, err := schedule.PrincipalPaid.Add(schedule.InterestPaid)
+	if err != nil {
+		return money.Money{}, err
+	}
+
+	return schedule.PaymentAmount.Sub(paid)
+}
+
 func (schedule *Schedule) BeforeCreate(tx *gorm.DB) (err error) {
 	schedule.ScheduleID = uuid.New()
 	return schedule.AuditLog.BeforeCreate(tx)
@@ -0,0 +1,28 @@
+package domain
+
+import (
+	"billing-engine/pkg/money"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"time"
+)
+
+// WriteOff records the balance a loan still owed when it was taken out of the active portfolio.
+type WriteOff struct {
+	WriteOffID   uuid.UUID   `json:"write_off_id" gorm:"type:uuid;primaryKey"`
+	LoanID       uuid.UUID   `json:"loan_id" gorm:"type:uuid;uniqueIndex;not null"`
+	Reason       string      `json:"reason"`
+	ApprovedBy   string      `json:"approved_by"`
+	DaysPastDue  int         `json:"days_past_due"`
+	Principal    money.Money `json:"principal" gorm:"embedded;embeddedPrefix:principal_"`
+	Interest     money.Money `json:"interest" gorm:"embedded;embeddedPrefix:interest_"`
+	Penalty      money.Money `json:"penalty" gorm:"embedded;embeddedPrefix:penalty_"`
+	Total        money.Money `json:"total" gorm:"embedded;embeddedPrefix:total_"`
+	WrittenOffAt time.Time   `json:"written_off_at"`
+	AuditLog
+}
+
+func (writeOff *WriteOff) BeforeCreate(tx *gorm.DB) (err error) {
+	writeOff.WriteOffID = uuid.New()
+	return writeOff.AuditLog.BeforeCreate(tx)
+}
@@ -0,0 +1,13 @@
+package eligibility
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestEligibility(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Eligibility Suite")
+}
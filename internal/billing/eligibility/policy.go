@@ -0,0 +1,176 @@
+package eligibility
+
+import (
+	"billing-engine/internal/billing/domain"
+	"billing-engine/pkg/config"
+	"billing-engine/pkg/money"
+	"fmt"
+	"strings"
+	"time"
+)
+
+type RuleType string
+
+const (
+	// RuleMaxExposure passes when the principal the customer owes or was granted on other loans plus the
+	// requested amount is at most Threshold, in minor units of the requested currency.
+	RuleMaxExposure RuleType = "MAX_EXPOSURE"
+	// RuleNoDelinquency passes when none of the loans of the customer is delinquent.
+	RuleNoDelinquency RuleType = "NO_DELINQUENCY"
+	// RuleProductAmountRange passes when the requested amount is within the principal range of the product.
+	RuleProductAmountRange RuleType = "PRODUCT_AMOUNT_RANGE"
+	// RuleMinRelationshipDays passes when the customer has been with us for at least Threshold days.
+	RuleMinRelationshipDays RuleType = "MIN_RELATIONSHIP_DAYS"
+)
+
+type Rule struct {
+	Name      string   `json:"name"`
+	Type      RuleType `json:"type"`
+	Threshold int64    `json:"threshold"`
+}
+
+// Policy holds the rules an application has to pass, all of them are checked so the applicant sees every
+// reason at once.
+type Policy struct {
+	Rules       []Rule
+	AutoApprove bool
+}
+
+// Applicant is what the rules look at. Exposure and Delinquent are only filled in when a rule needs them.
+type Applicant struct {
+	Customer   domain.Customer
+	Product    domain.Product
+	Amount     money.Money
+	Exposure   money.Money
+	Delinquent bool
+}
+
+type Check struct {
+	Rule   Rule
+	Passed bool
+	Reason string
+}
+
+type Result struct {
+	Eligible bool
+	Checks   []Check
+}
+
+// DefaultPolicy keeps the original behaviour: any amount within the product range is approved right away.
+func DefaultPolicy() Policy {
+	return Policy{
+		Rules:       []Rule{{Name: "within-product-range", Type: RuleProductAmountRange}},
+		AutoApprove: true,
+	}
+}
+
+// NewPolicy builds the policy from configuration, falling back to the rules of DefaultPolicy when no rule is
+// configured. Applications wait for an approver unless AutoApprove is set.
+func NewPolicy(cfg config.Eligibility) (Policy, error) {
+	policy := Policy{Rules: DefaultPolicy().Rules, AutoApprove: cfg.AutoApprove}
+	if len(cfg.Rules) == 0 {
+		return policy, nil
+	}
+
+	policy.Rules = nil
+	for _, rule := range cfg.Rules {
+		ruleType := RuleType(rule.Type)
+		switch ruleType {
+		case RuleMaxExposure, RuleNoDelinquency, RuleProductAmountRange, RuleMinRelationshipDays:
+		default:
+			return Policy{}, fmt.Errorf("eligibility rule %q has unknown type %q", rule.Name, rule.Type)
+		}
+
+		policy.Rules = append(policy.Rules, Rule{Name: rule.Name, Type: ruleType, Threshold: rule.Threshold})
+	}
+
+	return policy, nil
+}
+
+// Needs reports whether one of the rules is of the given type, so costly inputs are only gathered when used.
+func (p Policy) Needs(ruleType RuleType) bool {
+	for _, rule := range p.Rules {
+		if rule.Type == ruleType {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Evaluate checks the applicant against every rule, the applicant is eligible when all of them pass.
+func (p Policy) Evaluate(applicant Applicant, now time.Time) Result {
+	result := Result{Eligible: true}
+	for _, rule := range p.Rules {
+		check := rule.check(applicant, now)
+		if !check.Passed {
+			result.Eligible = false
+		}
+
+		result.Checks = append(result.Checks, check)
+	}
+
+	return result
+}
+
+// Reason joins the reasons of the failed checks, for the loan status history.
+func (r Result) Reason() string {
+	var reasons []string
+	for _, check := range r.Checks {
+		if !check.Passed {
+			reasons = append(reasons, fmt.Sprintf("%s: %s", check.Rule.Name, check.Reason))
+		}
+	}
+
+	return strings.Join(reasons, "; ")
+}
+
+func (r Rule) check(applicant Applicant, now time.Time) Check {
+	check := Check{Rule: r, Passed: true}
+	switch r.Type {
+	case RuleMaxExposure:
+		limit := money.New(r.Threshold, applicant.Amount.Currency)
+		if !applicant.Exposure.IsZero() && applicant.Exposure.Currency != applicant.Amount.Currency {
+			check.Passed = false
+			check.Reason = fmt.Sprintf("existing loans are held in %s", applicant.Exposure.Currency)
+			break
+		}
+
+		exposure, err := applicant.Amount.Add(money.New(applicant.Exposure.Amount, applicant.Amount.Currency))
+		if err != nil {
+			check.Passed = false
+			check.Reason = err.Error()
+			break
+		}
+
+		if exposure.Amount > limit.Amount {
+			check.Passed = false
+			check.Reason = fmt.Sprintf("total exposure of %s exceeds %s", exposure, limit)
+		}
+	case RuleNoDelinquency:
+		if applicant.Delinquent {
+			check.Passed = false
+			check.Reason = "customer has a delinquent loan"
+		}
+	case RuleProductAmountRange:
+		product := applicant.Product
+		if !money.SameCurrency(applicant.Amount, product.MinPrincipal, product.MaxPrincipal) {
+			check.Passed = false
+			check.Reason = fmt.Sprintf("amount must be in %s", product.MinPrincipal.Currency)
+			break
+		}
+
+		if applicant.Amount.Amount < product.MinPrincipal.Amount || applicant.Amount.Amount > product.MaxPrincipal.Amount {
+			check.Passed = false
+			check.Reason = fmt.Sprintf("amount must be between %s and %s", product.MinPrincipal, product.MaxPrincipal)
+		}
+	case RuleMinRelationshipDays:
+		days := int(now.Sub(applicant.Customer.CreatedAt).Hours() / 24)
+		if int64(days) < r.Threshold {
+			check.Passed = false
+			check.Reason = fmt.Sprintf("customer for %d days, at least %d required", days, r.Threshold)
+		}
+	}
+
+	return check
+}
@@ -0,0 +1,95 @@
+package eligibility
+
+import (
+	"billing-engine/internal/billing/domain"
+	"billing-engine/pkg/config"
+	"billing-engine/pkg/money"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Policy", func() {
+	now := time.Date(2024, 6, 30, 10, 0, 0, 0, time.UTC)
+
+	idr := func(amount int64) money.Money {
+		return money.New(amount, "IDR")
+	}
+
+	var applicant Applicant
+
+	BeforeEach(func() {
+		applicant = Applicant{
+			Customer: domain.Customer{CreatedAt: now.AddDate(0, -3, 0)},
+			Product:  domain.Product{MinPrincipal: idr(1000000), MaxPrincipal: idr(10000000)},
+			Amount:   idr(5000000),
+			Exposure: money.Zero("IDR"),
+		}
+	})
+
+	Describe("NewPolicy", func() {
+		It("should fall back to the default rules and wait for an approver", func() {
+			policy, err := NewPolicy(config.Eligibility{})
+			Expect(err).To(BeNil())
+			Expect(policy.Rules).To(Equal(DefaultPolicy().Rules))
+			Expect(policy.AutoApprove).To(BeFalse())
+		})
+
+		It("should reject an unknown rule type", func() {
+			_, err := NewPolicy(config.Eligibility{Rules: []config.EligibilityRule{
+				{Name: "unknown", Type: "CREDIT_SCORE", Threshold: 1},
+			}})
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Describe("Evaluate", func() {
+		policy := Policy{Rules: []Rule{
+			{Name: "within-product-range", Type: RuleProductAmountRange},
+			{Name: "not-delinquent", Type: RuleNoDelinquency},
+			{Name: "max-exposure", Type: RuleMaxExposure, Threshold: 8000000},
+			{Name: "customer-for-30-days", Type: RuleMinRelationshipDays, Threshold: 30},
+		}}
+
+		It("should be eligible when every rule passes", func() {
+			result := policy.Evaluate(applicant, now)
+			Expect(result.Eligible).To(BeTrue())
+			Expect(result.Checks).To(HaveLen(4))
+			Expect(result.Reason()).To(BeEmpty())
+		})
+
+		It("should report every failed rule", func() {
+			applicant.Delinquent = true
+			applicant.Exposure = idr(4000000)
+			applicant.Customer.CreatedAt = now.AddDate(0, 0, -10)
+
+			result := policy.Evaluate(applicant, now)
+			Expect(result.Eligible).To(BeFalse())
+			Expect(result.Checks[0].Passed).To(BeTrue())
+			Expect(result.Checks[1].Passed).To(BeFalse())
+			Expect(result.Checks[2].Passed).To(BeFalse())
+			Expect(result.Checks[3].Passed).To(BeFalse())
+			Expect(result.Reason()).To(ContainSubstring("max-exposure"))
+		})
+
+		It("should reject an amount outside the product range", func() {
+			applicant.Amount = idr(20000000)
+
+			result := DefaultPolicy().Evaluate(applicant, now)
+			Expect(result.Eligible).To(BeFalse())
+		})
+
+		It("should not mix currencies when checking exposure", func() {
+			applicant.Exposure = money.New(100, "USD")
+
+			result := policy.Evaluate(applicant, now)
+			Expect(result.Checks[2].Passed).To(BeFalse())
+		})
+	})
+
+	It("should tell which inputs the rules need", func() {
+		Expect(DefaultPolicy().Needs(RuleMaxExposure)).To(BeFalse())
+		Expect(DefaultPolicy().Needs(RuleProductAmountRange)).To(BeTrue())
+	})
+})
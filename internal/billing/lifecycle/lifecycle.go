@@ -0,0 +1,59 @@
+package lifecycle
+
+import (
+	"billing-engine/pkg/enum"
+	"fmt"
+)
+
+// transitions lists, for every loan status, the statuses a loan may move to next.
+// REJECTED, PAID_OFF, CANCELLED, WRITTEN_OFF and REFINANCED are final.
+var transitions = map[enum.LoanStatus][]enum.LoanStatus{
+	enum.LoanStatusPendingApproval: {enum.LoanStatusApproved, enum.LoanStatusRejected, enum.LoanStatusCancelled},
+	enum.LoanStatusApproved:        {enum.LoanStatusActive, enum.LoanStatusCancelled},
+	enum.LoanStatusActive: {
+		enum.LoanStatusPaidOff, enum.LoanStatusCancelled, enum.LoanStatusWrittenOff, enum.LoanStatusRestructured,
+		enum.LoanStatusRefinanced,
+	},
+	enum.LoanStatusRestructured: {
+		enum.LoanStatusPaidOff, enum.LoanStatusWrittenOff, enum.LoanStatusRestructured, enum.LoanStatusRefinanced,
+	},
+}
+
+// repayingStatuses are the statuses of a loan the borrower is still paying off.
+var repayingStatuses = []enum.LoanStatus{enum.LoanStatusActive, enum.LoanStatusRestructured}
+
+// CanTransition reports whether a loan in status from may move to status to.
+func CanTransition(from, to enum.LoanStatus) bool {
+	for _, next := range transitions[from] {
+		if next == to {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Validate returns an error describing the rejected transition, or nil when it is allowed.
+func Validate(from, to enum.LoanStatus) error {
+	if !CanTransition(from, to) {
+		return fmt.Errorf("loan cannot move from %s to %s", from, to)
+	}
+
+	return nil
+}
+
+// IsRepaying reports whether the loan is part of the active portfolio.
+func IsRepaying(status enum.LoanStatus) bool {
+	for _, val := range repayingStatuses {
+		if val == status {
+			return true
+		}
+	}
+
+	return false
+}
+
+// RepayingStatuses returns the statuses of loans in the active portfolio, to be used in queries.
+func RepayingStatuses() []enum.LoanStatus {
+	return append([]enum.LoanStatus(nil), repayingStatuses...)
+}
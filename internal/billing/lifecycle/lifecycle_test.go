@@ -0,0 +1,43 @@
+package lifecycle
+
+import (
+	"billing-engine/pkg/enum"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Lifecycle", func() {
+	DescribeTable("CanTransition",
+		func(from, to enum.LoanStatus, allowed bool) {
+			Expect(CanTransition(from, to)).To(Equal(allowed))
+		},
+		Entry("approval", enum.LoanStatusPendingApproval, enum.LoanStatusApproved, true),
+		Entry("rejection", enum.LoanStatusPendingApproval, enum.LoanStatusRejected, true),
+		Entry("disbursement", enum.LoanStatusApproved, enum.LoanStatusActive, true),
+		Entry("final payment", enum.LoanStatusActive, enum.LoanStatusPaidOff, true),
+		Entry("restructured loan paid off", enum.LoanStatusRestructured, enum.LoanStatusPaidOff, true),
+		Entry("top-up", enum.LoanStatusActive, enum.LoanStatusRefinanced, true),
+		Entry("skipping approval", enum.LoanStatusPendingApproval, enum.LoanStatusActive, false),
+		Entry("reopening a paid off loan", enum.LoanStatusPaidOff, enum.LoanStatusActive, false),
+		Entry("approving a rejected application", enum.LoanStatusRejected, enum.LoanStatusApproved, false),
+		Entry("paying off twice", enum.LoanStatusPaidOff, enum.LoanStatusPaidOff, false),
+		Entry("cancelling a written off loan", enum.LoanStatusWrittenOff, enum.LoanStatusCancelled, false),
+		Entry("topping up a refinanced loan", enum.LoanStatusRefinanced, enum.LoanStatusRefinanced, false),
+		Entry("unknown status", enum.LoanStatus(""), enum.LoanStatusActive, false),
+	)
+
+	It("should describe a rejected transition", func() {
+		err := Validate(enum.LoanStatusPaidOff, enum.LoanStatusActive)
+		Expect(err).To(MatchError("loan cannot move from PAID_OFF to ACTIVE"))
+		Expect(Validate(enum.LoanStatusActive, enum.LoanStatusPaidOff)).To(BeNil())
+	})
+
+	It("should only count active and restructured loans as repaying", func() {
+		Expect(IsRepaying(enum.LoanStatusActive)).To(BeTrue())
+		Expect(IsRepaying(enum.LoanStatusRestructured)).To(BeTrue())
+		Expect(IsRepaying(enum.LoanStatusPaidOff)).To(BeFalse())
+		Expect(IsRepaying(enum.LoanStatusPendingApproval)).To(BeFalse())
+		Expect(RepayingStatuses()).To(ConsistOf(enum.LoanStatusActive, enum.LoanStatusRestructured))
+	})
+})
@@ -11,6 +11,9 @@ package mocks
 
 import (
 	domain "billing-engine/internal/billing/domain"
+	repository "billing-engine/internal/billing/repository"
+	money "billing-engine/pkg/money"
+	producer "billing-engine/pkg/producer"
 	context "context"
 	reflect "reflect"
 	time "time"
@@ -42,20 +45,80 @@ func (m *MockBillingRepositoryProvider) EXPECT() *MockBillingRepositoryProviderM
 	return m.recorder
 }
 
-// CreateCustomer mocks base method.
-func (m *MockBillingRepositoryProvider) CreateCustomer(arg0 context.Context, arg1 []domain.Customer) error {
+// ActivateLoan mocks base method.
+func (m *MockBillingRepositoryProvider) ActivateLoan(arg0 context.Context, arg1 domain.Loan, arg2 domain.LoanStatusHistory) (*domain.Loan, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "CreateCustomer", arg0, arg1)
+	ret := m.ctrl.Call(m, "ActivateLoan", arg0, arg1, arg2)
+	ret0, _ := ret[0].(*domain.Loan)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ActivateLoan indicates an expected call of ActivateLoan.
+func (mr *MockBillingRepositoryProviderMockRecorder) ActivateLoan(arg0, arg1, arg2 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ActivateLoan", reflect.TypeOf((*MockBillingRepositoryProvider)(nil).ActivateLoan), arg0, arg1, arg2)
+}
+
+// AddLoanCredit mocks base method.
+func (m *MockBillingRepositoryProvider) AddLoanCredit(arg0 context.Context, arg1 uuid.UUID, arg2 money.Money) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddLoanCredit", arg0, arg1, arg2)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
+// AddLoanCredit indicates an expected call of AddLoanCredit.
+func (mr *MockBillingRepositoryProviderMockRecorder) AddLoanCredit(arg0, arg1, arg2 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddLoanCredit", reflect.TypeOf((*MockBillingRepositoryProvider)(nil).AddLoanCredit), arg0, arg1, arg2)
+}
+
+// CancelLoan mocks base method.
+func (m *MockBillingRepositoryProvider) CancelLoan(arg0 context.Context, arg1 domain.Cancellation, arg2 domain.LoanStatusHistory) (*domain.Cancellation, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CancelLoan", arg0, arg1, arg2)
+	ret0, _ := ret[0].(*domain.Cancellation)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CancelLoan indicates an expected call of CancelLoan.
+func (mr *MockBillingRepositoryProviderMockRecorder) CancelLoan(arg0, arg1, arg2 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CancelLoan", reflect.TypeOf((*MockBillingRepositoryProvider)(nil).CancelLoan), arg0, arg1, arg2)
+}
+
+// CreateCustomer mocks base method.
+func (m *MockBillingRepositoryProvider) CreateCustomer(arg0 context.Context, arg1 domain.Customer) (*domain.Customer, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateCustomer", arg0, arg1)
+	ret0, _ := ret[0].(*domain.Customer)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
 // CreateCustomer indicates an expected call of CreateCustomer.
 func (mr *MockBillingRepositoryProviderMockRecorder) CreateCustomer(arg0, arg1 any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateCustomer", reflect.TypeOf((*MockBillingRepositoryProvider)(nil).CreateCustomer), arg0, arg1)
 }
 
+// CreateDisbursement mocks base method.
+func (m *MockBillingRepositoryProvider) CreateDisbursement(arg0 context.Context, arg1 domain.Disbursement) (*domain.Disbursement, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateDisbursement", arg0, arg1)
+	ret0, _ := ret[0].(*domain.Disbursement)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateDisbursement indicates an expected call of CreateDisbursement.
+func (mr *MockBillingRepositoryProviderMockRecorder) CreateDisbursement(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateDisbursement", reflect.TypeOf((*MockBillingRepositoryProvider)(nil).CreateDisbursement), arg0, arg1)
+}
+
 // CreateLoan mocks base method.
 func (m *MockBillingRepositoryProvider) CreateLoan(arg0 context.Context, arg1 domain.Loan) (*domain.Loan, error) {
 	m.ctrl.T.Helper()
@@ -71,19 +134,164 @@ func (mr *MockBillingRepositoryProviderMockRecorder) CreateLoan(arg0, arg1 any)
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateLoan", reflect.TypeOf((*MockBillingRepositoryProvider)(nil).CreateLoan), arg0, arg1)
 }
 
-// GetCustomer mocks base method.
-func (m *MockBillingRepositoryProvider) GetCustomer(arg0 context.Context) ([]domain.Customer, error) {
+// CreateOutboxMessage mocks base method.
+func (m *MockBillingRepositoryProvider) CreateOutboxMessage(arg0 context.Context, arg1 producer.Message) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetCustomer", arg0)
-	ret0, _ := ret[0].([]domain.Customer)
+	ret := m.ctrl.Call(m, "CreateOutboxMessage", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreateOutboxMessage indicates an expected call of CreateOutboxMessage.
+func (mr *MockBillingRepositoryProviderMockRecorder) CreateOutboxMessage(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateOutboxMessage", reflect.TypeOf((*MockBillingRepositoryProvider)(nil).CreateOutboxMessage), arg0, arg1)
+}
+
+// CreatePayoffQuote mocks base method.
+func (m *MockBillingRepositoryProvider) CreatePayoffQuote(arg0 context.Context, arg1 domain.PayoffQuote) (*domain.PayoffQuote, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreatePayoffQuote", arg0, arg1)
+	ret0, _ := ret[0].(*domain.PayoffQuote)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreatePayoffQuote indicates an expected call of CreatePayoffQuote.
+func (mr *MockBillingRepositoryProviderMockRecorder) CreatePayoffQuote(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreatePayoffQuote", reflect.TypeOf((*MockBillingRepositoryProvider)(nil).CreatePayoffQuote), arg0, arg1)
+}
+
+// CreatePenalties mocks base method.
+func (m *MockBillingRepositoryProvider) CreatePenalties(arg0 context.Context, arg1 []domain.Penalty) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreatePenalties", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreatePenalties indicates an expected call of CreatePenalties.
+func (mr *MockBillingRepositoryProviderMockRecorder) CreatePenalties(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreatePenalties", reflect.TypeOf((*MockBillingRepositoryProvider)(nil).CreatePenalties), arg0, arg1)
+}
+
+// CreateProduct mocks base method.
+func (m *MockBillingRepositoryProvider) CreateProduct(arg0 context.Context, arg1 domain.Product) (*domain.Product, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateProduct", arg0, arg1)
+	ret0, _ := ret[0].(*domain.Product)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateProduct indicates an expected call of CreateProduct.
+func (mr *MockBillingRepositoryProviderMockRecorder) CreateProduct(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateProduct", reflect.TypeOf((*MockBillingRepositoryProvider)(nil).CreateProduct), arg0, arg1)
+}
+
+// CreateRecovery mocks base method.
+func (m *MockBillingRepositoryProvider) CreateRecovery(arg0 context.Context, arg1 domain.Recovery) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateRecovery", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreateRecovery indicates an expected call of CreateRecovery.
+func (mr *MockBillingRepositoryProviderMockRecorder) CreateRecovery(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateRecovery", reflect.TypeOf((*MockBillingRepositoryProvider)(nil).CreateRecovery), arg0, arg1)
+}
+
+// CreateWriteOff mocks base method.
+func (m *MockBillingRepositoryProvider) CreateWriteOff(arg0 context.Context, arg1 domain.WriteOff) (*domain.WriteOff, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateWriteOff", arg0, arg1)
+	ret0, _ := ret[0].(*domain.WriteOff)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateWriteOff indicates an expected call of CreateWriteOff.
+func (mr *MockBillingRepositoryProviderMockRecorder) CreateWriteOff(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateWriteOff", reflect.TypeOf((*MockBillingRepositoryProvider)(nil).CreateWriteOff), arg0, arg1)
+}
+
+// DeactivateCustomer mocks base method.
+func (m *MockBillingRepositoryProvider) DeactivateCustomer(arg0 context.Context, arg1 uuid.UUID, arg2 time.Time) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeactivateCustomer", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeactivateCustomer indicates an expected call of DeactivateCustomer.
+func (mr *MockBillingRepositoryProviderMockRecorder) DeactivateCustomer(arg0, arg1, arg2 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeactivateCustomer", reflect.TypeOf((*MockBillingRepositoryProvider)(nil).DeactivateCustomer), arg0, arg1, arg2)
+}
+
+// DeleteProduct mocks base method.
+func (m *MockBillingRepositoryProvider) DeleteProduct(arg0 context.Context, arg1 uuid.UUID) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteProduct", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteProduct indicates an expected call of DeleteProduct.
+func (mr *MockBillingRepositoryProviderMockRecorder) DeleteProduct(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteProduct", reflect.TypeOf((*MockBillingRepositoryProvider)(nil).DeleteProduct), arg0, arg1)
+}
+
+// GetActiveLoans mocks base method.
+func (m *MockBillingRepositoryProvider) GetActiveLoans(arg0 context.Context, arg1 uuid.UUID) ([]domain.Loan, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetActiveLoans", arg0, arg1)
+	ret0, _ := ret[0].([]domain.Loan)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetActiveLoans indicates an expected call of GetActiveLoans.
+func (mr *MockBillingRepositoryProviderMockRecorder) GetActiveLoans(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetActiveLoans", reflect.TypeOf((*MockBillingRepositoryProvider)(nil).GetActiveLoans), arg0, arg1)
+}
+
+// GetClosedLoansWithCredit mocks base method.
+func (m *MockBillingRepositoryProvider) GetClosedLoansWithCredit(arg0 context.Context, arg1 uuid.UUID) ([]domain.Loan, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetClosedLoansWithCredit", arg0, arg1)
+	ret0, _ := ret[0].([]domain.Loan)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// GetCustomer indicates an expected call of GetCustomer.
-func (mr *MockBillingRepositoryProviderMockRecorder) GetCustomer(arg0 any) *gomock.Call {
+// GetClosedLoansWithCredit indicates an expected call of GetClosedLoansWithCredit.
+func (mr *MockBillingRepositoryProviderMockRecorder) GetClosedLoansWithCredit(arg0, arg1 any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetCustomer", reflect.TypeOf((*MockBillingRepositoryProvider)(nil).GetCustomer), arg0)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetClosedLoansWithCredit", reflect.TypeOf((*MockBillingRepositoryProvider)(nil).GetClosedLoansWithCredit), arg0, arg1)
+}
+
+// GetCustomerByEmail mocks base method.
+func (m *MockBillingRepositoryProvider) GetCustomerByEmail(arg0 context.Context, arg1 string) (*domain.Customer, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetCustomerByEmail", arg0, arg1)
+	ret0, _ := ret[0].(*domain.Customer)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetCustomerByEmail indicates an expected call of GetCustomerByEmail.
+func (mr *MockBillingRepositoryProviderMockRecorder) GetCustomerByEmail(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetCustomerByEmail", reflect.TypeOf((*MockBillingRepositoryProvider)(nil).GetCustomerByEmail), arg0, arg1)
 }
 
 // GetCustomerByID mocks base method.
@@ -101,6 +309,36 @@ func (mr *MockBillingRepositoryProviderMockRecorder) GetCustomerByID(arg0, arg1
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetCustomerByID", reflect.TypeOf((*MockBillingRepositoryProvider)(nil).GetCustomerByID), arg0, arg1)
 }
 
+// GetDisbursementByLoanID mocks base method.
+func (m *MockBillingRepositoryProvider) GetDisbursementByLoanID(arg0 context.Context, arg1 uuid.UUID) (*domain.Disbursement, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetDisbursementByLoanID", arg0, arg1)
+	ret0, _ := ret[0].(*domain.Disbursement)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetDisbursementByLoanID indicates an expected call of GetDisbursementByLoanID.
+func (mr *MockBillingRepositoryProviderMockRecorder) GetDisbursementByLoanID(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetDisbursementByLoanID", reflect.TypeOf((*MockBillingRepositoryProvider)(nil).GetDisbursementByLoanID), arg0, arg1)
+}
+
+// GetLoanByID mocks base method.
+func (m *MockBillingRepositoryProvider) GetLoanByID(arg0 context.Context, arg1 uuid.UUID) (*domain.Loan, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetLoanByID", arg0, arg1)
+	ret0, _ := ret[0].(*domain.Loan)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetLoanByID indicates an expected call of GetLoanByID.
+func (mr *MockBillingRepositoryProviderMockRecorder) GetLoanByID(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetLoanByID", reflect.TypeOf((*MockBillingRepositoryProvider)(nil).GetLoanByID), arg0, arg1)
+}
+
 // GetLoanByIDAndCustomerID mocks base method.
 func (m *MockBillingRepositoryProvider) GetLoanByIDAndCustomerID(arg0 context.Context, arg1, arg2 uuid.UUID) (*domain.Loan, error) {
 	m.ctrl.T.Helper()
@@ -116,19 +354,124 @@ func (mr *MockBillingRepositoryProviderMockRecorder) GetLoanByIDAndCustomerID(ar
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetLoanByIDAndCustomerID", reflect.TypeOf((*MockBillingRepositoryProvider)(nil).GetLoanByIDAndCustomerID), arg0, arg1, arg2)
 }
 
-// GetLoanByScheduleID mocks base method.
-func (m *MockBillingRepositoryProvider) GetLoanByScheduleID(arg0 context.Context, arg1 uuid.UUID) (*domain.Loan, error) {
+// GetLoansWithOverdueSchedules mocks base method.
+func (m *MockBillingRepositoryProvider) GetLoansWithOverdueSchedules(arg0 context.Context, arg1 time.Time) ([]domain.Loan, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetLoansWithOverdueSchedules", arg0, arg1)
+	ret0, _ := ret[0].([]domain.Loan)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetLoansWithOverdueSchedules indicates an expected call of GetLoansWithOverdueSchedules.
+func (mr *MockBillingRepositoryProviderMockRecorder) GetLoansWithOverdueSchedules(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetLoansWithOverdueSchedules", reflect.TypeOf((*MockBillingRepositoryProvider)(nil).GetLoansWithOverdueSchedules), arg0, arg1)
+}
+
+// GetLoansWithSchedulesDueBetween mocks base method.
+func (m *MockBillingRepositoryProvider) GetLoansWithSchedulesDueBetween(arg0 context.Context, arg1 repository.LoanFilter, arg2, arg3 time.Time) ([]domain.Loan, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetLoansWithSchedulesDueBetween", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].([]domain.Loan)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetLoansWithSchedulesDueBetween indicates an expected call of GetLoansWithSchedulesDueBetween.
+func (mr *MockBillingRepositoryProviderMockRecorder) GetLoansWithSchedulesDueBetween(arg0, arg1, arg2, arg3 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetLoansWithSchedulesDueBetween", reflect.TypeOf((*MockBillingRepositoryProvider)(nil).GetLoansWithSchedulesDueBetween), arg0, arg1, arg2, arg3)
+}
+
+// GetMissedSchedules mocks base method.
+func (m *MockBillingRepositoryProvider) GetMissedSchedules(arg0 context.Context, arg1 uuid.UUID) ([]domain.Schedule, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetMissedSchedules", arg0, arg1)
+	ret0, _ := ret[0].([]domain.Schedule)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetMissedSchedules indicates an expected call of GetMissedSchedules.
+func (mr *MockBillingRepositoryProviderMockRecorder) GetMissedSchedules(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetMissedSchedules", reflect.TypeOf((*MockBillingRepositoryProvider)(nil).GetMissedSchedules), arg0, arg1)
+}
+
+// GetOpenSchedules mocks base method.
+func (m *MockBillingRepositoryProvider) GetOpenSchedules(arg0 context.Context, arg1 uuid.UUID) ([]domain.Schedule, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetOpenSchedules", arg0, arg1)
+	ret0, _ := ret[0].([]domain.Schedule)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetOpenSchedules indicates an expected call of GetOpenSchedules.
+func (mr *MockBillingRepositoryProviderMockRecorder) GetOpenSchedules(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOpenSchedules", reflect.TypeOf((*MockBillingRepositoryProvider)(nil).GetOpenSchedules), arg0, arg1)
+}
+
+// GetOutstandingPrincipal mocks base method.
+func (m *MockBillingRepositoryProvider) GetOutstandingPrincipal(arg0 context.Context, arg1 uuid.UUID, arg2 string) (int64, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetLoanByScheduleID", arg0, arg1)
+	ret := m.ctrl.Call(m, "GetOutstandingPrincipal", arg0, arg1, arg2)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetOutstandingPrincipal indicates an expected call of GetOutstandingPrincipal.
+func (mr *MockBillingRepositoryProviderMockRecorder) GetOutstandingPrincipal(arg0, arg1, arg2 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOutstandingPrincipal", reflect.TypeOf((*MockBillingRepositoryProvider)(nil).GetOutstandingPrincipal), arg0, arg1, arg2)
+}
+
+// GetPendingTopUp mocks base method.
+func (m *MockBillingRepositoryProvider) GetPendingTopUp(arg0 context.Context, arg1 uuid.UUID) (*domain.Loan, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetPendingTopUp", arg0, arg1)
 	ret0, _ := ret[0].(*domain.Loan)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// GetLoanByScheduleID indicates an expected call of GetLoanByScheduleID.
-func (mr *MockBillingRepositoryProviderMockRecorder) GetLoanByScheduleID(arg0, arg1 any) *gomock.Call {
+// GetPendingTopUp indicates an expected call of GetPendingTopUp.
+func (mr *MockBillingRepositoryProviderMockRecorder) GetPendingTopUp(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPendingTopUp", reflect.TypeOf((*MockBillingRepositoryProvider)(nil).GetPendingTopUp), arg0, arg1)
+}
+
+// GetProductByID mocks base method.
+func (m *MockBillingRepositoryProvider) GetProductByID(arg0 context.Context, arg1 uuid.UUID) (*domain.Product, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetProductByID", arg0, arg1)
+	ret0, _ := ret[0].(*domain.Product)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetProductByID indicates an expected call of GetProductByID.
+func (mr *MockBillingRepositoryProviderMockRecorder) GetProductByID(arg0, arg1 any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetLoanByScheduleID", reflect.TypeOf((*MockBillingRepositoryProvider)(nil).GetLoanByScheduleID), arg0, arg1)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetProductByID", reflect.TypeOf((*MockBillingRepositoryProvider)(nil).GetProductByID), arg0, arg1)
+}
+
+// GetProducts mocks base method.
+func (m *MockBillingRepositoryProvider) GetProducts(arg0 context.Context) ([]domain.Product, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetProducts", arg0)
+	ret0, _ := ret[0].([]domain.Product)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetProducts indicates an expected call of GetProducts.
+func (mr *MockBillingRepositoryProviderMockRecorder) GetProducts(arg0 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetProducts", reflect.TypeOf((*MockBillingRepositoryProvider)(nil).GetProducts), arg0)
 }
 
 // GetSchedule mocks base method.
@@ -146,11 +489,41 @@ func (mr *MockBillingRepositoryProviderMockRecorder) GetSchedule(arg0, arg1, arg
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetSchedule", reflect.TypeOf((*MockBillingRepositoryProvider)(nil).GetSchedule), arg0, arg1, arg2)
 }
 
+// GetScheduleByID mocks base method.
+func (m *MockBillingRepositoryProvider) GetScheduleByID(arg0 context.Context, arg1 uuid.UUID) (*domain.Schedule, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetScheduleByID", arg0, arg1)
+	ret0, _ := ret[0].(*domain.Schedule)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetScheduleByID indicates an expected call of GetScheduleByID.
+func (mr *MockBillingRepositoryProviderMockRecorder) GetScheduleByID(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetScheduleByID", reflect.TypeOf((*MockBillingRepositoryProvider)(nil).GetScheduleByID), arg0, arg1)
+}
+
+// GetTotalPaid mocks base method.
+func (m *MockBillingRepositoryProvider) GetTotalPaid(arg0 context.Context, arg1 uuid.UUID) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetTotalPaid", arg0, arg1)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetTotalPaid indicates an expected call of GetTotalPaid.
+func (mr *MockBillingRepositoryProviderMockRecorder) GetTotalPaid(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTotalPaid", reflect.TypeOf((*MockBillingRepositoryProvider)(nil).GetTotalPaid), arg0, arg1)
+}
+
 // GetTotalUnpaidPaymentOnActiveLoan mocks base method.
-func (m *MockBillingRepositoryProvider) GetTotalUnpaidPaymentOnActiveLoan(arg0 context.Context, arg1 uuid.UUID) (float64, error) {
+func (m *MockBillingRepositoryProvider) GetTotalUnpaidPaymentOnActiveLoan(arg0 context.Context, arg1 uuid.UUID) (int64, error) {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "GetTotalUnpaidPaymentOnActiveLoan", arg0, arg1)
-	ret0, _ := ret[0].(float64)
+	ret0, _ := ret[0].(int64)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
@@ -161,34 +534,237 @@ func (mr *MockBillingRepositoryProviderMockRecorder) GetTotalUnpaidPaymentOnActi
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTotalUnpaidPaymentOnActiveLoan", reflect.TypeOf((*MockBillingRepositoryProvider)(nil).GetTotalUnpaidPaymentOnActiveLoan), arg0, arg1)
 }
 
-// GetUnpaidAndMissPaymentUntil mocks base method.
-func (m *MockBillingRepositoryProvider) GetUnpaidAndMissPaymentUntil(arg0 context.Context, arg1 uuid.UUID, arg2 time.Time) ([]domain.Schedule, error) {
+// GetTotalUnpaidPenaltyOnActiveLoan mocks base method.
+func (m *MockBillingRepositoryProvider) GetTotalUnpaidPenaltyOnActiveLoan(arg0 context.Context, arg1 uuid.UUID) (int64, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetUnpaidAndMissPaymentUntil", arg0, arg1, arg2)
-	ret0, _ := ret[0].([]domain.Schedule)
+	ret := m.ctrl.Call(m, "GetTotalUnpaidPenaltyOnActiveLoan", arg0, arg1)
+	ret0, _ := ret[0].(int64)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// GetUnpaidAndMissPaymentUntil indicates an expected call of GetUnpaidAndMissPaymentUntil.
-func (mr *MockBillingRepositoryProviderMockRecorder) GetUnpaidAndMissPaymentUntil(arg0, arg1, arg2 any) *gomock.Call {
+// GetTotalUnpaidPenaltyOnActiveLoan indicates an expected call of GetTotalUnpaidPenaltyOnActiveLoan.
+func (mr *MockBillingRepositoryProviderMockRecorder) GetTotalUnpaidPenaltyOnActiveLoan(arg0, arg1 any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUnpaidAndMissPaymentUntil", reflect.TypeOf((*MockBillingRepositoryProvider)(nil).GetUnpaidAndMissPaymentUntil), arg0, arg1, arg2)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTotalUnpaidPenaltyOnActiveLoan", reflect.TypeOf((*MockBillingRepositoryProvider)(nil).GetTotalUnpaidPenaltyOnActiveLoan), arg0, arg1)
 }
 
-// LastActiveLoan mocks base method.
-func (m *MockBillingRepositoryProvider) LastActiveLoan(arg0 context.Context, arg1 uuid.UUID) (*domain.Loan, error) {
+// GrantPaymentHoliday mocks base method.
+func (m *MockBillingRepositoryProvider) GrantPaymentHoliday(arg0 context.Context, arg1 domain.PaymentHoliday, arg2 []domain.Schedule, arg3 time.Time) (*domain.PaymentHoliday, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "LastActiveLoan", arg0, arg1)
-	ret0, _ := ret[0].(*domain.Loan)
+	ret := m.ctrl.Call(m, "GrantPaymentHoliday", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(*domain.PaymentHoliday)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GrantPaymentHoliday indicates an expected call of GrantPaymentHoliday.
+func (mr *MockBillingRepositoryProviderMockRecorder) GrantPaymentHoliday(arg0, arg1, arg2, arg3 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GrantPaymentHoliday", reflect.TypeOf((*MockBillingRepositoryProvider)(nil).GrantPaymentHoliday), arg0, arg1, arg2, arg3)
+}
+
+// HasPaymentHolidayBetween mocks base method.
+func (m *MockBillingRepositoryProvider) HasPaymentHolidayBetween(arg0 context.Context, arg1 uuid.UUID, arg2, arg3 time.Time) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "HasPaymentHolidayBetween", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// HasPaymentHolidayBetween indicates an expected call of HasPaymentHolidayBetween.
+func (mr *MockBillingRepositoryProviderMockRecorder) HasPaymentHolidayBetween(arg0, arg1, arg2, arg3 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "HasPaymentHolidayBetween", reflect.TypeOf((*MockBillingRepositoryProvider)(nil).HasPaymentHolidayBetween), arg0, arg1, arg2, arg3)
+}
+
+// LockCustomer mocks base method.
+func (m *MockBillingRepositoryProvider) LockCustomer(arg0 context.Context, arg1 uuid.UUID) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "LockCustomer", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// LockCustomer indicates an expected call of LockCustomer.
+func (mr *MockBillingRepositoryProviderMockRecorder) LockCustomer(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LockCustomer", reflect.TypeOf((*MockBillingRepositoryProvider)(nil).LockCustomer), arg0, arg1)
+}
+
+// LockLoan mocks base method.
+func (m *MockBillingRepositoryProvider) LockLoan(arg0 context.Context, arg1 uuid.UUID) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "LockLoan", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// LockLoan indicates an expected call of LockLoan.
+func (mr *MockBillingRepositoryProviderMockRecorder) LockLoan(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LockLoan", reflect.TypeOf((*MockBillingRepositoryProvider)(nil).LockLoan), arg0, arg1)
+}
+
+// MarkEventProcessed mocks base method.
+func (m *MockBillingRepositoryProvider) MarkEventProcessed(arg0 context.Context, arg1, arg2 string) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "MarkEventProcessed", arg0, arg1, arg2)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// MarkEventProcessed indicates an expected call of MarkEventProcessed.
+func (mr *MockBillingRepositoryProviderMockRecorder) MarkEventProcessed(arg0, arg1, arg2 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MarkEventProcessed", reflect.TypeOf((*MockBillingRepositoryProvider)(nil).MarkEventProcessed), arg0, arg1, arg2)
+}
+
+// MarkScheduleMissed mocks base method.
+func (m *MockBillingRepositoryProvider) MarkScheduleMissed(arg0 context.Context, arg1 uuid.UUID, arg2 int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "MarkScheduleMissed", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// MarkScheduleMissed indicates an expected call of MarkScheduleMissed.
+func (mr *MockBillingRepositoryProviderMockRecorder) MarkScheduleMissed(arg0, arg1, arg2 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MarkScheduleMissed", reflect.TypeOf((*MockBillingRepositoryProvider)(nil).MarkScheduleMissed), arg0, arg1, arg2)
+}
+
+// RefinanceLoan mocks base method.
+func (m *MockBillingRepositoryProvider) RefinanceLoan(arg0 context.Context, arg1 domain.LoanStatusHistory) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RefinanceLoan", arg0, arg1)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RefinanceLoan indicates an expected call of RefinanceLoan.
+func (mr *MockBillingRepositoryProviderMockRecorder) RefinanceLoan(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RefinanceLoan", reflect.TypeOf((*MockBillingRepositoryProvider)(nil).RefinanceLoan), arg0, arg1)
+}
+
+// RestructureLoan mocks base method.
+func (m *MockBillingRepositoryProvider) RestructureLoan(arg0 context.Context, arg1 domain.Loan, arg2 domain.Restructure, arg3 []uuid.UUID) (*domain.Restructure, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RestructureLoan", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(*domain.Restructure)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// LastActiveLoan indicates an expected call of LastActiveLoan.
-func (mr *MockBillingRepositoryProviderMockRecorder) LastActiveLoan(arg0, arg1 any) *gomock.Call {
+// RestructureLoan indicates an expected call of RestructureLoan.
+func (mr *MockBillingRepositoryProviderMockRecorder) RestructureLoan(arg0, arg1, arg2, arg3 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RestructureLoan", reflect.TypeOf((*MockBillingRepositoryProvider)(nil).RestructureLoan), arg0, arg1, arg2, arg3)
+}
+
+// SearchCustomers mocks base method.
+func (m *MockBillingRepositoryProvider) SearchCustomers(arg0 context.Context, arg1 repository.CustomerFilter) ([]domain.Customer, int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SearchCustomers", arg0, arg1)
+	ret0, _ := ret[0].([]domain.Customer)
+	ret1, _ := ret[1].(int64)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// SearchCustomers indicates an expected call of SearchCustomers.
+func (mr *MockBillingRepositoryProviderMockRecorder) SearchCustomers(arg0, arg1 any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LastActiveLoan", reflect.TypeOf((*MockBillingRepositoryProvider)(nil).LastActiveLoan), arg0, arg1)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SearchCustomers", reflect.TypeOf((*MockBillingRepositoryProvider)(nil).SearchCustomers), arg0, arg1)
+}
+
+// SettlePayoffQuote mocks base method.
+func (m *MockBillingRepositoryProvider) SettlePayoffQuote(arg0 context.Context, arg1 uuid.UUID) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SettlePayoffQuote", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SettlePayoffQuote indicates an expected call of SettlePayoffQuote.
+func (mr *MockBillingRepositoryProviderMockRecorder) SettlePayoffQuote(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SettlePayoffQuote", reflect.TypeOf((*MockBillingRepositoryProvider)(nil).SettlePayoffQuote), arg0, arg1)
+}
+
+// UpdateCreditLimit mocks base method.
+func (m *MockBillingRepositoryProvider) UpdateCreditLimit(arg0 context.Context, arg1 uuid.UUID, arg2 money.Money) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateCreditLimit", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateCreditLimit indicates an expected call of UpdateCreditLimit.
+func (mr *MockBillingRepositoryProviderMockRecorder) UpdateCreditLimit(arg0, arg1, arg2 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateCreditLimit", reflect.TypeOf((*MockBillingRepositoryProvider)(nil).UpdateCreditLimit), arg0, arg1, arg2)
+}
+
+// UpdateCustomer mocks base method.
+func (m *MockBillingRepositoryProvider) UpdateCustomer(arg0 context.Context, arg1 domain.Customer) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateCustomer", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateCustomer indicates an expected call of UpdateCustomer.
+func (mr *MockBillingRepositoryProviderMockRecorder) UpdateCustomer(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateCustomer", reflect.TypeOf((*MockBillingRepositoryProvider)(nil).UpdateCustomer), arg0, arg1)
+}
+
+// UpdateDisbursement mocks base method.
+func (m *MockBillingRepositoryProvider) UpdateDisbursement(arg0 context.Context, arg1 domain.Disbursement) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateDisbursement", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateDisbursement indicates an expected call of UpdateDisbursement.
+func (mr *MockBillingRepositoryProviderMockRecorder) UpdateDisbursement(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateDisbursement", reflect.TypeOf((*MockBillingRepositoryProvider)(nil).UpdateDisbursement), arg0, arg1)
+}
+
+// UpdateLoanStatus mocks base method.
+func (m *MockBillingRepositoryProvider) UpdateLoanStatus(arg0 context.Context, arg1 domain.LoanStatusHistory) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateLoanStatus", arg0, arg1)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateLoanStatus indicates an expected call of UpdateLoanStatus.
+func (mr *MockBillingRepositoryProviderMockRecorder) UpdateLoanStatus(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateLoanStatus", reflect.TypeOf((*MockBillingRepositoryProvider)(nil).UpdateLoanStatus), arg0, arg1)
+}
+
+// UpdateProduct mocks base method.
+func (m *MockBillingRepositoryProvider) UpdateProduct(arg0 context.Context, arg1 *domain.Product) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateProduct", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateProduct indicates an expected call of UpdateProduct.
+func (mr *MockBillingRepositoryProviderMockRecorder) UpdateProduct(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateProduct", reflect.TypeOf((*MockBillingRepositoryProvider)(nil).UpdateProduct), arg0, arg1)
 }
 
 // UpdateSchedulePayment mocks base method.
@@ -204,3 +780,17 @@ func (mr *MockBillingRepositoryProviderMockRecorder) UpdateSchedulePayment(arg0,
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateSchedulePayment", reflect.TypeOf((*MockBillingRepositoryProvider)(nil).UpdateSchedulePayment), arg0, arg1)
 }
+
+// WithTransaction mocks base method.
+func (m *MockBillingRepositoryProvider) WithTransaction(arg0 context.Context, arg1 func(repository.BillingRepositoryProvider) error) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "WithTransaction", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// WithTransaction indicates an expected call of WithTransaction.
+func (mr *MockBillingRepositoryProviderMockRecorder) WithTransaction(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "WithTransaction", reflect.TypeOf((*MockBillingRepositoryProvider)(nil).WithTransaction), arg0, arg1)
+}
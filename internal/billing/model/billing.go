@@ -2,29 +2,80 @@ package model
 
 import (
 	"billing-engine/pkg/enum"
+	"billing-engine/pkg/money"
 	"github.com/google/uuid"
+	"time"
 )
 
 type CreateLoanPayload struct {
-	CustomerID uuid.UUID `json:"customer_id"`
-	LoanAmount float64   `json:"loan_amount"`
+	CustomerID uuid.UUID   `json:"customer_id"`
+	ProductID  uuid.UUID   `json:"product_id" validate:"required"`
+	LoanAmount money.Money `json:"loan_amount"`
 }
 
 type ScheduleResponse struct {
-	ScheduleID     uuid.UUID          `json:"schedule_id"`
-	LoanID         uuid.UUID          `json:"loan_id"`
-	PaymentNo      int                `json:"payment_no"`
-	PaymentDueDate string             `json:"payment_due_date"`
-	PaymentAmount  float64            `json:"payment_amount"`
-	PaymentStatus  enum.PaymentStatus `json:"payment_status"`
-	IsMissPayment  bool               `json:"is_miss_payment"`
+	ScheduleID         uuid.UUID          `json:"schedule_id"`
+	LoanID             uuid.UUID          `json:"loan_id"`
+	PaymentNo          int                `json:"payment_no"`
+	PaymentDueDate     string             `json:"payment_due_date"`
+	PaymentAmount      money.Money        `json:"payment_amount"`
+	PrincipalAmount    money.Money        `json:"principal_amount"`
+	InterestAmount     money.Money        `json:"interest_amount"`
+	RemainingPrincipal money.Money        `json:"remaining_principal"`
+	PaymentStatus      enum.PaymentStatus `json:"payment_status"`
+	IsMissPayment      bool               `json:"is_miss_payment"`
+	PrincipalPaid      money.Money        `json:"principal_paid"`
+	InterestPaid       money.Money        `json:"interest_paid"`
+	PenaltyPaid        money.Money        `json:"penalty_paid"`
 }
 
+// CreateLoanResponse LoanAmount and Schedules are only filled in once the loan is active, Eligibility lists the
+// rules the application was checked against.
 type CreateLoanResponse struct {
-	LoanID     uuid.UUID          `json:"loan_id"`
-	CustomerID uuid.UUID          `json:"customer_id"`
-	LoanAmount float64            `json:"loan_amount"`
-	Schedules  []ScheduleResponse `json:"schedules"`
+	LoanID      uuid.UUID                  `json:"loan_id"`
+	CustomerID  uuid.UUID                  `json:"customer_id"`
+	LoanAmount  money.Money                `json:"loan_amount"`
+	Status      enum.LoanStatus            `json:"status"`
+	Schedules   []ScheduleResponse         `json:"schedules"`
+	Eligibility []EligibilityCheckResponse `json:"eligibility,omitempty"`
+}
+
+type EligibilityCheckResponse struct {
+	Rule   string `json:"rule"`
+	Type   string `json:"type"`
+	Passed bool   `json:"passed"`
+	Reason string `json:"reason,omitempty"`
+}
+
+type ApproveLoanPayload struct {
+	LoanID     uuid.UUID `param:"loan_id"`
+	ApprovedBy string    `json:"approved_by" validate:"required"`
+}
+
+type DisburseLoanPayload struct {
+	LoanID        uuid.UUID `param:"loan_id"`
+	BankCode      string    `json:"bank_code" validate:"required"`
+	AccountNumber string    `json:"account_number" validate:"required"`
+}
+
+// DisburseLoanResponse LoanAmount and Schedules are only filled in once the transfer went through.
+type DisburseLoanResponse struct {
+	DisbursementID    uuid.UUID               `json:"disbursement_id"`
+	LoanID            uuid.UUID               `json:"loan_id"`
+	Status            enum.DisbursementStatus `json:"status"`
+	LoanStatus        enum.LoanStatus         `json:"loan_status"`
+	Amount            money.Money             `json:"amount"`
+	Attempts          int                     `json:"attempts"`
+	ProviderReference string                  `json:"provider_reference,omitempty"`
+	DisbursedAt       *time.Time              `json:"disbursed_at,omitempty"`
+	LoanAmount        money.Money             `json:"loan_amount"`
+	Schedules         []ScheduleResponse      `json:"schedules"`
+}
+
+type RejectLoanPayload struct {
+	LoanID     uuid.UUID `param:"loan_id"`
+	RejectedBy string    `json:"rejected_by" validate:"required"`
+	Reason     string    `json:"reason" validate:"required"`
 }
 
 type GetScheduleResponse struct {
@@ -36,22 +87,246 @@ type GetSchedulePayload struct {
 	CustomerID uuid.UUID `query:"customer_id"`
 }
 
+// IsDelinquentResponse describes the customer by their worst loan, every active loan is given in Loans.
 type IsDelinquentResponse struct {
-	IsDelinquent bool `json:"is_delinquent"`
+	IsDelinquent          bool                      `json:"is_delinquent"`
+	DaysPastDue           int                       `json:"days_past_due"`
+	DPDBucket             string                    `json:"dpd_bucket"`
+	MatchedRule           *DelinquencyRuleResponse  `json:"matched_rule,omitempty"`
+	OldestOverdueSchedule *ScheduleResponse         `json:"oldest_overdue_schedule,omitempty"`
+	Loans                 []LoanDelinquencyResponse `json:"loans"`
+}
+
+type LoanDelinquencyResponse struct {
+	LoanID                uuid.UUID                `json:"loan_id"`
+	IsDelinquent          bool                     `json:"is_delinquent"`
+	DaysPastDue           int                      `json:"days_past_due"`
+	DPDBucket             string                   `json:"dpd_bucket"`
+	MatchedRule           *DelinquencyRuleResponse `json:"matched_rule,omitempty"`
+	OldestOverdueSchedule *ScheduleResponse        `json:"oldest_overdue_schedule,omitempty"`
+}
+
+type DelinquencyRuleResponse struct {
+	Name      string `json:"name"`
+	Type      string `json:"type"`
+	Threshold int    `json:"threshold"`
+}
+
+// CustomerPayload Email is unique across customers regardless of case, PhoneNumber is in E.164 format.
+type CustomerPayload struct {
+	FirstName   string `json:"first_name" validate:"required,max=100"`
+	LastName    string `json:"last_name" validate:"max=100"`
+	Email       string `json:"email" validate:"required,email,max=255"`
+	PhoneNumber string `json:"phone_number" validate:"omitempty,e164"`
+}
+
+type UpdateCustomerPayload struct {
+	CustomerID uuid.UUID `param:"customer_id"`
+	CustomerPayload
+}
+
+// SearchCustomersPayload the name, email and phone number match anywhere in the field, pages start at 1.
+type SearchCustomersPayload struct {
+	Name            string `query:"name"`
+	Email           string `query:"email"`
+	PhoneNumber     string `query:"phone_number"`
+	IncludeInactive bool   `query:"include_inactive"`
+	Page            int    `query:"page" validate:"omitempty,min=1"`
+	PageSize        int    `query:"page_size" validate:"omitempty,min=1,max=100"`
+}
+
+type SearchCustomersResponse struct {
+	Customers []GetCustomerResponse `json:"customers"`
+	Page      int                   `json:"page"`
+	PageSize  int                   `json:"page_size"`
+	Total     int64                 `json:"total"`
 }
 
 type GetCustomerResponse struct {
-	CustomerID  uuid.UUID `json:"customer_id"`
-	FirstName   string    `json:"first_name"`
-	LastName    string    `json:"last_name"`
-	Email       string    `json:"email"`
-	PhoneNumber string    `json:"phone_number"`
+	CustomerID    uuid.UUID   `json:"customer_id"`
+	FirstName     string      `json:"first_name"`
+	LastName      string      `json:"last_name"`
+	Email         string      `json:"email"`
+	PhoneNumber   string      `json:"phone_number"`
+	CreditLimit   money.Money `json:"credit_limit"`
+	Active        bool        `json:"active"`
+	DeactivatedAt *time.Time  `json:"deactivated_at,omitempty"`
+	CreatedAt     time.Time   `json:"created_at"`
 }
 
-type CreateCustomerPayload struct {
-	TotalCustomer int `json:"total_customer"`
+type UpdateCreditLimitPayload struct {
+	CustomerID  uuid.UUID   `param:"customer_id"`
+	CreditLimit money.Money `json:"credit_limit"`
 }
 
+// CreditLimitResponse OutstandingPrincipal counts the loans being repaid and the applications that may still
+// be paid out, Headroom is what is left of the limit for a new loan.
+type CreditLimitResponse struct {
+	CustomerID           uuid.UUID   `json:"customer_id"`
+	CreditLimit          money.Money `json:"credit_limit"`
+	OutstandingPrincipal money.Money `json:"outstanding_principal"`
+	Headroom             money.Money `json:"headroom"`
+}
+
+// GetOutstandingBalanceResponse OutstandingBalance includes the unpaid penalties, which are also given on their own.
+// The balances add up every active loan of the customer, each of them is given in Loans.
+// GetOutstandingBalanceResponse CreditBalance is what the customer paid beyond the balance of their loans and
+// is owed back to them, it is not taken off OutstandingBalance. Loans lists the loans being repaid and the
+// paid off loans that still hold a credit.
 type GetOutstandingBalanceResponse struct {
-	OutstandingBalance float64 `json:"outstanding_balance"`
+	OutstandingBalance money.Money               `json:"outstanding_balance"`
+	PenaltyBalance     money.Money               `json:"penalty_balance"`
+	CreditBalance      money.Money               `json:"credit_balance"`
+	Loans              []LoanOutstandingResponse `json:"loans"`
+}
+
+type LoanOutstandingResponse struct {
+	LoanID             uuid.UUID       `json:"loan_id"`
+	Status             enum.LoanStatus `json:"status"`
+	OutstandingBalance money.Money     `json:"outstanding_balance"`
+	PenaltyBalance     money.Money     `json:"penalty_balance"`
+	CreditBalance      money.Money     `json:"credit_balance"`
+}
+
+// ProductPayload amounts are in minor units and must all share one currency.
+type ProductPayload struct {
+	Name               string                  `json:"name" validate:"required"`
+	InterestRate       float64                 `json:"interest_rate" validate:"gte=0"`
+	Tenor              int                     `json:"tenor" validate:"gt=0"`
+	InstallmentCount   int                     `json:"installment_count" validate:"gt=0"`
+	Frequency          enum.Frequency          `json:"frequency" validate:"oneof=WEEKLY BIWEEKLY MONTHLY CUSTOM"`
+	IntervalDays       int                     `json:"interval_days" validate:"required_if=Frequency CUSTOM,gte=0"`
+	AmortizationMethod enum.AmortizationMethod `json:"amortization_method" validate:"oneof=FLAT ANNUITY DECLINING_BALANCE"`
+	AdminFee           money.Money             `json:"admin_fee"`
+	MinPrincipal       money.Money             `json:"min_principal"`
+	MaxPrincipal       money.Money             `json:"max_principal"`
+	LateFee            money.Money             `json:"late_fee"`
+	DailyPenaltyRate   float64                 `json:"daily_penalty_rate" validate:"gte=0,lte=1"`
+	PenaltyCap         money.Money             `json:"penalty_cap"`
+
+	EarlySettlementFeeRate float64 `json:"early_settlement_fee_rate" validate:"gte=0,lte=1"`
+}
+
+type UpdateProductPayload struct {
+	ProductID uuid.UUID `param:"product_id"`
+	ProductPayload
+}
+
+// RestructureLoanPayload InstallmentCount is the number of installments of the new schedule, InterestRate keeps
+// the current rate when omitted and GracePeriods delays the first new installment by that many periods.
+// Without CapitaliseArrears the overdue installments stay payable as they are and only the rest is rescheduled.
+type RestructureLoanPayload struct {
+	LoanID            uuid.UUID `param:"loan_id"`
+	InstallmentCount  int       `json:"installment_count" validate:"required,gt=0"`
+	InterestRate      *float64  `json:"interest_rate" validate:"omitempty,gte=0,lte=1"`
+	CapitaliseArrears bool      `json:"capitalise_arrears"`
+	GracePeriods      int       `json:"grace_periods" validate:"gte=0"`
+	Reason            string    `json:"reason" validate:"required"`
+}
+
+type RestructureLoanResponse struct {
+	LoanID               uuid.UUID          `json:"loan_id"`
+	RestructureID        uuid.UUID          `json:"restructure_id"`
+	Status               enum.LoanStatus    `json:"status"`
+	OutstandingPrincipal money.Money        `json:"outstanding_principal"`
+	CapitalisedArrears   money.Money        `json:"capitalised_arrears"`
+	NewPrincipal         money.Money        `json:"new_principal"`
+	InterestRate         float64            `json:"interest_rate"`
+	TotalAmount          money.Money        `json:"total_amount"`
+	Schedules            []ScheduleResponse `json:"schedules"`
+}
+
+// PaymentHolidayPayload dates are inclusive and formatted as 2006-01-02.
+type PaymentHolidayPayload struct {
+	LoanID    uuid.UUID `param:"loan_id"`
+	StartDate string    `json:"start_date" validate:"required,datetime=2006-01-02"`
+	EndDate   string    `json:"end_date" validate:"required,datetime=2006-01-02"`
+	Reason    string    `json:"reason" validate:"required"`
+}
+
+// BulkPaymentHolidayPayload grants the holiday to every active loan with an installment in the window that
+// matches the optional product and frequency filters.
+type BulkPaymentHolidayPayload struct {
+	StartDate string         `json:"start_date" validate:"required,datetime=2006-01-02"`
+	EndDate   string         `json:"end_date" validate:"required,datetime=2006-01-02"`
+	Reason    string         `json:"reason" validate:"required"`
+	ProductID *uuid.UUID     `json:"product_id"`
+	Frequency enum.Frequency `json:"frequency"`
+}
+
+type PaymentHolidayResponse struct {
+	HolidayID            uuid.UUID          `json:"holiday_id"`
+	LoanID               uuid.UUID          `json:"loan_id"`
+	StartDate            string             `json:"start_date"`
+	EndDate              string             `json:"end_date"`
+	DeferredInstallments int                `json:"deferred_installments"`
+	WaivedPenalty        money.Money        `json:"waived_penalty"`
+	Schedules            []ScheduleResponse `json:"schedules"`
+}
+
+type BulkPaymentHolidayResponse struct {
+	Granted []PaymentHolidayResponse `json:"granted"`
+	Failed  []FailedLoanResponse     `json:"failed"`
+}
+
+type FailedLoanResponse struct {
+	LoanID uuid.UUID `json:"loan_id"`
+	Error  string    `json:"error"`
+}
+
+// TopUpLoanPayload Amount is the principal of the new loan, it has to cover the settlement of the old one.
+type TopUpLoanPayload struct {
+	LoanID uuid.UUID   `param:"loan_id"`
+	Amount money.Money `json:"amount"`
+}
+
+// TopUpLoanResponse NetDisbursement is what the borrower receives once the new loan is disbursed.
+type TopUpLoanResponse struct {
+	LoanID           uuid.UUID       `json:"loan_id"`
+	RefinancedLoanID uuid.UUID       `json:"refinanced_loan_id"`
+	CustomerID       uuid.UUID       `json:"customer_id"`
+	Status           enum.LoanStatus `json:"status"`
+	PrincipalAmount  money.Money     `json:"principal_amount"`
+	AdminFee         money.Money     `json:"admin_fee"`
+	SettledAmount    money.Money     `json:"settled_amount"`
+	NetDisbursement  money.Money     `json:"net_disbursement"`
+}
+
+type CancelLoanPayload struct {
+	LoanID uuid.UUID `param:"loan_id"`
+	Reason string    `json:"reason" validate:"required"`
+}
+
+// CancelLoanResponse AmountDue is what the borrower still has to return, Refund what is paid back to them.
+type CancelLoanResponse struct {
+	CancellationID uuid.UUID       `json:"cancellation_id"`
+	LoanID         uuid.UUID       `json:"loan_id"`
+	Status         enum.LoanStatus `json:"status"`
+	DaysHeld       int             `json:"days_held"`
+	Disbursed      money.Money     `json:"disbursed"`
+	Paid           money.Money     `json:"paid"`
+	Fee            money.Money     `json:"fee"`
+	AmountDue      money.Money     `json:"amount_due"`
+	Refund         money.Money     `json:"refund"`
+	CancelledAt    time.Time       `json:"cancelled_at"`
+}
+
+// WriteOffLoanPayload ApprovedBy is whoever signed off the write-off, it is stored as given.
+type WriteOffLoanPayload struct {
+	LoanID     uuid.UUID `param:"loan_id"`
+	Reason     string    `json:"reason" validate:"required"`
+	ApprovedBy string    `json:"approved_by" validate:"required"`
+}
+
+type WriteOffLoanResponse struct {
+	WriteOffID   uuid.UUID       `json:"write_off_id"`
+	LoanID       uuid.UUID       `json:"loan_id"`
+	Status       enum.LoanStatus `json:"status"`
+	DaysPastDue  int             `json:"days_past_due"`
+	Principal    money.Money     `json:"principal"`
+	Interest     money.Money     `json:"interest"`
+	Penalty      money.Money     `json:"penalty"`
+	Total        money.Money     `json:"total"`
+	ApprovedBy   string          `json:"approved_by"`
+	WrittenOffAt time.Time       `json:"written_off_at"`
 }
@@ -1,9 +1,127 @@
 package model
 
-import "github.com/google/uuid"
+import (
+	"billing-engine/internal/billing/domain"
+	"billing-engine/pkg/enum"
+	"billing-engine/pkg/money"
+	"github.com/google/uuid"
+	"time"
+)
 
+// PaymentEventPayload Credit is what the payment brought in beyond the outstanding balance of the loan.
 type PaymentEventPayload struct {
-	CustomerID uuid.UUID `json:"customer_id"`
-	ScheduleID uuid.UUID `json:"schedule_id"`
-	LoanID     uuid.UUID `json:"loan_id"`
+	LoanID      uuid.UUID                  `json:"loan_id"`
+	PaymentID   uuid.UUID                  `json:"payment_id"`
+	AmountPaid  money.Money                `json:"amount_paid"`
+	Credit      money.Money                `json:"credit"`
+	PaymentType enum.PaymentType           `json:"payment_type"`
+	PaymentDate time.Time                  `json:"payment_date"`
+	Allocations []PaymentAllocationPayload `json:"allocations"`
+	Schedules   []ScheduleBalancePayload   `json:"schedules"`
+}
+
+type PaymentAllocationPayload struct {
+	ScheduleID uuid.UUID                `json:"schedule_id"`
+	PaymentNo  int                      `json:"payment_no"`
+	Component  enum.AllocationComponent `json:"component"`
+	Amount     money.Money              `json:"amount"`
+}
+
+// ScheduleBalancePayload is the state of an installment after the payment, applying it twice is harmless.
+type ScheduleBalancePayload struct {
+	ScheduleID    uuid.UUID          `json:"schedule_id"`
+	PaymentStatus enum.PaymentStatus `json:"payment_status"`
+	PrincipalPaid money.Money        `json:"principal_paid"`
+	InterestPaid  money.Money        `json:"interest_paid"`
+	PenaltyPaid   money.Money        `json:"penalty_paid"`
+}
+
+type ScheduleMissedEventPayload struct {
+	LoanID         uuid.UUID   `json:"loan_id"`
+	CustomerID     uuid.UUID   `json:"customer_id"`
+	ScheduleID     uuid.UUID   `json:"schedule_id"`
+	PaymentNo      int         `json:"payment_no"`
+	PaymentDueDate time.Time   `json:"payment_due_date"`
+	PaymentAmount  money.Money `json:"payment_amount"`
+	DaysPastDue    int         `json:"days_past_due"`
+}
+
+// PenaltyAccruedEventPayload Accrued is what was charged in this run, TotalPenalty is everything charged
+// on the schedule so far, so consumers can simply overwrite their copy.
+type PenaltyAccruedEventPayload struct {
+	LoanID       uuid.UUID   `json:"loan_id"`
+	CustomerID   uuid.UUID   `json:"customer_id"`
+	ScheduleID   uuid.UUID   `json:"schedule_id"`
+	DaysPastDue  int         `json:"days_past_due"`
+	Accrued      money.Money `json:"accrued"`
+	TotalPenalty money.Money `json:"total_penalty"`
+}
+
+type LoanSettledEventPayload struct {
+	LoanID     uuid.UUID                `json:"loan_id"`
+	QuoteID    uuid.UUID                `json:"quote_id"`
+	PaymentID  uuid.UUID                `json:"payment_id"`
+	AmountPaid money.Money              `json:"amount_paid"`
+	SettledAt  time.Time                `json:"settled_at"`
+	Schedules  []ScheduleBalancePayload `json:"schedules"`
+}
+
+type LoanStatusChangedEventPayload struct {
+	LoanID     uuid.UUID       `json:"loan_id"`
+	CustomerID uuid.UUID       `json:"customer_id"`
+	FromStatus enum.LoanStatus `json:"from_status"`
+	ToStatus   enum.LoanStatus `json:"to_status"`
+	Reason     string          `json:"reason"`
+	ChangedAt  time.Time       `json:"changed_at"`
+}
+
+type LoanDisbursedEventPayload struct {
+	LoanID            uuid.UUID   `json:"loan_id"`
+	CustomerID        uuid.UUID   `json:"customer_id"`
+	DisbursementID    uuid.UUID   `json:"disbursement_id"`
+	Amount            money.Money `json:"amount"`
+	ProviderReference string      `json:"provider_reference"`
+	DisbursedAt       time.Time   `json:"disbursed_at"`
+}
+
+// LoanRefinancedEventPayload LoanID is the loan that was settled, NewLoanID the top-up that settled it.
+type LoanRefinancedEventPayload struct {
+	LoanID        uuid.UUID   `json:"loan_id"`
+	CustomerID    uuid.UUID   `json:"customer_id"`
+	NewLoanID     uuid.UUID   `json:"new_loan_id"`
+	SettledAmount money.Money `json:"settled_amount"`
+	RefinancedAt  time.Time   `json:"refinanced_at"`
+}
+
+type LoanCancelledEventPayload struct {
+	LoanID         uuid.UUID   `json:"loan_id"`
+	CustomerID     uuid.UUID   `json:"customer_id"`
+	CancellationID uuid.UUID   `json:"cancellation_id"`
+	AmountDue      money.Money `json:"amount_due"`
+	Refund         money.Money `json:"refund"`
+	CancelledAt    time.Time   `json:"cancelled_at"`
+}
+
+// LoanRestructuredEventPayload CancelledScheduleIDs are the schedules replaced by Schedules.
+type LoanRestructuredEventPayload struct {
+	LoanID               uuid.UUID         `json:"loan_id"`
+	CustomerID           uuid.UUID         `json:"customer_id"`
+	RestructureID        uuid.UUID         `json:"restructure_id"`
+	CancelledScheduleIDs []uuid.UUID       `json:"cancelled_schedule_ids"`
+	Schedules            []domain.Schedule `json:"schedules"`
+}
+
+// PaymentHolidayGrantedEventPayload Schedules are the deferred schedules with their new due dates.
+type PaymentHolidayGrantedEventPayload struct {
+	LoanID     uuid.UUID                `json:"loan_id"`
+	CustomerID uuid.UUID                `json:"customer_id"`
+	HolidayID  uuid.UUID                `json:"holiday_id"`
+	Schedules  []ScheduleDueDatePayload `json:"schedules"`
+}
+
+// ScheduleDueDatePayload PenaltyAmount is the penalty left on the schedule once the holiday waived it.
+type ScheduleDueDatePayload struct {
+	ScheduleID     uuid.UUID   `json:"schedule_id"`
+	PaymentDueDate time.Time   `json:"payment_due_date"`
+	PenaltyAmount  money.Money `json:"penalty_amount"`
 }
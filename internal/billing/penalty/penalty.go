@@ -0,0 +1,118 @@
+package penalty
+
+import (
+	"billing-engine/internal/billing/domain"
+	"billing-engine/pkg/enum"
+	"billing-engine/pkg/money"
+)
+
+// Rules are the penalty terms of a loan, copied from its product when the loan is created.
+// DailyRate is a fraction of the overdue amount charged per day past due, and a zero Cap means no cap.
+type Rules struct {
+	LateFee   money.Money
+	DailyRate float64
+	Cap       money.Money
+}
+
+type Accrual struct {
+	LateFee money.Money
+	Daily   money.Money
+}
+
+func (a Accrual) Total() (money.Money, error) {
+	return a.LateFee.Add(a.Daily)
+}
+
+// Accrue returns what still has to be charged on an overdue installment given the entries already in
+// the ledger. The daily penalty is computed for the whole period past due and only the difference is
+// charged, so running it several times on the same day charges nothing extra.
+func (r Rules) Accrue(overdue money.Money, daysPastDue int, charged []domain.Penalty) (Accrual, error) {
+	accrual := Accrual{LateFee: money.Zero(overdue.Currency), Daily: money.Zero(overdue.Currency)}
+	if daysPastDue <= 0 {
+		return accrual, nil
+	}
+
+	lateFeeCharged, err := Sum(charged, enum.PenaltyLateFee)
+	if err != nil {
+		return Accrual{}, err
+	}
+
+	dailyCharged, err := Sum(charged, enum.PenaltyDaily)
+	if err != nil {
+		return Accrual{}, err
+	}
+
+	if lateFeeCharged.IsZero() && r.LateFee.IsPositive() {
+		accrual.LateFee = r.LateFee
+	}
+
+	due, err := overdue.MulRate(r.DailyRate * float64(daysPastDue)).Sub(dailyCharged)
+	if err != nil {
+		return Accrual{}, err
+	}
+
+	if due.IsPositive() {
+		accrual.Daily = due
+	}
+
+	if r.Cap.IsPositive() {
+		charged, err := lateFeeCharged.Add(dailyCharged)
+		if err != nil {
+			return Accrual{}, err
+		}
+
+		room, err := r.Cap.Sub(charged)
+		if err != nil {
+			return Accrual{}, err
+		}
+
+		accrual.LateFee, err = clamp(accrual.LateFee, room)
+		if err != nil {
+			return Accrual{}, err
+		}
+
+		room, err = room.Sub(accrual.LateFee)
+		if err != nil {
+			return Accrual{}, err
+		}
+
+		accrual.Daily, err = clamp(accrual.Daily, room)
+		if err != nil {
+			return Accrual{}, err
+		}
+	}
+
+	return accrual, nil
+}
+
+// Sum adds up the entries of the given types, or all entries when no type is given.
+func Sum(penalties []domain.Penalty, types ...enum.PenaltyType) (money.Money, error) {
+	var amounts []money.Money
+	for _, val := range penalties {
+		if len(types) > 0 && !hasType(types, val.Type) {
+			continue
+		}
+
+		amounts = append(amounts, val.Amount)
+	}
+
+	return money.Sum(amounts...)
+}
+
+func hasType(types []enum.PenaltyType, penaltyType enum.PenaltyType) bool {
+	for _, val := range types {
+		if val == penaltyType {
+			return true
+		}
+	}
+
+	return false
+}
+
+func clamp(amount, room money.Money) (money.Money, error) {
+	if !room.IsPositive() {
+		return money.Zero(amount.Currency), nil
+	}
+
+	return amount.Min(room)
+}
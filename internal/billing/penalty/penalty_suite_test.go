@@ -0,0 +1,13 @@
+package penalty
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestPenalty(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Penalty Suite")
+}
@@ -0,0 +1,85 @@
+package penalty
+
+import (
+	"billing-engine/internal/billing/domain"
+	"billing-engine/pkg/enum"
+	"billing-engine/pkg/money"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func idr(amount int64) money.Money {
+	return money.New(amount, "IDR")
+}
+
+var _ = Describe("Penalty", func() {
+	rules := Rules{LateFee: idr(25000), DailyRate: 0.001, Cap: idr(0)}
+	overdue := idr(1000000)
+
+	It("should charge nothing before the due date", func() {
+		accrual, err := rules.Accrue(overdue, 0, nil)
+		Expect(err).To(BeNil())
+		Expect(accrual.Total()).To(Equal(idr(0)))
+	})
+
+	It("should charge the late fee once and the daily penalty for every day past due", func() {
+		accrual, err := rules.Accrue(overdue, 3, nil)
+		Expect(err).To(BeNil())
+		Expect(accrual.LateFee).To(Equal(idr(25000)))
+		Expect(accrual.Daily).To(Equal(idr(3000)))
+
+		charged := []domain.Penalty{
+			{Type: enum.PenaltyLateFee, Amount: idr(25000)},
+			{Type: enum.PenaltyDaily, Amount: idr(3000)},
+		}
+		accrual, err = rules.Accrue(overdue, 5, charged)
+		Expect(err).To(BeNil())
+		Expect(accrual.LateFee.IsZero()).To(BeTrue())
+		Expect(accrual.Daily).To(Equal(idr(2000)))
+	})
+
+	It("should charge nothing extra when run twice on the same day", func() {
+		charged := []domain.Penalty{
+			{Type: enum.PenaltyLateFee, Amount: idr(25000)},
+			{Type: enum.PenaltyDaily, Amount: idr(3000)},
+		}
+		accrual, err := rules.Accrue(overdue, 3, charged)
+		Expect(err).To(BeNil())
+		Expect(accrual.Total()).To(Equal(idr(0)))
+	})
+
+	It("should stop charging at the cap", func() {
+		capped := Rules{LateFee: idr(25000), DailyRate: 0.001, Cap: idr(30000)}
+		accrual, err := capped.Accrue(overdue, 10, nil)
+		Expect(err).To(BeNil())
+		Expect(accrual.LateFee).To(Equal(idr(25000)))
+		Expect(accrual.Daily).To(Equal(idr(5000)))
+
+		charged := []domain.Penalty{
+			{Type: enum.PenaltyLateFee, Amount: idr(25000)},
+			{Type: enum.PenaltyDaily, Amount: idr(5000)},
+		}
+		accrual, err = capped.Accrue(overdue, 20, charged)
+		Expect(err).To(BeNil())
+		Expect(accrual.Total()).To(Equal(idr(0)))
+	})
+
+	It("should sum entries by type", func() {
+		charged := []domain.Penalty{
+			{Type: enum.PenaltyLateFee, Amount: idr(25000)},
+			{Type: enum.PenaltyDaily, Amount: idr(3000)},
+			{Type: enum.PenaltyDaily, Amount: idr(1000)},
+		}
+		Expect(Sum(charged)).To(Equal(idr(29000)))
+		Expect(Sum(charged, enum.PenaltyDaily)).To(Equal(idr(4000)))
+	})
+
+	It("should refuse to sum entries in different currencies", func() {
+		charged := []domain.Penalty{
+			{Type: enum.PenaltyLateFee, Amount: idr(25000)},
+			{Type: enum.PenaltyDaily, Amount: money.New(3, "USD")},
+		}
+		_, err := Sum(charged)
+		Expect(err).To(MatchError(money.ErrCurrencyMismatch))
+	})
+})
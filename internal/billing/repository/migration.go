@@ -0,0 +1,29 @@
+package repository
+
+import (
+	"billing-engine/internal/billing/domain"
+	"billing-engine/pkg/enum"
+	"gorm.io/gorm"
+)
+
+// MigrateLoanStatus moves the loans stored before the status lifecycle onto it: a finished loan is PAID_OFF
+// and any other loan ACTIVE, changed when the loan was last updated. It drops the is_finish column once the
+// loans are moved, so it does nothing when it runs again. Run it after AutoMigrate added the status columns.
+func MigrateLoanStatus(db *gorm.DB) error {
+	if !db.Migrator().HasColumn(&domain.Loan{}, "is_finish") {
+		return nil
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Exec(`UPDATE loans
+			SET status = CASE WHEN is_finish THEN ? ELSE ? END,
+				status_changed_at = COALESCE(updated_at, created_at, NOW())
+			WHERE status IS NULL OR status = ''`,
+			enum.LoanStatusPaidOff, enum.LoanStatusActive).Error
+		if err != nil {
+			return err
+		}
+
+		return tx.Migrator().DropColumn(&domain.Loan{}, "is_finish")
+	})
+}
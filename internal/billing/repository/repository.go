@@ -2,39 +2,128 @@ package repository
 
 import (
 	"billing-engine/internal/billing/domain"
+	"billing-engine/internal/billing/lifecycle"
 	"billing-engine/pkg/enum"
+	"billing-engine/pkg/inbox"
 	"billing-engine/pkg/logger"
+	"billing-engine/pkg/money"
+	"billing-engine/pkg/outbox"
+	"billing-engine/pkg/producer"
 	"context"
 	"errors"
 	"github.com/google/uuid"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"strings"
 	"time"
 )
 
 //go:generate mockgen -destination=../mocks/mock_billing_repository.go -package=mocks billing-engine/internal/billing/repository BillingRepositoryProvider
 type BillingRepositoryProvider interface {
+	// WithTransaction runs fn as one unit of work, every call on the repository given to fn is part of the
+	// transaction. It is committed when fn returns nil and rolled back otherwise.
+	WithTransaction(ctx context.Context, fn func(repo BillingRepositoryProvider) error) error
+	// CreateOutboxMessage stores an event for the outbox relay, call it inside WithTransaction so the event
+	// is only sent when the change it announces is committed.
+	CreateOutboxMessage(ctx context.Context, message producer.Message) error
+	// MarkEventProcessed records a consumed event and reports whether it was new, call it inside WithTransaction
+	// so the event counts as processed exactly when its change is committed.
+	MarkEventProcessed(ctx context.Context, eventID, eventName string) (bool, error)
+
 	CreateLoan(ctx context.Context, request domain.Loan) (*domain.Loan, error)
 	GetSchedule(ctx context.Context, loanID, customerID uuid.UUID) ([]domain.Schedule, error)
-	GetUnpaidAndMissPaymentUntil(ctx context.Context, customerID uuid.UUID, date time.Time) ([]domain.Schedule, error)
+	GetMissedSchedules(ctx context.Context, loanID uuid.UUID) ([]domain.Schedule, error)
+	GetLoansWithOverdueSchedules(ctx context.Context, until time.Time) ([]domain.Loan, error)
+	MarkScheduleMissed(ctx context.Context, scheduleID uuid.UUID, daysPastDue int) error
+	GetOpenSchedules(ctx context.Context, loanID uuid.UUID) ([]domain.Schedule, error)
+	CreatePenalties(ctx context.Context, penalties []domain.Penalty) error
+	CreatePayoffQuote(ctx context.Context, quote domain.PayoffQuote) (*domain.PayoffQuote, error)
+	SettlePayoffQuote(ctx context.Context, quoteID uuid.UUID) error
+	UpdateLoanStatus(ctx context.Context, history domain.LoanStatusHistory) (bool, error)
+	ActivateLoan(ctx context.Context, loan domain.Loan, history domain.LoanStatusHistory) (*domain.Loan, error)
+	GetDisbursementByLoanID(ctx context.Context, loanID uuid.UUID) (*domain.Disbursement, error)
+	CreateDisbursement(ctx context.Context, disbursement domain.Disbursement) (*domain.Disbursement, error)
+	UpdateDisbursement(ctx context.Context, disbursement domain.Disbursement) error
+	RestructureLoan(ctx context.Context, loan domain.Loan, restructure domain.Restructure, cancelledIDs []uuid.UUID) (*domain.Restructure, error)
+	GetLoansWithSchedulesDueBetween(ctx context.Context, filter LoanFilter, from, until time.Time) ([]domain.Loan, error)
+	GrantPaymentHoliday(ctx context.Context, holiday domain.PaymentHoliday, schedules []domain.Schedule, endDate time.Time) (*domain.PaymentHoliday, error)
+	HasPaymentHolidayBetween(ctx context.Context, loanID uuid.UUID, start, end time.Time) (bool, error)
+	CreateWriteOff(ctx context.Context, writeOff domain.WriteOff) (*domain.WriteOff, error)
+	CreateRecovery(ctx context.Context, recovery domain.Recovery) error
+	CancelLoan(ctx context.Context, cancellation domain.Cancellation, history domain.LoanStatusHistory) (*domain.Cancellation, error)
+	RefinanceLoan(ctx context.Context, history domain.LoanStatusHistory) (bool, error)
+	GetPendingTopUp(ctx context.Context, loanID uuid.UUID) (*domain.Loan, error)
+	GetTotalUnpaidPenaltyOnActiveLoan(ctx context.Context, loanID uuid.UUID) (int64, error)
 	GetLoanByIDAndCustomerID(ctx context.Context, loanID, customerID uuid.UUID) (*domain.Loan, error)
-	GetTotalUnpaidPaymentOnActiveLoan(ctx context.Context, loanId uuid.UUID) (float64, error)
-	LastActiveLoan(ctx context.Context, customerID uuid.UUID) (*domain.Loan, error)
-	GetLoanByScheduleID(ctx context.Context, scheduleID uuid.UUID) (*domain.Loan, error)
+	GetTotalUnpaidPaymentOnActiveLoan(ctx context.Context, loanId uuid.UUID) (int64, error)
+	GetTotalPaid(ctx context.Context, loanID uuid.UUID) (int64, error)
+	GetActiveLoans(ctx context.Context, customerID uuid.UUID) ([]domain.Loan, error)
+	GetClosedLoansWithCredit(ctx context.Context, customerID uuid.UUID) ([]domain.Loan, error)
+	GetLoanByID(ctx context.Context, loanID uuid.UUID) (*domain.Loan, error)
+	LockLoan(ctx context.Context, loanID uuid.UUID) error
 	UpdateSchedulePayment(ctx context.Context, schedule *domain.Schedule) error
 	GetScheduleByID(ctx context.Context, scheduleID uuid.UUID) (*domain.Schedule, error)
 
-	// CreateCustomer NOTE: this method is out of context, so I will just merge it in the billing service
-	CreateCustomer(ctx context.Context, request []domain.Customer) error
-	// GetCustomer NOTE: this method is out of context, so I will just merge it in the billing service
-	GetCustomer(ctx context.Context) ([]domain.Customer, error)
+	CreateProduct(ctx context.Context, request domain.Product) (*domain.Product, error)
+	GetProducts(ctx context.Context) ([]domain.Product, error)
+	GetProductByID(ctx context.Context, productID uuid.UUID) (*domain.Product, error)
+	UpdateProduct(ctx context.Context, product *domain.Product) error
+	DeleteProduct(ctx context.Context, productID uuid.UUID) error
+
+	CreateCustomer(ctx context.Context, customer domain.Customer) (*domain.Customer, error)
+	UpdateCustomer(ctx context.Context, customer domain.Customer) error
+	DeactivateCustomer(ctx context.Context, customerID uuid.UUID, deactivatedAt time.Time) error
+	SearchCustomers(ctx context.Context, filter CustomerFilter) ([]domain.Customer, int64, error)
 	GetCustomerByID(ctx context.Context, customerID uuid.UUID) (*domain.Customer, error)
+	LockCustomer(ctx context.Context, customerID uuid.UUID) error
+	GetCustomerByEmail(ctx context.Context, email string) (*domain.Customer, error)
+	UpdateCreditLimit(ctx context.Context, customerID uuid.UUID, limit money.Money) error
+	AddLoanCredit(ctx context.Context, loanID uuid.UUID, credit money.Money) error
+	GetOutstandingPrincipal(ctx context.Context, customerID uuid.UUID, currency string) (int64, error)
 }
 
+// LoanFilter narrows bulk operations down, zero fields match every loan.
+type LoanFilter struct {
+	ProductID *uuid.UUID
+	Frequency enum.Frequency
+}
+
+// CustomerFilter matches customers whose name, email and phone number contain the given parts, empty fields
+// match every customer. Deactivated customers are left out unless IncludeInactive is set.
+type CustomerFilter struct {
+	Name            string
+	Email           string
+	PhoneNumber     string
+	IncludeInactive bool
+	Limit           int
+	Offset          int
+}
+
+// ErrDuplicateEmail is returned when a customer is stored with an email another customer already has.
+var ErrDuplicateEmail = errors.New("email is already registered")
+
+// openStatuses are the statuses of a schedule that still expects money.
+var openStatuses = []enum.PaymentStatus{enum.PaymentStatusPending, enum.PaymentStatusPartiallyPaid}
+
 type repo struct {
 	db  *gorm.DB
 	log logger.Logger
 }
 
+func (r repo) WithTransaction(ctx context.Context, fn func(repo BillingRepositoryProvider) error) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return fn(repo{db: tx, log: r.log})
+	})
+}
+
+func (r repo) CreateOutboxMessage(ctx context.Context, message producer.Message) error {
+	return outbox.Write(r.db.WithContext(ctx), message)
+}
+
+func (r repo) MarkEventProcessed(ctx context.Context, eventID, eventName string) (bool, error) {
+	return inbox.Record(r.db.WithContext(ctx), eventID, eventName)
+}
+
 func (r repo) UpdateSchedulePayment(ctx context.Context, schedule *domain.Schedule) error {
 	return r.db.WithContext(ctx).Model(&schedule).Updates(schedule).Error
 }
@@ -51,13 +140,9 @@ func (r repo) GetScheduleByID(ctx context.Context, scheduleID uuid.UUID) (*domai
 	return &schedule, nil
 }
 
-func (r repo) GetLoanByScheduleID(ctx context.Context, scheduleID uuid.UUID) (*domain.Loan, error) {
+func (r repo) GetLoanByID(ctx context.Context, loanID uuid.UUID) (*domain.Loan, error) {
 	var loan domain.Loan
-	err := r.db.WithContext(ctx).Model(&domain.Schedule{}).
-		Select("loans.*").
-		Joins("JOIN loans ON loans.loan_id = schedules.loan_id").
-		Where("schedules.schedule_id = ?", scheduleID).
-		First(&loan).Error
+	err := r.db.WithContext(ctx).Where("loan_id = ?", loanID).First(&loan).Error
 	if err != nil && errors.Is(err, gorm.ErrRecordNotFound) {
 		return nil, nil
 	} else if err != nil {
@@ -86,9 +171,12 @@ func (r repo) GetSchedule(ctx context.Context, loanID, customerID uuid.UUID) ([]
 	return schedules, nil
 }
 
-func (r repo) GetUnpaidAndMissPaymentUntil(ctx context.Context, loanId uuid.UUID, date time.Time) ([]domain.Schedule, error) {
+func (r repo) GetMissedSchedules(ctx context.Context, loanID uuid.UUID) ([]domain.Schedule, error) {
 	var schedules []domain.Schedule
-	err := r.db.Debug().WithContext(ctx).Where("loan_id = ? AND payment_due_date < ? AND payment_status = ?", loanId, date, enum.PaymentStatusPending).Order("payment_no asc").Find(&schedules).Error
+	err := r.db.WithContext(ctx).
+		Where("loan_id = ? AND is_miss_payment = ? AND payment_status IN ?", loanID, true, openStatuses).
+		Order("payment_no asc").
+		Find(&schedules).Error
 	if err != nil {
 		return nil, err
 	}
@@ -96,9 +184,388 @@ func (r repo) GetUnpaidAndMissPaymentUntil(ctx context.Context, loanId uuid.UUID
 	return schedules, nil
 }
 
-func (r repo) GetLoanByIDAndCustomerID(ctx context.Context, loanID, customerID uuid.UUID) (*domain.Loan, error) {
+// GetLoansWithOverdueSchedules returns the repaying loans that have open schedules due before until,
+// with only those overdue schedules and their penalties preloaded.
+func (r repo) GetLoansWithOverdueSchedules(ctx context.Context, until time.Time) ([]domain.Loan, error) {
+	var loans []domain.Loan
+	overdue := r.db.Model(&domain.Schedule{}).Select("loan_id").
+		Where("payment_status IN ? AND payment_due_date < ?", openStatuses, until)
+
+	err := r.db.WithContext(ctx).
+		Preload("Schedules", func(db *gorm.DB) *gorm.DB {
+			return db.Where("payment_status IN ? AND payment_due_date < ?", openStatuses, until).
+				Order("payment_no asc")
+		}).
+		Preload("Schedules.Penalties").
+		Where("status IN ? AND loan_id IN (?)", lifecycle.RepayingStatuses(), overdue).
+		Find(&loans).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return loans, nil
+}
+
+func (r repo) MarkScheduleMissed(ctx context.Context, scheduleID uuid.UUID, daysPastDue int) error {
+	return r.db.WithContext(ctx).Model(&domain.Schedule{}).
+		Where("schedule_id = ?", scheduleID).
+		Updates(map[string]interface{}{"is_miss_payment": true, "days_past_due": daysPastDue}).Error
+}
+
+// GetOpenSchedules returns the schedules of the loan that still expect money, oldest first, with their penalties.
+func (r repo) GetOpenSchedules(ctx context.Context, loanID uuid.UUID) ([]domain.Schedule, error) {
+	var schedules []domain.Schedule
+	err := r.db.WithContext(ctx).
+		Preload("Penalties").
+		Where("loan_id = ? AND payment_status IN ?", loanID, openStatuses).
+		Order("payment_no asc").
+		Find(&schedules).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return schedules, nil
+}
+
+func (r repo) CreatePayoffQuote(ctx context.Context, quote domain.PayoffQuote) (*domain.PayoffQuote, error) {
+	err := r.db.WithContext(ctx).Create(&quote).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return &quote, nil
+}
+
+func (r repo) SettlePayoffQuote(ctx context.Context, quoteID uuid.UUID) error {
+	return r.db.WithContext(ctx).Model(&domain.PayoffQuote{}).
+		Where("quote_id = ?", quoteID).
+		Update("status", enum.QuoteStatusSettled).Error
+}
+
+// UpdateLoanStatus moves the loan to history.ToStatus and records the change. It returns false without
+// changing anything when the loan is no longer in history.FromStatus.
+func (r repo) UpdateLoanStatus(ctx context.Context, history domain.LoanStatusHistory) (bool, error) {
+	var updated bool
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&domain.Loan{}).
+			Where("loan_id = ? AND status = ?", history.LoanID, history.FromStatus).
+			Updates(map[string]interface{}{"status": history.ToStatus, "status_changed_at": history.ChangedAt})
+		if result.Error != nil {
+			return result.Error
+		}
+
+		if result.RowsAffected == 0 {
+			return nil
+		}
+
+		updated = true
+		return tx.Create(&history).Error
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return updated, nil
+}
+
+// ActivateLoan stores the schedules of the loan and moves it to history.ToStatus with its new start and end date,
+// and for a top-up the amount that settles the loan it refinances, in one transaction. It returns nil without
+// changing anything when the loan is no longer in history.FromStatus.
+func (r repo) ActivateLoan(ctx context.Context, loan domain.Loan, history domain.LoanStatusHistory) (*domain.Loan, error) {
+	var activated *domain.Loan
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		updates := map[string]interface{}{
+			"status":            history.ToStatus,
+			"status_changed_at": history.ChangedAt,
+			"start_date":        loan.StartDate,
+			"end_date":          loan.EndDate,
+		}
+		if loan.RefinancedLoanID != nil {
+			updates["refinanced_amount"] = loan.RefinancedAmount.Amount
+			updates["refinanced_currency"] = loan.RefinancedAmount.Currency
+		}
+
+		result := tx.Model(&domain.Loan{}).
+			Where("loan_id = ? AND status = ?", loan.LoanID, history.FromStatus).
+			Updates(updates)
+		if result.Error != nil {
+			return result.Error
+		}
+
+		if result.RowsAffected == 0 {
+			return nil
+		}
+
+		for i := range loan.Schedules {
+			loan.Schedules[i].LoanID = loan.LoanID
+		}
+
+		err := tx.Create(&loan.Schedules).Error
+		if err != nil {
+			return err
+		}
+
+		err = tx.Create(&history).Error
+		if err != nil {
+			return err
+		}
+
+		loan.Status = history.ToStatus
+		loan.StatusChangedAt = history.ChangedAt
+		activated = &loan
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return activated, nil
+}
+
+func (r repo) GetDisbursementByLoanID(ctx context.Context, loanID uuid.UUID) (*domain.Disbursement, error) {
+	var disbursement domain.Disbursement
+	err := r.db.WithContext(ctx).Where("loan_id = ?", loanID).First(&disbursement).Error
+	if err != nil && errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	return &disbursement, nil
+}
+
+func (r repo) CreateDisbursement(ctx context.Context, disbursement domain.Disbursement) (*domain.Disbursement, error) {
+	err := r.db.WithContext(ctx).Create(&disbursement).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return &disbursement, nil
+}
+
+func (r repo) UpdateDisbursement(ctx context.Context, disbursement domain.Disbursement) error {
+	return r.db.WithContext(ctx).Model(&domain.Disbursement{}).
+		Where("disbursement_id = ?", disbursement.DisbursementID).
+		Updates(map[string]interface{}{
+			"amount_amount":      disbursement.Amount.Amount,
+			"amount_currency":    disbursement.Amount.Currency,
+			"bank_code":          disbursement.BankCode,
+			"account_number":     disbursement.AccountNumber,
+			"status":             disbursement.Status,
+			"attempts":           disbursement.Attempts,
+			"last_error":         disbursement.LastError,
+			"provider_reference": disbursement.ProviderReference,
+			"disbursed_at":       disbursement.DisbursedAt,
+		}).Error
+}
+
+// RestructureLoan cancels the replaced schedules, stores the restructure together with its new schedules and
+// moves the loan to its new rate and end date in one transaction.
+func (r repo) RestructureLoan(ctx context.Context, loan domain.Loan, restructure domain.Restructure, cancelledIDs []uuid.UUID) (*domain.Restructure, error) {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&domain.Schedule{}).
+			Where("schedule_id IN ? AND payment_status IN ?", cancelledIDs, openStatuses).
+			Update("payment_status", enum.PaymentStatusCancelled).Error
+		if err != nil {
+			return err
+		}
+
+		err = tx.Create(&restructure).Error
+		if err != nil {
+			return err
+		}
+
+		return tx.Model(&domain.Loan{}).
+			Where("loan_id = ?", loan.LoanID).
+			Updates(map[string]interface{}{"interest_rate": loan.InterestRate, "end_date": loan.EndDate}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &restructure, nil
+}
+
+// GetLoansWithSchedulesDueBetween returns the repaying loans matching filter that have an open schedule due in
+// [from, until). Loans and schedules are not preloaded.
+func (r repo) GetLoansWithSchedulesDueBetween(ctx context.Context, filter LoanFilter, from, until time.Time) ([]domain.Loan, error) {
+	var loans []domain.Loan
+	due := r.db.Model(&domain.Schedule{}).Select("loan_id").
+		Where("payment_status IN ? AND payment_due_date >= ? AND payment_due_date < ?", openStatuses, from, until)
+
+	query := r.db.WithContext(ctx).
+		Where("status IN ? AND loan_id IN (?)", lifecycle.RepayingStatuses(), due)
+	if filter.ProductID != nil {
+		query = query.Where("product_id = ?", *filter.ProductID)
+	}
+
+	if filter.Frequency != "" {
+		query = query.Where("frequency = ?", filter.Frequency)
+	}
+
+	err := query.Order("start_date asc").Find(&loans).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return loans, nil
+}
+
+// HasPaymentHolidayBetween reports whether the loan has a holiday sharing a day with the window from start to
+// end, both days included.
+func (r repo) HasPaymentHolidayBetween(ctx context.Context, loanID uuid.UUID, start, end time.Time) (bool, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&domain.PaymentHoliday{}).
+		Where("loan_id = ? AND start_date <= ? AND end_date >= ?", loanID, end, start).
+		Count(&count).Error
+	if err != nil {
+		return false, err
+	}
+
+	return count > 0, nil
+}
+
+// GrantPaymentHoliday stores the holiday, moves the deferred schedules to their new due dates and clears their
+// missed flag, and extends the loan to endDate in one transaction.
+func (r repo) GrantPaymentHoliday(ctx context.Context, holiday domain.PaymentHoliday, schedules []domain.Schedule, endDate time.Time) (*domain.PaymentHoliday, error) {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Create(&holiday).Error
+		if err != nil {
+			return err
+		}
+
+		for _, schedule := range schedules {
+			err = tx.Model(&domain.Schedule{}).
+				Where("schedule_id = ?", schedule.ScheduleID).
+				Updates(map[string]interface{}{
+					"payment_due_date": schedule.PaymentDueDate,
+					"is_miss_payment":  false,
+					"days_past_due":    0,
+				}).Error
+			if err != nil {
+				return err
+			}
+		}
+
+		return tx.Model(&domain.Loan{}).
+			Where("loan_id = ?", holiday.LoanID).
+			Update("end_date", endDate).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &holiday, nil
+}
+
+func (r repo) CreateWriteOff(ctx context.Context, writeOff domain.WriteOff) (*domain.WriteOff, error) {
+	err := r.db.WithContext(ctx).Create(&writeOff).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return &writeOff, nil
+}
+
+// CreateRecovery books a recovery once per payment, a replayed payment is ignored.
+func (r repo) CreateRecovery(ctx context.Context, recovery domain.Recovery) error {
+	return r.db.WithContext(ctx).
+		Clauses(clause.OnConflict{Columns: []clause.Column{{Name: "payment_id"}}, DoNothing: true}).
+		Create(&recovery).Error
+}
+
+// CancelLoan moves the loan to history.ToStatus, closes its open schedules, clears its credit and stores the
+// cancellation in one transaction, the credit is part of what the cancellation refunds. It returns nil without
+// changing anything when the loan is no longer in history.FromStatus.
+func (r repo) CancelLoan(ctx context.Context, cancellation domain.Cancellation, history domain.LoanStatusHistory) (*domain.Cancellation, error) {
+	var stored *domain.Cancellation
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&domain.Loan{}).
+			Where("loan_id = ? AND status = ?", history.LoanID, history.FromStatus).
+			Updates(map[string]interface{}{
+				"status":                history.ToStatus,
+				"status_changed_at":     history.ChangedAt,
+				"credit_balance_amount": 0,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+
+		if result.RowsAffected == 0 {
+			return nil
+		}
+
+		err := tx.Model(&domain.Schedule{}).
+			Where("loan_id = ? AND payment_status IN ?", history.LoanID, openStatuses).
+			Update("payment_status", enum.PaymentStatusCancelled).Error
+		if err != nil {
+			return err
+		}
+
+		err = tx.Create(&history).Error
+		if err != nil {
+			return err
+		}
+
+		err = tx.Create(&cancellation).Error
+		if err != nil {
+			return err
+		}
+
+		stored = &cancellation
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return stored, nil
+}
+
+// RefinanceLoan closes a loan settled by a top-up as REFINANCED and cancels its open schedules, it reports
+// false when the loan moved away from history.FromStatus in the meantime.
+func (r repo) RefinanceLoan(ctx context.Context, history domain.LoanStatusHistory) (bool, error) {
+	refinanced := false
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&domain.Loan{}).
+			Where("loan_id = ? AND status = ?", history.LoanID, history.FromStatus).
+			Updates(map[string]interface{}{"status": history.ToStatus, "status_changed_at": history.ChangedAt})
+		if result.Error != nil {
+			return result.Error
+		}
+
+		if result.RowsAffected == 0 {
+			return nil
+		}
+
+		err := tx.Model(&domain.Schedule{}).
+			Where("loan_id = ? AND payment_status IN ?", history.LoanID, openStatuses).
+			Update("payment_status", enum.PaymentStatusCancelled).Error
+		if err != nil {
+			return err
+		}
+
+		err = tx.Create(&history).Error
+		if err != nil {
+			return err
+		}
+
+		refinanced = true
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return refinanced, nil
+}
+
+// GetPendingTopUp returns the top-up of the loan that was approved but not paid out yet.
+func (r repo) GetPendingTopUp(ctx context.Context, loanID uuid.UUID) (*domain.Loan, error) {
 	var loan domain.Loan
-	err := r.db.WithContext(ctx).Where("loan_id = ? AND customer_id = ?", loanID, customerID).First(&loan).Error
+	err := r.db.WithContext(ctx).
+		Where("refinanced_loan_id = ? AND status = ?", loanID, enum.LoanStatusApproved).
+		First(&loan).Error
 	if err != nil && errors.Is(err, gorm.ErrRecordNotFound) {
 		return nil, nil
 	} else if err != nil {
@@ -108,9 +575,33 @@ func (r repo) GetLoanByIDAndCustomerID(ctx context.Context, loanID, customerID u
 	return &loan, nil
 }
 
-func (r repo) LastActiveLoan(ctx context.Context, customerID uuid.UUID) (*domain.Loan, error) {
+func (r repo) CreatePenalties(ctx context.Context, penalties []domain.Penalty) error {
+	return r.db.WithContext(ctx).Create(&penalties).Error
+}
+
+// GetTotalUnpaidPenaltyOnActiveLoan returns the unpaid penalties in minor units of the loan currency. Penalties
+// of cancelled schedules were capitalised by a restructure and are left out.
+func (r repo) GetTotalUnpaidPenaltyOnActiveLoan(ctx context.Context, loanID uuid.UUID) (int64, error) {
+	var totalUnpaid int64
+	schedules := r.db.Model(&domain.Schedule{}).Select("schedule_id").
+		Where("loan_id = ? AND payment_status <> ?", loanID, enum.PaymentStatusCancelled)
+	charged := r.db.Model(&domain.Penalty{}).Select("COALESCE(SUM(penalty_amount), 0)").
+		Where("loan_id = ? AND schedule_id IN (?)", loanID, schedules)
+	paid := r.db.Model(&domain.Schedule{}).Select("COALESCE(SUM(penalty_paid_amount), 0)").
+		Where("loan_id = ? AND payment_status <> ?", loanID, enum.PaymentStatusCancelled)
+	err := r.db.WithContext(ctx).Raw("SELECT (?) - (?)", charged, paid).
+		Row().
+		Scan(&totalUnpaid)
+	if err != nil {
+		return 0, err
+	}
+
+	return totalUnpaid, nil
+}
+
+func (r repo) GetLoanByIDAndCustomerID(ctx context.Context, loanID, customerID uuid.UUID) (*domain.Loan, error) {
 	var loan domain.Loan
-	err := r.db.WithContext(ctx).Where("customer_id = ? AND is_finish = ?", customerID, false).First(&loan).Error
+	err := r.db.WithContext(ctx).Where("loan_id = ? AND customer_id = ?", loanID, customerID).First(&loan).Error
 	if err != nil && errors.Is(err, gorm.ErrRecordNotFound) {
 		return nil, nil
 	} else if err != nil {
@@ -120,11 +611,26 @@ func (r repo) LastActiveLoan(ctx context.Context, customerID uuid.UUID) (*domain
 	return &loan, nil
 }
 
-func (r repo) GetTotalUnpaidPaymentOnActiveLoan(ctx context.Context, loanId uuid.UUID) (float64, error) {
-	var totalUnpaid float64
+// GetActiveLoans returns every loan the customer is still repaying, oldest first.
+func (r repo) GetActiveLoans(ctx context.Context, customerID uuid.UUID) ([]domain.Loan, error) {
+	var loans []domain.Loan
+	err := r.db.WithContext(ctx).
+		Where("customer_id = ? AND status IN ?", customerID, lifecycle.RepayingStatuses()).
+		Order("start_date asc").
+		Find(&loans).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return loans, nil
+}
+
+// GetTotalUnpaidPaymentOnActiveLoan returns the unpaid total in minor units of the loan currency.
+func (r repo) GetTotalUnpaidPaymentOnActiveLoan(ctx context.Context, loanId uuid.UUID) (int64, error) {
+	var totalUnpaid int64
 	err := r.db.WithContext(ctx).Model(&domain.Schedule{}).
-		Select("SUM(payment_amount)").
-		Where("loan_id = ? AND payment_status = ?", loanId, enum.PaymentStatusPending).
+		Select("COALESCE(SUM(payment_amount - principal_paid_amount - interest_paid_amount), 0)").
+		Where("loan_id = ? AND payment_status IN ?", loanId, openStatuses).
 		Row().
 		Scan(&totalUnpaid)
 	if err != nil {
@@ -134,19 +640,181 @@ func (r repo) GetTotalUnpaidPaymentOnActiveLoan(ctx context.Context, loanId uuid
 	return totalUnpaid, nil
 }
 
-func (r repo) CreateCustomer(ctx context.Context, request []domain.Customer) error {
-	return r.db.WithContext(ctx).Create(&request).Error
+// GetClosedLoansWithCredit returns the loans the customer no longer repays that still hold a credit, oldest
+// first.
+func (r repo) GetClosedLoansWithCredit(ctx context.Context, customerID uuid.UUID) ([]domain.Loan, error) {
+	var loans []domain.Loan
+	err := r.db.WithContext(ctx).
+		Where("customer_id = ? AND status NOT IN ? AND credit_balance_amount > 0", customerID, lifecycle.RepayingStatuses()).
+		Order("start_date asc").
+		Find(&loans).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return loans, nil
+}
+
+// GetTotalPaid sums everything paid on the schedules of the loan, penalties included.
+func (r repo) GetTotalPaid(ctx context.Context, loanID uuid.UUID) (int64, error) {
+	var totalPaid int64
+	err := r.db.WithContext(ctx).Model(&domain.Schedule{}).
+		Select("COALESCE(SUM(principal_paid_amount + interest_paid_amount + penalty_paid_amount), 0)").
+		Where("loan_id = ?", loanID).
+		Row().
+		Scan(&totalPaid)
+	if err != nil {
+		return 0, err
+	}
+
+	return totalPaid, nil
+}
+
+func (r repo) CreateProduct(ctx context.Context, request domain.Product) (*domain.Product, error) {
+	err := r.db.WithContext(ctx).Create(&request).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return &request, nil
+}
+
+func (r repo) GetProducts(ctx context.Context) ([]domain.Product, error) {
+	var products []domain.Product
+	err := r.db.WithContext(ctx).Order("name asc").Find(&products).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return products, nil
+}
+
+func (r repo) GetProductByID(ctx context.Context, productID uuid.UUID) (*domain.Product, error) {
+	var product domain.Product
+	err := r.db.WithContext(ctx).Where("product_id = ?", productID).First(&product).Error
+	if err != nil && errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	return &product, nil
+}
+
+func (r repo) UpdateProduct(ctx context.Context, product *domain.Product) error {
+	return r.db.WithContext(ctx).Save(product).Error
+}
+
+func (r repo) DeleteProduct(ctx context.Context, productID uuid.UUID) error {
+	return r.db.WithContext(ctx).Where("product_id = ?", productID).Delete(&domain.Product{}).Error
+}
+
+func (r repo) CreateCustomer(ctx context.Context, customer domain.Customer) (*domain.Customer, error) {
+	err := r.db.WithContext(ctx).Create(&customer).Error
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return nil, ErrDuplicateEmail
+	} else if err != nil {
+		return nil, err
+	}
+
+	return &customer, nil
+}
+
+// UpdateCustomer stores the contact details of the customer, the credit limit and deactivation have their own
+// methods.
+func (r repo) UpdateCustomer(ctx context.Context, customer domain.Customer) error {
+	err := r.db.WithContext(ctx).Model(&domain.Customer{}).
+		Where("customer_id = ?", customer.CustomerID).
+		Updates(map[string]interface{}{
+			"first_name":   customer.FirstName,
+			"last_name":    customer.LastName,
+			"email":        customer.Email,
+			"phone_number": customer.PhoneNumber,
+			"updated_at":   time.Now(),
+		}).Error
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return ErrDuplicateEmail
+	}
+
+	return err
+}
+
+func (r repo) DeactivateCustomer(ctx context.Context, customerID uuid.UUID, deactivatedAt time.Time) error {
+	return r.db.WithContext(ctx).Model(&domain.Customer{}).
+		Where("customer_id = ? AND deactivated_at IS NULL", customerID).
+		Update("deactivated_at", deactivatedAt).Error
 }
 
-func (r repo) GetCustomer(ctx context.Context) ([]domain.Customer, error) {
-	var result []domain.Customer
+// SearchCustomers returns a page of the customers matching the filter ordered by name, along with how many
+// customers match in total.
+func (r repo) SearchCustomers(ctx context.Context, filter CustomerFilter) ([]domain.Customer, int64, error) {
+	query := r.db.WithContext(ctx).Model(&domain.Customer{})
+	if filter.Name != "" {
+		query = query.Where("CONCAT_WS(' ', first_name, last_name) ILIKE ?", containsPattern(filter.Name))
+	}
+
+	if filter.Email != "" {
+		query = query.Where("email ILIKE ?", containsPattern(filter.Email))
+	}
+
+	if filter.PhoneNumber != "" {
+		query = query.Where("phone_number LIKE ?", containsPattern(filter.PhoneNumber))
+	}
+
+	if !filter.IncludeInactive {
+		query = query.Where("deactivated_at IS NULL")
+	}
+
+	var total int64
+	err := query.Count(&total).Error
+	if err != nil {
+		return nil, 0, err
+	}
 
-	err := r.db.WithContext(ctx).Find(&result).Error
+	var customers []domain.Customer
+	err = query.Order("first_name, last_name, customer_id").
+		Limit(filter.Limit).
+		Offset(filter.Offset).
+		Find(&customers).Error
 	if err != nil {
+		return nil, 0, err
+	}
+
+	return customers, total, nil
+}
+
+func (r repo) GetCustomerByEmail(ctx context.Context, email string) (*domain.Customer, error) {
+	var customer domain.Customer
+	err := r.db.WithContext(ctx).Where("LOWER(email) = LOWER(?)", email).First(&customer).Error
+	if err != nil && errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	} else if err != nil {
 		return nil, err
 	}
 
-	return result, nil
+	return &customer, nil
+}
+
+// LockLoan locks the row of the loan until the transaction ends, so changes to the schedules of the loan made
+// inside WithTransaction are handled one at a time.
+func (r repo) LockLoan(ctx context.Context, loanID uuid.UUID) error {
+	var loan domain.Loan
+	return r.db.WithContext(ctx).
+		Clauses(clause.Locking{Strength: "UPDATE"}).
+		Select("loan_id").
+		Where("loan_id = ?", loanID).
+		Find(&loan).Error
+}
+
+// LockCustomer locks the row of the customer until the transaction ends, so loans of the customer that are
+// checked against their limits and stored inside WithTransaction are handled one at a time.
+func (r repo) LockCustomer(ctx context.Context, customerID uuid.UUID) error {
+	var customer domain.Customer
+	return r.db.WithContext(ctx).
+		Clauses(clause.Locking{Strength: "UPDATE"}).
+		Select("customer_id").
+		Where("customer_id = ?", customerID).
+		Find(&customer).Error
 }
 
 func (r repo) GetCustomerByID(ctx context.Context, customerID uuid.UUID) (*domain.Customer, error) {
@@ -161,9 +829,68 @@ func (r repo) GetCustomerByID(ctx context.Context, customerID uuid.UUID) (*domai
 	return &customer, nil
 }
 
+func (r repo) UpdateCreditLimit(ctx context.Context, customerID uuid.UUID, limit money.Money) error {
+	return r.db.WithContext(ctx).Model(&domain.Customer{}).
+		Where("customer_id = ?", customerID).
+		Updates(map[string]interface{}{"credit_limit_amount": limit.Amount, "credit_limit_currency": limit.Currency}).Error
+}
+
+// AddLoanCredit adds what a payment brought in beyond the outstanding balance to the credit of the loan.
+func (r repo) AddLoanCredit(ctx context.Context, loanID uuid.UUID, credit money.Money) error {
+	return r.db.WithContext(ctx).Model(&domain.Loan{}).
+		Where("loan_id = ?", loanID).
+		Updates(map[string]interface{}{
+			"credit_balance_amount":   gorm.Expr("COALESCE(credit_balance_amount, 0) + ?", credit.Amount),
+			"credit_balance_currency": credit.Currency,
+		}).Error
+}
+
+// GetOutstandingPrincipal sums the principal the customer still owes on loans being repaid and the principal
+// of applications and approved loans that may still be paid out, in the given currency. A loan that a pending
+// top-up will settle is left out, the principal of the top-up already covers it.
+func (r repo) GetOutstandingPrincipal(ctx context.Context, customerID uuid.UUID, currency string) (int64, error) {
+	pending := []enum.LoanStatus{enum.LoanStatusPendingApproval, enum.LoanStatusApproved}
+	settledByTopUp := r.db.Model(&domain.Loan{}).
+		Select("refinanced_loan_id").
+		Where("customer_id = ? AND status IN ? AND refinanced_loan_id IS NOT NULL", customerID, pending)
+
+	var repaying int64
+	err := r.db.WithContext(ctx).Model(&domain.Schedule{}).
+		Select("COALESCE(SUM(schedules.principal_amount - schedules.principal_paid_amount), 0)").
+		Joins("JOIN loans ON loans.loan_id = schedules.loan_id").
+		Where("loans.customer_id = ? AND loans.principal_currency = ? AND loans.status IN ? AND schedules.payment_status IN ?",
+			customerID, currency, lifecycle.RepayingStatuses(), openStatuses).
+		Where("loans.loan_id NOT IN (?)", settledByTopUp).
+		Row().
+		Scan(&repaying)
+	if err != nil {
+		return 0, err
+	}
+
+	var committed int64
+	err = r.db.WithContext(ctx).Model(&domain.Loan{}).
+		Select("COALESCE(SUM(principal_amount), 0)").
+		Where("customer_id = ? AND principal_currency = ? AND status IN ?", customerID, currency, pending).
+		Row().
+		Scan(&committed)
+	if err != nil {
+		return 0, err
+	}
+
+	return repaying + committed, nil
+}
+
 func NewBillingRepositoryProvider(db *gorm.DB, log logger.Logger) BillingRepositoryProvider {
 	return &repo{
 		db:  db,
 		log: log,
 	}
 }
+
+// containsPattern turns the search term into a LIKE pattern matching it anywhere, wildcards in the term are
+// matched literally.
+func containsPattern(term string) string {
+	return "%" + likeEscaper.Replace(term) + "%"
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
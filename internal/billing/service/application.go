@@ -0,0 +1,201 @@
+package service
+
+import (
+	"billing-engine/internal/billing/domain"
+	"billing-engine/internal/billing/eligibility"
+	"billing-engine/internal/billing/lifecycle"
+	"billing-engine/internal/billing/model"
+	apperror "billing-engine/pkg/customerror"
+	"billing-engine/pkg/enum"
+	"billing-engine/pkg/money"
+	"context"
+	"fmt"
+	"github.com/google/uuid"
+	"time"
+)
+
+// ApproveLoan approves an application waiting for an approver. The eligibility rules are checked again, the
+// situation of the customer may have changed since the application came in.
+func (b BillingService) ApproveLoan(ctx context.Context, payload model.ApproveLoanPayload) (*model.CreateLoanResponse, error) {
+	b.log.WithField("loan_id", payload.LoanID).
+		WithField("approved_by", payload.ApprovedBy).Info("[ApproveLoan] approving loan")
+
+	loan, err := b.repo.GetLoanByID(ctx, payload.LoanID)
+	if err != nil {
+		b.log.WithField("loan_id", payload.LoanID).
+			WithField("error", err.Error()).Error("[ApproveLoan] Unexpected error when getting loan")
+		return nil, err
+	}
+
+	if loan == nil {
+		b.log.WithField("loan_id", payload.LoanID).Info("[ApproveLoan] loan not found")
+		return nil, apperror.New(apperror.NotFound, "loan not found")
+	}
+
+	err = lifecycle.Validate(loan.Status, enum.LoanStatusApproved)
+	if err != nil {
+		b.log.WithField("loan_id", payload.LoanID).
+			WithField("error", err.Error()).Info("[ApproveLoan] loan cannot be approved")
+		return nil, apperror.New(apperror.InvalidInput, err.Error())
+	}
+
+	customer, err := b.repo.GetCustomerByID(ctx, loan.CustomerID)
+	if err != nil {
+		b.log.WithField("customer_id", loan.CustomerID).
+			WithField("error", err.Error()).Error("[ApproveLoan] Unexpected error when getting customer")
+		return nil, err
+	}
+
+	if customer == nil {
+		b.log.WithField("customer_id", loan.CustomerID).Error("[ApproveLoan] customer not found")
+		return nil, apperror.New(apperror.NotFound, "customer not found")
+	}
+
+	product, err := b.repo.GetProductByID(ctx, loan.ProductID)
+	if err != nil {
+		b.log.WithField("product_id", loan.ProductID).
+			WithField("error", err.Error()).Error("[ApproveLoan] Unexpected error when getting product")
+		return nil, err
+	}
+
+	if product == nil {
+		b.log.WithField("product_id", loan.ProductID).Error("[ApproveLoan] product not found")
+		return nil, apperror.New(apperror.NotFound, "product not found")
+	}
+
+	result, err := b.evaluateApplication(ctx, *customer, *product, loan.PrincipalAmount, true)
+	if err != nil {
+		return nil, err
+	}
+
+	if !result.Eligible {
+		b.log.WithField("loan_id", payload.LoanID).
+			WithField("reason", result.Reason()).Info("[ApproveLoan] loan is not eligible anymore")
+		return nil, apperror.New(apperror.InvalidInput, "loan is not eligible: "+result.Reason())
+	}
+
+	return b.approveLoan(ctx, *loan, fmt.Sprintf("approved by %s", payload.ApprovedBy), result)
+}
+
+// RejectLoan turns down an application waiting for an approver.
+func (b BillingService) RejectLoan(ctx context.Context, payload model.RejectLoanPayload) (*model.CreateLoanResponse, error) {
+	b.log.WithField("loan_id", payload.LoanID).
+		WithField("rejected_by", payload.RejectedBy).Info("[RejectLoan] rejecting loan")
+
+	loan, err := b.repo.GetLoanByID(ctx, payload.LoanID)
+	if err != nil {
+		b.log.WithField("loan_id", payload.LoanID).
+			WithField("error", err.Error()).Error("[RejectLoan] Unexpected error when getting loan")
+		return nil, err
+	}
+
+	if loan == nil {
+		b.log.WithField("loan_id", payload.LoanID).Info("[RejectLoan] loan not found")
+		return nil, apperror.New(apperror.NotFound, "loan not found")
+	}
+
+	err = b.changeLoanStatus(ctx, *loan, enum.LoanStatusRejected,
+		fmt.Sprintf("%s, rejected by %s", payload.Reason, payload.RejectedBy))
+	if err != nil {
+		return nil, err
+	}
+
+	loan.Status = enum.LoanStatusRejected
+	return b.mapLoanResponse(*loan, money.Zero(loan.PrincipalAmount.Currency), eligibility.Result{}), nil
+}
+
+// evaluateApplication gathers what the eligibility rules look at and checks the application against them. The
+// exposure is the principal the customer owes or was granted, read from the database; an application that is
+// already stored is part of it, pass stored so its amount is not counted twice.
+func (b BillingService) evaluateApplication(ctx context.Context, customer domain.Customer, product domain.Product, amount money.Money, stored bool) (eligibility.Result, error) {
+	applicant := eligibility.Applicant{
+		Customer: customer,
+		Product:  product,
+		Amount:   amount,
+		Exposure: money.Zero(amount.Currency),
+	}
+
+	if b.eligibility.Needs(eligibility.RuleMaxExposure) {
+		total, err := b.repo.GetOutstandingPrincipal(ctx, customer.CustomerID, amount.Currency)
+		if err != nil {
+			b.log.WithField("customer_id", customer.CustomerID).
+				WithField("error", err.Error()).Error("[evaluateApplication] Unexpected error when getting outstanding principal")
+			return eligibility.Result{}, err
+		}
+
+		if stored {
+			total -= amount.Amount
+		}
+
+		applicant.Exposure = money.New(total, amount.Currency)
+	}
+
+	if b.eligibility.Needs(eligibility.RuleNoDelinquency) {
+		delinquent, err := b.IsCustomerDelinquency(ctx, customer.CustomerID)
+		if err != nil {
+			return eligibility.Result{}, err
+		}
+
+		applicant.Delinquent = delinquent.IsDelinquent
+	}
+
+	return b.eligibility.Evaluate(applicant, time.Now()), nil
+}
+
+// loanFromProduct builds a loan on the current terms of the product, they stay with the loan when the product
+// changes later.
+func loanFromProduct(customerID uuid.UUID, product domain.Product, amount money.Money, status enum.LoanStatus, now time.Time) domain.Loan {
+	return domain.Loan{
+		CustomerID:       customerID,
+		ProductID:        product.ProductID,
+		PrincipalAmount:  amount,
+		InterestRate:     product.InterestRate,
+		Tenor:            product.Tenor,
+		InstallmentCount: product.InstallmentCount,
+		AdminFee:         product.AdminFee,
+		Frequency:        product.Frequency,
+		IntervalDays:     product.IntervalDays,
+		Status:           status,
+		StatusChangedAt:  now,
+
+		AmortizationMethod: product.AmortizationMethod,
+
+		LateFee:          product.LateFee,
+		DailyPenaltyRate: product.DailyPenaltyRate,
+		PenaltyCap:       product.PenaltyCap,
+
+		EarlySettlementFeeRate: product.EarlySettlementFeeRate,
+	}
+}
+
+// approveLoan approves the application, the loan waits for its disbursement from then on.
+func (b BillingService) approveLoan(ctx context.Context, loan domain.Loan, reason string, result eligibility.Result) (*model.CreateLoanResponse, error) {
+	err := b.changeLoanStatus(ctx, loan, enum.LoanStatusApproved, reason)
+	if err != nil {
+		return nil, err
+	}
+
+	loan.Status = enum.LoanStatusApproved
+	return b.mapLoanResponse(loan, money.Zero(loan.PrincipalAmount.Currency), result), nil
+}
+
+func (b BillingService) mapLoanResponse(loan domain.Loan, totalLoan money.Money, result eligibility.Result) *model.CreateLoanResponse {
+	var checks []model.EligibilityCheckResponse
+	for _, check := range result.Checks {
+		checks = append(checks, model.EligibilityCheckResponse{
+			Rule:   check.Rule.Name,
+			Type:   string(check.Rule.Type),
+			Passed: check.Passed,
+			Reason: check.Reason,
+		})
+	}
+
+	return &model.CreateLoanResponse{
+		LoanID:      loan.LoanID,
+		CustomerID:  loan.CustomerID,
+		LoanAmount:  totalLoan,
+		Status:      loan.Status,
+		Schedules:   b.MapScheduleResponse(loan.Schedules),
+		Eligibility: checks,
+	}
+}
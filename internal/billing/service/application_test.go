@@ -0,0 +1,175 @@
+package service
+
+import (
+	"billing-engine/internal/billing/domain"
+	"billing-engine/internal/billing/eligibility"
+	"billing-engine/internal/billing/mocks"
+	"billing-engine/internal/billing/model"
+	apperror "billing-engine/pkg/customerror"
+	"billing-engine/pkg/enum"
+	"billing-engine/pkg/producer"
+	"errors"
+	"github.com/google/uuid"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"go.uber.org/mock/gomock"
+	"time"
+)
+
+var _ = Describe("Application", func() {
+	var (
+		svc     *BillingService
+		repo    *mocks.MockBillingRepositoryProvider
+		loan    domain.Loan
+		product domain.Product
+	)
+
+	BeforeEach(func() {
+		svc, repo, _ = newTestService()
+		svc.eligibility = eligibility.Policy{Rules: []eligibility.Rule{
+			{Name: "within-product-range", Type: eligibility.RuleProductAmountRange},
+			{Name: "customer-for-30-days", Type: eligibility.RuleMinRelationshipDays, Threshold: 30},
+		}}
+
+		product = domain.Product{
+			ProductID:    uuid.New(),
+			MinPrincipal: idr(1000000),
+			MaxPrincipal: idr(10000000),
+		}
+		loan = domain.Loan{
+			LoanID:             uuid.New(),
+			CustomerID:         uuid.New(),
+			ProductID:          product.ProductID,
+			PrincipalAmount:    idr(1200000),
+			InterestRate:       0.1,
+			Tenor:              12,
+			InstallmentCount:   12,
+			Frequency:          enum.FrequencyMonthly,
+			AmortizationMethod: enum.AmortizationFlat,
+			Status:             enum.LoanStatusPendingApproval,
+		}
+	})
+
+	Describe("ApproveLoan", func() {
+		payload := func() model.ApproveLoanPayload {
+			return model.ApproveLoanPayload{LoanID: loan.LoanID, ApprovedBy: "credit officer"}
+		}
+
+		It("should approve the application and wait for disbursement", func() {
+			repo.EXPECT().GetLoanByID(ctx, loan.LoanID).Return(&loan, nil)
+			repo.EXPECT().GetCustomerByID(ctx, loan.CustomerID).
+				Return(&domain.Customer{CreatedAt: time.Now().AddDate(0, -2, 0)}, nil)
+			repo.EXPECT().GetProductByID(ctx, loan.ProductID).Return(&product, nil)
+			repo.EXPECT().UpdateLoanStatus(ctx, gomock.Any()).DoAndReturn(func(_ any, history domain.LoanStatusHistory) (bool, error) {
+				Expect(history.ToStatus).To(Equal(enum.LoanStatusApproved))
+				Expect(history.Reason).To(Equal("approved by credit officer"))
+				return true, nil
+			})
+			repo.EXPECT().CreateOutboxMessage(ctx, gomock.Any()).DoAndReturn(func(_ any, message producer.Message) error {
+				Expect(message.EventName).To(Equal(producer.EVENT_NAME_LOAN_STATUS_CHANGED))
+				return nil
+			})
+
+			response, err := svc.ApproveLoan(ctx, payload())
+			Expect(err).To(BeNil())
+			Expect(response.Status).To(Equal(enum.LoanStatusApproved))
+			Expect(response.Schedules).To(BeEmpty())
+		})
+
+		It("when the customer is not eligible anymore", func() {
+			repo.EXPECT().GetLoanByID(ctx, loan.LoanID).Return(&loan, nil)
+			repo.EXPECT().GetCustomerByID(ctx, loan.CustomerID).Return(&domain.Customer{CreatedAt: time.Now()}, nil)
+			repo.EXPECT().GetProductByID(ctx, loan.ProductID).Return(&product, nil)
+
+			_, err := svc.ApproveLoan(ctx, payload())
+
+			var errs *apperror.CustomError
+			Expect(errors.As(err, &errs)).To(BeTrue())
+			Expect(errs.Cause).To(Equal(apperror.InvalidInput))
+		})
+
+		Context("with a max exposure rule", func() {
+			BeforeEach(func() {
+				svc.eligibility = eligibility.Policy{Rules: []eligibility.Rule{
+					{Name: "max-exposure", Type: eligibility.RuleMaxExposure, Threshold: 5000000},
+				}}
+				repo.EXPECT().GetLoanByID(ctx, loan.LoanID).Return(&loan, nil)
+				repo.EXPECT().GetCustomerByID(ctx, loan.CustomerID).Return(&domain.Customer{CustomerID: loan.CustomerID}, nil)
+				repo.EXPECT().GetProductByID(ctx, loan.ProductID).Return(&product, nil)
+			})
+
+			It("should not count the application itself in the committed principal", func() {
+				repo.EXPECT().GetOutstandingPrincipal(ctx, loan.CustomerID, "IDR").Return(int64(5000000), nil)
+				repo.EXPECT().UpdateLoanStatus(ctx, gomock.Any()).Return(true, nil)
+				repo.EXPECT().CreateOutboxMessage(ctx, gomock.Any()).Return(nil)
+
+				response, err := svc.ApproveLoan(ctx, payload())
+				Expect(err).To(BeNil())
+				Expect(response.Status).To(Equal(enum.LoanStatusApproved))
+			})
+
+			It("when the committed principal of the customer exceeds the exposure", func() {
+				repo.EXPECT().GetOutstandingPrincipal(ctx, loan.CustomerID, "IDR").Return(int64(5000001), nil)
+
+				_, err := svc.ApproveLoan(ctx, payload())
+
+				var errs *apperror.CustomError
+				Expect(errors.As(err, &errs)).To(BeTrue())
+				Expect(errs.Cause).To(Equal(apperror.InvalidInput))
+				Expect(errs.Msg).To(ContainSubstring("max-exposure"))
+			})
+		})
+
+		It("when the application was already rejected", func() {
+			loan.Status = enum.LoanStatusRejected
+			repo.EXPECT().GetLoanByID(ctx, loan.LoanID).Return(&loan, nil)
+
+			_, err := svc.ApproveLoan(ctx, payload())
+
+			var errs *apperror.CustomError
+			Expect(errors.As(err, &errs)).To(BeTrue())
+			Expect(errs.Cause).To(Equal(apperror.InvalidInput))
+		})
+
+		It("when loan not found", func() {
+			repo.EXPECT().GetLoanByID(ctx, gomock.Any()).Return(nil, nil)
+
+			_, err := svc.ApproveLoan(ctx, payload())
+
+			var errs *apperror.CustomError
+			Expect(errors.As(err, &errs)).To(BeTrue())
+			Expect(errs.Cause).To(Equal(apperror.NotFound))
+		})
+	})
+
+	Describe("RejectLoan", func() {
+		It("should reject the application", func() {
+			repo.EXPECT().GetLoanByID(ctx, loan.LoanID).Return(&loan, nil)
+			repo.EXPECT().UpdateLoanStatus(ctx, gomock.Any()).DoAndReturn(func(_ any, history domain.LoanStatusHistory) (bool, error) {
+				Expect(history.ToStatus).To(Equal(enum.LoanStatusRejected))
+				Expect(history.Reason).To(Equal("income not verified, rejected by credit officer"))
+				return true, nil
+			})
+			repo.EXPECT().CreateOutboxMessage(ctx, gomock.Any()).Return(nil)
+
+			response, err := svc.RejectLoan(ctx, model.RejectLoanPayload{
+				LoanID:     loan.LoanID,
+				RejectedBy: "credit officer",
+				Reason:     "income not verified",
+			})
+			Expect(err).To(BeNil())
+			Expect(response.Status).To(Equal(enum.LoanStatusRejected))
+		})
+
+		It("when the loan is already active", func() {
+			loan.Status = enum.LoanStatusActive
+			repo.EXPECT().GetLoanByID(ctx, loan.LoanID).Return(&loan, nil)
+
+			_, err := svc.RejectLoan(ctx, model.RejectLoanPayload{LoanID: loan.LoanID, RejectedBy: "credit officer", Reason: "late"})
+
+			var errs *apperror.CustomError
+			Expect(errors.As(err, &errs)).To(BeTrue())
+			Expect(errs.Cause).To(Equal(apperror.InvalidInput))
+		})
+	})
+})
@@ -0,0 +1,215 @@
+package service
+
+import (
+	"billing-engine/internal/billing/constant"
+	"billing-engine/internal/billing/domain"
+	"billing-engine/internal/billing/lifecycle"
+	"billing-engine/internal/billing/model"
+	"billing-engine/internal/billing/repository"
+	apperror "billing-engine/pkg/customerror"
+	"billing-engine/pkg/enum"
+	"billing-engine/pkg/money"
+	"billing-engine/pkg/producer"
+	"context"
+	"fmt"
+	"github.com/google/uuid"
+	"time"
+)
+
+// CancelLoan lets the borrower back out of a loan. An application or an approved loan that was not paid out
+// yet is cancelled as is, a disbursed loan only within the cooling-off period and against the return of the
+// money. The payment service stops taking payments on the loan once it receives LOAN_CANCELLED.
+func (b BillingService) CancelLoan(ctx context.Context, payload model.CancelLoanPayload) (*model.CancelLoanResponse, error) {
+	b.log.WithField("loan_id", payload.LoanID).Info("[CancelLoan] cancelling loan")
+
+	loan, err := b.repo.GetLoanByID(ctx, payload.LoanID)
+	if err != nil {
+		b.log.WithField("loan_id", payload.LoanID).
+			WithField("error", err.Error()).Error("[CancelLoan] Unexpected error when getting loan")
+		return nil, err
+	}
+
+	if loan == nil {
+		b.log.WithField("loan_id", payload.LoanID).Info("[CancelLoan] loan not found")
+		return nil, apperror.New(apperror.NotFound, "loan not found")
+	}
+
+	err = lifecycle.Validate(loan.Status, enum.LoanStatusCancelled)
+	if err != nil {
+		b.log.WithField("loan_id", payload.LoanID).
+			WithField("error", err.Error()).Info("[CancelLoan] loan cannot be cancelled")
+		return nil, apperror.New(apperror.InvalidInput, err.Error())
+	}
+
+	// a disbursed top-up settled the loan it refinanced, backing out of it would leave that balance unpaid. One
+	// that was not paid out yet never touched the old loan and is cancelled as is.
+	if loan.RefinancedLoanID != nil && loan.Status == enum.LoanStatusActive {
+		b.log.WithField("loan_id", payload.LoanID).Info("[CancelLoan] top-up cannot be cancelled")
+		return nil, apperror.New(apperror.InvalidInput,
+			fmt.Sprintf("a disbursed top-up cannot be cancelled, it settled loan %s", *loan.RefinancedLoanID))
+	}
+
+	record, err := b.repo.GetDisbursementByLoanID(ctx, payload.LoanID)
+	if err != nil {
+		b.log.WithField("loan_id", payload.LoanID).
+			WithField("error", err.Error()).Error("[CancelLoan] Unexpected error when getting disbursement")
+		return nil, err
+	}
+
+	totalPaid, err := b.repo.GetTotalPaid(ctx, payload.LoanID)
+	if err != nil {
+		b.log.WithField("loan_id", payload.LoanID).
+			WithField("error", err.Error()).Error("[CancelLoan] Unexpected error when getting total paid")
+		return nil, err
+	}
+
+	now := time.Now()
+	// what was paid beyond the balance sits on the loan as credit, it is refunded with the rest
+	paid := money.New(totalPaid+loan.CreditBalance.Amount, loan.PrincipalAmount.Currency)
+	cancellation, err := planCancellation(*loan, record, paid, now)
+	if err != nil {
+		b.log.WithField("loan_id", payload.LoanID).
+			WithField("error", err.Error()).Info("[CancelLoan] failed to plan cancellation")
+		return nil, err
+	}
+
+	cancellation.Reason = payload.Reason
+	history := domain.LoanStatusHistory{
+		LoanID:     loan.LoanID,
+		FromStatus: loan.Status,
+		ToStatus:   enum.LoanStatusCancelled,
+		Reason:     payload.Reason,
+		ChangedAt:  now,
+	}
+
+	var stored *domain.Cancellation
+	err = b.repo.WithTransaction(ctx, func(repo repository.BillingRepositoryProvider) error {
+		tx := b.withRepo(repo)
+
+		var err error
+		stored, err = tx.repo.CancelLoan(ctx, cancellation, history)
+		if err != nil {
+			b.log.WithField("loan_id", payload.LoanID).
+				WithField("error", err.Error()).Error("[CancelLoan] Unexpected error when cancelling loan")
+			return err
+		}
+
+		if stored == nil {
+			b.log.WithField("loan_id", payload.LoanID).Error("[CancelLoan] loan status changed concurrently")
+			return apperror.New(apperror.InvalidInput, "loan status was changed by another request")
+		}
+
+		err = tx.publishStatusChange(ctx, *loan, history)
+		if err != nil {
+			return err
+		}
+
+		producerMessage := producer.Message{
+			EventID:   uuid.New().String(),
+			EventName: producer.EVENT_NAME_LOAN_CANCELLED,
+			Data: model.LoanCancelledEventPayload{
+				LoanID:         loan.LoanID,
+				CustomerID:     loan.CustomerID,
+				CancellationID: stored.CancellationID,
+				AmountDue:      stored.AmountDue,
+				Refund:         stored.Refund,
+				CancelledAt:    stored.CancelledAt,
+			},
+		}
+
+		err = tx.repo.CreateOutboxMessage(ctx, producerMessage)
+		if err != nil {
+			b.log.WithField("loan_id", payload.LoanID).
+				WithField("error", err.Error()).Error("[CancelLoan] failed to write message to outbox")
+			return err
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = b.flushCache(ctx, loan.CustomerID)
+	if err != nil {
+		b.log.WithField("loan_id", payload.LoanID).
+			WithField("error", err.Error()).Error("[CancelLoan] failed to flush cache")
+		return nil, err
+	}
+
+	b.log.WithField("cancellation_id", stored.CancellationID).Info("[CancelLoan] loan cancelled")
+	return &model.CancelLoanResponse{
+		CancellationID: stored.CancellationID,
+		LoanID:         loan.LoanID,
+		Status:         enum.LoanStatusCancelled,
+		DaysHeld:       stored.DaysHeld,
+		Disbursed:      stored.Disbursed,
+		Paid:           stored.Paid,
+		Fee:            stored.Fee,
+		AmountDue:      stored.AmountDue,
+		Refund:         stored.Refund,
+		CancelledAt:    stored.CancelledAt,
+	}, nil
+}
+
+// planCancellation works out what it takes to cancel the loan. The fee is the interest on the disbursed
+// amount for the days the borrower held it, the admin fee they never received is not charged.
+func planCancellation(loan domain.Loan, record *domain.Disbursement, paid money.Money, now time.Time) (domain.Cancellation, error) {
+	currency := loan.PrincipalAmount.Currency
+	cancellation := domain.Cancellation{
+		LoanID:      loan.LoanID,
+		Disbursed:   money.Zero(currency),
+		Paid:        paid,
+		Fee:         money.Zero(currency),
+		AmountDue:   money.Zero(currency),
+		Refund:      money.Zero(currency),
+		CancelledAt: now,
+	}
+
+	var disbursedAt time.Time
+	switch {
+	case record != nil && record.Status == enum.DisbursementStatusSucceeded && record.DisbursedAt != nil:
+		cancellation.Disbursed = record.Amount
+		disbursedAt = *record.DisbursedAt
+	case loan.Status == enum.LoanStatusActive:
+		// loans activated before disbursements were tracked were paid out on their start date
+		disbursed, err := loan.PrincipalAmount.Sub(loan.AdminFee)
+		if err != nil {
+			return domain.Cancellation{}, err
+		}
+
+		cancellation.Disbursed = disbursed
+		disbursedAt = loan.StartDate
+	default:
+		return cancellation, nil
+	}
+
+	cancellation.DaysHeld = int(now.Sub(disbursedAt).Hours() / 24)
+	if cancellation.DaysHeld > constant.COOLING_OFF_DAYS {
+		return domain.Cancellation{}, apperror.New(apperror.InvalidInput,
+			fmt.Sprintf("loan can only be cancelled within %d days of disbursement, it was disbursed %d days ago",
+				constant.COOLING_OFF_DAYS, cancellation.DaysHeld))
+	}
+
+	cancellation.Fee = cancellation.Disbursed.MulRate(loan.InterestRate * float64(cancellation.DaysHeld) / 365)
+	owed, err := cancellation.Disbursed.Add(cancellation.Fee)
+	if err != nil {
+		return domain.Cancellation{}, err
+	}
+
+	overpaid, err := paid.GreaterThan(owed)
+	if err != nil {
+		return domain.Cancellation{}, err
+	}
+
+	if overpaid {
+		cancellation.Refund, err = paid.Sub(owed)
+	} else {
+		cancellation.AmountDue, err = owed.Sub(paid)
+	}
+	if err != nil {
+		return domain.Cancellation{}, err
+	}
+
+	return cancellation, nil
+}
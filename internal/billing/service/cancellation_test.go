@@ -0,0 +1,189 @@
+package service
+
+import (
+	"billing-engine/internal/billing/domain"
+	"billing-engine/internal/billing/mocks"
+	"billing-engine/internal/billing/model"
+	apperror "billing-engine/pkg/customerror"
+	"billing-engine/pkg/enum"
+	"billing-engine/pkg/producer"
+	"errors"
+	"github.com/google/uuid"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"go.uber.org/mock/gomock"
+	"time"
+)
+
+var _ = Describe("Cancellation", func() {
+	var (
+		svc     *BillingService
+		repo    *mocks.MockBillingRepositoryProvider
+		cache   *mocks.MockBillingCacheProvider
+		loan    domain.Loan
+		record  domain.Disbursement
+		payload model.CancelLoanPayload
+	)
+
+	BeforeEach(func() {
+		svc, repo, cache = newTestService()
+
+		disbursedAt := time.Now().AddDate(0, 0, -10)
+		loan = domain.Loan{
+			LoanID:          uuid.New(),
+			CustomerID:      uuid.New(),
+			PrincipalAmount: idr(1000000),
+			AdminFee:        idr(50000),
+			InterestRate:    0.365,
+			StartDate:       disbursedAt,
+			Status:          enum.LoanStatusActive,
+		}
+		record = domain.Disbursement{
+			DisbursementID: uuid.New(),
+			LoanID:         loan.LoanID,
+			Amount:         idr(950000),
+			Status:         enum.DisbursementStatusSucceeded,
+			DisbursedAt:    &disbursedAt,
+		}
+		payload = model.CancelLoanPayload{LoanID: loan.LoanID, Reason: "changed my mind"}
+	})
+
+	Describe("CancelLoan", func() {
+		It("should charge the interest for the days the money was held", func() {
+			repo.EXPECT().GetLoanByID(ctx, loan.LoanID).Return(&loan, nil)
+			repo.EXPECT().GetDisbursementByLoanID(ctx, loan.LoanID).Return(&record, nil)
+			repo.EXPECT().GetTotalPaid(ctx, loan.LoanID).Return(int64(0), nil)
+			repo.EXPECT().CancelLoan(ctx, gomock.Any(), gomock.Any()).
+				DoAndReturn(func(_ any, cancellation domain.Cancellation, history domain.LoanStatusHistory) (*domain.Cancellation, error) {
+					Expect(history.FromStatus).To(Equal(enum.LoanStatusActive))
+					Expect(history.ToStatus).To(Equal(enum.LoanStatusCancelled))
+					Expect(cancellation.Reason).To(Equal(payload.Reason))
+					cancellation.CancellationID = uuid.New()
+					return &cancellation, nil
+				})
+			var events []string
+			repo.EXPECT().CreateOutboxMessage(ctx, gomock.Any()).DoAndReturn(func(_ any, message producer.Message) error {
+				events = append(events, message.EventName)
+				return nil
+			}).Times(2)
+			cache.EXPECT().Get(ctx, gomock.Any()).Return(nil, nil).Times(2)
+
+			response, err := svc.CancelLoan(ctx, payload)
+			Expect(err).To(BeNil())
+			Expect(response.Status).To(Equal(enum.LoanStatusCancelled))
+			Expect(response.DaysHeld).To(Equal(10))
+			Expect(response.Disbursed).To(Equal(idr(950000)))
+			Expect(response.Fee).To(Equal(idr(9500)))
+			Expect(response.AmountDue).To(Equal(idr(959500)))
+			Expect(response.Refund.IsZero()).To(BeTrue())
+			Expect(events).To(Equal([]string{
+				producer.EVENT_NAME_LOAN_STATUS_CHANGED,
+				producer.EVENT_NAME_LOAN_CANCELLED,
+			}))
+		})
+
+		It("should refund the credit of the loan with what was paid", func() {
+			loan.CreditBalance = idr(1000000)
+			repo.EXPECT().GetLoanByID(ctx, loan.LoanID).Return(&loan, nil)
+			repo.EXPECT().GetDisbursementByLoanID(ctx, loan.LoanID).Return(&record, nil)
+			repo.EXPECT().GetTotalPaid(ctx, loan.LoanID).Return(int64(0), nil)
+			repo.EXPECT().CancelLoan(ctx, gomock.Any(), gomock.Any()).
+				DoAndReturn(func(_ any, cancellation domain.Cancellation, _ domain.LoanStatusHistory) (*domain.Cancellation, error) {
+					return &cancellation, nil
+				})
+			repo.EXPECT().CreateOutboxMessage(ctx, gomock.Any()).Return(nil).Times(2)
+			cache.EXPECT().Get(ctx, gomock.Any()).Return(nil, nil).Times(2)
+
+			response, err := svc.CancelLoan(ctx, payload)
+			Expect(err).To(BeNil())
+			Expect(response.Paid).To(Equal(idr(1000000)))
+			Expect(response.AmountDue.IsZero()).To(BeTrue())
+			Expect(response.Refund).To(Equal(idr(40500)))
+		})
+
+		It("should cancel an approved loan that was not disbursed for free", func() {
+			loan.Status = enum.LoanStatusApproved
+			repo.EXPECT().GetLoanByID(ctx, loan.LoanID).Return(&loan, nil)
+			repo.EXPECT().GetDisbursementByLoanID(ctx, loan.LoanID).Return(nil, nil)
+			repo.EXPECT().GetTotalPaid(ctx, loan.LoanID).Return(int64(0), nil)
+			repo.EXPECT().CancelLoan(ctx, gomock.Any(), gomock.Any()).
+				DoAndReturn(func(_ any, cancellation domain.Cancellation, _ domain.LoanStatusHistory) (*domain.Cancellation, error) {
+					return &cancellation, nil
+				})
+			repo.EXPECT().CreateOutboxMessage(ctx, gomock.Any()).Return(nil).Times(2)
+			cache.EXPECT().Get(ctx, gomock.Any()).Return(nil, nil).Times(2)
+
+			response, err := svc.CancelLoan(ctx, payload)
+			Expect(err).To(BeNil())
+			Expect(response.AmountDue.IsZero()).To(BeTrue())
+			Expect(response.Fee.IsZero()).To(BeTrue())
+		})
+
+		It("should cancel a top-up that was not disbursed without touching the old loan", func() {
+			refinancedID := uuid.New()
+			loan.Status = enum.LoanStatusApproved
+			loan.RefinancedLoanID = &refinancedID
+			repo.EXPECT().GetLoanByID(ctx, loan.LoanID).Return(&loan, nil)
+			repo.EXPECT().GetDisbursementByLoanID(ctx, loan.LoanID).Return(nil, nil)
+			repo.EXPECT().GetTotalPaid(ctx, loan.LoanID).Return(int64(0), nil)
+			repo.EXPECT().CancelLoan(ctx, gomock.Any(), gomock.Any()).
+				DoAndReturn(func(_ any, cancellation domain.Cancellation, history domain.LoanStatusHistory) (*domain.Cancellation, error) {
+					Expect(history.LoanID).To(Equal(loan.LoanID))
+					return &cancellation, nil
+				})
+			repo.EXPECT().CreateOutboxMessage(ctx, gomock.Any()).Return(nil).Times(2)
+			cache.EXPECT().Get(ctx, gomock.Any()).Return(nil, nil).Times(2)
+
+			response, err := svc.CancelLoan(ctx, payload)
+			Expect(err).To(BeNil())
+			Expect(response.Status).To(Equal(enum.LoanStatusCancelled))
+		})
+
+		It("when the top-up was disbursed", func() {
+			refinancedID := uuid.New()
+			loan.RefinancedLoanID = &refinancedID
+			repo.EXPECT().GetLoanByID(ctx, loan.LoanID).Return(&loan, nil)
+
+			_, err := svc.CancelLoan(ctx, payload)
+
+			var errs *apperror.CustomError
+			Expect(errors.As(err, &errs)).To(BeTrue())
+			Expect(errs.Cause).To(Equal(apperror.InvalidInput))
+		})
+
+		It("when the cooling-off period is over", func() {
+			disbursedAt := time.Now().AddDate(0, 0, -20)
+			record.DisbursedAt = &disbursedAt
+			repo.EXPECT().GetLoanByID(ctx, loan.LoanID).Return(&loan, nil)
+			repo.EXPECT().GetDisbursementByLoanID(ctx, loan.LoanID).Return(&record, nil)
+			repo.EXPECT().GetTotalPaid(ctx, loan.LoanID).Return(int64(0), nil)
+
+			_, err := svc.CancelLoan(ctx, payload)
+
+			var errs *apperror.CustomError
+			Expect(errors.As(err, &errs)).To(BeTrue())
+			Expect(errs.Cause).To(Equal(apperror.InvalidInput))
+		})
+
+		It("when the loan is already paid off", func() {
+			loan.Status = enum.LoanStatusPaidOff
+			repo.EXPECT().GetLoanByID(ctx, loan.LoanID).Return(&loan, nil)
+
+			_, err := svc.CancelLoan(ctx, payload)
+
+			var errs *apperror.CustomError
+			Expect(errors.As(err, &errs)).To(BeTrue())
+			Expect(errs.Cause).To(Equal(apperror.InvalidInput))
+		})
+	})
+
+	Describe("planCancellation", func() {
+		It("should refund what was paid above the amount owed", func() {
+			now := time.Now()
+			cancellation, err := planCancellation(loan, &record, idr(1000000), now)
+			Expect(err).To(BeNil())
+			Expect(cancellation.AmountDue.IsZero()).To(BeTrue())
+			Expect(cancellation.Refund).To(Equal(idr(40500)))
+		})
+	})
+})
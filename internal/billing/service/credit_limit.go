@@ -0,0 +1,131 @@
+package service
+
+import (
+	"billing-engine/internal/billing/constant"
+	"billing-engine/internal/billing/domain"
+	"billing-engine/internal/billing/model"
+	apperror "billing-engine/pkg/customerror"
+	"billing-engine/pkg/money"
+	"context"
+	"fmt"
+	"github.com/google/uuid"
+)
+
+// GetCreditLimit returns the credit limit of the customer and how much of it is still free for a new loan.
+func (b BillingService) GetCreditLimit(ctx context.Context, customerID uuid.UUID) (*model.CreditLimitResponse, error) {
+	b.log.WithField("customer_id", customerID).Info("[GetCreditLimit] getting credit limit for customer")
+
+	customer, err := b.getCustomer(ctx, customerID)
+	if err != nil {
+		return nil, err
+	}
+
+	return b.creditLimitResponse(ctx, *customer)
+}
+
+// UpdateCreditLimit sets the credit limit of the customer. A limit below what the customer already owes is
+// accepted, it only stops new loans until the balance comes down.
+func (b BillingService) UpdateCreditLimit(ctx context.Context, payload model.UpdateCreditLimitPayload) (*model.CreditLimitResponse, error) {
+	b.log.WithField("customer_id", payload.CustomerID).
+		WithField("credit_limit", payload.CreditLimit).Info("[UpdateCreditLimit] updating credit limit")
+
+	limit := money.New(payload.CreditLimit.Amount, payload.CreditLimit.Currency)
+	if !money.IsSupported(limit.Currency) {
+		return nil, apperror.New(apperror.InvalidInput, fmt.Sprintf("currency %q is not supported", limit.Currency))
+	}
+
+	if limit.IsNegative() {
+		return nil, apperror.New(apperror.InvalidInput, "credit limit must not be negative")
+	}
+
+	customer, err := b.getCustomer(ctx, payload.CustomerID)
+	if err != nil {
+		return nil, err
+	}
+
+	err = b.repo.UpdateCreditLimit(ctx, payload.CustomerID, limit)
+	if err != nil {
+		b.log.WithField("customer_id", payload.CustomerID).
+			WithField("error", err.Error()).Error("[UpdateCreditLimit] Unexpected error when updating credit limit")
+		return nil, err
+	}
+
+	customer.CreditLimit = limit
+	return b.creditLimitResponse(ctx, *customer)
+}
+
+// checkCreditLimit refuses a loan that would take the principal the customer owes over their credit limit.
+func (b BillingService) checkCreditLimit(ctx context.Context, customer domain.Customer, amount money.Money) error {
+	limit, err := b.creditLimitResponse(ctx, customer)
+	if err != nil {
+		return err
+	}
+
+	if amount.Currency != limit.CreditLimit.Currency {
+		return apperror.New(apperror.InvalidInput,
+			fmt.Sprintf("credit limit of the customer is in %s", limit.CreditLimit.Currency))
+	}
+
+	exceeds, err := amount.GreaterThan(limit.Headroom)
+	if err != nil {
+		return err
+	}
+
+	if exceeds {
+		b.log.WithField("customer_id", customer.CustomerID).
+			WithField("amount", amount).
+			WithField("headroom", limit.Headroom).Info("[checkCreditLimit] credit limit exceeded")
+		return apperror.New(apperror.LimitExceeded,
+			fmt.Sprintf("loan of %s exceeds the remaining credit limit of %s", amount, limit.Headroom))
+	}
+
+	return nil
+}
+
+func (b BillingService) creditLimitResponse(ctx context.Context, customer domain.Customer) (*model.CreditLimitResponse, error) {
+	limit := creditLimit(customer)
+	total, err := b.repo.GetOutstandingPrincipal(ctx, customer.CustomerID, limit.Currency)
+	if err != nil {
+		b.log.WithField("customer_id", customer.CustomerID).
+			WithField("error", err.Error()).Error("[creditLimitResponse] Unexpected error when getting outstanding principal")
+		return nil, err
+	}
+
+	outstanding := money.New(total, limit.Currency)
+	headroom := money.Zero(limit.Currency)
+	if total < limit.Amount {
+		headroom = money.New(limit.Amount-total, limit.Currency)
+	}
+
+	return &model.CreditLimitResponse{
+		CustomerID:           customer.CustomerID,
+		CreditLimit:          limit,
+		OutstandingPrincipal: outstanding,
+		Headroom:             headroom,
+	}, nil
+}
+
+func (b BillingService) getCustomer(ctx context.Context, customerID uuid.UUID) (*domain.Customer, error) {
+	customer, err := b.repo.GetCustomerByID(ctx, customerID)
+	if err != nil {
+		b.log.WithField("customer_id", customerID).
+			WithField("error", err.Error()).Error("[getCustomer] Unexpected error when getting customer")
+		return nil, err
+	}
+
+	if customer == nil {
+		b.log.WithField("customer_id", customerID).Info("[getCustomer] customer not found")
+		return nil, apperror.New(apperror.NotFound, "customer not found")
+	}
+
+	return customer, nil
+}
+
+// creditLimit returns the limit of the customer, or the default one when they were never given a limit.
+func creditLimit(customer domain.Customer) money.Money {
+	if customer.CreditLimit.Currency == "" {
+		return money.New(constant.DEFAULT_CREDIT_LIMIT, money.DefaultCurrency)
+	}
+
+	return customer.CreditLimit
+}
@@ -0,0 +1,93 @@
+package service
+
+import (
+	"billing-engine/internal/billing/constant"
+	"billing-engine/internal/billing/domain"
+	"billing-engine/internal/billing/mocks"
+	"billing-engine/internal/billing/model"
+	apperror "billing-engine/pkg/customerror"
+	"billing-engine/pkg/money"
+	"errors"
+	"github.com/google/uuid"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("CreditLimit", func() {
+	var (
+		svc      *BillingService
+		repo     *mocks.MockBillingRepositoryProvider
+		customer domain.Customer
+	)
+
+	BeforeEach(func() {
+		svc, repo, _ = newTestService()
+
+		customer = domain.Customer{CustomerID: uuid.New(), CreditLimit: idr(10000000)}
+	})
+
+	Describe("GetCreditLimit", func() {
+		It("should return the headroom left under the limit", func() {
+			repo.EXPECT().GetCustomerByID(ctx, customer.CustomerID).Return(&customer, nil)
+			repo.EXPECT().GetOutstandingPrincipal(ctx, customer.CustomerID, "IDR").Return(int64(4000000), nil)
+
+			response, err := svc.GetCreditLimit(ctx, customer.CustomerID)
+			Expect(err).To(BeNil())
+			Expect(response.CreditLimit).To(Equal(idr(10000000)))
+			Expect(response.OutstandingPrincipal).To(Equal(idr(4000000)))
+			Expect(response.Headroom).To(Equal(idr(6000000)))
+		})
+
+		It("should fall back to the default limit", func() {
+			customer.CreditLimit = money.Money{}
+			repo.EXPECT().GetCustomerByID(ctx, customer.CustomerID).Return(&customer, nil)
+			repo.EXPECT().GetOutstandingPrincipal(ctx, customer.CustomerID, money.DefaultCurrency).Return(int64(0), nil)
+
+			response, err := svc.GetCreditLimit(ctx, customer.CustomerID)
+			Expect(err).To(BeNil())
+			Expect(response.CreditLimit).To(Equal(money.New(constant.DEFAULT_CREDIT_LIMIT, money.DefaultCurrency)))
+		})
+
+		It("should not give a negative headroom when the customer owes more than the limit", func() {
+			repo.EXPECT().GetCustomerByID(ctx, customer.CustomerID).Return(&customer, nil)
+			repo.EXPECT().GetOutstandingPrincipal(ctx, customer.CustomerID, "IDR").Return(int64(12000000), nil)
+
+			response, err := svc.GetCreditLimit(ctx, customer.CustomerID)
+			Expect(err).To(BeNil())
+			Expect(response.Headroom.IsZero()).To(BeTrue())
+		})
+
+		It("when customer not found", func() {
+			repo.EXPECT().GetCustomerByID(ctx, customer.CustomerID).Return(nil, nil)
+
+			_, err := svc.GetCreditLimit(ctx, customer.CustomerID)
+
+			var errs *apperror.CustomError
+			Expect(errors.As(err, &errs)).To(BeTrue())
+			Expect(errs.Cause).To(Equal(apperror.NotFound))
+		})
+	})
+
+	Describe("UpdateCreditLimit", func() {
+		It("should store the new limit", func() {
+			payload := model.UpdateCreditLimitPayload{CustomerID: customer.CustomerID, CreditLimit: money.New(20000000, "idr")}
+			repo.EXPECT().GetCustomerByID(ctx, customer.CustomerID).Return(&customer, nil)
+			repo.EXPECT().UpdateCreditLimit(ctx, customer.CustomerID, idr(20000000)).Return(nil)
+			repo.EXPECT().GetOutstandingPrincipal(ctx, customer.CustomerID, "IDR").Return(int64(4000000), nil)
+
+			response, err := svc.UpdateCreditLimit(ctx, payload)
+			Expect(err).To(BeNil())
+			Expect(response.Headroom).To(Equal(idr(16000000)))
+		})
+
+		It("when the limit is negative", func() {
+			payload := model.UpdateCreditLimitPayload{CustomerID: customer.CustomerID, CreditLimit: idr(-1)}
+
+			_, err := svc.UpdateCreditLimit(ctx, payload)
+
+			var errs *apperror.CustomError
+			Expect(errors.As(err, &errs)).To(BeTrue())
+			Expect(errs.Cause).To(Equal(apperror.InvalidInput))
+		})
+	})
+})
@@ -0,0 +1,189 @@
+package service
+
+import (
+	"billing-engine/internal/billing/constant"
+	"billing-engine/internal/billing/domain"
+	"billing-engine/internal/billing/model"
+	"billing-engine/internal/billing/repository"
+	apperror "billing-engine/pkg/customerror"
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"strings"
+	"time"
+)
+
+func (b BillingService) CreateCustomer(ctx context.Context, payload model.CustomerPayload) (*model.GetCustomerResponse, error) {
+	payload = normalizeCustomerPayload(payload)
+	b.log.WithField("email", payload.Email).Info("[CreateCustomer] creating customer")
+
+	err := b.checkEmailAvailable(ctx, payload.Email, uuid.Nil)
+	if err != nil {
+		return nil, err
+	}
+
+	customer := domain.Customer{}
+	applyCustomerPayload(&customer, payload)
+	created, err := b.repo.CreateCustomer(ctx, customer)
+	if errors.Is(err, repository.ErrDuplicateEmail) {
+		b.log.WithField("email", payload.Email).Info("[CreateCustomer] email is already registered")
+		return nil, apperror.New(apperror.AlreadyExists, "email is already registered")
+	} else if err != nil {
+		b.log.WithField("email", payload.Email).
+			WithField("error", err.Error()).Error("[CreateCustomer] Unexpected error when creating customer")
+		return nil, err
+	}
+
+	b.log.WithField("customer_id", created.CustomerID).Info("[CreateCustomer] customer created successfully")
+	return customerResponse(*created), nil
+}
+
+func (b BillingService) UpdateCustomer(ctx context.Context, payload model.UpdateCustomerPayload) (*model.GetCustomerResponse, error) {
+	payload.CustomerPayload = normalizeCustomerPayload(payload.CustomerPayload)
+	b.log.WithField("customer_id", payload.CustomerID).Info("[UpdateCustomer] updating customer")
+
+	customer, err := b.getCustomer(ctx, payload.CustomerID)
+	if err != nil {
+		return nil, err
+	}
+
+	err = b.checkEmailAvailable(ctx, payload.Email, payload.CustomerID)
+	if err != nil {
+		return nil, err
+	}
+
+	applyCustomerPayload(customer, payload.CustomerPayload)
+	err = b.repo.UpdateCustomer(ctx, *customer)
+	if errors.Is(err, repository.ErrDuplicateEmail) {
+		b.log.WithField("customer_id", payload.CustomerID).Info("[UpdateCustomer] email is already registered")
+		return nil, apperror.New(apperror.AlreadyExists, "email is already registered")
+	} else if err != nil {
+		b.log.WithField("customer_id", payload.CustomerID).
+			WithField("error", err.Error()).Error("[UpdateCustomer] Unexpected error when updating customer")
+		return nil, err
+	}
+
+	b.log.WithField("customer_id", payload.CustomerID).Info("[UpdateCustomer] customer updated successfully")
+	return customerResponse(*customer), nil
+}
+
+func (b BillingService) GetCustomer(ctx context.Context, customerID uuid.UUID) (*model.GetCustomerResponse, error) {
+	customer, err := b.getCustomer(ctx, customerID)
+	if err != nil {
+		return nil, err
+	}
+
+	return customerResponse(*customer), nil
+}
+
+func (b BillingService) SearchCustomers(ctx context.Context, payload model.SearchCustomersPayload) (*model.SearchCustomersResponse, error) {
+	if payload.Page == 0 {
+		payload.Page = 1
+	}
+
+	if payload.PageSize == 0 {
+		payload.PageSize = constant.DEFAULT_CUSTOMER_PAGE_SIZE
+	}
+
+	filter := repository.CustomerFilter{
+		Name:            strings.TrimSpace(payload.Name),
+		Email:           strings.TrimSpace(payload.Email),
+		PhoneNumber:     strings.TrimSpace(payload.PhoneNumber),
+		IncludeInactive: payload.IncludeInactive,
+		Limit:           payload.PageSize,
+		Offset:          (payload.Page - 1) * payload.PageSize,
+	}
+
+	customers, total, err := b.repo.SearchCustomers(ctx, filter)
+	if err != nil {
+		b.log.WithField("payload", payload).
+			WithField("error", err.Error()).Error("[SearchCustomers] Unexpected error when searching customers")
+		return nil, err
+	}
+
+	resp := model.SearchCustomersResponse{
+		Customers: make([]model.GetCustomerResponse, 0, len(customers)),
+		Page:      payload.Page,
+		PageSize:  payload.PageSize,
+		Total:     total,
+	}
+	for _, customer := range customers {
+		resp.Customers = append(resp.Customers, *customerResponse(customer))
+	}
+
+	return &resp, nil
+}
+
+// DeactivateCustomer stops the customer from taking new loans. Their current loans are not touched and are
+// repaid as usual, deactivating a customer twice keeps the first date.
+func (b BillingService) DeactivateCustomer(ctx context.Context, customerID uuid.UUID) (*model.GetCustomerResponse, error) {
+	b.log.WithField("customer_id", customerID).Info("[DeactivateCustomer] deactivating customer")
+
+	customer, err := b.getCustomer(ctx, customerID)
+	if err != nil {
+		return nil, err
+	}
+
+	if customer.DeactivatedAt != nil {
+		return customerResponse(*customer), nil
+	}
+
+	now := time.Now()
+	err = b.repo.DeactivateCustomer(ctx, customerID, now)
+	if err != nil {
+		b.log.WithField("customer_id", customerID).
+			WithField("error", err.Error()).Error("[DeactivateCustomer] Unexpected error when deactivating customer")
+		return nil, err
+	}
+
+	customer.DeactivatedAt = &now
+	b.log.WithField("customer_id", customerID).Info("[DeactivateCustomer] customer deactivated successfully")
+	return customerResponse(*customer), nil
+}
+
+// checkEmailAvailable refuses an email that belongs to another customer than the one given. The unique index
+// catches the same, but only for an exact match and without telling which field clashed.
+func (b BillingService) checkEmailAvailable(ctx context.Context, email string, customerID uuid.UUID) error {
+	existing, err := b.repo.GetCustomerByEmail(ctx, email)
+	if err != nil {
+		b.log.WithField("email", email).
+			WithField("error", err.Error()).Error("[checkEmailAvailable] Unexpected error when getting customer by email")
+		return err
+	}
+
+	if existing != nil && existing.CustomerID != customerID {
+		b.log.WithField("email", email).Info("[checkEmailAvailable] email is already registered")
+		return apperror.New(apperror.AlreadyExists, "email is already registered")
+	}
+
+	return nil
+}
+
+func normalizeCustomerPayload(payload model.CustomerPayload) model.CustomerPayload {
+	payload.FirstName = strings.TrimSpace(payload.FirstName)
+	payload.LastName = strings.TrimSpace(payload.LastName)
+	payload.Email = strings.ToLower(strings.TrimSpace(payload.Email))
+	payload.PhoneNumber = strings.TrimSpace(payload.PhoneNumber)
+	return payload
+}
+
+func applyCustomerPayload(customer *domain.Customer, payload model.CustomerPayload) {
+	customer.FirstName = payload.FirstName
+	customer.LastName = payload.LastName
+	customer.Email = payload.Email
+	customer.PhoneNumber = payload.PhoneNumber
+}
+
+func customerResponse(customer domain.Customer) *model.GetCustomerResponse {
+	return &model.GetCustomerResponse{
+		CustomerID:    customer.CustomerID,
+		FirstName:     customer.FirstName,
+		LastName:      customer.LastName,
+		Email:         customer.Email,
+		PhoneNumber:   customer.PhoneNumber,
+		CreditLimit:   creditLimit(customer),
+		Active:        customer.DeactivatedAt == nil,
+		DeactivatedAt: customer.DeactivatedAt,
+		CreatedAt:     customer.CreatedAt,
+	}
+}
@@ -0,0 +1,179 @@
+package service
+
+import (
+	"billing-engine/internal/billing/domain"
+	"billing-engine/internal/billing/mocks"
+	"billing-engine/internal/billing/model"
+	"billing-engine/internal/billing/repository"
+	apperror "billing-engine/pkg/customerror"
+	"errors"
+	"github.com/google/uuid"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"go.uber.org/mock/gomock"
+	"time"
+)
+
+var _ = Describe("Customer", func() {
+	var (
+		svc      *BillingService
+		repo     *mocks.MockBillingRepositoryProvider
+		customer domain.Customer
+		payload  model.CustomerPayload
+	)
+
+	BeforeEach(func() {
+		svc, repo, _ = newTestService()
+
+		customer = domain.Customer{
+			CustomerID: uuid.New(),
+			FirstName:  "Siti",
+			LastName:   "Rahayu",
+			Email:      "siti@example.com",
+		}
+		payload = model.CustomerPayload{
+			FirstName:   " Siti ",
+			LastName:    "Rahayu",
+			Email:       " Siti@Example.com",
+			PhoneNumber: "+6281234567890",
+		}
+	})
+
+	Describe("CreateCustomer", func() {
+		It("should store the customer with a normalized email", func() {
+			repo.EXPECT().GetCustomerByEmail(ctx, "siti@example.com").Return(nil, nil)
+			repo.EXPECT().CreateCustomer(ctx, gomock.Any()).DoAndReturn(func(_ any, created domain.Customer) (*domain.Customer, error) {
+				Expect(created.FirstName).To(Equal("Siti"))
+				Expect(created.Email).To(Equal("siti@example.com"))
+				Expect(created.PhoneNumber).To(Equal(payload.PhoneNumber))
+				created.CustomerID = uuid.New()
+				return &created, nil
+			})
+
+			response, err := svc.CreateCustomer(ctx, payload)
+			Expect(err).To(BeNil())
+			Expect(response.Active).To(BeTrue())
+			Expect(response.CreditLimit).To(Equal(creditLimit(domain.Customer{})))
+		})
+
+		It("when the email is already registered", func() {
+			repo.EXPECT().GetCustomerByEmail(ctx, "siti@example.com").Return(&customer, nil)
+
+			_, err := svc.CreateCustomer(ctx, payload)
+
+			var errs *apperror.CustomError
+			Expect(errors.As(err, &errs)).To(BeTrue())
+			Expect(errs.Cause).To(Equal(apperror.AlreadyExists))
+		})
+
+		It("when the email is registered concurrently", func() {
+			repo.EXPECT().GetCustomerByEmail(ctx, "siti@example.com").Return(nil, nil)
+			repo.EXPECT().CreateCustomer(ctx, gomock.Any()).Return(nil, repository.ErrDuplicateEmail)
+
+			_, err := svc.CreateCustomer(ctx, payload)
+
+			var errs *apperror.CustomError
+			Expect(errors.As(err, &errs)).To(BeTrue())
+			Expect(errs.Cause).To(Equal(apperror.AlreadyExists))
+		})
+	})
+
+	Describe("UpdateCustomer", func() {
+		It("should keep the email of the customer itself", func() {
+			repo.EXPECT().GetCustomerByID(ctx, customer.CustomerID).Return(&customer, nil)
+			repo.EXPECT().GetCustomerByEmail(ctx, "siti@example.com").Return(&customer, nil)
+			repo.EXPECT().UpdateCustomer(ctx, gomock.Any()).DoAndReturn(func(_ any, updated domain.Customer) error {
+				Expect(updated.CustomerID).To(Equal(customer.CustomerID))
+				Expect(updated.PhoneNumber).To(Equal(payload.PhoneNumber))
+				return nil
+			})
+
+			response, err := svc.UpdateCustomer(ctx, model.UpdateCustomerPayload{CustomerID: customer.CustomerID, CustomerPayload: payload})
+			Expect(err).To(BeNil())
+			Expect(response.PhoneNumber).To(Equal(payload.PhoneNumber))
+		})
+
+		It("when the email belongs to another customer", func() {
+			other := domain.Customer{CustomerID: uuid.New(), Email: "siti@example.com"}
+			repo.EXPECT().GetCustomerByID(ctx, customer.CustomerID).Return(&customer, nil)
+			repo.EXPECT().GetCustomerByEmail(ctx, "siti@example.com").Return(&other, nil)
+
+			_, err := svc.UpdateCustomer(ctx, model.UpdateCustomerPayload{CustomerID: customer.CustomerID, CustomerPayload: payload})
+
+			var errs *apperror.CustomError
+			Expect(errors.As(err, &errs)).To(BeTrue())
+			Expect(errs.Cause).To(Equal(apperror.AlreadyExists))
+		})
+
+		It("when the customer does not exist", func() {
+			repo.EXPECT().GetCustomerByID(ctx, customer.CustomerID).Return(nil, nil)
+
+			_, err := svc.UpdateCustomer(ctx, model.UpdateCustomerPayload{CustomerID: customer.CustomerID, CustomerPayload: payload})
+
+			var errs *apperror.CustomError
+			Expect(errors.As(err, &errs)).To(BeTrue())
+			Expect(errs.Cause).To(Equal(apperror.NotFound))
+		})
+	})
+
+	Describe("SearchCustomers", func() {
+		It("should turn the page into an offset", func() {
+			repo.EXPECT().SearchCustomers(ctx, repository.CustomerFilter{Name: "siti", Limit: 10, Offset: 20}).
+				Return([]domain.Customer{customer}, int64(21), nil)
+
+			response, err := svc.SearchCustomers(ctx, model.SearchCustomersPayload{Name: " siti ", Page: 3, PageSize: 10})
+			Expect(err).To(BeNil())
+			Expect(response.Customers).To(HaveLen(1))
+			Expect(response.Total).To(Equal(int64(21)))
+		})
+
+		It("should default to the first page", func() {
+			repo.EXPECT().SearchCustomers(ctx, gomock.Any()).DoAndReturn(func(_ any, filter repository.CustomerFilter) ([]domain.Customer, int64, error) {
+				Expect(filter.Offset).To(Equal(0))
+				Expect(filter.Limit).To(BeNumerically(">", 0))
+				return nil, 0, nil
+			})
+
+			response, err := svc.SearchCustomers(ctx, model.SearchCustomersPayload{})
+			Expect(err).To(BeNil())
+			Expect(response.Page).To(Equal(1))
+			Expect(response.Customers).To(BeEmpty())
+		})
+	})
+
+	Describe("DeactivateCustomer", func() {
+		It("should deactivate an active customer", func() {
+			repo.EXPECT().GetCustomerByID(ctx, customer.CustomerID).Return(&customer, nil)
+			repo.EXPECT().DeactivateCustomer(ctx, customer.CustomerID, gomock.Any()).Return(nil)
+
+			response, err := svc.DeactivateCustomer(ctx, customer.CustomerID)
+			Expect(err).To(BeNil())
+			Expect(response.Active).To(BeFalse())
+			Expect(response.DeactivatedAt).NotTo(BeNil())
+		})
+
+		It("should keep the first deactivation date", func() {
+			deactivatedAt := time.Now().AddDate(0, -1, 0)
+			customer.DeactivatedAt = &deactivatedAt
+			repo.EXPECT().GetCustomerByID(ctx, customer.CustomerID).Return(&customer, nil)
+
+			response, err := svc.DeactivateCustomer(ctx, customer.CustomerID)
+			Expect(err).To(BeNil())
+			Expect(*response.DeactivatedAt).To(Equal(deactivatedAt))
+		})
+	})
+
+	Describe("CreateLoan", func() {
+		It("when the customer is deactivated", func() {
+			deactivatedAt := time.Now()
+			customer.DeactivatedAt = &deactivatedAt
+			repo.EXPECT().GetCustomerByID(ctx, customer.CustomerID).Return(&customer, nil)
+
+			_, err := svc.CreateLoan(ctx, model.CreateLoanPayload{CustomerID: customer.CustomerID, ProductID: uuid.New()})
+
+			var errs *apperror.CustomError
+			Expect(errors.As(err, &errs)).To(BeTrue())
+			Expect(errs.Cause).To(Equal(apperror.InvalidInput))
+		})
+	})
+})
@@ -0,0 +1,285 @@
+package service
+
+import (
+	"billing-engine/internal/billing/disbursement"
+	"billing-engine/internal/billing/domain"
+	"billing-engine/internal/billing/lifecycle"
+	"billing-engine/internal/billing/model"
+	"billing-engine/internal/billing/repository"
+	apperror "billing-engine/pkg/customerror"
+	"billing-engine/pkg/enum"
+	"billing-engine/pkg/money"
+	"billing-engine/pkg/producer"
+	"context"
+	"fmt"
+	"github.com/google/uuid"
+	"time"
+)
+
+// DisburseLoan pays out an approved loan and activates it, the schedule starts on the day the money was
+// transferred. A failed transfer is kept as FAILED and disbursing the loan again retries it with the same
+// reference, so the bank never pays out twice. A top-up re-quotes the loan it refinances on every try.
+func (b BillingService) DisburseLoan(ctx context.Context, payload model.DisburseLoanPayload) (*model.DisburseLoanResponse, error) {
+	b.log.WithField("loan_id", payload.LoanID).Info("[DisburseLoan] disbursing loan")
+
+	loan, err := b.repo.GetLoanByID(ctx, payload.LoanID)
+	if err != nil {
+		b.log.WithField("loan_id", payload.LoanID).
+			WithField("error", err.Error()).Error("[DisburseLoan] Unexpected error when getting loan")
+		return nil, err
+	}
+
+	if loan == nil {
+		b.log.WithField("loan_id", payload.LoanID).Info("[DisburseLoan] loan not found")
+		return nil, apperror.New(apperror.NotFound, "loan not found")
+	}
+
+	err = lifecycle.Validate(loan.Status, enum.LoanStatusActive)
+	if err != nil {
+		b.log.WithField("loan_id", payload.LoanID).
+			WithField("error", err.Error()).Info("[DisburseLoan] loan cannot be disbursed")
+		return nil, apperror.New(apperror.InvalidInput, err.Error())
+	}
+
+	record, err := b.repo.GetDisbursementByLoanID(ctx, payload.LoanID)
+	if err != nil {
+		b.log.WithField("loan_id", payload.LoanID).
+			WithField("error", err.Error()).Error("[DisburseLoan] Unexpected error when getting disbursement")
+		return nil, err
+	}
+
+	// a top-up settles the loan it refinances once it is activated, that loan must still be open to a top-up
+	// before the money goes out
+	if loan.RefinancedLoanID != nil && (record == nil || record.Status != enum.DisbursementStatusSucceeded) {
+		refinanced, err := b.getRefinancedLoan(ctx, *loan)
+		if err != nil {
+			return nil, err
+		}
+
+		settlement, err := b.quoteRefinance(ctx, *refinanced, time.Now())
+		if err != nil {
+			return nil, err
+		}
+
+		// the old loan may have been paid on since the top-up was approved or since a failed transfer, the
+		// payout keeps back what is left today
+		loan.RefinancedAmount = settlement.SettlementAmount
+	}
+
+	if record == nil || record.Status != enum.DisbursementStatusSucceeded {
+		amount, err := netDisbursement(*loan)
+		if err != nil {
+			b.log.WithField("loan_id", payload.LoanID).
+				WithField("error", err.Error()).Error("[DisburseLoan] failed to compute the disbursement")
+			return nil, err
+		}
+
+		if !amount.IsPositive() {
+			return nil, apperror.New(apperror.InvalidInput, "nothing is left to disburse after the admin fee")
+		}
+
+		if record == nil {
+			record, err = b.repo.CreateDisbursement(ctx, domain.Disbursement{
+				LoanID:        loan.LoanID,
+				Amount:        amount,
+				BankCode:      payload.BankCode,
+				AccountNumber: payload.AccountNumber,
+				Status:        enum.DisbursementStatusPending,
+			})
+			if err != nil {
+				b.log.WithField("loan_id", payload.LoanID).
+					WithField("error", err.Error()).Error("[DisburseLoan] Unexpected error when creating disbursement")
+				return nil, err
+			}
+		} else {
+			// the bank never paid out a transfer that did not succeed, a retry sends what is owed today
+			record.Amount = amount
+		}
+	}
+
+	// a transfer that went through before the loan could be activated is not sent again
+	if record.Status != enum.DisbursementStatusSucceeded {
+		record.BankCode = payload.BankCode
+		record.AccountNumber = payload.AccountNumber
+		err = b.transfer(ctx, record)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// what the transfer kept back is what settles the refinanced loan
+	if loan.RefinancedLoanID != nil {
+		kept, err := money.Sum(loan.AdminFee, record.Amount)
+		if err == nil {
+			loan.RefinancedAmount, err = loan.PrincipalAmount.Sub(kept)
+		}
+
+		if err != nil {
+			b.log.WithField("loan_id", payload.LoanID).
+				WithField("error", err.Error()).Error("[DisburseLoan] failed to compute the refinanced amount")
+			return nil, err
+		}
+	}
+
+	var activated *domain.Loan
+	var totalLoan money.Money
+	err = b.repo.WithTransaction(ctx, func(repo repository.BillingRepositoryProvider) error {
+		tx := b.withRepo(repo)
+
+		var err error
+		activated, totalLoan, err = tx.activateLoan(ctx, *loan, *record.DisbursedAt,
+			fmt.Sprintf("disbursed with reference %s", record.ProviderReference))
+		if err != nil {
+			return err
+		}
+
+		producerMessage := producer.Message{
+			EventID:   uuid.New().String(),
+			EventName: producer.EVENT_NAME_LOAN_DISBURSED,
+			Data: model.LoanDisbursedEventPayload{
+				LoanID:            loan.LoanID,
+				CustomerID:        loan.CustomerID,
+				DisbursementID:    record.DisbursementID,
+				Amount:            record.Amount,
+				ProviderReference: record.ProviderReference,
+				DisbursedAt:       *record.DisbursedAt,
+			},
+		}
+
+		err = tx.repo.CreateOutboxMessage(ctx, producerMessage)
+		if err != nil {
+			b.log.WithField("loan_id", payload.LoanID).
+				WithField("error", err.Error()).Error("[DisburseLoan] failed to write message to outbox")
+			return err
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = b.flushCache(ctx, loan.CustomerID)
+	if err != nil {
+		b.log.WithField("loan_id", payload.LoanID).
+			WithField("error", err.Error()).Error("[DisburseLoan] failed to flush cache")
+		return nil, err
+	}
+
+	b.log.WithField("disbursement_id", record.DisbursementID).Info("[DisburseLoan] loan disbursed")
+	return &model.DisburseLoanResponse{
+		DisbursementID:    record.DisbursementID,
+		LoanID:            loan.LoanID,
+		Status:            record.Status,
+		LoanStatus:        activated.Status,
+		Amount:            record.Amount,
+		Attempts:          record.Attempts,
+		ProviderReference: record.ProviderReference,
+		DisbursedAt:       record.DisbursedAt,
+		LoanAmount:        totalLoan,
+		Schedules:         b.MapScheduleResponse(activated.Schedules),
+	}, nil
+}
+
+// transfer sends the disbursement to the bank and stores the outcome, a failed transfer is returned as an
+// error once it is stored.
+func (b BillingService) transfer(ctx context.Context, record *domain.Disbursement) error {
+	result, attempts, transferErr := b.disburser.Disburse(ctx, disbursement.TransferRequest{
+		Reference:     record.DisbursementID.String(),
+		BankCode:      record.BankCode,
+		AccountNumber: record.AccountNumber,
+		Amount:        record.Amount,
+	})
+	record.Attempts += attempts
+	if transferErr != nil {
+		record.Status = enum.DisbursementStatusFailed
+		record.LastError = transferErr.Error()
+	} else {
+		record.Status = enum.DisbursementStatusSucceeded
+		record.LastError = ""
+		record.ProviderReference = result.TransferID
+		record.DisbursedAt = &result.TransferredAt
+	}
+
+	err := b.repo.UpdateDisbursement(ctx, *record)
+	if err != nil {
+		b.log.WithField("disbursement_id", record.DisbursementID).
+			WithField("error", err.Error()).Error("[transfer] Unexpected error when updating disbursement")
+		return err
+	}
+
+	if transferErr != nil {
+		b.log.WithField("disbursement_id", record.DisbursementID).
+			WithField("attempts", attempts).
+			WithField("error", transferErr.Error()).Error("[transfer] bank transfer failed")
+		return apperror.New(apperror.InternalError,
+			fmt.Sprintf("disbursement failed after %d attempts: %s", attempts, transferErr))
+	}
+
+	return nil
+}
+
+// activateLoan generates the schedule of an approved loan starting at startDate and writes LOAN_CREATED to
+// the outbox, the payment service only learns about a loan once it can be paid. A top-up settles the loan it
+// refinances in the same go. Call it in a transaction.
+func (b BillingService) activateLoan(ctx context.Context, loan domain.Loan, startDate time.Time, reason string) (*domain.Loan, money.Money, error) {
+	loan.StartDate = startDate
+	totalLoan, schedules, err := b.paymentSchemaMaker(loan)
+	if err != nil {
+		b.log.WithField("loan_id", loan.LoanID).
+			WithField("error", err.Error()).Error("[activateLoan] failed to generate payment schedule")
+		return nil, money.Money{}, apperror.New(apperror.InvalidInput, err.Error())
+	}
+
+	loan.Schedules = schedules
+	// the loan ends when its last installment is due, whatever the frequency
+	loan.EndDate = schedules[len(schedules)-1].PaymentDueDate
+	history := domain.LoanStatusHistory{
+		LoanID:     loan.LoanID,
+		FromStatus: loan.Status,
+		ToStatus:   enum.LoanStatusActive,
+		Reason:     reason,
+		ChangedAt:  time.Now(),
+	}
+
+	activated, err := b.repo.ActivateLoan(ctx, loan, history)
+	if err != nil {
+		b.log.WithField("loan_id", loan.LoanID).
+			WithField("error", err.Error()).Error("[activateLoan] Unexpected error when activating loan")
+		return nil, money.Money{}, err
+	}
+
+	if activated == nil {
+		b.log.WithField("loan_id", loan.LoanID).Error("[activateLoan] loan status changed concurrently")
+		return nil, money.Money{}, apperror.New(apperror.InvalidInput, "loan status was changed by another request")
+	}
+
+	err = b.publishStatusChange(ctx, *activated, history)
+	if err != nil {
+		return nil, money.Money{}, err
+	}
+
+	if activated.RefinancedLoanID != nil {
+		err = b.settleRefinancedLoan(ctx, *activated, history.ChangedAt)
+		if err != nil {
+			return nil, money.Money{}, err
+		}
+	}
+
+	producerMessage := producer.Message{
+		EventID:   uuid.New().String(),
+		EventName: producer.EVENT_NAME_LOAN_CREATED,
+		Data:      activated,
+	}
+
+	b.log.WithField("loan_id", loan.LoanID).
+		WithField("producer_payload", producerMessage).Info("[activateLoan] writing message to outbox")
+	err = b.repo.CreateOutboxMessage(ctx, producerMessage)
+	if err != nil {
+		b.log.WithField("loan_id", loan.LoanID).
+			WithField("error", err.Error()).Error("[activateLoan] failed to write message to outbox")
+		return nil, money.Money{}, err
+	}
+
+	return activated, totalLoan, nil
+}
@@ -0,0 +1,260 @@
+package service
+
+import (
+	"billing-engine/internal/billing/disbursement"
+	"billing-engine/internal/billing/domain"
+	"billing-engine/internal/billing/mocks"
+	"billing-engine/internal/billing/model"
+	apperror "billing-engine/pkg/customerror"
+	"billing-engine/pkg/enum"
+	"billing-engine/pkg/producer"
+	"errors"
+	"github.com/google/uuid"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"go.uber.org/mock/gomock"
+	"time"
+)
+
+var _ = Describe("Disbursement", func() {
+	var (
+		svc       *BillingService
+		repo      *mocks.MockBillingRepositoryProvider
+		cache     *mocks.MockBillingCacheProvider
+		simulator *disbursement.Simulator
+		loan      domain.Loan
+		payload   model.DisburseLoanPayload
+	)
+
+	BeforeEach(func() {
+		svc, repo, cache = newTestService()
+		simulator, _ = disbursement.NewSimulator("", 0)
+		svc.disburser = disbursement.Disburser{Provider: simulator, MaxAttempts: 3}
+
+		loan = domain.Loan{
+			LoanID:             uuid.New(),
+			CustomerID:         uuid.New(),
+			PrincipalAmount:    idr(1200000),
+			AdminFee:           idr(50000),
+			InterestRate:       0.1,
+			Tenor:              12,
+			InstallmentCount:   12,
+			Frequency:          enum.FrequencyMonthly,
+			AmortizationMethod: enum.AmortizationFlat,
+			Status:             enum.LoanStatusApproved,
+		}
+		payload = model.DisburseLoanPayload{LoanID: loan.LoanID, BankCode: "014", AccountNumber: "1234567890"}
+	})
+
+	Describe("DisburseLoan", func() {
+		It("should disburse the loan and start the schedule on the disbursement date", func() {
+			disbursementID := uuid.New()
+			repo.EXPECT().GetLoanByID(ctx, loan.LoanID).Return(&loan, nil)
+			repo.EXPECT().GetDisbursementByLoanID(ctx, loan.LoanID).Return(nil, nil)
+			repo.EXPECT().CreateDisbursement(ctx, gomock.Any()).
+				DoAndReturn(func(_ any, record domain.Disbursement) (*domain.Disbursement, error) {
+					Expect(record.Amount).To(Equal(idr(1150000)))
+					Expect(record.Status).To(Equal(enum.DisbursementStatusPending))
+					record.DisbursementID = disbursementID
+					return &record, nil
+				})
+			repo.EXPECT().UpdateDisbursement(ctx, gomock.Any()).DoAndReturn(func(_ any, record domain.Disbursement) error {
+				Expect(record.Status).To(Equal(enum.DisbursementStatusSucceeded))
+				Expect(record.Attempts).To(Equal(1))
+				Expect(record.DisbursedAt).NotTo(BeNil())
+				return nil
+			})
+			repo.EXPECT().ActivateLoan(ctx, gomock.Any(), gomock.Any()).
+				DoAndReturn(func(_ any, loan domain.Loan, history domain.LoanStatusHistory) (*domain.Loan, error) {
+					Expect(history.FromStatus).To(Equal(enum.LoanStatusApproved))
+					Expect(history.ToStatus).To(Equal(enum.LoanStatusActive))
+					Expect(loan.Schedules).To(HaveLen(12))
+					Expect(loan.Schedules[0].PaymentDueDate).To(BeTemporally("~", loan.StartDate.AddDate(0, 1, 0), 24*time.Hour))
+					loan.Status = history.ToStatus
+					return &loan, nil
+				})
+			var events []string
+			repo.EXPECT().CreateOutboxMessage(ctx, gomock.Any()).DoAndReturn(func(_ any, message producer.Message) error {
+				events = append(events, message.EventName)
+				return nil
+			}).Times(3)
+			cache.EXPECT().Get(ctx, gomock.Any()).Return(nil, nil).Times(2)
+
+			response, err := svc.DisburseLoan(ctx, payload)
+			Expect(err).To(BeNil())
+			Expect(response.Status).To(Equal(enum.DisbursementStatusSucceeded))
+			Expect(response.LoanStatus).To(Equal(enum.LoanStatusActive))
+			Expect(response.ProviderReference).NotTo(BeEmpty())
+			Expect(response.Schedules).To(HaveLen(12))
+			Expect(events).To(Equal([]string{
+				producer.EVENT_NAME_LOAN_STATUS_CHANGED,
+				producer.EVENT_NAME_LOAN_CREATED,
+				producer.EVENT_NAME_LOAN_DISBURSED,
+			}))
+		})
+
+		It("should settle the loan a top-up refinances when the top-up is activated", func() {
+			old := domain.Loan{
+				LoanID:          uuid.New(),
+				CustomerID:      loan.CustomerID,
+				PrincipalAmount: idr(1000000),
+				Status:          enum.LoanStatusActive,
+			}
+			loan.RefinancedLoanID = &old.LoanID
+			loan.RefinancedAmount = idr(400000)
+			open := []domain.Schedule{{
+				ScheduleID:      uuid.New(),
+				PaymentDueDate:  time.Now().AddDate(0, 1, 0),
+				PrincipalAmount: idr(300000),
+				InterestAmount:  idr(30000),
+				PaymentStatus:   enum.PaymentStatusPending,
+			}}
+			repo.EXPECT().GetLoanByID(ctx, loan.LoanID).Return(&loan, nil)
+			repo.EXPECT().GetDisbursementByLoanID(ctx, loan.LoanID).Return(nil, nil)
+			repo.EXPECT().GetLoanByID(ctx, old.LoanID).Return(&old, nil).Times(2)
+			repo.EXPECT().GetMissedSchedules(ctx, old.LoanID).Return(nil, nil)
+			repo.EXPECT().GetOpenSchedules(ctx, old.LoanID).Return(open, nil)
+			repo.EXPECT().CreateDisbursement(ctx, gomock.Any()).
+				DoAndReturn(func(_ any, record domain.Disbursement) (*domain.Disbursement, error) {
+					// the old loan was paid on since the top-up was approved, only what is left is kept back
+					Expect(record.Amount).To(Equal(idr(850000)))
+					return &record, nil
+				})
+			repo.EXPECT().UpdateDisbursement(ctx, gomock.Any()).Return(nil)
+			repo.EXPECT().ActivateLoan(ctx, gomock.Any(), gomock.Any()).
+				DoAndReturn(func(_ any, loan domain.Loan, history domain.LoanStatusHistory) (*domain.Loan, error) {
+					Expect(loan.RefinancedAmount).To(Equal(idr(300000)))
+					loan.Status = history.ToStatus
+					return &loan, nil
+				})
+			repo.EXPECT().RefinanceLoan(ctx, gomock.Any()).DoAndReturn(func(_ any, history domain.LoanStatusHistory) (bool, error) {
+				Expect(history.LoanID).To(Equal(old.LoanID))
+				Expect(history.FromStatus).To(Equal(enum.LoanStatusActive))
+				Expect(history.ToStatus).To(Equal(enum.LoanStatusRefinanced))
+				return true, nil
+			})
+			var events []string
+			repo.EXPECT().CreateOutboxMessage(ctx, gomock.Any()).DoAndReturn(func(_ any, message producer.Message) error {
+				events = append(events, message.EventName)
+				if message.EventName == producer.EVENT_NAME_LOAN_REFINANCED {
+					Expect(message.Data.(model.LoanRefinancedEventPayload).SettledAmount).To(Equal(idr(300000)))
+				}
+				return nil
+			}).Times(5)
+			cache.EXPECT().Get(ctx, gomock.Any()).Return(nil, nil).Times(2)
+
+			_, err := svc.DisburseLoan(ctx, payload)
+			Expect(err).To(BeNil())
+			Expect(events).To(Equal([]string{
+				producer.EVENT_NAME_LOAN_STATUS_CHANGED,
+				producer.EVENT_NAME_LOAN_STATUS_CHANGED,
+				producer.EVENT_NAME_LOAN_REFINANCED,
+				producer.EVENT_NAME_LOAN_CREATED,
+				producer.EVENT_NAME_LOAN_DISBURSED,
+			}))
+		})
+
+		It("when the loan a top-up refinances has fallen behind", func() {
+			old := domain.Loan{LoanID: uuid.New(), PrincipalAmount: idr(1000000), Status: enum.LoanStatusActive}
+			loan.RefinancedLoanID = &old.LoanID
+			repo.EXPECT().GetLoanByID(ctx, loan.LoanID).Return(&loan, nil)
+			repo.EXPECT().GetDisbursementByLoanID(ctx, loan.LoanID).Return(nil, nil)
+			repo.EXPECT().GetLoanByID(ctx, old.LoanID).Return(&old, nil)
+			repo.EXPECT().GetMissedSchedules(ctx, old.LoanID).Return([]domain.Schedule{{ScheduleID: uuid.New()}}, nil)
+
+			_, err := svc.DisburseLoan(ctx, payload)
+
+			var errs *apperror.CustomError
+			Expect(errors.As(err, &errs)).To(BeTrue())
+			Expect(errs.Cause).To(Equal(apperror.InvalidInput))
+		})
+
+		It("should keep a failed transfer for a later retry", func() {
+			simulator.FailNext(3)
+			repo.EXPECT().GetLoanByID(ctx, loan.LoanID).Return(&loan, nil)
+			repo.EXPECT().GetDisbursementByLoanID(ctx, loan.LoanID).Return(&domain.Disbursement{
+				DisbursementID: uuid.New(),
+				LoanID:         loan.LoanID,
+				Amount:         idr(1150000),
+				Status:         enum.DisbursementStatusFailed,
+				Attempts:       3,
+			}, nil)
+			repo.EXPECT().UpdateDisbursement(ctx, gomock.Any()).DoAndReturn(func(_ any, record domain.Disbursement) error {
+				Expect(record.Status).To(Equal(enum.DisbursementStatusFailed))
+				Expect(record.Attempts).To(Equal(6))
+				Expect(record.BankCode).To(Equal(payload.BankCode))
+				Expect(record.LastError).NotTo(BeEmpty())
+				return nil
+			})
+
+			_, err := svc.DisburseLoan(ctx, payload)
+
+			var errs *apperror.CustomError
+			Expect(errors.As(err, &errs)).To(BeTrue())
+			Expect(errs.Cause).To(Equal(apperror.InternalError))
+		})
+
+		It("should re-quote the loan a top-up refinances when a failed transfer is retried", func() {
+			simulator.FailNext(3)
+			old := domain.Loan{
+				LoanID:          uuid.New(),
+				CustomerID:      loan.CustomerID,
+				PrincipalAmount: idr(1000000),
+				Status:          enum.LoanStatusActive,
+			}
+			loan.RefinancedLoanID = &old.LoanID
+			loan.RefinancedAmount = idr(400000)
+			open := []domain.Schedule{{
+				ScheduleID:      uuid.New(),
+				PaymentDueDate:  time.Now().AddDate(0, 1, 0),
+				PrincipalAmount: idr(300000),
+				InterestAmount:  idr(30000),
+				PaymentStatus:   enum.PaymentStatusPending,
+			}}
+			repo.EXPECT().GetLoanByID(ctx, loan.LoanID).Return(&loan, nil)
+			repo.EXPECT().GetDisbursementByLoanID(ctx, loan.LoanID).Return(&domain.Disbursement{
+				DisbursementID: uuid.New(),
+				LoanID:         loan.LoanID,
+				Amount:         idr(750000),
+				Status:         enum.DisbursementStatusFailed,
+				Attempts:       3,
+			}, nil)
+			repo.EXPECT().GetLoanByID(ctx, old.LoanID).Return(&old, nil)
+			repo.EXPECT().GetMissedSchedules(ctx, old.LoanID).Return(nil, nil)
+			repo.EXPECT().GetOpenSchedules(ctx, old.LoanID).Return(open, nil)
+			repo.EXPECT().UpdateDisbursement(ctx, gomock.Any()).DoAndReturn(func(_ any, record domain.Disbursement) error {
+				// the old loan was paid on since the first transfer failed, the retry keeps back less
+				Expect(record.Amount).To(Equal(idr(850000)))
+				Expect(record.Status).To(Equal(enum.DisbursementStatusFailed))
+				return nil
+			})
+
+			_, err := svc.DisburseLoan(ctx, payload)
+
+			var errs *apperror.CustomError
+			Expect(errors.As(err, &errs)).To(BeTrue())
+			Expect(errs.Cause).To(Equal(apperror.InternalError))
+		})
+
+		It("when the loan is not approved", func() {
+			loan.Status = enum.LoanStatusPendingApproval
+			repo.EXPECT().GetLoanByID(ctx, loan.LoanID).Return(&loan, nil)
+
+			_, err := svc.DisburseLoan(ctx, payload)
+
+			var errs *apperror.CustomError
+			Expect(errors.As(err, &errs)).To(BeTrue())
+			Expect(errs.Cause).To(Equal(apperror.InvalidInput))
+		})
+
+		It("when the loan does not exist", func() {
+			repo.EXPECT().GetLoanByID(ctx, loan.LoanID).Return(nil, nil)
+
+			_, err := svc.DisburseLoan(ctx, payload)
+
+			var errs *apperror.CustomError
+			Expect(errors.As(err, &errs)).To(BeTrue())
+			Expect(errs.Cause).To(Equal(apperror.NotFound))
+		})
+	})
+})
@@ -0,0 +1,356 @@
+package service
+
+import (
+	"billing-engine/internal/billing/domain"
+	"billing-engine/internal/billing/lifecycle"
+	"billing-engine/internal/billing/model"
+	"billing-engine/internal/billing/penalty"
+	"billing-engine/internal/billing/repository"
+	apperror "billing-engine/pkg/customerror"
+	"billing-engine/pkg/enum"
+	"billing-engine/pkg/money"
+	"billing-engine/pkg/producer"
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"time"
+)
+
+// GrantPaymentHoliday defers the installments of a loan that fall in the holiday window. Installments that were
+// already flagged as missed in the window stop counting as missed and the penalty they still owe is waived. A
+// window that overlaps a holiday the loan already has is refused.
+func (b BillingService) GrantPaymentHoliday(ctx context.Context, payload model.PaymentHolidayPayload) (*model.PaymentHolidayResponse, error) {
+	b.log.WithField("loan_id", payload.LoanID).Info("[GrantPaymentHoliday] granting payment holiday")
+
+	start, end, err := parseHolidayWindow(payload.StartDate, payload.EndDate)
+	if err != nil {
+		b.log.WithField("loan_id", payload.LoanID).
+			WithField("error", err.Error()).Info("[GrantPaymentHoliday] invalid holiday window")
+		return nil, apperror.New(apperror.InvalidInput, err.Error())
+	}
+
+	loan, err := b.repo.GetLoanByID(ctx, payload.LoanID)
+	if err != nil {
+		b.log.WithField("loan_id", payload.LoanID).
+			WithField("error", err.Error()).Error("[GrantPaymentHoliday] Unexpected error when getting loan")
+		return nil, err
+	}
+
+	if loan == nil {
+		b.log.WithField("loan_id", payload.LoanID).Info("[GrantPaymentHoliday] loan not found")
+		return nil, apperror.New(apperror.NotFound, "loan not found")
+	}
+
+	if !lifecycle.IsRepaying(loan.Status) {
+		b.log.WithField("loan_id", payload.LoanID).
+			WithField("status", loan.Status).Info("[GrantPaymentHoliday] loan is not being repaid")
+		return nil, apperror.New(apperror.InvalidInput, "only loans being repaid can get a payment holiday")
+	}
+
+	resp, err := b.grantPaymentHoliday(ctx, *loan, start, end, payload.Reason)
+	if err != nil {
+		return nil, err
+	}
+
+	err = b.flushCache(ctx, loan.CustomerID)
+	if err != nil {
+		b.log.WithField("loan_id", payload.LoanID).
+			WithField("error", err.Error()).Error("[GrantPaymentHoliday] failed to flush cache")
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+// GrantBulkPaymentHoliday grants the holiday to every matching loan. A loan that fails is reported and
+// skipped, the others are still deferred.
+func (b BillingService) GrantBulkPaymentHoliday(ctx context.Context, payload model.BulkPaymentHolidayPayload) (*model.BulkPaymentHolidayResponse, error) {
+	b.log.WithField("payload", payload).Info("[GrantBulkPaymentHoliday] granting payment holidays")
+
+	start, end, err := parseHolidayWindow(payload.StartDate, payload.EndDate)
+	if err != nil {
+		b.log.WithField("error", err.Error()).Info("[GrantBulkPaymentHoliday] invalid holiday window")
+		return nil, apperror.New(apperror.InvalidInput, err.Error())
+	}
+
+	filter := repository.LoanFilter{ProductID: payload.ProductID, Frequency: payload.Frequency}
+	loans, err := b.repo.GetLoansWithSchedulesDueBetween(ctx, filter, start, end.AddDate(0, 0, 1))
+	if err != nil {
+		b.log.WithField("error", err.Error()).Error("[GrantBulkPaymentHoliday] Unexpected error when getting loans")
+		return nil, err
+	}
+
+	resp := &model.BulkPaymentHolidayResponse{
+		Granted: make([]model.PaymentHolidayResponse, 0, len(loans)),
+		Failed:  []model.FailedLoanResponse{},
+	}
+	affectedCustomers := map[uuid.UUID]struct{}{}
+	for _, loan := range loans {
+		granted, err := b.grantPaymentHoliday(ctx, loan, start, end, payload.Reason)
+		if err != nil {
+			resp.Failed = append(resp.Failed, model.FailedLoanResponse{LoanID: loan.LoanID, Error: err.Error()})
+			continue
+		}
+
+		resp.Granted = append(resp.Granted, *granted)
+		affectedCustomers[loan.CustomerID] = struct{}{}
+	}
+
+	for customerID := range affectedCustomers {
+		err = b.flushCache(ctx, customerID)
+		if err != nil {
+			b.log.WithField("customer_id", customerID).
+				WithField("error", err.Error()).Error("[GrantBulkPaymentHoliday] failed to flush cache")
+			return nil, err
+		}
+	}
+
+	b.log.WithField("granted", len(resp.Granted)).
+		WithField("failed", len(resp.Failed)).Info("[GrantBulkPaymentHoliday] payment holidays granted")
+	return resp, nil
+}
+
+func (b BillingService) grantPaymentHoliday(ctx context.Context, loan domain.Loan, start, end time.Time, reason string) (*model.PaymentHolidayResponse, error) {
+	var stored *domain.PaymentHoliday
+	var deferred []domain.Schedule
+	err := b.repo.WithTransaction(ctx, func(repo repository.BillingRepositoryProvider) error {
+		tx := b.withRepo(repo)
+
+		// the loan stays locked until the holiday is stored, so a concurrent grant waits and sees it as an overlap
+		err := tx.repo.LockLoan(ctx, loan.LoanID)
+		if err != nil {
+			b.log.WithField("loan_id", loan.LoanID).
+				WithField("error", err.Error()).Error("[grantPaymentHoliday] Unexpected error when locking loan")
+			return err
+		}
+
+		overlaps, err := tx.repo.HasPaymentHolidayBetween(ctx, loan.LoanID, start, end)
+		if err != nil {
+			b.log.WithField("loan_id", loan.LoanID).
+				WithField("error", err.Error()).Error("[grantPaymentHoliday] Unexpected error when checking payment holidays")
+			return err
+		}
+
+		if overlaps {
+			b.log.WithField("loan_id", loan.LoanID).Info("[grantPaymentHoliday] loan already has a payment holiday in the window")
+			return apperror.New(apperror.InvalidInput, "loan already has a payment holiday overlapping the window")
+		}
+
+		schedules, err := tx.repo.GetOpenSchedules(ctx, loan.LoanID)
+		if err != nil {
+			b.log.WithField("loan_id", loan.LoanID).
+				WithField("error", err.Error()).Error("[grantPaymentHoliday] Unexpected error when getting open schedules")
+			return err
+		}
+
+		now := time.Now()
+		holiday, planned, endDate, err := planPaymentHoliday(loan, schedules, start, end, now)
+		if err != nil {
+			b.log.WithField("loan_id", loan.LoanID).
+				WithField("error", err.Error()).Info("[grantPaymentHoliday] nothing to defer")
+			return apperror.New(apperror.InvalidInput, err.Error())
+		}
+
+		deferred = planned
+		currency := loan.PrincipalAmount.Currency
+		waivers, err := waivePenalties(loan, deferred, now)
+		if err == nil {
+			holiday.WaivedPenalty, err = waivedTotal(currency, waivers)
+		}
+
+		if err != nil {
+			b.log.WithField("loan_id", loan.LoanID).
+				WithField("error", err.Error()).Error("[grantPaymentHoliday] failed to waive penalties")
+			return err
+		}
+
+		holiday.Reason = reason
+		stored, err = tx.repo.GrantPaymentHoliday(ctx, holiday, deferred, endDate)
+		if err != nil {
+			b.log.WithField("loan_id", loan.LoanID).
+				WithField("error", err.Error()).Error("[grantPaymentHoliday] Unexpected error when granting payment holiday")
+			return err
+		}
+
+		if len(waivers) > 0 {
+			err = tx.repo.CreatePenalties(ctx, waivers)
+			if err != nil {
+				b.log.WithField("loan_id", loan.LoanID).
+					WithField("error", err.Error()).Error("[grantPaymentHoliday] Unexpected error when waiving penalties")
+				return err
+			}
+		}
+
+		dueDates := make([]model.ScheduleDueDatePayload, 0, len(deferred))
+		for _, schedule := range deferred {
+			charged, err := penalty.Sum(schedule.Penalties)
+			if err == nil {
+				charged, err = money.Zero(currency).Add(charged)
+			}
+
+			if err != nil {
+				b.log.WithField("schedule_id", schedule.ScheduleID).
+					WithField("error", err.Error()).Error("[grantPaymentHoliday] failed to add up penalties")
+				return err
+			}
+
+			dueDates = append(dueDates, model.ScheduleDueDatePayload{
+				ScheduleID:     schedule.ScheduleID,
+				PaymentDueDate: schedule.PaymentDueDate,
+				PenaltyAmount:  charged,
+			})
+		}
+
+		producerMessage := producer.Message{
+			EventID:   uuid.New().String(),
+			EventName: producer.EVENT_NAME_PAYMENT_HOLIDAY_GRANTED,
+			Data: model.PaymentHolidayGrantedEventPayload{
+				LoanID:     loan.LoanID,
+				CustomerID: loan.CustomerID,
+				HolidayID:  stored.HolidayID,
+				Schedules:  dueDates,
+			},
+		}
+
+		err = tx.repo.CreateOutboxMessage(ctx, producerMessage)
+		if err != nil {
+			b.log.WithField("loan_id", loan.LoanID).
+				WithField("error", err.Error()).Error("[grantPaymentHoliday] failed to write message to outbox")
+			return err
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	b.log.WithField("holiday_id", stored.HolidayID).Info("[grantPaymentHoliday] payment holiday granted")
+	return &model.PaymentHolidayResponse{
+		HolidayID:            stored.HolidayID,
+		LoanID:               loan.LoanID,
+		StartDate:            start.Format("2006-01-02"),
+		EndDate:              end.Format("2006-01-02"),
+		DeferredInstallments: stored.DeferredInstallments,
+		WaivedPenalty:        stored.WaivedPenalty,
+		Schedules:            b.MapScheduleResponse(deferred),
+	}, nil
+}
+
+// planPaymentHoliday counts the open installments due in the window, both dates included, and moves that
+// installment and every later one forward by as many periods, so the cadence of the loan is kept and
+// nothing falls due in the window anymore. It returns the deferred schedules and the new loan end date.
+func planPaymentHoliday(loan domain.Loan, schedules []domain.Schedule, start, end, now time.Time) (domain.PaymentHoliday, []domain.Schedule, time.Time, error) {
+	until := end.AddDate(0, 0, 1)
+	holiday := domain.PaymentHoliday{LoanID: loan.LoanID, StartDate: start, EndDate: end, GrantedAt: now}
+	for _, schedule := range schedules {
+		if !schedule.PaymentDueDate.Before(start) && schedule.PaymentDueDate.Before(until) {
+			holiday.DeferredInstallments++
+		}
+	}
+
+	if holiday.DeferredInstallments == 0 {
+		return domain.PaymentHoliday{}, nil, time.Time{}, errors.New("no open installment falls within the payment holiday")
+	}
+
+	endDate := loan.EndDate
+	var deferred []domain.Schedule
+	for _, schedule := range schedules {
+		if schedule.PaymentDueDate.Before(start) {
+			continue
+		}
+
+		schedule.PaymentDueDate = installmentDueDate(schedule.PaymentDueDate, loan.Frequency, loan.IntervalDays, holiday.DeferredInstallments)
+		schedule.IsMissPayment = false
+		schedule.DaysPastDue = 0
+		deferred = append(deferred, schedule)
+		if schedule.PaymentDueDate.After(endDate) {
+			endDate = schedule.PaymentDueDate
+		}
+	}
+
+	return holiday, deferred, endDate, nil
+}
+
+// waivePenalties reverses the penalty the deferred schedules still owe, the daily penalty first and then the
+// late fee, and adds the reversals to the penalties of the schedules. What was already paid off is kept, and
+// an installment that is missed again after the holiday is charged from scratch.
+func waivePenalties(loan domain.Loan, deferred []domain.Schedule, now time.Time) ([]domain.Penalty, error) {
+	var waivers []domain.Penalty
+	for i, schedule := range deferred {
+		charged, err := penalty.Sum(schedule.Penalties)
+		if err != nil {
+			return nil, err
+		}
+
+		unpaid, err := charged.Sub(schedule.PenaltyPaid)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, penaltyType := range []enum.PenaltyType{enum.PenaltyDaily, enum.PenaltyLateFee} {
+			if !unpaid.IsPositive() {
+				break
+			}
+
+			ofType, err := penalty.Sum(schedule.Penalties, penaltyType)
+			if err != nil {
+				return nil, err
+			}
+
+			waived, err := ofType.Min(unpaid)
+			if err != nil {
+				return nil, err
+			}
+
+			if !waived.IsPositive() {
+				continue
+			}
+
+			waiver := domain.Penalty{
+				LoanID:      loan.LoanID,
+				ScheduleID:  schedule.ScheduleID,
+				Type:        penaltyType,
+				Amount:      money.New(-waived.Amount, waived.Currency),
+				AccrualDate: now,
+			}
+			waivers = append(waivers, waiver)
+			deferred[i].Penalties = append(deferred[i].Penalties, waiver)
+			unpaid, err = unpaid.Sub(waived)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return waivers, nil
+}
+
+// waivedTotal is the penalty the waivers reverse, as a positive amount in currency.
+func waivedTotal(currency string, waivers []domain.Penalty) (money.Money, error) {
+	reversed, err := penalty.Sum(waivers)
+	if err != nil {
+		return money.Money{}, err
+	}
+
+	return money.Zero(currency).Sub(reversed)
+}
+
+// parseHolidayWindow returns the first and the last day of the holiday at midnight, local time.
+func parseHolidayWindow(startDate, endDate string) (time.Time, time.Time, error) {
+	start, err := time.ParseInLocation("2006-01-02", startDate, time.Local)
+	if err != nil {
+		return time.Time{}, time.Time{}, errors.New("start date must be formatted as 2006-01-02")
+	}
+
+	end, err := time.ParseInLocation("2006-01-02", endDate, time.Local)
+	if err != nil {
+		return time.Time{}, time.Time{}, errors.New("end date must be formatted as 2006-01-02")
+	}
+
+	if end.Before(start) {
+		return time.Time{}, time.Time{}, errors.New("end date must not be before start date")
+	}
+
+	return start, end, nil
+}
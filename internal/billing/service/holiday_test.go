@@ -0,0 +1,242 @@
+package service
+
+import (
+	"billing-engine/internal/billing/domain"
+	"billing-engine/internal/billing/mocks"
+	"billing-engine/internal/billing/model"
+	"billing-engine/internal/billing/repository"
+	apperror "billing-engine/pkg/customerror"
+	"billing-engine/pkg/enum"
+	"billing-engine/pkg/producer"
+	"errors"
+	"github.com/google/uuid"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"go.uber.org/mock/gomock"
+	"time"
+)
+
+var _ = Describe("PaymentHoliday", func() {
+	var (
+		svc        *BillingService
+		repo       *mocks.MockBillingRepositoryProvider
+		cache      *mocks.MockBillingCacheProvider
+		loan       domain.Loan
+		schedules  []domain.Schedule
+		start, end time.Time
+	)
+
+	date := func(month time.Month, day int) time.Time {
+		return time.Date(2024, month, day, 0, 0, 0, 0, time.Local)
+	}
+
+	BeforeEach(func() {
+		svc, repo, cache = newTestService()
+
+		loan = domain.Loan{
+			LoanID:     uuid.New(),
+			CustomerID: uuid.New(),
+			Frequency:  enum.FrequencyMonthly,
+			EndDate:    date(time.May, 10).Add(9 * time.Hour),
+			Status:     enum.LoanStatusActive,
+
+			PrincipalAmount: idr(3000000),
+		}
+		schedules = []domain.Schedule{
+			{ScheduleID: uuid.New(), PaymentNo: 1, PaymentDueDate: date(time.March, 10).Add(9 * time.Hour)},
+			{ScheduleID: uuid.New(), PaymentNo: 2, PaymentDueDate: date(time.April, 10).Add(9 * time.Hour), IsMissPayment: true, DaysPastDue: 3},
+			{ScheduleID: uuid.New(), PaymentNo: 3, PaymentDueDate: date(time.May, 10).Add(9 * time.Hour)},
+		}
+		start, end = date(time.April, 1), date(time.April, 30)
+	})
+
+	Describe("planPaymentHoliday", func() {
+		It("should move the installments from the window onwards by the number of deferred periods", func() {
+			holiday, deferred, endDate, err := planPaymentHoliday(loan, schedules, start, end, time.Now())
+			Expect(err).To(BeNil())
+			Expect(holiday.DeferredInstallments).To(Equal(1))
+			Expect(deferred).To(HaveLen(2))
+			Expect(deferred[0].ScheduleID).To(Equal(schedules[1].ScheduleID))
+			Expect(deferred[0].PaymentDueDate).To(Equal(date(time.May, 10).Add(9 * time.Hour)))
+			Expect(deferred[0].IsMissPayment).To(BeFalse())
+			Expect(deferred[0].DaysPastDue).To(BeZero())
+			Expect(deferred[1].PaymentDueDate).To(Equal(date(time.June, 10).Add(9 * time.Hour)))
+			Expect(endDate).To(Equal(deferred[1].PaymentDueDate))
+		})
+
+		It("should include installments due on the last day of the window", func() {
+			holiday, deferred, _, err := planPaymentHoliday(loan, schedules, date(time.April, 1), date(time.May, 10), time.Now())
+			Expect(err).To(BeNil())
+			Expect(holiday.DeferredInstallments).To(Equal(2))
+			Expect(deferred[0].PaymentDueDate).To(Equal(date(time.June, 10).Add(9 * time.Hour)))
+		})
+
+		It("should refuse a window without installments", func() {
+			_, _, _, err := planPaymentHoliday(loan, schedules, date(time.March, 11), date(time.March, 20), time.Now())
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Describe("waivePenalties", func() {
+		It("should keep the penalty that was already paid off", func() {
+			deferred := []domain.Schedule{{
+				ScheduleID:  uuid.New(),
+				PenaltyPaid: idr(20000),
+				Penalties: []domain.Penalty{
+					{Type: enum.PenaltyLateFee, Amount: idr(50000)},
+					{Type: enum.PenaltyDaily, Amount: idr(3000)},
+				},
+			}}
+
+			waivers, err := waivePenalties(loan, deferred, time.Now())
+			Expect(err).To(BeNil())
+			Expect(waivers).To(HaveLen(2))
+			Expect(waivers[0].Type).To(Equal(enum.PenaltyDaily))
+			Expect(waivers[0].Amount).To(Equal(idr(-3000)))
+			Expect(waivers[1].Type).To(Equal(enum.PenaltyLateFee))
+			Expect(waivers[1].Amount).To(Equal(idr(-30000)))
+			Expect(waivers[1].LoanID).To(Equal(loan.LoanID))
+			Expect(deferred[0].Penalties).To(HaveLen(4))
+		})
+	})
+
+	Describe("parseHolidayWindow", func() {
+		It("should refuse an end date before the start date", func() {
+			_, _, err := parseHolidayWindow("2024-04-30", "2024-04-01")
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("should parse both days at midnight", func() {
+			from, until, err := parseHolidayWindow("2024-04-01", "2024-04-30")
+			Expect(err).To(BeNil())
+			Expect(from).To(Equal(start))
+			Expect(until).To(Equal(end))
+		})
+	})
+
+	Describe("GrantPaymentHoliday", func() {
+		payload := model.PaymentHolidayPayload{StartDate: "2024-04-01", EndDate: "2024-04-30", Reason: "harvest season"}
+
+		It("should defer the schedules and tell the payment service", func() {
+			payload.LoanID = loan.LoanID
+			repo.EXPECT().GetLoanByID(ctx, loan.LoanID).Return(&loan, nil)
+			repo.EXPECT().LockLoan(ctx, loan.LoanID).Return(nil)
+			repo.EXPECT().HasPaymentHolidayBetween(ctx, loan.LoanID, start, end).Return(false, nil)
+			repo.EXPECT().GetOpenSchedules(ctx, loan.LoanID).Return(schedules, nil)
+			repo.EXPECT().GrantPaymentHoliday(ctx, gomock.Any(), gomock.Any(), date(time.June, 10).Add(9*time.Hour)).
+				DoAndReturn(func(_ any, holiday domain.PaymentHoliday, deferred []domain.Schedule, _ time.Time) (*domain.PaymentHoliday, error) {
+					Expect(holiday.Reason).To(Equal("harvest season"))
+					Expect(holiday.WaivedPenalty).To(Equal(idr(0)))
+					Expect(deferred).To(HaveLen(2))
+					holiday.HolidayID = uuid.New()
+					return &holiday, nil
+				})
+			repo.EXPECT().CreateOutboxMessage(ctx, gomock.Any()).DoAndReturn(func(_ any, message producer.Message) error {
+				Expect(message.EventName).To(Equal(producer.EVENT_NAME_PAYMENT_HOLIDAY_GRANTED))
+				data := message.Data.(model.PaymentHolidayGrantedEventPayload)
+				Expect(data.Schedules).To(HaveLen(2))
+				return nil
+			})
+			cache.EXPECT().Get(ctx, gomock.Any()).Return(nil, nil).Times(2)
+
+			response, err := svc.GrantPaymentHoliday(ctx, payload)
+			Expect(err).To(BeNil())
+			Expect(response.DeferredInstallments).To(Equal(1))
+			Expect(response.Schedules[0].PaymentDueDate).To(Equal("2024-05-10"))
+		})
+
+		It("should waive the penalty of the deferred installments in the same transaction", func() {
+			payload.LoanID = loan.LoanID
+			schedules[1].Penalties = []domain.Penalty{
+				{Type: enum.PenaltyLateFee, Amount: idr(50000)},
+				{Type: enum.PenaltyDaily, Amount: idr(3000)},
+			}
+			repo.EXPECT().GetLoanByID(ctx, loan.LoanID).Return(&loan, nil)
+			repo.EXPECT().LockLoan(ctx, loan.LoanID).Return(nil)
+			repo.EXPECT().HasPaymentHolidayBetween(ctx, loan.LoanID, start, end).Return(false, nil)
+			repo.EXPECT().GetOpenSchedules(ctx, loan.LoanID).Return(schedules, nil)
+			repo.EXPECT().GrantPaymentHoliday(ctx, gomock.Any(), gomock.Any(), gomock.Any()).
+				DoAndReturn(func(_ any, holiday domain.PaymentHoliday, _ []domain.Schedule, _ time.Time) (*domain.PaymentHoliday, error) {
+					Expect(holiday.WaivedPenalty).To(Equal(idr(53000)))
+					return &holiday, nil
+				})
+			repo.EXPECT().CreatePenalties(ctx, gomock.Any()).DoAndReturn(func(_ any, penalties []domain.Penalty) error {
+				Expect(penalties).To(HaveLen(2))
+				Expect(penalties[0].ScheduleID).To(Equal(schedules[1].ScheduleID))
+				Expect(penalties[0].Amount).To(Equal(idr(-3000)))
+				Expect(penalties[1].Amount).To(Equal(idr(-50000)))
+				return nil
+			})
+			repo.EXPECT().CreateOutboxMessage(ctx, gomock.Any()).DoAndReturn(func(_ any, message producer.Message) error {
+				data := message.Data.(model.PaymentHolidayGrantedEventPayload)
+				Expect(data.Schedules[0].PenaltyAmount).To(Equal(idr(0)))
+				return nil
+			})
+			cache.EXPECT().Get(ctx, gomock.Any()).Return(nil, nil).Times(2)
+
+			response, err := svc.GrantPaymentHoliday(ctx, payload)
+			Expect(err).To(BeNil())
+			Expect(response.WaivedPenalty).To(Equal(idr(53000)))
+		})
+
+		It("when the loan already has a payment holiday in the window", func() {
+			payload.LoanID = loan.LoanID
+			repo.EXPECT().GetLoanByID(ctx, loan.LoanID).Return(&loan, nil)
+			repo.EXPECT().LockLoan(ctx, loan.LoanID).Return(nil)
+			repo.EXPECT().HasPaymentHolidayBetween(ctx, loan.LoanID, start, end).Return(true, nil)
+
+			_, err := svc.GrantPaymentHoliday(ctx, payload)
+
+			var errs *apperror.CustomError
+			Expect(errors.As(err, &errs)).To(BeTrue())
+			Expect(errs.Cause).To(Equal(apperror.InvalidInput))
+		})
+
+		It("when loan is not being repaid", func() {
+			payload.LoanID = loan.LoanID
+			loan.Status = enum.LoanStatusPaidOff
+			repo.EXPECT().GetLoanByID(ctx, loan.LoanID).Return(&loan, nil)
+
+			_, err := svc.GrantPaymentHoliday(ctx, payload)
+
+			var errs *apperror.CustomError
+			Expect(errors.As(err, &errs)).To(BeTrue())
+			Expect(errs.Cause).To(Equal(apperror.InvalidInput))
+		})
+	})
+
+	Describe("GrantBulkPaymentHoliday", func() {
+		It("should report the loans that could not be deferred and grant the others", func() {
+			productID := uuid.New()
+			other := domain.Loan{LoanID: uuid.New(), CustomerID: uuid.New(), Frequency: enum.FrequencyMonthly}
+			payload := model.BulkPaymentHolidayPayload{
+				StartDate: "2024-04-01",
+				EndDate:   "2024-04-30",
+				Reason:    "ramadan",
+				ProductID: &productID,
+			}
+
+			repo.EXPECT().GetLoansWithSchedulesDueBetween(ctx, repository.LoanFilter{ProductID: &productID}, start, end.AddDate(0, 0, 1)).
+				Return([]domain.Loan{loan, other}, nil)
+			repo.EXPECT().LockLoan(ctx, loan.LoanID).Return(nil)
+			repo.EXPECT().HasPaymentHolidayBetween(ctx, loan.LoanID, start, end).Return(false, nil)
+			repo.EXPECT().GetOpenSchedules(ctx, loan.LoanID).Return(schedules, nil)
+			repo.EXPECT().GrantPaymentHoliday(ctx, gomock.Any(), gomock.Any(), gomock.Any()).
+				DoAndReturn(func(_ any, holiday domain.PaymentHoliday, _ []domain.Schedule, _ time.Time) (*domain.PaymentHoliday, error) {
+					return &holiday, nil
+				})
+			repo.EXPECT().LockLoan(ctx, other.LoanID).Return(nil)
+			repo.EXPECT().HasPaymentHolidayBetween(ctx, other.LoanID, start, end).Return(false, nil)
+			repo.EXPECT().GetOpenSchedules(ctx, other.LoanID).Return(nil, someErr)
+			repo.EXPECT().CreateOutboxMessage(ctx, gomock.Any()).Return(nil)
+			cache.EXPECT().Get(ctx, gomock.Any()).Return(nil, nil).Times(2)
+
+			response, err := svc.GrantBulkPaymentHoliday(ctx, payload)
+			Expect(err).To(BeNil())
+			Expect(response.Granted).To(HaveLen(1))
+			Expect(response.Granted[0].LoanID).To(Equal(loan.LoanID))
+			Expect(response.Failed).To(HaveLen(1))
+			Expect(response.Failed[0].LoanID).To(Equal(other.LoanID))
+		})
+	})
+})
@@ -0,0 +1,100 @@
+package service
+
+import (
+	"billing-engine/internal/billing/domain"
+	"billing-engine/internal/billing/lifecycle"
+	"billing-engine/internal/billing/model"
+	"billing-engine/internal/billing/repository"
+	apperror "billing-engine/pkg/customerror"
+	"billing-engine/pkg/enum"
+	"billing-engine/pkg/producer"
+	"context"
+	"github.com/google/uuid"
+	"time"
+)
+
+// changeLoanStatus moves the loan to status to when its lifecycle allows it, records the change and
+// announces it with a LOAN_STATUS_CHANGED event.
+func (b BillingService) changeLoanStatus(ctx context.Context, loan domain.Loan, to enum.LoanStatus, reason string) error {
+	err := lifecycle.Validate(loan.Status, to)
+	if err != nil {
+		b.log.WithField("loan_id", loan.LoanID).
+			WithField("error", err.Error()).Error("[changeLoanStatus] invalid loan status transition")
+		return apperror.New(apperror.InvalidInput, err.Error())
+	}
+
+	history := domain.LoanStatusHistory{
+		LoanID:     loan.LoanID,
+		FromStatus: loan.Status,
+		ToStatus:   to,
+		Reason:     reason,
+		ChangedAt:  time.Now(),
+	}
+
+	return b.repo.WithTransaction(ctx, func(repo repository.BillingRepositoryProvider) error {
+		tx := b.withRepo(repo)
+		updated, err := tx.repo.UpdateLoanStatus(ctx, history)
+		if err != nil {
+			b.log.WithField("loan_id", loan.LoanID).
+				WithField("error", err.Error()).Error("[changeLoanStatus] Unexpected error when updating loan status")
+			return err
+		}
+
+		if !updated {
+			b.log.WithField("loan_id", loan.LoanID).Error("[changeLoanStatus] loan status changed concurrently")
+			return apperror.New(apperror.InvalidInput, "loan status was changed by another request")
+		}
+
+		return tx.publishStatusChange(ctx, loan, history)
+	})
+}
+
+// publishStatusChange writes the LOAN_STATUS_CHANGED event of a status change to the outbox, call it in the
+// transaction that stores the change.
+func (b BillingService) publishStatusChange(ctx context.Context, loan domain.Loan, history domain.LoanStatusHistory) error {
+	producerMessage := producer.Message{
+		EventID:   uuid.New().String(),
+		EventName: producer.EVENT_NAME_LOAN_STATUS_CHANGED,
+		Data: model.LoanStatusChangedEventPayload{
+			LoanID:     loan.LoanID,
+			CustomerID: loan.CustomerID,
+			FromStatus: history.FromStatus,
+			ToStatus:   history.ToStatus,
+			Reason:     history.Reason,
+			ChangedAt:  history.ChangedAt,
+		},
+	}
+
+	err := b.repo.CreateOutboxMessage(ctx, producerMessage)
+	if err != nil {
+		b.log.WithField("loan_id", loan.LoanID).
+			WithField("error", err.Error()).Error("[publishStatusChange] failed to write message to outbox")
+		return err
+	}
+
+	b.log.WithField("loan_id", loan.LoanID).
+		WithField("from_status", history.FromStatus).
+		WithField("to_status", history.ToStatus).Info("[publishStatusChange] loan status changed")
+	return nil
+}
+
+// payOffIfComplete marks a repaying loan PAID_OFF once none of its schedules expects money anymore.
+// A loan that is already paid off is left alone, so replayed payment events are harmless.
+func (b BillingService) payOffIfComplete(ctx context.Context, loan domain.Loan, reason string) error {
+	if !lifecycle.IsRepaying(loan.Status) {
+		return nil
+	}
+
+	schedules, err := b.repo.GetOpenSchedules(ctx, loan.LoanID)
+	if err != nil {
+		b.log.WithField("loan_id", loan.LoanID).
+			WithField("error", err.Error()).Error("[payOffIfComplete] Unexpected error when getting open schedules")
+		return err
+	}
+
+	if len(schedules) > 0 {
+		return nil
+	}
+
+	return b.changeLoanStatus(ctx, loan, enum.LoanStatusPaidOff, reason)
+}
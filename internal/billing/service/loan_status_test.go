@@ -0,0 +1,116 @@
+package service
+
+import (
+	"billing-engine/internal/billing/domain"
+	"billing-engine/internal/billing/mocks"
+	"billing-engine/internal/billing/model"
+	apperror "billing-engine/pkg/customerror"
+	"billing-engine/pkg/enum"
+	"billing-engine/pkg/producer"
+	"errors"
+	"github.com/google/uuid"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"go.uber.org/mock/gomock"
+)
+
+var _ = Describe("LoanStatus", func() {
+	var (
+		svc      *BillingService
+		repo     *mocks.MockBillingRepositoryProvider
+		cache    *mocks.MockBillingCacheProvider
+		loan     domain.Loan
+		schedule domain.Schedule
+		payload  model.PaymentEventPayload
+	)
+
+	BeforeEach(func() {
+		svc, repo, cache = newTestService()
+
+		loan = domain.Loan{LoanID: uuid.New(), CustomerID: uuid.New(), Status: enum.LoanStatusActive}
+		schedule = domain.Schedule{ScheduleID: uuid.New(), LoanID: loan.LoanID, PaymentNo: 3}
+		payload = model.PaymentEventPayload{
+			LoanID:    loan.LoanID,
+			PaymentID: uuid.New(),
+			Schedules: []model.ScheduleBalancePayload{
+				{ScheduleID: schedule.ScheduleID, PaymentStatus: enum.PaymentStatusPaid, PrincipalPaid: idr(100000)},
+			},
+		}
+	})
+
+	Describe("UpdatePayment", func() {
+		BeforeEach(func() {
+			repo.EXPECT().GetLoanByID(ctx, loan.LoanID).DoAndReturn(func(_ any, _ uuid.UUID) (*domain.Loan, error) {
+				return &loan, nil
+			})
+			repo.EXPECT().GetScheduleByID(ctx, schedule.ScheduleID).Return(&schedule, nil)
+			repo.EXPECT().UpdateSchedulePayment(ctx, gomock.Any()).Return(nil)
+		})
+
+		It("should pay off the loan on the final installment", func() {
+			repo.EXPECT().GetOpenSchedules(ctx, loan.LoanID).Return(nil, nil)
+			repo.EXPECT().UpdateLoanStatus(ctx, gomock.Any()).DoAndReturn(func(_ any, history domain.LoanStatusHistory) (bool, error) {
+				Expect(history.LoanID).To(Equal(loan.LoanID))
+				Expect(history.FromStatus).To(Equal(enum.LoanStatusActive))
+				Expect(history.ToStatus).To(Equal(enum.LoanStatusPaidOff))
+				Expect(history.ChangedAt).NotTo(BeZero())
+				return true, nil
+			})
+			repo.EXPECT().CreateOutboxMessage(ctx, gomock.Any()).DoAndReturn(func(_ any, message producer.Message) error {
+				Expect(message.EventName).To(Equal(producer.EVENT_NAME_LOAN_STATUS_CHANGED))
+				data := message.Data.(model.LoanStatusChangedEventPayload)
+				Expect(data.ToStatus).To(Equal(enum.LoanStatusPaidOff))
+				return nil
+			})
+			cache.EXPECT().Get(ctx, gomock.Any()).Return(nil, nil).Times(2)
+
+			Expect(svc.UpdatePayment(ctx, payload)).To(Succeed())
+		})
+
+		It("should keep the loan active while schedules are open", func() {
+			repo.EXPECT().GetOpenSchedules(ctx, loan.LoanID).Return([]domain.Schedule{{PaymentNo: 4}}, nil)
+			cache.EXPECT().Get(ctx, gomock.Any()).Return(nil, nil).Times(2)
+
+			Expect(svc.UpdatePayment(ctx, payload)).To(Succeed())
+		})
+
+		It("should keep what was paid beyond the balance as credit of the loan", func() {
+			payload.Credit = idr(10000)
+			repo.EXPECT().GetOpenSchedules(ctx, loan.LoanID).Return([]domain.Schedule{{PaymentNo: 4}}, nil)
+			repo.EXPECT().AddLoanCredit(ctx, loan.LoanID, idr(10000)).Return(nil)
+			cache.EXPECT().Get(ctx, gomock.Any()).Return(nil, nil).Times(2)
+
+			Expect(svc.UpdatePayment(ctx, payload)).To(Succeed())
+		})
+
+		It("should ignore a replayed final payment of a paid off loan", func() {
+			loan.Status = enum.LoanStatusPaidOff
+			cache.EXPECT().Get(ctx, gomock.Any()).Return(nil, nil).Times(2)
+
+			Expect(svc.UpdatePayment(ctx, payload)).To(Succeed())
+		})
+
+		It("when the loan status was changed concurrently", func() {
+			repo.EXPECT().GetOpenSchedules(ctx, loan.LoanID).Return(nil, nil)
+			repo.EXPECT().UpdateLoanStatus(ctx, gomock.Any()).Return(false, nil)
+
+			err := svc.UpdatePayment(ctx, payload)
+
+			var errs *apperror.CustomError
+			Expect(errors.As(err, &errs)).To(BeTrue())
+			Expect(errs.Cause).To(Equal(apperror.InvalidInput))
+		})
+	})
+
+	Describe("changeLoanStatus", func() {
+		It("should reject a transition the lifecycle does not allow", func() {
+			loan.Status = enum.LoanStatusPaidOff
+
+			err := svc.changeLoanStatus(ctx, loan, enum.LoanStatusActive, "reopen")
+
+			var errs *apperror.CustomError
+			Expect(errors.As(err, &errs)).To(BeTrue())
+			Expect(errs.Cause).To(Equal(apperror.InvalidInput))
+		})
+	})
+})
@@ -0,0 +1,216 @@
+package service
+
+import (
+	"billing-engine/internal/billing/delinquency"
+	"billing-engine/internal/billing/domain"
+	"billing-engine/internal/billing/model"
+	"billing-engine/internal/billing/penalty"
+	"billing-engine/internal/billing/repository"
+	"billing-engine/pkg/enum"
+	"billing-engine/pkg/money"
+	"billing-engine/pkg/producer"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"time"
+)
+
+// MarkOverdueSchedules flags every pending schedule that is past its due date as missed, refreshes its
+// days past due and accrues the penalties of the loan. A SCHEDULE_MISSED event is sent only the first
+// time a schedule is flagged, so the job can run as often as needed. A loan that fails is logged and left
+// for the next run, the other loans are still handled and the errors are returned together at the end.
+func (b BillingService) MarkOverdueSchedules(ctx context.Context, now time.Time) error {
+	b.log.WithField("now", now).Info("[MarkOverdueSchedules] looking for overdue schedules")
+
+	loans, err := b.repo.GetLoansWithOverdueSchedules(ctx, now)
+	if err != nil {
+		b.log.WithField("error", err.Error()).Error("[MarkOverdueSchedules] Unexpected error when getting overdue schedules")
+		return err
+	}
+
+	var errs []error
+	affectedCustomers := map[uuid.UUID]struct{}{}
+	for _, loan := range loans {
+		flagged, err := b.markLoanOverdue(ctx, loan, now)
+		if flagged {
+			affectedCustomers[loan.CustomerID] = struct{}{}
+		}
+
+		if err != nil {
+			b.log.WithField("loan_id", loan.LoanID).
+				WithField("error", err.Error()).Error("[MarkOverdueSchedules] failed to mark overdue schedules of loan")
+			errs = append(errs, fmt.Errorf("loan %s: %w", loan.LoanID, err))
+		}
+	}
+
+	for customerID := range affectedCustomers {
+		err = b.flushCache(ctx, customerID)
+		if err != nil {
+			b.log.WithField("customer_id", customerID).
+				WithField("error", err.Error()).Error("[MarkOverdueSchedules] failed to flush cache")
+			errs = append(errs, fmt.Errorf("customer %s: %w", customerID, err))
+		}
+	}
+
+	b.log.WithField("customers", len(affectedCustomers)).
+		WithField("failed", len(errs)).Info("[MarkOverdueSchedules] overdue schedules marked")
+	return errors.Join(errs...)
+}
+
+// markLoanOverdue handles the overdue schedules of one loan and reports whether any of them was stored, it
+// stops at the first schedule that fails.
+func (b BillingService) markLoanOverdue(ctx context.Context, loan domain.Loan, now time.Time) (bool, error) {
+	flagged := false
+	for _, schedule := range loan.Schedules {
+		dpd := delinquency.DaysPastDue(schedule.PaymentDueDate, now)
+		if dpd == 0 || (schedule.IsMissPayment && schedule.DaysPastDue == dpd) {
+			continue
+		}
+
+		// the penalties, the events and the flag are committed together, so a failed run leaves nothing
+		// behind and the next run handles the schedule again
+		err := b.repo.WithTransaction(ctx, func(repo repository.BillingRepositoryProvider) error {
+			return b.withRepo(repo).markOverdue(ctx, loan, schedule, dpd, now)
+		})
+		if err != nil {
+			return flagged, err
+		}
+		flagged = true
+	}
+
+	return flagged, nil
+}
+
+// markOverdue accrues the penalties of an overdue schedule, writes its events to the outbox and flags it
+// missed. Call it in a transaction.
+func (b BillingService) markOverdue(ctx context.Context, loan domain.Loan, schedule domain.Schedule, dpd int, now time.Time) error {
+	accrued, err := b.accruePenalties(ctx, loan, schedule, dpd, now)
+	if err != nil {
+		return err
+	}
+
+	// the event is only written the first time, a schedule that is already flagged only accrues
+	if !schedule.IsMissPayment {
+		producerMessage := producer.Message{
+			EventID:   uuid.New().String(),
+			EventName: producer.EVENT_NAME_SCHEDULE_MISSED,
+			Data: model.ScheduleMissedEventPayload{
+				LoanID:         loan.LoanID,
+				CustomerID:     loan.CustomerID,
+				ScheduleID:     schedule.ScheduleID,
+				PaymentNo:      schedule.PaymentNo,
+				PaymentDueDate: schedule.PaymentDueDate,
+				PaymentAmount:  schedule.PaymentAmount,
+				DaysPastDue:    dpd,
+			},
+		}
+
+		err = b.repo.CreateOutboxMessage(ctx, producerMessage)
+		if err != nil {
+			b.log.WithField("schedule_id", schedule.ScheduleID).
+				WithField("error", err.Error()).Error("[markOverdue] failed to write message to outbox")
+			return err
+		}
+	}
+
+	charged, err := penalty.Sum(schedule.Penalties)
+	if err != nil {
+		b.log.WithField("schedule_id", schedule.ScheduleID).
+			WithField("error", err.Error()).Error("[markOverdue] failed to add up penalties")
+		return err
+	}
+
+	totalPenalty, err := charged.Add(accrued)
+	if err != nil {
+		b.log.WithField("schedule_id", schedule.ScheduleID).
+			WithField("error", err.Error()).Error("[markOverdue] failed to add up penalties")
+		return err
+	}
+
+	if totalPenalty.IsPositive() {
+		producerMessage := producer.Message{
+			EventID:   uuid.New().String(),
+			EventName: producer.EVENT_NAME_PENALTY_ACCRUED,
+			Data: model.PenaltyAccruedEventPayload{
+				LoanID:       loan.LoanID,
+				CustomerID:   loan.CustomerID,
+				ScheduleID:   schedule.ScheduleID,
+				DaysPastDue:  dpd,
+				Accrued:      accrued,
+				TotalPenalty: totalPenalty,
+			},
+		}
+
+		err = b.repo.CreateOutboxMessage(ctx, producerMessage)
+		if err != nil {
+			b.log.WithField("schedule_id", schedule.ScheduleID).
+				WithField("error", err.Error()).Error("[markOverdue] failed to write message to outbox")
+			return err
+		}
+	}
+
+	err = b.repo.MarkScheduleMissed(ctx, schedule.ScheduleID, dpd)
+	if err != nil {
+		b.log.WithField("schedule_id", schedule.ScheduleID).
+			WithField("error", err.Error()).Error("[markOverdue] Unexpected error when marking schedule missed")
+		return err
+	}
+
+	return nil
+}
+
+// accruePenalties writes the charges due on an overdue schedule to the penalty ledger and returns their total.
+func (b BillingService) accruePenalties(ctx context.Context, loan domain.Loan, schedule domain.Schedule,
+	dpd int, now time.Time) (money.Money, error) {
+	rules := penalty.Rules{LateFee: loan.LateFee, DailyRate: loan.DailyPenaltyRate, Cap: loan.PenaltyCap}
+	unpaid, err := schedule.UnpaidAmount()
+	if err != nil {
+		b.log.WithField("schedule_id", schedule.ScheduleID).
+			WithField("error", err.Error()).Error("[accruePenalties] failed to compute the unpaid amount")
+		return money.Money{}, err
+	}
+
+	accrual, err := rules.Accrue(unpaid, dpd, schedule.Penalties)
+	if err != nil {
+		b.log.WithField("schedule_id", schedule.ScheduleID).
+			WithField("error", err.Error()).Error("[accruePenalties] failed to accrue penalties")
+		return money.Money{}, err
+	}
+
+	var penalties []domain.Penalty
+	charges := []struct {
+		penaltyType enum.PenaltyType
+		amount      money.Money
+	}{
+		{enum.PenaltyLateFee, accrual.LateFee},
+		{enum.PenaltyDaily, accrual.Daily},
+	}
+	for _, charge := range charges {
+		if !charge.amount.IsPositive() {
+			continue
+		}
+
+		penalties = append(penalties, domain.Penalty{
+			LoanID:      loan.LoanID,
+			ScheduleID:  schedule.ScheduleID,
+			Type:        charge.penaltyType,
+			Amount:      charge.amount,
+			DaysPastDue: dpd,
+			AccrualDate: now,
+		})
+	}
+
+	if len(penalties) == 0 {
+		return accrual.Total()
+	}
+
+	err = b.repo.CreatePenalties(ctx, penalties)
+	if err != nil {
+		b.log.WithField("schedule_id", schedule.ScheduleID).
+			WithField("error", err.Error()).Error("[accruePenalties] Unexpected error when creating penalties")
+		return money.Money{}, err
+	}
+
+	return accrual.Total()
+}
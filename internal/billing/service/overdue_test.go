@@ -0,0 +1,138 @@
+package service
+
+import (
+	"billing-engine/internal/billing/domain"
+	"billing-engine/internal/billing/mocks"
+	"billing-engine/internal/billing/model"
+	"billing-engine/pkg/enum"
+	"billing-engine/pkg/producer"
+	"github.com/google/uuid"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"go.uber.org/mock/gomock"
+	"time"
+)
+
+var _ = Describe("Overdue", func() {
+	var (
+		svc   *BillingService
+		repo  *mocks.MockBillingRepositoryProvider
+		cache *mocks.MockBillingCacheProvider
+		now   time.Time
+		loan  domain.Loan
+	)
+
+	BeforeEach(func() {
+		svc, repo, cache = newTestService()
+
+		now = time.Date(2024, time.March, 10, 9, 0, 0, 0, time.UTC)
+		loan = domain.Loan{
+			LoanID:     uuid.New(),
+			CustomerID: uuid.New(),
+			Schedules: []domain.Schedule{
+				{
+					ScheduleID:     uuid.New(),
+					PaymentNo:      1,
+					PaymentDueDate: now.AddDate(0, 0, -14),
+					PaymentAmount:  idr(110000),
+					PaymentStatus:  enum.PaymentStatusPending,
+					IsMissPayment:  true,
+					DaysPastDue:    13,
+				},
+				{
+					ScheduleID:     uuid.New(),
+					PaymentNo:      2,
+					PaymentDueDate: now.AddDate(0, 0, -7),
+					PaymentAmount:  idr(110000),
+					PaymentStatus:  enum.PaymentStatusPending,
+				},
+			},
+		}
+	})
+
+	It("should flag new misses once and refresh days past due", func() {
+		repo.EXPECT().GetLoansWithOverdueSchedules(ctx, now).Return([]domain.Loan{loan}, nil)
+		repo.EXPECT().CreateOutboxMessage(ctx, gomock.Any()).DoAndReturn(func(_ any, message producer.Message) error {
+			Expect(message.EventName).To(Equal(producer.EVENT_NAME_SCHEDULE_MISSED))
+			payload := message.Data.(model.ScheduleMissedEventPayload)
+			Expect(payload.ScheduleID).To(Equal(loan.Schedules[1].ScheduleID))
+			Expect(payload.DaysPastDue).To(Equal(7))
+			return nil
+		})
+		repo.EXPECT().MarkScheduleMissed(ctx, loan.Schedules[0].ScheduleID, 14).Return(nil)
+		repo.EXPECT().MarkScheduleMissed(ctx, loan.Schedules[1].ScheduleID, 7).Return(nil)
+		cache.EXPECT().Get(ctx, gomock.Any()).Return(nil, nil).Times(2)
+
+		Expect(svc.MarkOverdueSchedules(ctx, now)).To(Succeed())
+	})
+
+	It("should skip schedules that are already up to date", func() {
+		loan.Schedules = loan.Schedules[:1]
+		loan.Schedules[0].DaysPastDue = 14
+		repo.EXPECT().GetLoansWithOverdueSchedules(ctx, now).Return([]domain.Loan{loan}, nil)
+
+		Expect(svc.MarkOverdueSchedules(ctx, now)).To(Succeed())
+	})
+
+	It("should not flag the schedule when the event cannot be written", func() {
+		loan.Schedules = loan.Schedules[1:]
+		repo.EXPECT().GetLoansWithOverdueSchedules(ctx, now).Return([]domain.Loan{loan}, nil)
+		repo.EXPECT().CreateOutboxMessage(ctx, gomock.Any()).Return(someErr)
+
+		Expect(svc.MarkOverdueSchedules(ctx, now)).To(MatchError(someErr))
+	})
+
+	It("should keep marking the other loans when one loan fails", func() {
+		loan.Schedules = loan.Schedules[1:]
+		other := loan
+		other.LoanID = uuid.New()
+		other.Schedules = []domain.Schedule{loan.Schedules[0]}
+		other.Schedules[0].ScheduleID = uuid.New()
+		repo.EXPECT().GetLoansWithOverdueSchedules(ctx, now).Return([]domain.Loan{loan, other}, nil)
+		gomock.InOrder(
+			repo.EXPECT().CreateOutboxMessage(ctx, gomock.Any()).Return(someErr),
+			repo.EXPECT().CreateOutboxMessage(ctx, gomock.Any()).Return(nil),
+		)
+		repo.EXPECT().MarkScheduleMissed(ctx, other.Schedules[0].ScheduleID, 7).Return(nil)
+		cache.EXPECT().Get(ctx, gomock.Any()).Return(nil, nil).Times(2)
+
+		err := svc.MarkOverdueSchedules(ctx, now)
+		Expect(err).To(MatchError(someErr))
+		Expect(err.Error()).To(ContainSubstring(loan.LoanID.String()))
+	})
+
+	It("should accrue penalties and send the running total", func() {
+		loan.LateFee = idr(25000)
+		loan.DailyPenaltyRate = 0.001
+		loan.PenaltyCap = idr(0)
+		loan.Schedules = loan.Schedules[:1]
+		loan.Schedules[0].Penalties = []domain.Penalty{
+			{Type: enum.PenaltyLateFee, Amount: idr(25000)},
+			{Type: enum.PenaltyDaily, Amount: idr(1430)},
+		}
+
+		repo.EXPECT().GetLoansWithOverdueSchedules(ctx, now).Return([]domain.Loan{loan}, nil)
+		repo.EXPECT().CreatePenalties(ctx, gomock.Any()).DoAndReturn(func(_ any, penalties []domain.Penalty) error {
+			Expect(penalties).To(HaveLen(1))
+			Expect(penalties[0].Type).To(Equal(enum.PenaltyDaily))
+			Expect(penalties[0].Amount).To(Equal(idr(110)))
+			return nil
+		})
+		repo.EXPECT().CreateOutboxMessage(ctx, gomock.Any()).DoAndReturn(func(_ any, message producer.Message) error {
+			Expect(message.EventName).To(Equal(producer.EVENT_NAME_PENALTY_ACCRUED))
+			payload := message.Data.(model.PenaltyAccruedEventPayload)
+			Expect(payload.Accrued).To(Equal(idr(110)))
+			Expect(payload.TotalPenalty).To(Equal(idr(26540)))
+			return nil
+		})
+		repo.EXPECT().MarkScheduleMissed(ctx, loan.Schedules[0].ScheduleID, 14).Return(nil)
+		cache.EXPECT().Get(ctx, gomock.Any()).Return(nil, nil).Times(2)
+
+		Expect(svc.MarkOverdueSchedules(ctx, now)).To(Succeed())
+	})
+
+	It("when error getting overdue schedules", func() {
+		repo.EXPECT().GetLoansWithOverdueSchedules(ctx, now).Return(nil, someErr)
+		Expect(svc.MarkOverdueSchedules(ctx, now)).To(Equal(someErr))
+	})
+})
@@ -0,0 +1,231 @@
+package service
+
+import (
+	"billing-engine/internal/billing/constant"
+	"billing-engine/internal/billing/domain"
+	"billing-engine/internal/billing/lifecycle"
+	"billing-engine/internal/billing/model"
+	"billing-engine/internal/billing/penalty"
+	"billing-engine/internal/billing/repository"
+	apperror "billing-engine/pkg/customerror"
+	"billing-engine/pkg/enum"
+	"billing-engine/pkg/money"
+	"billing-engine/pkg/producer"
+	"context"
+	"fmt"
+	"github.com/google/uuid"
+	"time"
+)
+
+// GetPayoffQuote issues a quote that settles the loan in full and sends it to the payment service, which
+// accepts the settlement payment until the quote expires.
+func (b BillingService) GetPayoffQuote(ctx context.Context, loanID uuid.UUID) (*domain.PayoffQuote, error) {
+	b.log.WithField("loan_id", loanID).Info("[GetPayoffQuote] quoting loan payoff")
+
+	loan, err := b.repo.GetLoanByID(ctx, loanID)
+	if err != nil {
+		b.log.WithField("loan_id", loanID).
+			WithField("error", err.Error()).Error("[GetPayoffQuote] Unexpected error when getting loan")
+		return nil, err
+	}
+
+	if loan == nil {
+		b.log.WithField("loan_id", loanID).Info("[GetPayoffQuote] loan not found")
+		return nil, apperror.New(apperror.NotFound, "loan not found")
+	}
+
+	schedules, err := b.repo.GetOpenSchedules(ctx, loanID)
+	if err != nil {
+		b.log.WithField("loan_id", loanID).
+			WithField("error", err.Error()).Error("[GetPayoffQuote] Unexpected error when getting open schedules")
+		return nil, err
+	}
+
+	if !lifecycle.IsRepaying(loan.Status) || len(schedules) == 0 {
+		b.log.WithField("loan_id", loanID).Info("[GetPayoffQuote] loan has nothing left to settle")
+		return nil, apperror.New(apperror.InvalidInput, "loan has nothing left to settle")
+	}
+
+	var quote *domain.PayoffQuote
+	err = b.repo.WithTransaction(ctx, func(repo repository.BillingRepositoryProvider) error {
+		built, err := buildPayoffQuote(*loan, schedules, time.Now())
+		if err != nil {
+			b.log.WithField("loan_id", loanID).
+				WithField("error", err.Error()).Error("[GetPayoffQuote] failed to build payoff quote")
+			return err
+		}
+
+		quote, err = repo.CreatePayoffQuote(ctx, built)
+		if err != nil {
+			b.log.WithField("loan_id", loanID).
+				WithField("error", err.Error()).Error("[GetPayoffQuote] Unexpected error when creating payoff quote")
+			return err
+		}
+
+		producerMessage := producer.Message{
+			EventID:   uuid.New().String(),
+			EventName: producer.EVENT_NAME_PAYOFF_QUOTED,
+			Data:      quote,
+		}
+
+		err = repo.CreateOutboxMessage(ctx, producerMessage)
+		if err != nil {
+			b.log.WithField("loan_id", loanID).
+				WithField("error", err.Error()).Error("[GetPayoffQuote] failed to write message to outbox")
+			return err
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	b.log.WithField("quote_id", quote.QuoteID).Info("[GetPayoffQuote] payoff quote issued")
+	return quote, nil
+}
+
+// buildPayoffQuote collects everything unpaid on the open schedules. Interest of installments that fall due
+// after the quote expires is not earned yet, so it is rebated, and the early settlement fee is charged on
+// the principal of those installments.
+func buildPayoffQuote(loan domain.Loan, schedules []domain.Schedule, now time.Time) (domain.PayoffQuote, error) {
+	currency := loan.PrincipalAmount.Currency
+	quote := domain.PayoffQuote{
+		LoanID:     loan.LoanID,
+		CustomerID: loan.CustomerID,
+		QuoteDate:  now,
+		ValidUntil: now.AddDate(0, 0, constant.PAYOFF_QUOTE_VALIDITY_DAYS),
+		Status:     enum.QuoteStatusActive,
+	}
+
+	principal := []money.Money{money.Zero(currency)}
+	interest := []money.Money{money.Zero(currency)}
+	penalties := []money.Money{money.Zero(currency)}
+	rebates := []money.Money{money.Zero(currency)}
+	notYetDue := []money.Money{money.Zero(currency)}
+	for _, schedule := range schedules {
+		line, err := payoffQuoteLine(schedule, currency)
+		if err != nil {
+			return domain.PayoffQuote{}, err
+		}
+
+		if schedule.PaymentDueDate.After(quote.ValidUntil) {
+			line.InterestRebate = line.InterestAmount
+			line.InterestAmount = money.Zero(currency)
+			notYetDue = append(notYetDue, line.PrincipalAmount)
+		}
+
+		principal = append(principal, line.PrincipalAmount)
+		interest = append(interest, line.InterestAmount)
+		penalties = append(penalties, line.PenaltyAmount)
+		rebates = append(rebates, line.InterestRebate)
+		quote.Lines = append(quote.Lines, line)
+	}
+
+	var err error
+	if quote.PrincipalAmount, err = money.Sum(principal...); err != nil {
+		return domain.PayoffQuote{}, err
+	}
+
+	if quote.InterestAmount, err = money.Sum(interest...); err != nil {
+		return domain.PayoffQuote{}, err
+	}
+
+	if quote.PenaltyAmount, err = money.Sum(penalties...); err != nil {
+		return domain.PayoffQuote{}, err
+	}
+
+	if quote.InterestRebate, err = money.Sum(rebates...); err != nil {
+		return domain.PayoffQuote{}, err
+	}
+
+	settledEarly, err := money.Sum(notYetDue...)
+	if err != nil {
+		return domain.PayoffQuote{}, err
+	}
+
+	quote.SettlementFee = settledEarly.MulRate(loan.EarlySettlementFeeRate)
+	quote.SettlementAmount, err = money.Sum(quote.PrincipalAmount, quote.InterestAmount, quote.PenaltyAmount, quote.SettlementFee)
+	if err != nil {
+		return domain.PayoffQuote{}, err
+	}
+
+	return quote, nil
+}
+
+// payoffQuoteLine is what is still unpaid on the schedule.
+func payoffQuoteLine(schedule domain.Schedule, currency string) (domain.PayoffQuoteLine, error) {
+	principal, err := schedule.PrincipalAmount.Sub(schedule.PrincipalPaid)
+	if err != nil {
+		return domain.PayoffQuoteLine{}, err
+	}
+
+	interest, err := schedule.InterestAmount.Sub(schedule.InterestPaid)
+	if err != nil {
+		return domain.PayoffQuoteLine{}, err
+	}
+
+	charged, err := penalty.Sum(schedule.Penalties)
+	if err != nil {
+		return domain.PayoffQuoteLine{}, err
+	}
+
+	unpaidPenalty, err := charged.Sub(schedule.PenaltyPaid)
+	if err != nil {
+		return domain.PayoffQuoteLine{}, err
+	}
+
+	return domain.PayoffQuoteLine{
+		ScheduleID:      schedule.ScheduleID,
+		PaymentNo:       schedule.PaymentNo,
+		PrincipalAmount: principal,
+		InterestAmount:  interest,
+		PenaltyAmount:   unpaidPenalty,
+		InterestRebate:  money.Zero(currency),
+	}, nil
+}
+
+// SettleLoan closes a loan paid off with a payoff quote in the payment service.
+func (b BillingService) SettleLoan(ctx context.Context, payload model.LoanSettledEventPayload) error {
+	b.log.WithField("loan_id", payload.LoanID).
+		WithField("quote_id", payload.QuoteID).Info("[SettleLoan] settling loan")
+
+	loan, err := b.repo.GetLoanByID(ctx, payload.LoanID)
+	if err != nil {
+		b.log.WithField("loan_id", payload.LoanID).
+			WithField("error", err.Error()).Error("[SettleLoan] Unexpected error when getting loan")
+		return err
+	}
+
+	if loan == nil {
+		b.log.WithField("loan_id", payload.LoanID).Error("[SettleLoan] loan not found")
+		return apperror.New(apperror.NotFound, "loan not found")
+	}
+
+	err = b.applyScheduleBalances(ctx, *loan, payload.Schedules)
+	if err != nil {
+		return err
+	}
+
+	err = b.repo.SettlePayoffQuote(ctx, payload.QuoteID)
+	if err != nil {
+		b.log.WithField("quote_id", payload.QuoteID).
+			WithField("error", err.Error()).Error("[SettleLoan] Unexpected error when settling quote")
+		return err
+	}
+
+	err = b.payOffIfComplete(ctx, *loan, fmt.Sprintf("settled with payoff quote %s", payload.QuoteID))
+	if err != nil {
+		return err
+	}
+
+	err = b.flushCache(ctx, loan.CustomerID)
+	if err != nil {
+		b.log.WithField("loan_id", payload.LoanID).
+			WithField("error", err.Error()).Error("[SettleLoan] failed to flush cache")
+		return err
+	}
+
+	b.log.WithField("loan_id", payload.LoanID).Info("[SettleLoan] loan settled")
+	return nil
+}
@@ -0,0 +1,170 @@
+package service
+
+import (
+	"billing-engine/internal/billing/domain"
+	"billing-engine/internal/billing/mocks"
+	"billing-engine/internal/billing/model"
+	apperror "billing-engine/pkg/customerror"
+	"billing-engine/pkg/enum"
+	"billing-engine/pkg/producer"
+	"errors"
+	"github.com/google/uuid"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"go.uber.org/mock/gomock"
+	"time"
+)
+
+var _ = Describe("Payoff", func() {
+	var (
+		svc       *BillingService
+		repo      *mocks.MockBillingRepositoryProvider
+		cache     *mocks.MockBillingCacheProvider
+		now       time.Time
+		loan      domain.Loan
+		schedules []domain.Schedule
+	)
+
+	BeforeEach(func() {
+		svc, repo, cache = newTestService()
+
+		now = time.Date(2024, time.March, 10, 9, 0, 0, 0, time.UTC)
+		loan = domain.Loan{
+			LoanID:                 uuid.New(),
+			CustomerID:             uuid.New(),
+			PrincipalAmount:        idr(300000),
+			EarlySettlementFeeRate: 0.01,
+			Status:                 enum.LoanStatusActive,
+		}
+		schedules = []domain.Schedule{
+			{
+				ScheduleID:      uuid.New(),
+				PaymentNo:       1,
+				PaymentDueDate:  now.AddDate(0, 0, -3),
+				PrincipalAmount: idr(100000),
+				InterestAmount:  idr(10000),
+				InterestPaid:    idr(10000),
+				PenaltyPaid:     idr(1000),
+				Penalties:       []domain.Penalty{{Type: enum.PenaltyLateFee, Amount: idr(5000)}},
+			},
+			{
+				ScheduleID:      uuid.New(),
+				PaymentNo:       2,
+				PaymentDueDate:  now.AddDate(0, 0, 5),
+				PrincipalAmount: idr(100000),
+				InterestAmount:  idr(10000),
+			},
+			{
+				ScheduleID:      uuid.New(),
+				PaymentNo:       3,
+				PaymentDueDate:  now.AddDate(0, 1, 0),
+				PrincipalAmount: idr(100000),
+				InterestAmount:  idr(10000),
+			},
+		}
+	})
+
+	Describe("buildPayoffQuote", func() {
+		It("should rebate interest due after the quote expires and charge the fee on that principal", func() {
+			quote, err := buildPayoffQuote(loan, schedules, now)
+
+			Expect(err).To(BeNil())
+
+			Expect(quote.ValidUntil).To(Equal(now.AddDate(0, 0, 7)))
+			Expect(quote.PrincipalAmount).To(Equal(idr(300000)))
+			Expect(quote.InterestAmount).To(Equal(idr(10000)))
+			Expect(quote.PenaltyAmount).To(Equal(idr(4000)))
+			Expect(quote.InterestRebate).To(Equal(idr(10000)))
+			Expect(quote.SettlementFee).To(Equal(idr(1000)))
+			Expect(quote.SettlementAmount).To(Equal(idr(315000)))
+			Expect(quote.Lines).To(HaveLen(3))
+			Expect(quote.Status).To(Equal(enum.QuoteStatusActive))
+		})
+	})
+
+	Describe("GetPayoffQuote", func() {
+		It("should store the quote and send it to the payment service", func() {
+			repo.EXPECT().GetLoanByID(ctx, loan.LoanID).Return(&loan, nil)
+			repo.EXPECT().GetOpenSchedules(ctx, loan.LoanID).Return(schedules, nil)
+			repo.EXPECT().CreatePayoffQuote(ctx, gomock.Any()).DoAndReturn(func(_ any, quote domain.PayoffQuote) (*domain.PayoffQuote, error) {
+				quote.QuoteID = uuid.New()
+				return &quote, nil
+			})
+			repo.EXPECT().CreateOutboxMessage(ctx, gomock.Any()).DoAndReturn(func(_ any, message producer.Message) error {
+				Expect(message.EventName).To(Equal(producer.EVENT_NAME_PAYOFF_QUOTED))
+				return nil
+			})
+
+			quote, err := svc.GetPayoffQuote(ctx, loan.LoanID)
+			Expect(err).To(BeNil())
+			Expect(quote.LoanID).To(Equal(loan.LoanID))
+		})
+
+		It("when loan is already paid off", func() {
+			loan.Status = enum.LoanStatusPaidOff
+			repo.EXPECT().GetLoanByID(ctx, loan.LoanID).Return(&loan, nil)
+			repo.EXPECT().GetOpenSchedules(ctx, loan.LoanID).Return(schedules, nil)
+
+			_, err := svc.GetPayoffQuote(ctx, loan.LoanID)
+
+			var errs *apperror.CustomError
+			Expect(errors.As(err, &errs)).To(BeTrue())
+			Expect(errs.Cause).To(Equal(apperror.InvalidInput))
+		})
+
+		It("when loan has nothing left to settle", func() {
+			repo.EXPECT().GetLoanByID(ctx, loan.LoanID).Return(&loan, nil)
+			repo.EXPECT().GetOpenSchedules(ctx, loan.LoanID).Return(nil, nil)
+
+			_, err := svc.GetPayoffQuote(ctx, loan.LoanID)
+
+			var errs *apperror.CustomError
+			Expect(errors.As(err, &errs)).To(BeTrue())
+			Expect(errs.Cause).To(Equal(apperror.InvalidInput))
+		})
+
+		It("when loan not found", func() {
+			repo.EXPECT().GetLoanByID(ctx, gomock.Any()).Return(nil, nil)
+
+			_, err := svc.GetPayoffQuote(ctx, uuid.New())
+
+			var errs *apperror.CustomError
+			Expect(errors.As(err, &errs)).To(BeTrue())
+			Expect(errs.Cause).To(Equal(apperror.NotFound))
+		})
+	})
+
+	Describe("SettleLoan", func() {
+		It("should close the schedules and the quote and pay off the loan", func() {
+			payload := model.LoanSettledEventPayload{
+				LoanID:  loan.LoanID,
+				QuoteID: uuid.New(),
+				Schedules: []model.ScheduleBalancePayload{
+					{ScheduleID: schedules[0].ScheduleID, PaymentStatus: enum.PaymentStatusPaid, PrincipalPaid: idr(100000)},
+				},
+			}
+			schedules[0].LoanID = loan.LoanID
+
+			repo.EXPECT().GetLoanByID(ctx, loan.LoanID).Return(&loan, nil)
+			repo.EXPECT().GetScheduleByID(ctx, schedules[0].ScheduleID).Return(&schedules[0], nil)
+			repo.EXPECT().UpdateSchedulePayment(ctx, gomock.Any()).DoAndReturn(func(_ any, schedule *domain.Schedule) error {
+				Expect(schedule.PaymentStatus).To(Equal(enum.PaymentStatusPaid))
+				return nil
+			})
+			repo.EXPECT().SettlePayoffQuote(ctx, payload.QuoteID).Return(nil)
+			repo.EXPECT().GetOpenSchedules(ctx, loan.LoanID).Return(nil, nil)
+			repo.EXPECT().UpdateLoanStatus(ctx, gomock.Any()).DoAndReturn(func(_ any, history domain.LoanStatusHistory) (bool, error) {
+				Expect(history.FromStatus).To(Equal(enum.LoanStatusActive))
+				Expect(history.ToStatus).To(Equal(enum.LoanStatusPaidOff))
+				return true, nil
+			})
+			repo.EXPECT().CreateOutboxMessage(ctx, gomock.Any()).DoAndReturn(func(_ any, message producer.Message) error {
+				Expect(message.EventName).To(Equal(producer.EVENT_NAME_LOAN_STATUS_CHANGED))
+				return nil
+			})
+			cache.EXPECT().Get(ctx, gomock.Any()).Return(nil, nil).Times(2)
+
+			Expect(svc.SettleLoan(ctx, payload)).To(Succeed())
+		})
+	})
+})
@@ -0,0 +1,177 @@
+package service
+
+import (
+	"billing-engine/internal/billing/amortization"
+	"billing-engine/internal/billing/domain"
+	"billing-engine/internal/billing/model"
+	apperror "billing-engine/pkg/customerror"
+	"billing-engine/pkg/enum"
+	"billing-engine/pkg/money"
+	"context"
+	"fmt"
+	"github.com/google/uuid"
+	"math"
+)
+
+func (b BillingService) CreateProduct(ctx context.Context, payload model.ProductPayload) (*domain.Product, error) {
+	b.log.WithField("payload", payload).Info("[CreateProduct] creating loan product")
+
+	if err := validateProductAmounts(payload); err != nil {
+		b.log.WithField("payload", payload).WithField("error", err.Error()).Error("[CreateProduct] invalid product amounts")
+		return nil, err
+	}
+
+	if err := validateProductTerm(payload); err != nil {
+		b.log.WithField("payload", payload).WithField("error", err.Error()).Error("[CreateProduct] invalid product term")
+		return nil, err
+	}
+
+	product := domain.Product{}
+	b.applyProductPayload(&product, payload)
+
+	newProduct, err := b.repo.CreateProduct(ctx, product)
+	if err != nil {
+		b.log.WithField("payload", payload).
+			WithField("error", err.Error()).Error("[CreateProduct] Unexpected error when creating product")
+		return nil, err
+	}
+
+	b.log.WithField("product_id", newProduct.ProductID).Info("[CreateProduct] product created successfully")
+	return newProduct, nil
+}
+
+func (b BillingService) GetProducts(ctx context.Context) ([]domain.Product, error) {
+	products, err := b.repo.GetProducts(ctx)
+	if err != nil {
+		b.log.WithField("error", err.Error()).Error("[GetProducts] Unexpected error when getting products")
+		return nil, err
+	}
+
+	return products, nil
+}
+
+func (b BillingService) GetProduct(ctx context.Context, productID uuid.UUID) (*domain.Product, error) {
+	product, err := b.repo.GetProductByID(ctx, productID)
+	if err != nil {
+		b.log.WithField("product_id", productID).
+			WithField("error", err.Error()).Error("[GetProduct] Unexpected error when getting product")
+		return nil, err
+	}
+
+	if product == nil {
+		b.log.WithField("product_id", productID).Info("[GetProduct] product not found")
+		return nil, apperror.New(apperror.NotFound, "product not found")
+	}
+
+	return product, nil
+}
+
+func (b BillingService) UpdateProduct(ctx context.Context, payload model.UpdateProductPayload) (*domain.Product, error) {
+	b.log.WithField("payload", payload).Info("[UpdateProduct] updating loan product")
+
+	if err := validateProductAmounts(payload.ProductPayload); err != nil {
+		b.log.WithField("payload", payload).WithField("error", err.Error()).Error("[UpdateProduct] invalid product amounts")
+		return nil, err
+	}
+
+	if err := validateProductTerm(payload.ProductPayload); err != nil {
+		b.log.WithField("payload", payload).WithField("error", err.Error()).Error("[UpdateProduct] invalid product term")
+		return nil, err
+	}
+
+	product, err := b.GetProduct(ctx, payload.ProductID)
+	if err != nil {
+		return nil, err
+	}
+
+	// loans keep their own copy of the pricing, so changing the product only affects new loans
+	b.applyProductPayload(product, payload.ProductPayload)
+	err = b.repo.UpdateProduct(ctx, product)
+	if err != nil {
+		b.log.WithField("product_id", payload.ProductID).
+			WithField("error", err.Error()).Error("[UpdateProduct] Unexpected error when updating product")
+		return nil, err
+	}
+
+	b.log.WithField("product_id", payload.ProductID).Info("[UpdateProduct] product updated successfully")
+	return product, nil
+}
+
+func (b BillingService) DeleteProduct(ctx context.Context, productID uuid.UUID) error {
+	b.log.WithField("product_id", productID).Info("[DeleteProduct] deleting loan product")
+
+	_, err := b.GetProduct(ctx, productID)
+	if err != nil {
+		return err
+	}
+
+	err = b.repo.DeleteProduct(ctx, productID)
+	if err != nil {
+		b.log.WithField("product_id", productID).
+			WithField("error", err.Error()).Error("[DeleteProduct] Unexpected error when deleting product")
+		return err
+	}
+
+	b.log.WithField("product_id", productID).Info("[DeleteProduct] product deleted successfully")
+	return nil
+}
+
+func (b BillingService) applyProductPayload(product *domain.Product, payload model.ProductPayload) {
+	product.Name = payload.Name
+	product.InterestRate = payload.InterestRate
+	product.Tenor = payload.Tenor
+	product.InstallmentCount = payload.InstallmentCount
+	product.Frequency = payload.Frequency
+	product.IntervalDays = payload.IntervalDays
+	product.AmortizationMethod = payload.AmortizationMethod
+	product.AdminFee = payload.AdminFee
+	product.MinPrincipal = payload.MinPrincipal
+	product.MaxPrincipal = payload.MaxPrincipal
+	product.DailyPenaltyRate = payload.DailyPenaltyRate
+	product.EarlySettlementFeeRate = payload.EarlySettlementFeeRate
+	// penalty amounts are optional, an omitted one is stored as zero in the product currency
+	product.LateFee = money.New(payload.LateFee.Amount, payload.MinPrincipal.Currency)
+	product.PenaltyCap = money.New(payload.PenaltyCap.Amount, payload.MinPrincipal.Currency)
+}
+
+// validateProductAmounts checks what the struct tags cannot: every amount shares one supported currency
+// and the principal range is well-formed.
+func validateProductAmounts(payload model.ProductPayload) error {
+	if !money.IsSupported(payload.MinPrincipal.Currency) {
+		return apperror.New(apperror.InvalidInput, fmt.Sprintf("unsupported currency %q", payload.MinPrincipal.Currency))
+	}
+
+	if !money.SameCurrency(payload.MinPrincipal, payload.MaxPrincipal, payload.AdminFee) {
+		return apperror.New(apperror.InvalidInput, "all product amounts must use the same currency")
+	}
+
+	if payload.MinPrincipal.IsNegative() || payload.AdminFee.IsNegative() || payload.MaxPrincipal.Amount < payload.MinPrincipal.Amount {
+		return apperror.New(apperror.InvalidInput, "product amounts must be positive and min_principal must not exceed max_principal")
+	}
+
+	for _, val := range []money.Money{payload.LateFee, payload.PenaltyCap} {
+		if val.IsNegative() || (val.Currency != "" && val.Currency != payload.MinPrincipal.Currency) {
+			return apperror.New(apperror.InvalidInput, "penalty amounts must be positive and in the product currency")
+		}
+	}
+
+	return nil
+}
+
+// validateProductTerm checks that the installments cover the tenor: the installment count at the product
+// frequency has to come to the tenor in months, rounded to whole months.
+func validateProductTerm(payload model.ProductPayload) error {
+	if payload.Frequency == enum.FrequencyCustom && payload.IntervalDays <= 0 {
+		return apperror.New(apperror.InvalidInput, "interval_days must be positive for a custom frequency")
+	}
+
+	periodsPerYear := amortization.PeriodsPerYear(payload.Frequency, payload.IntervalDays)
+	months := int(math.Round(float64(payload.InstallmentCount) * 12 / periodsPerYear))
+	if months != payload.Tenor {
+		return apperror.New(apperror.InvalidInput,
+			fmt.Sprintf("%d %s installments last %d months, which does not match the tenor of %d months",
+				payload.InstallmentCount, payload.Frequency, months, payload.Tenor))
+	}
+
+	return nil
+}
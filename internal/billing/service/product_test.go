@@ -0,0 +1,143 @@
+package service
+
+import (
+	"billing-engine/internal/billing/domain"
+	"billing-engine/internal/billing/mocks"
+	"billing-engine/internal/billing/model"
+	apperror "billing-engine/pkg/customerror"
+	"billing-engine/pkg/enum"
+	"billing-engine/pkg/money"
+	"errors"
+	"github.com/google/uuid"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"go.uber.org/mock/gomock"
+)
+
+var _ = Describe("Product", func() {
+	var (
+		svc     *BillingService
+		repo    *mocks.MockBillingRepositoryProvider
+		payload model.ProductPayload
+	)
+
+	BeforeEach(func() {
+		svc, repo, _ = newTestService()
+
+		payload = model.ProductPayload{
+			Name:             "Monthly Micro Loan",
+			InterestRate:     0.2,
+			Tenor:            6,
+			InstallmentCount: 6,
+			Frequency:        enum.FrequencyMonthly,
+			AdminFee:         idr(50000),
+			MinPrincipal:     idr(1000000),
+			MaxPrincipal:     idr(5000000),
+		}
+	})
+
+	Describe("CreateProduct", func() {
+		It("should map the payload into the product", func() {
+			repo.EXPECT().CreateProduct(ctx, gomock.Any()).DoAndReturn(func(_ any, product domain.Product) (*domain.Product, error) {
+				Expect(product.Name).To(Equal(payload.Name))
+				Expect(product.InterestRate).To(Equal(payload.InterestRate))
+				Expect(product.InstallmentCount).To(Equal(payload.InstallmentCount))
+				Expect(product.MaxPrincipal).To(Equal(payload.MaxPrincipal))
+				Expect(product.PenaltyCap).To(Equal(money.Zero(payload.MinPrincipal.Currency)))
+				return &product, nil
+			})
+
+			product, err := svc.CreateProduct(ctx, payload)
+			Expect(err).To(BeNil())
+			Expect(product.Tenor).To(Equal(payload.Tenor))
+		})
+
+		It("when product amounts mix currencies", func() {
+			payload.AdminFee = money.New(5, "USD")
+			_, err := svc.CreateProduct(ctx, payload)
+
+			var errs *apperror.CustomError
+			ok := errors.As(err, &errs)
+			Expect(ok).To(BeTrue())
+			Expect(errs.Cause).To(Equal(apperror.InvalidInput))
+		})
+
+		It("when a penalty amount is negative", func() {
+			payload.LateFee = money.New(-1, "IDR")
+			_, err := svc.CreateProduct(ctx, payload)
+
+			var errs *apperror.CustomError
+			ok := errors.As(err, &errs)
+			Expect(ok).To(BeTrue())
+			Expect(errs.Cause).To(Equal(apperror.InvalidInput))
+		})
+
+		It("when the installments do not cover the tenor", func() {
+			payload.Frequency = enum.FrequencyWeekly
+			_, err := svc.CreateProduct(ctx, payload)
+
+			var errs *apperror.CustomError
+			ok := errors.As(err, &errs)
+			Expect(ok).To(BeTrue())
+			Expect(errs.Cause).To(Equal(apperror.InvalidInput))
+		})
+
+		It("should accept weekly installments that come to the tenor", func() {
+			payload.Frequency = enum.FrequencyWeekly
+			payload.InstallmentCount = 26
+			repo.EXPECT().CreateProduct(ctx, gomock.Any()).DoAndReturn(func(_ any, product domain.Product) (*domain.Product, error) {
+				return &product, nil
+			})
+
+			_, err := svc.CreateProduct(ctx, payload)
+			Expect(err).To(BeNil())
+		})
+
+		It("when error on create product", func() {
+			repo.EXPECT().CreateProduct(ctx, gomock.Any()).Return(nil, someErr)
+			_, err := svc.CreateProduct(ctx, payload)
+			Expect(err).To(Equal(someErr))
+		})
+	})
+
+	Describe("UpdateProduct", func() {
+		It("should update an existing product", func() {
+			productID := uuid.New()
+			repo.EXPECT().GetProductByID(ctx, productID).Return(&domain.Product{ProductID: productID}, nil)
+			repo.EXPECT().UpdateProduct(ctx, gomock.Any()).Return(nil)
+
+			product, err := svc.UpdateProduct(ctx, model.UpdateProductPayload{ProductID: productID, ProductPayload: payload})
+			Expect(err).To(BeNil())
+			Expect(product.ProductID).To(Equal(productID))
+			Expect(product.Name).To(Equal(payload.Name))
+		})
+
+		It("when product not found", func() {
+			repo.EXPECT().GetProductByID(ctx, gomock.Any()).Return(nil, nil)
+			_, err := svc.UpdateProduct(ctx, model.UpdateProductPayload{ProductID: uuid.New(), ProductPayload: payload})
+
+			var errs *apperror.CustomError
+			ok := errors.As(err, &errs)
+			Expect(ok).To(BeTrue())
+			Expect(errs.Cause).To(Equal(apperror.NotFound))
+		})
+	})
+
+	Describe("DeleteProduct", func() {
+		It("should delete an existing product", func() {
+			productID := uuid.New()
+			repo.EXPECT().GetProductByID(ctx, productID).Return(&domain.Product{ProductID: productID}, nil)
+			repo.EXPECT().DeleteProduct(ctx, productID).Return(nil)
+
+			Expect(svc.DeleteProduct(ctx, productID)).To(Succeed())
+		})
+
+		It("when error on delete product", func() {
+			productID := uuid.New()
+			repo.EXPECT().GetProductByID(ctx, productID).Return(&domain.Product{ProductID: productID}, nil)
+			repo.EXPECT().DeleteProduct(ctx, productID).Return(someErr)
+
+			Expect(svc.DeleteProduct(ctx, productID)).To(Equal(someErr))
+		})
+	})
+})
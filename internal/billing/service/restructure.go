@@ -0,0 +1,214 @@
+package service
+
+import (
+	"billing-engine/internal/billing/amortization"
+	"billing-engine/internal/billing/domain"
+	"billing-engine/internal/billing/lifecycle"
+	"billing-engine/internal/billing/model"
+	"billing-engine/internal/billing/repository"
+	apperror "billing-engine/pkg/customerror"
+	"billing-engine/pkg/enum"
+	"billing-engine/pkg/money"
+	"billing-engine/pkg/producer"
+	"context"
+	"github.com/google/uuid"
+	"time"
+)
+
+// RestructureLoan replaces the remaining schedules of a loan with a new schedule on the agreed terms. The
+// replaced schedules are kept as CANCELLED and the payment service is told to swap its copy.
+func (b BillingService) RestructureLoan(ctx context.Context, payload model.RestructureLoanPayload) (*model.RestructureLoanResponse, error) {
+	b.log.WithField("loan_id", payload.LoanID).Info("[RestructureLoan] restructuring loan")
+
+	loan, err := b.repo.GetLoanByID(ctx, payload.LoanID)
+	if err != nil {
+		b.log.WithField("loan_id", payload.LoanID).
+			WithField("error", err.Error()).Error("[RestructureLoan] Unexpected error when getting loan")
+		return nil, err
+	}
+
+	if loan == nil {
+		b.log.WithField("loan_id", payload.LoanID).Info("[RestructureLoan] loan not found")
+		return nil, apperror.New(apperror.NotFound, "loan not found")
+	}
+
+	err = lifecycle.Validate(loan.Status, enum.LoanStatusRestructured)
+	if err != nil {
+		b.log.WithField("loan_id", payload.LoanID).
+			WithField("error", err.Error()).Info("[RestructureLoan] loan cannot be restructured")
+		return nil, apperror.New(apperror.InvalidInput, err.Error())
+	}
+
+	schedules, err := b.repo.GetOpenSchedules(ctx, payload.LoanID)
+	if err != nil {
+		b.log.WithField("loan_id", payload.LoanID).
+			WithField("error", err.Error()).Error("[RestructureLoan] Unexpected error when getting open schedules")
+		return nil, err
+	}
+
+	now := time.Now()
+	restructure, cancelledIDs, err := planRestructure(*loan, schedules, payload, now)
+	if err != nil {
+		b.log.WithField("loan_id", payload.LoanID).
+			WithField("error", err.Error()).Info("[RestructureLoan] failed to plan restructure")
+		return nil, err
+	}
+
+	restructured := *loan
+	restructured.StartDate = now
+	restructured.InterestRate = restructure.InterestRate
+	totalAmount, newSchedules, err := b.scheduleMaker(restructured, amortization.Terms{
+		Principal:        restructure.NewPrincipal,
+		AnnualRate:       restructure.InterestRate,
+		InstallmentCount: payload.InstallmentCount,
+		PeriodsPerYear:   amortization.PeriodsPerYear(loan.Frequency, loan.IntervalDays),
+	}, lastPaymentNo(schedules)+1, payload.GracePeriods)
+	if err != nil {
+		b.log.WithField("loan_id", payload.LoanID).
+			WithField("error", err.Error()).Error("[RestructureLoan] failed to generate payment schedule")
+		return nil, apperror.New(apperror.InvalidInput, err.Error())
+	}
+
+	for i := range newSchedules {
+		newSchedules[i].LoanID = loan.LoanID
+	}
+
+	restructure.Schedules = newSchedules
+	restructured.EndDate = newSchedules[len(newSchedules)-1].PaymentDueDate
+	var stored *domain.Restructure
+	err = b.repo.WithTransaction(ctx, func(repo repository.BillingRepositoryProvider) error {
+		tx := b.withRepo(repo)
+
+		var err error
+		stored, err = tx.repo.RestructureLoan(ctx, restructured, restructure, cancelledIDs)
+		if err != nil {
+			b.log.WithField("loan_id", payload.LoanID).
+				WithField("error", err.Error()).Error("[RestructureLoan] Unexpected error when restructuring loan")
+			return err
+		}
+
+		err = tx.changeLoanStatus(ctx, *loan, enum.LoanStatusRestructured, payload.Reason)
+		if err != nil {
+			return err
+		}
+
+		producerMessage := producer.Message{
+			EventID:   uuid.New().String(),
+			EventName: producer.EVENT_NAME_LOAN_RESTRUCTURED,
+			Data: model.LoanRestructuredEventPayload{
+				LoanID:               loan.LoanID,
+				CustomerID:           loan.CustomerID,
+				RestructureID:        stored.RestructureID,
+				CancelledScheduleIDs: cancelledIDs,
+				Schedules:            stored.Schedules,
+			},
+		}
+
+		err = tx.repo.CreateOutboxMessage(ctx, producerMessage)
+		if err != nil {
+			b.log.WithField("loan_id", payload.LoanID).
+				WithField("error", err.Error()).Error("[RestructureLoan] failed to write message to outbox")
+			return err
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = b.flushCache(ctx, loan.CustomerID)
+	if err != nil {
+		b.log.WithField("loan_id", payload.LoanID).
+			WithField("error", err.Error()).Error("[RestructureLoan] failed to flush cache")
+		return nil, err
+	}
+
+	b.log.WithField("restructure_id", stored.RestructureID).Info("[RestructureLoan] loan restructured")
+	return &model.RestructureLoanResponse{
+		LoanID:               loan.LoanID,
+		RestructureID:        stored.RestructureID,
+		Status:               enum.LoanStatusRestructured,
+		OutstandingPrincipal: stored.OutstandingPrincipal,
+		CapitalisedArrears:   stored.CapitalisedArrears,
+		NewPrincipal:         stored.NewPrincipal,
+		InterestRate:         stored.InterestRate,
+		TotalAmount:          totalAmount,
+		Schedules:            b.MapScheduleResponse(stored.Schedules),
+	}, nil
+}
+
+// planRestructure works out which open schedules are replaced and the principal of the new schedule. Overdue
+// installments are only replaced when their arrears are capitalised, their unpaid interest and penalties are
+// then added to the principal. Unpaid interest of installments not due yet is dropped, the new schedule
+// charges interest again.
+func planRestructure(loan domain.Loan, schedules []domain.Schedule, payload model.RestructureLoanPayload, now time.Time) (domain.Restructure, []uuid.UUID, error) {
+	currency := loan.PrincipalAmount.Currency
+	restructure := domain.Restructure{
+		LoanID:               loan.LoanID,
+		Reason:               payload.Reason,
+		OutstandingPrincipal: money.Zero(currency),
+		CapitalisedArrears:   money.Zero(currency),
+		PreviousInterestRate: loan.InterestRate,
+		InterestRate:         loan.InterestRate,
+		InstallmentCount:     payload.InstallmentCount,
+		GracePeriods:         payload.GracePeriods,
+		RestructuredAt:       now,
+	}
+
+	if payload.InterestRate != nil {
+		restructure.InterestRate = *payload.InterestRate
+	}
+
+	var cancelledIDs []uuid.UUID
+	principal := []money.Money{money.Zero(currency)}
+	arrears := []money.Money{money.Zero(currency)}
+	for _, schedule := range schedules {
+		overdue := !schedule.PaymentDueDate.After(now)
+		if overdue && !payload.CapitaliseArrears {
+			continue
+		}
+
+		unpaid, err := payoffQuoteLine(schedule, currency)
+		if err != nil {
+			return domain.Restructure{}, nil, err
+		}
+
+		cancelledIDs = append(cancelledIDs, schedule.ScheduleID)
+		principal = append(principal, unpaid.PrincipalAmount)
+		if overdue {
+			arrears = append(arrears, unpaid.InterestAmount, unpaid.PenaltyAmount)
+		}
+	}
+
+	var err error
+	if restructure.OutstandingPrincipal, err = money.Sum(principal...); err != nil {
+		return domain.Restructure{}, nil, err
+	}
+
+	if restructure.CapitalisedArrears, err = money.Sum(arrears...); err != nil {
+		return domain.Restructure{}, nil, err
+	}
+
+	restructure.NewPrincipal, err = restructure.OutstandingPrincipal.Add(restructure.CapitalisedArrears)
+	if err != nil {
+		return domain.Restructure{}, nil, err
+	}
+
+	if !restructure.NewPrincipal.IsPositive() {
+		return domain.Restructure{}, nil, apperror.New(apperror.InvalidInput, "loan has nothing left to restructure")
+	}
+
+	return restructure, cancelledIDs, nil
+}
+
+func lastPaymentNo(schedules []domain.Schedule) int {
+	last := 0
+	for _, schedule := range schedules {
+		if schedule.PaymentNo > last {
+			last = schedule.PaymentNo
+		}
+	}
+
+	return last
+}
@@ -0,0 +1,168 @@
+package service
+
+import (
+	"billing-engine/internal/billing/domain"
+	"billing-engine/internal/billing/mocks"
+	"billing-engine/internal/billing/model"
+	apperror "billing-engine/pkg/customerror"
+	"billing-engine/pkg/enum"
+	"billing-engine/pkg/producer"
+	"errors"
+	"github.com/google/uuid"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"go.uber.org/mock/gomock"
+	"time"
+)
+
+var _ = Describe("Restructure", func() {
+	var (
+		svc       *BillingService
+		repo      *mocks.MockBillingRepositoryProvider
+		cache     *mocks.MockBillingCacheProvider
+		now       time.Time
+		loan      domain.Loan
+		schedules []domain.Schedule
+		payload   model.RestructureLoanPayload
+	)
+
+	BeforeEach(func() {
+		svc, repo, cache = newTestService()
+
+		now = time.Now()
+		loan = domain.Loan{
+			LoanID:             uuid.New(),
+			CustomerID:         uuid.New(),
+			PrincipalAmount:    idr(300000),
+			InterestRate:       0.12,
+			Frequency:          enum.FrequencyMonthly,
+			AmortizationMethod: enum.AmortizationFlat,
+			Status:             enum.LoanStatusActive,
+		}
+		schedules = []domain.Schedule{
+			{
+				ScheduleID:      uuid.New(),
+				PaymentNo:       1,
+				PaymentDueDate:  now.AddDate(0, 0, -3),
+				PrincipalAmount: idr(100000),
+				InterestAmount:  idr(10000),
+				Penalties:       []domain.Penalty{{Type: enum.PenaltyLateFee, Amount: idr(5000)}},
+			},
+			{
+				ScheduleID:      uuid.New(),
+				PaymentNo:       2,
+				PaymentDueDate:  now.AddDate(0, 0, 5),
+				PrincipalAmount: idr(100000),
+				InterestAmount:  idr(10000),
+			},
+			{
+				ScheduleID:      uuid.New(),
+				PaymentNo:       3,
+				PaymentDueDate:  now.AddDate(0, 1, 0),
+				PrincipalAmount: idr(100000),
+				InterestAmount:  idr(10000),
+				PrincipalPaid:   idr(20000),
+			},
+		}
+		payload = model.RestructureLoanPayload{
+			LoanID:           loan.LoanID,
+			InstallmentCount: 6,
+			Reason:           "crop failure",
+		}
+	})
+
+	Describe("planRestructure", func() {
+		It("should only reschedule installments not due yet without capitalising arrears", func() {
+			restructure, cancelledIDs, err := planRestructure(loan, schedules, payload, now)
+			Expect(err).To(BeNil())
+			Expect(cancelledIDs).To(Equal([]uuid.UUID{schedules[1].ScheduleID, schedules[2].ScheduleID}))
+			Expect(restructure.OutstandingPrincipal).To(Equal(idr(180000)))
+			Expect(restructure.CapitalisedArrears.IsZero()).To(BeTrue())
+			Expect(restructure.NewPrincipal).To(Equal(idr(180000)))
+			Expect(restructure.InterestRate).To(Equal(0.12))
+		})
+
+		It("should add overdue interest and penalties to the principal when capitalising arrears", func() {
+			rate := 0.06
+			payload.CapitaliseArrears = true
+			payload.InterestRate = &rate
+
+			restructure, cancelledIDs, err := planRestructure(loan, schedules, payload, now)
+			Expect(err).To(BeNil())
+			Expect(cancelledIDs).To(HaveLen(3))
+			Expect(restructure.OutstandingPrincipal).To(Equal(idr(280000)))
+			Expect(restructure.CapitalisedArrears).To(Equal(idr(15000)))
+			Expect(restructure.NewPrincipal).To(Equal(idr(295000)))
+			Expect(restructure.PreviousInterestRate).To(Equal(0.12))
+			Expect(restructure.InterestRate).To(Equal(0.06))
+		})
+
+		It("should refuse when only overdue installments are left and arrears are not capitalised", func() {
+			_, _, err := planRestructure(loan, schedules[:1], payload, now)
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Describe("RestructureLoan", func() {
+		It("should replace the remaining schedules and tell the payment service", func() {
+			payload.CapitaliseArrears = true
+			payload.GracePeriods = 1
+
+			repo.EXPECT().GetLoanByID(ctx, loan.LoanID).Return(&loan, nil)
+			repo.EXPECT().GetOpenSchedules(ctx, loan.LoanID).Return(schedules, nil)
+			repo.EXPECT().RestructureLoan(ctx, gomock.Any(), gomock.Any(), gomock.Any()).
+				DoAndReturn(func(_ any, restructured domain.Loan, restructure domain.Restructure, cancelledIDs []uuid.UUID) (*domain.Restructure, error) {
+					Expect(cancelledIDs).To(HaveLen(3))
+					Expect(restructure.Schedules).To(HaveLen(6))
+					Expect(restructure.Schedules[0].PaymentNo).To(Equal(4))
+					Expect(restructure.Schedules[0].LoanID).To(Equal(loan.LoanID))
+					Expect(restructure.Schedules[0].PaymentDueDate).To(BeTemporally("~", addMonthsClamped(now, 2), time.Minute))
+					Expect(restructured.EndDate).To(Equal(restructure.Schedules[5].PaymentDueDate))
+
+					restructure.RestructureID = uuid.New()
+					return &restructure, nil
+				})
+			repo.EXPECT().UpdateLoanStatus(ctx, gomock.Any()).Return(true, nil)
+			repo.EXPECT().CreateOutboxMessage(ctx, gomock.Any()).DoAndReturn(func(_ any, message producer.Message) error {
+				Expect(message.EventName).To(Equal(producer.EVENT_NAME_LOAN_STATUS_CHANGED))
+				return nil
+			})
+			repo.EXPECT().CreateOutboxMessage(ctx, gomock.Any()).DoAndReturn(func(_ any, message producer.Message) error {
+				Expect(message.EventName).To(Equal(producer.EVENT_NAME_LOAN_RESTRUCTURED))
+				data := message.Data.(model.LoanRestructuredEventPayload)
+				Expect(data.CancelledScheduleIDs).To(HaveLen(3))
+				Expect(data.Schedules).To(HaveLen(6))
+				return nil
+			})
+			cache.EXPECT().Get(ctx, gomock.Any()).Return(nil, nil).Times(2)
+
+			response, err := svc.RestructureLoan(ctx, payload)
+			Expect(err).To(BeNil())
+			Expect(response.Status).To(Equal(enum.LoanStatusRestructured))
+			Expect(response.NewPrincipal).To(Equal(idr(295000)))
+			// flat interest of 12% a year over six months
+			Expect(response.TotalAmount).To(Equal(idr(312700)))
+		})
+
+		It("when loan is paid off", func() {
+			loan.Status = enum.LoanStatusPaidOff
+			repo.EXPECT().GetLoanByID(ctx, loan.LoanID).Return(&loan, nil)
+
+			_, err := svc.RestructureLoan(ctx, payload)
+
+			var errs *apperror.CustomError
+			Expect(errors.As(err, &errs)).To(BeTrue())
+			Expect(errs.Cause).To(Equal(apperror.InvalidInput))
+		})
+
+		It("when loan not found", func() {
+			repo.EXPECT().GetLoanByID(ctx, gomock.Any()).Return(nil, nil)
+
+			_, err := svc.RestructureLoan(ctx, payload)
+
+			var errs *apperror.CustomError
+			Expect(errors.As(err, &errs)).To(BeTrue())
+			Expect(errs.Cause).To(Equal(apperror.NotFound))
+		})
+	})
+})
@@ -0,0 +1,34 @@
+package service
+
+import (
+	"billing-engine/pkg/enum"
+	"time"
+)
+
+// installmentDueDate returns the due date of the n-th installment counted from start. Every date is derived
+// from start rather than from the previous installment, so month-end clamping never drifts the schedule.
+func installmentDueDate(start time.Time, frequency enum.Frequency, intervalDays, n int) time.Time {
+	switch frequency {
+	case enum.FrequencyWeekly:
+		return start.AddDate(0, 0, 7*n)
+	case enum.FrequencyBiWeekly:
+		return start.AddDate(0, 0, 14*n)
+	case enum.FrequencyCustom:
+		return start.AddDate(0, 0, intervalDays*n)
+	default:
+		return addMonthsClamped(start, n)
+	}
+}
+
+// addMonthsClamped adds months to t, clamping the day to the end of the target month
+// so that Jan 31 + 1 month is Feb 28/29 instead of rolling over into March.
+func addMonthsClamped(t time.Time, months int) time.Time {
+	year, month, day := t.Date()
+	firstOfTarget := time.Date(year, month+time.Month(months), 1, t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), t.Location())
+	lastDay := firstOfTarget.AddDate(0, 1, -1).Day()
+	if day > lastDay {
+		day = lastDay
+	}
+
+	return firstOfTarget.AddDate(0, 0, day-1)
+}
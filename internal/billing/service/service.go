@@ -1,41 +1,66 @@
 package service
 
 import (
+	"billing-engine/internal/billing/amortization"
 	"billing-engine/internal/billing/constant"
+	"billing-engine/internal/billing/delinquency"
+	"billing-engine/internal/billing/disbursement"
 	"billing-engine/internal/billing/domain"
+	"billing-engine/internal/billing/eligibility"
 	"billing-engine/internal/billing/model"
 	"billing-engine/internal/billing/repository"
 	apperror "billing-engine/pkg/customerror"
 	"billing-engine/pkg/enum"
 	"billing-engine/pkg/logger"
+	"billing-engine/pkg/money"
 	"billing-engine/pkg/producer"
 	"context"
 	"encoding/json"
 	"fmt"
-	"github.com/brianvoe/gofakeit/v7"
 	"github.com/google/uuid"
-	"math"
 	"time"
 )
 
 type BillingServiceProvider interface {
 	CreateLoan(ctx context.Context, payload model.CreateLoanPayload) (*model.CreateLoanResponse, error)
+	ApproveLoan(ctx context.Context, payload model.ApproveLoanPayload) (*model.CreateLoanResponse, error)
+	RejectLoan(ctx context.Context, payload model.RejectLoanPayload) (*model.CreateLoanResponse, error)
+	DisburseLoan(ctx context.Context, payload model.DisburseLoanPayload) (*model.DisburseLoanResponse, error)
+	CancelLoan(ctx context.Context, payload model.CancelLoanPayload) (*model.CancelLoanResponse, error)
+	TopUpLoan(ctx context.Context, payload model.TopUpLoanPayload) (*model.TopUpLoanResponse, error)
+	GetCreditLimit(ctx context.Context, customerID uuid.UUID) (*model.CreditLimitResponse, error)
+	UpdateCreditLimit(ctx context.Context, payload model.UpdateCreditLimitPayload) (*model.CreditLimitResponse, error)
 	GetPaymentSchedule(ctx context.Context, request model.GetSchedulePayload) (*model.GetScheduleResponse, error)
 	IsCustomerDelinquency(ctx context.Context, customerID uuid.UUID) (*model.IsDelinquentResponse, error)
 	GetOutstandingBalance(ctx context.Context, customerID uuid.UUID) (*model.GetOutstandingBalanceResponse, error)
 	ProcessMessage(ctx context.Context, payload []byte) error
-
-	// CreateCustomer NOTE: this method is out of context, so I will just merge it in the billing service
-	CreateCustomer(ctx context.Context, payload model.CreateCustomerPayload) (*model.GetCustomerResponse, error)
-	// GetCustomer NOTE: this method is out of context, so I will just merge it in the billing service
-	GetCustomer(ctx context.Context) ([]domain.Customer, error)
+	MarkOverdueSchedules(ctx context.Context, now time.Time) error
+	GetPayoffQuote(ctx context.Context, loanID uuid.UUID) (*domain.PayoffQuote, error)
+	RestructureLoan(ctx context.Context, payload model.RestructureLoanPayload) (*model.RestructureLoanResponse, error)
+	GrantPaymentHoliday(ctx context.Context, payload model.PaymentHolidayPayload) (*model.PaymentHolidayResponse, error)
+	GrantBulkPaymentHoliday(ctx context.Context, payload model.BulkPaymentHolidayPayload) (*model.BulkPaymentHolidayResponse, error)
+	WriteOffLoan(ctx context.Context, payload model.WriteOffLoanPayload) (*model.WriteOffLoanResponse, error)
+
+	CreateProduct(ctx context.Context, payload model.ProductPayload) (*domain.Product, error)
+	GetProducts(ctx context.Context) ([]domain.Product, error)
+	GetProduct(ctx context.Context, productID uuid.UUID) (*domain.Product, error)
+	UpdateProduct(ctx context.Context, payload model.UpdateProductPayload) (*domain.Product, error)
+	DeleteProduct(ctx context.Context, productID uuid.UUID) error
+
+	CreateCustomer(ctx context.Context, payload model.CustomerPayload) (*model.GetCustomerResponse, error)
+	UpdateCustomer(ctx context.Context, payload model.UpdateCustomerPayload) (*model.GetCustomerResponse, error)
+	GetCustomer(ctx context.Context, customerID uuid.UUID) (*model.GetCustomerResponse, error)
+	SearchCustomers(ctx context.Context, payload model.SearchCustomersPayload) (*model.SearchCustomersResponse, error)
+	DeactivateCustomer(ctx context.Context, customerID uuid.UUID) (*model.GetCustomerResponse, error)
 }
 
 type BillingService struct {
-	repo     repository.BillingRepositoryProvider
-	log      logger.Logger
-	cache    repository.BillingCacheProvider
-	producer producer.ProducerProvider
+	repo        repository.BillingRepositoryProvider
+	log         logger.Logger
+	cache       repository.BillingCacheProvider
+	policy      delinquency.Policy
+	eligibility eligibility.Policy
+	disburser   disbursement.Disburser
 }
 
 func (b BillingService) CreateLoan(ctx context.Context, payload model.CreateLoanPayload) (*model.CreateLoanResponse, error) {
@@ -52,71 +77,163 @@ func (b BillingService) CreateLoan(ctx context.Context, payload model.CreateLoan
 		return nil, apperror.New(apperror.NotFound, "customer not found")
 	}
 
-	var loanSchema []domain.Schedule
+	if customer.DeactivatedAt != nil {
+		b.log.WithField("customer_id", payload.CustomerID).Info("[CreateLoan] customer is deactivated")
+		return nil, apperror.New(apperror.InvalidInput, "customer is deactivated")
+	}
+
+	product, err := b.repo.GetProductByID(ctx, payload.ProductID)
+	if err != nil {
+		b.log.WithField("product_id", payload.ProductID).
+			WithField("error", err.Error()).Error("[CreateLoan] Unexpected error when getting product")
+		return nil, err
+	}
+
+	if product == nil {
+		b.log.WithField("product_id", payload.ProductID).Error("[CreateLoan] product not found")
+		return nil, apperror.New(apperror.NotFound, "product not found")
+	}
 
-	loan := domain.Loan{
-		CustomerID:      payload.CustomerID,
-		PrincipalAmount: payload.LoanAmount,
-		InterestRate:    constant.INTEREST_RATE,
-		StartDate:       time.Now(),
-		EndDate:         time.Now().AddDate(0, 5, 0),
+	if payload.LoanAmount.Currency != product.MinPrincipal.Currency {
+		b.log.WithField("product_id", payload.ProductID).
+			WithField("loan_amount", payload.LoanAmount).Error("[CreateLoan] loan currency does not match product")
+		return nil, apperror.New(apperror.InvalidInput,
+			fmt.Sprintf("loan amount must be in %s", product.MinPrincipal.Currency))
 	}
 
-	totalLoan, loanSchema := b.paymentSchemaMaker(loan)
-	loan.Schedules = loanSchema
+	// the range is enforced whatever eligibility rules are configured, the rule only reports it to the applicant
+	if !payload.LoanAmount.IsPositive() ||
+		payload.LoanAmount.Amount < product.MinPrincipal.Amount || payload.LoanAmount.Amount > product.MaxPrincipal.Amount {
+		b.log.WithField("product_id", payload.ProductID).
+			WithField("loan_amount", payload.LoanAmount).Error("[CreateLoan] loan amount out of product range")
+		return nil, apperror.New(apperror.InvalidInput,
+			fmt.Sprintf("loan amount must be positive and between %s and %s", product.MinPrincipal, product.MaxPrincipal))
+	}
 
-	b.log.WithField("customer_id", payload.CustomerID).Info("[CreateLoan] creating loan for customer")
-	newLoan, err := b.repo.CreateLoan(ctx, loan)
+	now := time.Now()
+	loan := loanFromProduct(payload.CustomerID, *product, payload.LoanAmount, enum.LoanStatusPendingApproval, now)
+
+	// the schedule is only generated when the loan is activated, but terms that cannot be amortized are
+	// better refused right away
+	_, _, err = b.paymentSchemaMaker(loan)
+	if err != nil {
+		b.log.WithField("product_id", payload.ProductID).
+			WithField("error", err.Error()).Error("[CreateLoan] failed to generate payment schedule")
+		return nil, apperror.New(apperror.InvalidInput, err.Error())
+	}
+
+	var newLoan *domain.Loan
+	var result eligibility.Result
+	err = b.repo.WithTransaction(ctx, func(repo repository.BillingRepositoryProvider) error {
+		tx := b.withRepo(repo)
+
+		// the customer stays locked until the loan is stored, so a concurrent application waits and counts it
+		err := tx.repo.LockCustomer(ctx, payload.CustomerID)
+		if err != nil {
+			b.log.WithField("customer_id", payload.CustomerID).
+				WithField("error", err.Error()).Error("[CreateLoan] Unexpected error when locking customer")
+			return err
+		}
+
+		err = tx.checkCreditLimit(ctx, *customer, payload.LoanAmount)
+		if err != nil {
+			return err
+		}
+
+		result, err = tx.evaluateApplication(ctx, *customer, *product, payload.LoanAmount, false)
+		if err != nil {
+			return err
+		}
+
+		loan.StatusHistory = []domain.LoanStatusHistory{
+			{ToStatus: enum.LoanStatusPendingApproval, Reason: "application received", ChangedAt: now},
+		}
+		if !result.Eligible {
+			loan.Status = enum.LoanStatusRejected
+			loan.StatusHistory = append(loan.StatusHistory, domain.LoanStatusHistory{
+				FromStatus: enum.LoanStatusPendingApproval,
+				ToStatus:   enum.LoanStatusRejected,
+				Reason:     "not eligible: " + result.Reason(),
+				ChangedAt:  now,
+			})
+		}
+
+		b.log.WithField("customer_id", payload.CustomerID).Info("[CreateLoan] creating loan application for customer")
+		newLoan, err = tx.repo.CreateLoan(ctx, loan)
+		if err != nil {
+			b.log.WithField("customer_id", payload.CustomerID).
+				WithField("error", err.Error()).Info("[CreateLoan] Unexpected error when creating loan")
+			return err
+		}
+
+		return nil
+	})
 	if err != nil {
-		b.log.WithField("customer_id", payload.CustomerID).
-			WithField("error", err.Error()).Info("[CreateLoan] Unexpected error when creating loan")
 		return nil, err
 	}
 
 	b.log.WithField("customer_id", payload.CustomerID).
-		WithField("loan", loan).Info("[CreateLoan] loan created successfully")
+		WithField("loan_id", newLoan.LoanID).
+		WithField("status", newLoan.Status).Info("[CreateLoan] loan application created successfully")
 
-	newEventID := uuid.New().String()
-	producerMessage := producer.Message{
-		EventID:   newEventID,
-		EventName: producer.EVENT_NAME_LOAN_CREATED,
-		Data:      newLoan,
+	if newLoan.Status != enum.LoanStatusPendingApproval || !b.eligibility.AutoApprove {
+		return b.mapLoanResponse(*newLoan, money.Zero(payload.LoanAmount.Currency), result), nil
 	}
 
-	b.log.WithField("customer_id", payload.CustomerID).
-		WithField("producer_payload", producerMessage).Info("[CreateLoan] sending message to producer")
-	err = b.producer.SendMessage(ctx, producerMessage)
+	return b.approveLoan(ctx, *newLoan, "auto-approved", result)
+}
+
+func (b BillingService) paymentSchemaMaker(loan domain.Loan) (money.Money, []domain.Schedule, error) {
+	return b.scheduleMaker(loan, amortization.Terms{
+		Principal:        loan.PrincipalAmount,
+		AnnualRate:       loan.InterestRate,
+		InstallmentCount: loan.InstallmentCount,
+		PeriodsPerYear:   amortization.PeriodsPerYear(loan.Frequency, loan.IntervalDays),
+	}, 1, 0)
+}
+
+// scheduleMaker amortizes terms over installments numbered from firstPaymentNo. The first installment is due
+// one period after loan.StartDate, or gracePeriods periods later than that.
+func (b BillingService) scheduleMaker(loan domain.Loan, terms amortization.Terms, firstPaymentNo, gracePeriods int) (money.Money, []domain.Schedule, error) {
+	method, err := amortization.New(loan.AmortizationMethod)
 	if err != nil {
-		b.log.WithField("customer_id", payload.CustomerID).
-			WithField("error", err.Error()).Error("[CreateLoan][SendMessage] failed to send message to producer")
-		return nil, err
+		return money.Money{}, nil, err
 	}
 
-	return &model.CreateLoanResponse{
-		LoanID:     newLoan.LoanID,
-		CustomerID: newLoan.CustomerID,
-		LoanAmount: totalLoan,
-		Schedules:  b.MapScheduleResponse(newLoan.Schedules),
-	}, nil
-}
+	installments, err := method.Installments(terms)
+	if err != nil {
+		return money.Money{}, nil, err
+	}
 
-func (b BillingService) paymentSchemaMaker(loan domain.Loan) (float64, []domain.Schedule) {
+	totalLoan := money.Zero(terms.Principal.Currency)
 	var newSchedule []domain.Schedule
-	totalLoan := math.Round(loan.PrincipalAmount + (loan.PrincipalAmount * loan.InterestRate))
+	for i, installment := range installments {
+		amount, err := installment.Amount()
+		if err != nil {
+			return money.Money{}, nil, err
+		}
 
-	paymentPerMonth := math.Round(totalLoan / constant.MAX_PAYMENT)
+		totalLoan, err = totalLoan.Add(amount)
+		if err != nil {
+			return money.Money{}, nil, err
+		}
 
-	for i := 1; i <= constant.MAX_PAYMENT; i++ {
 		newSchedule = append(newSchedule, domain.Schedule{
-			PaymentNo:      i,
-			PaymentDueDate: time.Now().AddDate(0, i, 0),
-			PaymentAmount:  math.Round(paymentPerMonth),
-			PaymentStatus:  enum.PaymentStatusPending,
-			IsMissPayment:  false,
+			PaymentNo:          firstPaymentNo + i,
+			PaymentDueDate:     installmentDueDate(loan.StartDate, loan.Frequency, loan.IntervalDays, gracePeriods+i+1),
+			PaymentAmount:      amount,
+			PrincipalAmount:    installment.Principal,
+			InterestAmount:     installment.Interest,
+			RemainingPrincipal: installment.RemainingPrincipal,
+			PrincipalPaid:      money.Zero(loan.PrincipalAmount.Currency),
+			InterestPaid:       money.Zero(loan.PrincipalAmount.Currency),
+			PenaltyPaid:        money.Zero(loan.PrincipalAmount.Currency),
+			PaymentStatus:      enum.PaymentStatusPending,
+			IsMissPayment:      false,
 		})
 	}
 
-	return totalLoan, newSchedule
+	return totalLoan, newSchedule, nil
 }
 
 func (b BillingService) GetPaymentSchedule(ctx context.Context, request model.GetSchedulePayload) (*model.GetScheduleResponse, error) {
@@ -192,36 +309,66 @@ func (b BillingService) IsCustomerDelinquency(ctx context.Context, customerID uu
 		return nil, apperror.New(apperror.NotFound, "customer not found")
 	}
 
-	latestLoan, err := b.repo.LastActiveLoan(ctx, customerID)
+	loans, err := b.repo.GetActiveLoans(ctx, customerID)
 	if err != nil {
 		b.log.WithField("customer_id", customerID).
-			WithField("error", err.Error()).Error("[GetLatestActiveLoan] Unexpected error when getting loan")
+			WithField("error", err.Error()).Error("[GetActiveLoans] Unexpected error when getting loans")
 		return nil, err
 	}
 
-	// we only get the unpaid and miss payment until now
-	loanSchedule, err := b.repo.GetUnpaidAndMissPaymentUntil(ctx, latestLoan.LoanID, time.Now())
-	if err != nil {
-		b.log.WithField("customer_id", customerID).
-			WithField("error", err.Error()).Error("[GetLatestActiveLoan] Unexpected error when getting loan")
-		return nil, err
+	now := time.Now()
+	results := make([]delinquency.Result, 0, len(loans))
+	resp.Loans = make([]model.LoanDelinquencyResponse, 0, len(loans))
+	for _, loan := range loans {
+		// the overdue job flags every schedule that passed its due date unpaid, so we only need the missed ones
+		var missed []domain.Schedule
+		missed, err = b.repo.GetMissedSchedules(ctx, loan.LoanID)
+		if err != nil {
+			b.log.WithField("customer_id", customerID).
+				WithField("loan_id", loan.LoanID).
+				WithField("error", err.Error()).Error("[GetMissedSchedules] Unexpected error when getting missed schedules")
+			return nil, err
+		}
+
+		result := b.policy.Evaluate(missed, now)
+		results = append(results, result)
+
+		loanResp := b.mapDelinquencyResult(result)
+		loanResp.LoanID = loan.LoanID
+		resp.Loans = append(resp.Loans, loanResp)
 	}
 
-	if loanSchedule == nil || len(loanSchedule) < 2 {
-		return resp, nil
+	worst := b.mapDelinquencyResult(delinquency.Worst(results))
+	resp.IsDelinquent = worst.IsDelinquent
+	resp.DaysPastDue = worst.DaysPastDue
+	resp.DPDBucket = worst.DPDBucket
+	resp.MatchedRule = worst.MatchedRule
+	resp.OldestOverdueSchedule = worst.OldestOverdueSchedule
+
+	return resp, err
+}
+
+func (b BillingService) mapDelinquencyResult(result delinquency.Result) model.LoanDelinquencyResponse {
+	resp := model.LoanDelinquencyResponse{
+		IsDelinquent: result.IsDelinquent,
+		DaysPastDue:  result.DaysPastDue,
+		DPDBucket:    string(result.Bucket),
 	}
 
-	// since we only get the unpaid and miss payment until now, we can assume that the loan schedule is sorted,
-	// so we can just check the difference between the payment number
-	// if the difference is 1 then the customer is delinquent
-	for i := 0; i < len(loanSchedule)-1; i++ {
-		if loanSchedule[i+1].PaymentNo-loanSchedule[i].PaymentNo == 1 {
-			resp.IsDelinquent = true
-			break
+	if result.MatchedRule != nil {
+		resp.MatchedRule = &model.DelinquencyRuleResponse{
+			Name:      result.MatchedRule.Name,
+			Type:      string(result.MatchedRule.Type),
+			Threshold: result.MatchedRule.Threshold,
 		}
 	}
 
-	return resp, err
+	if result.OldestOverdue != nil {
+		oldest := b.MapScheduleResponse([]domain.Schedule{*result.OldestOverdue})
+		resp.OldestOverdueSchedule = &oldest[0]
+	}
+
+	return resp
 }
 
 func (b BillingService) GetOutstandingBalance(ctx context.Context, customerID uuid.UUID) (*model.GetOutstandingBalanceResponse, error) {
@@ -260,60 +407,89 @@ func (b BillingService) GetOutstandingBalance(ctx context.Context, customerID uu
 		return nil, apperror.New(apperror.NotFound, "customer not found")
 	}
 
-	lastActiveLoan, err := b.repo.LastActiveLoan(ctx, customerID)
+	loans, err := b.repo.GetActiveLoans(ctx, customerID)
 	if err != nil {
 		b.log.WithField("customer_id", customerID).
-			WithField("error", err.Error()).Error("[GetLatestActiveLoan] Unexpected error when getting loan")
+			WithField("error", err.Error()).Error("[GetActiveLoans] Unexpected error when getting loans")
 		return nil, err
 	}
 
-	totalOutstandingBalance, err := b.repo.GetTotalUnpaidPaymentOnActiveLoan(ctx, lastActiveLoan.LoanID)
+	// a loan that was overpaid keeps the excess as credit, also once it is paid off
+	closed, err := b.repo.GetClosedLoansWithCredit(ctx, customerID)
 	if err != nil {
 		b.log.WithField("customer_id", customerID).
-			WithField("error", err.Error()).Error("[GetTotalOutstandingBalance] Unexpected error when getting total outstanding balance")
+			WithField("error", err.Error()).Error("[GetOutstandingBalance] Unexpected error when getting loans with credit")
 		return nil, err
 	}
 
-	resp.OutstandingBalance = totalOutstandingBalance
-	err = b.cache.Set(ctx, cacheKey, &resp)
-	if err != nil {
-		b.log.WithField("customer_id", customerID).
-			WithField("error", err.Error()).Error("[GetOutstandingBalance - Cache Set] Unexpected error when setting cache")
-		return nil, err
+	repaying := len(loans)
+	loans = append(loans, closed...)
+	currency := money.DefaultCurrency
+	if len(loans) > 0 {
+		currency = loans[0].PrincipalAmount.Currency
 	}
 
-	return &resp, nil
-}
+	resp.OutstandingBalance = money.Zero(currency)
+	resp.PenaltyBalance = money.Zero(currency)
+	resp.CreditBalance = money.Zero(currency)
+	resp.Loans = make([]model.LoanOutstandingResponse, 0, len(loans))
+	for i, loan := range loans {
+		if loan.PrincipalAmount.Currency != currency {
+			b.log.WithField("customer_id", customerID).
+				WithField("loan_id", loan.LoanID).Error("[GetOutstandingBalance] customer has loans in more than one currency")
+			return nil, apperror.New(apperror.InternalError, "customer has loans in more than one currency")
+		}
 
-func (b BillingService) CreateCustomer(ctx context.Context, payload model.CreateCustomerPayload) (*model.GetCustomerResponse, error) {
-	var customers []domain.Customer
-	for i := 0; i < payload.TotalCustomer; i++ {
-		customer := domain.Customer{
-			FirstName: gofakeit.FirstName(),
-			LastName:  gofakeit.LastName(),
-			Email:     gofakeit.Email(),
+		credit := money.New(loan.CreditBalance.Amount, currency)
+		resp.CreditBalance = money.New(resp.CreditBalance.Amount+credit.Amount, currency)
+		if i >= repaying {
+			// a closed loan owes nothing, it is only listed for its credit
+			resp.Loans = append(resp.Loans, model.LoanOutstandingResponse{
+				LoanID:             loan.LoanID,
+				Status:             loan.Status,
+				OutstandingBalance: money.Zero(currency),
+				PenaltyBalance:     money.Zero(currency),
+				CreditBalance:      credit,
+			})
+			continue
 		}
 
-		customers = append(customers, customer)
-	}
+		totalOutstandingBalance, err := b.repo.GetTotalUnpaidPaymentOnActiveLoan(ctx, loan.LoanID)
+		if err != nil {
+			b.log.WithField("customer_id", customerID).
+				WithField("error", err.Error()).Error("[GetTotalOutstandingBalance] Unexpected error when getting total outstanding balance")
+			return nil, err
+		}
 
-	err := b.repo.CreateCustomer(ctx, customers)
-	if err != nil {
-		b.log.WithField("error", err.Error()).Error("[CreateCustomer] Unexpected error when creating customer")
-		return nil, err
-	}
+		totalPenalty, err := b.repo.GetTotalUnpaidPenaltyOnActiveLoan(ctx, loan.LoanID)
+		if err != nil {
+			b.log.WithField("customer_id", customerID).
+				WithField("error", err.Error()).Error("[GetTotalUnpaidPenalty] Unexpected error when getting total unpaid penalty")
+			return nil, err
+		}
 
-	return nil, nil
-}
+		// every loan was checked to be in currency, so the balances are added up as plain amounts
+		loanResp := model.LoanOutstandingResponse{
+			LoanID:             loan.LoanID,
+			Status:             loan.Status,
+			OutstandingBalance: money.New(totalOutstandingBalance+totalPenalty, currency),
+			PenaltyBalance:     money.New(totalPenalty, currency),
+			CreditBalance:      credit,
+		}
 
-func (b BillingService) GetCustomer(ctx context.Context) ([]domain.Customer, error) {
-	customers, err := b.repo.GetCustomer(ctx)
+		resp.OutstandingBalance = money.New(resp.OutstandingBalance.Amount+loanResp.OutstandingBalance.Amount, currency)
+		resp.PenaltyBalance = money.New(resp.PenaltyBalance.Amount+loanResp.PenaltyBalance.Amount, currency)
+		resp.Loans = append(resp.Loans, loanResp)
+	}
+
+	err = b.cache.Set(ctx, cacheKey, &resp)
 	if err != nil {
-		b.log.WithField("error", err.Error()).Error("[GetCustomer] Unexpected error when getting customer")
+		b.log.WithField("customer_id", customerID).
+			WithField("error", err.Error()).Error("[GetOutstandingBalance - Cache Set] Unexpected error when setting cache")
 		return nil, err
 	}
 
-	return customers, nil
+	return &resp, nil
 }
 
 func (b BillingService) MapScheduleResponse(schedule []domain.Schedule) []model.ScheduleResponse {
@@ -328,59 +504,97 @@ func (b BillingService) MapScheduleResponse(schedule []domain.Schedule) []model.
 			PaymentAmount:  val.PaymentAmount,
 			PaymentStatus:  val.PaymentStatus,
 			IsMissPayment:  val.IsMissPayment,
+
+			PrincipalAmount:    val.PrincipalAmount,
+			InterestAmount:     val.InterestAmount,
+			RemainingPrincipal: val.RemainingPrincipal,
+			PrincipalPaid:      val.PrincipalPaid,
+			InterestPaid:       val.InterestPaid,
+			PenaltyPaid:        val.PenaltyPaid,
 		})
 	}
 
 	return scheduleResp
 }
 
+// UpdatePayment copies the installment balances of a payment allocated by the payment service. The event
+// carries the state after the payment rather than the amounts, so a replayed event changes nothing.
+// Payments on a written-off loan are booked as recoveries instead, whatever the payment service allocated.
 func (b BillingService) UpdatePayment(ctx context.Context, payload model.PaymentEventPayload) error {
-	b.log.WithField("schedule_id", payload.ScheduleID).Info("[UpdatePayment] updating payment schedule")
+	b.log.WithField("loan_id", payload.LoanID).
+		WithField("payment_id", payload.PaymentID).Info("[UpdatePayment] updating payment schedules")
 
-	loan, err := b.repo.GetLoanByScheduleID(ctx, payload.ScheduleID)
+	loan, err := b.repo.GetLoanByID(ctx, payload.LoanID)
 	if err != nil {
-		b.log.WithField("schedule_id", payload.ScheduleID).
-			WithField("error", err.Error()).Error("[UpdatePayment] Unexpected error when getting schedule")
+		b.log.WithField("loan_id", payload.LoanID).
+			WithField("error", err.Error()).Error("[UpdatePayment] Unexpected error when getting loan")
 		return err
 	}
 
 	if loan == nil {
-		b.log.WithField("schedule_id", payload.ScheduleID).Error("[UpdatePayment] loan not found")
+		b.log.WithField("loan_id", payload.LoanID).Error("[UpdatePayment] loan not found")
 		return apperror.New(apperror.NotFound, "loan not found")
 	}
 
-	schedule, err := b.repo.GetScheduleByID(ctx, payload.ScheduleID)
+	if loan.Status == enum.LoanStatusWrittenOff {
+		err = b.bookRecovery(ctx, *loan, payload)
+	} else {
+		err = b.applyScheduleBalances(ctx, *loan, payload.Schedules)
+		if err == nil {
+			err = b.payOffIfComplete(ctx, *loan, fmt.Sprintf("final installment paid by payment %s", payload.PaymentID))
+		}
+	}
 	if err != nil {
-		b.log.WithField("schedule_id", payload.ScheduleID).
-			WithField("error", err.Error()).Error("[UpdatePayment] Unexpected error when getting schedule")
 		return err
 	}
 
-	if schedule == nil {
-		b.log.WithField("schedule_id", payload.ScheduleID).Error("[UpdatePayment] schedule not found")
-		return apperror.New(apperror.NotFound, "schedule not found")
-	}
-
-	if schedule.PaymentStatus == enum.PaymentStatusPaid {
-		return nil
-	}
-
-	schedule.PaymentStatus = enum.PaymentStatusPaid
-	err = b.repo.UpdateSchedulePayment(ctx, schedule)
-	if err != nil {
-		b.log.WithField("schedule_id", payload.ScheduleID).
-			WithField("error", err.Error()).Error("[UpdatePayment] Unexpected error when updating schedule")
-		return err
+	if payload.Credit.IsPositive() {
+		err = b.repo.AddLoanCredit(ctx, loan.LoanID, payload.Credit)
+		if err != nil {
+			b.log.WithField("loan_id", payload.LoanID).
+				WithField("error", err.Error()).Error("[UpdatePayment] Unexpected error when adding loan credit")
+			return err
+		}
 	}
 
 	err = b.flushCache(ctx, loan.CustomerID)
 	if err != nil {
-		b.log.WithField("schedule_id", payload.ScheduleID).
+		b.log.WithField("loan_id", payload.LoanID).
 			WithField("error", err.Error()).Error("[UpdatePayment] failed to flush cache")
 		return err
 	}
 
-	b.log.WithField("schedule_id", payload.ScheduleID).Info("[UpdatePayment] schedule updated successfully")
+	b.log.WithField("payment_id", payload.PaymentID).Info("[UpdatePayment] schedules updated successfully")
+	return nil
+}
+
+// applyScheduleBalances copies the installment balances sent by the payment service.
+func (b BillingService) applyScheduleBalances(ctx context.Context, loan domain.Loan, balances []model.ScheduleBalancePayload) error {
+	for _, balance := range balances {
+		schedule, err := b.repo.GetScheduleByID(ctx, balance.ScheduleID)
+		if err != nil {
+			b.log.WithField("schedule_id", balance.ScheduleID).
+				WithField("error", err.Error()).Error("[applyScheduleBalances] Unexpected error when getting schedule")
+			return err
+		}
+
+		if schedule == nil || schedule.LoanID != loan.LoanID {
+			b.log.WithField("schedule_id", balance.ScheduleID).Error("[applyScheduleBalances] schedule not found")
+			return apperror.New(apperror.NotFound, "schedule not found")
+		}
+
+		schedule.PaymentStatus = balance.PaymentStatus
+		schedule.PrincipalPaid = balance.PrincipalPaid
+		schedule.InterestPaid = balance.InterestPaid
+		schedule.PenaltyPaid = balance.PenaltyPaid
+		err = b.repo.UpdateSchedulePayment(ctx, schedule)
+		if err != nil {
+			b.log.WithField("schedule_id", balance.ScheduleID).
+				WithField("error", err.Error()).Error("[applyScheduleBalances] Unexpected error when updating schedule")
+			return err
+		}
+	}
+
 	return nil
 }
 
@@ -430,6 +644,33 @@ func (b BillingService) ProcessMessage(ctx context.Context, payload []byte) erro
 		return err
 	}
 
+	// the relay sends an event at least once. It is marked processed in the transaction that handles it, so a
+	// repeat is skipped, an event without an id cannot be told apart and is always handled.
+	if message.EventID == "" {
+		return b.handleMessage(ctx, message)
+	}
+
+	return b.repo.WithTransaction(ctx, func(repo repository.BillingRepositoryProvider) error {
+		tx := b.withRepo(repo)
+		processed, err := tx.repo.MarkEventProcessed(ctx, message.EventID, message.EventName)
+		if err != nil {
+			b.log.WithField("event_id", message.EventID).
+				WithField("error", err.Error()).Error("[ProcessMessage] failed to mark event processed")
+			return err
+		}
+
+		if !processed {
+			b.log.WithField("event_id", message.EventID).
+				WithField("event_name", message.EventName).Info("[ProcessMessage] event was already processed")
+			return nil
+		}
+
+		return tx.handleMessage(ctx, message)
+	})
+}
+
+// handleMessage applies the event to the service.
+func (b BillingService) handleMessage(ctx context.Context, message producer.Message) error {
 	switch message.EventName {
 	case producer.EVENT_NAME_PAYMENT_PAID:
 		var parseData model.PaymentEventPayload
@@ -450,21 +691,51 @@ func (b BillingService) ProcessMessage(ctx context.Context, payload []byte) erro
 			b.log.WithField("error", err).Error("[ProcessMessage] failed to process loan event")
 			return err
 		}
+	case producer.EVENT_NAME_LOAN_SETTLED:
+		var parseData model.LoanSettledEventPayload
+
+		dataByte, err := json.Marshal(message.Data)
+		if err != nil {
+			b.log.WithField("error", err).Error("[ProcessMessage] failed to marshal message.Data")
+			return err
+		}
+		err = json.Unmarshal(dataByte, &parseData)
+		if err != nil {
+			b.log.WithField("error", err).Error("[ProcessMessage] failed to assert message.Data to model")
+			return err
+		}
+
+		err = b.SettleLoan(ctx, parseData)
+		if err != nil {
+			b.log.WithField("error", err).Error("[ProcessMessage] failed to process settlement event")
+			return err
+		}
 	default:
 		b.log.WithField("event_name", message.EventName).
 			WithField("payload", message).Error("[ProcessMessage] unknown event name")
 	}
 
-	b.log.WithField("payload", string(payload)).Info("[ProcessMessage] message processed")
+	b.log.WithField("event_id", message.EventID).
+		WithField("event_name", message.EventName).Info("[ProcessMessage] message processed")
 	return nil
 }
 
+// withRepo returns a copy of the service working on the given repository, used to run the service logic
+// inside a unit of work.
+func (b BillingService) withRepo(repo repository.BillingRepositoryProvider) BillingService {
+	b.repo = repo
+	return b
+}
+
 func NewBillingService(repo repository.BillingRepositoryProvider,
-	cache repository.BillingCacheProvider, producer producer.ProducerProvider, log logger.Logger) *BillingService {
+	cache repository.BillingCacheProvider, policy delinquency.Policy,
+	eligibilityPolicy eligibility.Policy, disburser disbursement.Disburser, log logger.Logger) *BillingService {
 	return &BillingService{
-		repo:     repo,
-		log:      log,
-		cache:    cache,
-		producer: producer,
+		repo:        repo,
+		log:         log,
+		cache:       cache,
+		policy:      policy,
+		eligibility: eligibilityPolicy,
+		disburser:   disburser,
 	}
 }
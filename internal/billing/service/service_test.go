@@ -1,14 +1,18 @@
 package service
 
 import (
-	"billing-engine/internal/billing/constant"
+	"billing-engine/internal/billing/delinquency"
+	"billing-engine/internal/billing/disbursement"
 	"billing-engine/internal/billing/domain"
+	"billing-engine/internal/billing/eligibility"
 	"billing-engine/internal/billing/mocks"
 	"billing-engine/internal/billing/model"
+	"billing-engine/internal/billing/repository"
 	apperror "billing-engine/pkg/customerror"
 	"billing-engine/pkg/enum"
 	"billing-engine/pkg/logger"
-	pkgMock "billing-engine/pkg/mocks"
+	"billing-engine/pkg/money"
+	pkgProducer "billing-engine/pkg/producer"
 	"context"
 	"errors"
 	"github.com/google/uuid"
@@ -23,25 +27,37 @@ var ctx = context.Background()
 var randUUID = uuid.New()
 var timeNow = time.Now()
 
+func idr(amount int64) money.Money {
+	return money.New(amount, "IDR")
+}
+
+// newTestService builds a BillingService with the default policies on fresh repository and cache mocks, a
+// transaction runs straight on the repository mock.
+func newTestService() (*BillingService, *mocks.MockBillingRepositoryProvider, *mocks.MockBillingCacheProvider) {
+	mockCtrl := gomock.NewController(GinkgoT())
+	repo := mocks.NewMockBillingRepositoryProvider(mockCtrl)
+	cache := mocks.NewMockBillingCacheProvider(mockCtrl)
+	repo.EXPECT().WithTransaction(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, fn func(repository.BillingRepositoryProvider) error) error {
+			return fn(repo)
+		}).AnyTimes()
+
+	return NewBillingService(repo, cache, delinquency.DefaultPolicy(), eligibility.DefaultPolicy(),
+		disbursement.Disburser{}, logger.NewZeroLogger("test")), repo, cache
+}
+
 var _ = Describe("Service", func() {
 	var (
-		mockCtrl     *gomock.Controller
 		svc          *BillingService
 		repo         *mocks.MockBillingRepositoryProvider
-		log          logger.Logger
 		mockLoan     domain.Loan
+		mockProduct  domain.Product
 		mockSchedule []domain.Schedule
 		cache        *mocks.MockBillingCacheProvider
-		producer     *pkgMock.MockProducerProvider
 	)
 
 	BeforeEach(func() {
-		mockCtrl = gomock.NewController(GinkgoT())
-		repo = mocks.NewMockBillingRepositoryProvider(mockCtrl)
-		log = logger.NewZeroLogger("test")
-		cache = mocks.NewMockBillingCacheProvider(mockCtrl)
-		producer = pkgMock.NewMockProducerProvider(mockCtrl)
-		svc = NewBillingService(repo, cache, producer, log)
+		svc, repo, cache = newTestService()
 
 		mockSchedule = []domain.Schedule{
 			{
@@ -49,7 +65,7 @@ var _ = Describe("Service", func() {
 				LoanID:         randUUID,
 				PaymentNo:      1,
 				PaymentDueDate: timeNow.AddDate(0, 1, 0),
-				PaymentAmount:  1,
+				PaymentAmount:  idr(1),
 				PaymentStatus:  enum.PaymentStatusPending,
 				IsMissPayment:  false,
 			},
@@ -58,7 +74,7 @@ var _ = Describe("Service", func() {
 				LoanID:         randUUID,
 				PaymentNo:      2,
 				PaymentDueDate: timeNow.AddDate(0, 2, 0),
-				PaymentAmount:  1,
+				PaymentAmount:  idr(1),
 				PaymentStatus:  enum.PaymentStatusPending,
 				IsMissPayment:  false,
 			},
@@ -67,7 +83,7 @@ var _ = Describe("Service", func() {
 				LoanID:         randUUID,
 				PaymentNo:      3,
 				PaymentDueDate: timeNow.AddDate(0, 3, 0),
-				PaymentAmount:  1,
+				PaymentAmount:  idr(1),
 				PaymentStatus:  enum.PaymentStatusPending,
 				IsMissPayment:  false,
 			},
@@ -76,7 +92,7 @@ var _ = Describe("Service", func() {
 				LoanID:         randUUID,
 				PaymentNo:      4,
 				PaymentDueDate: timeNow.AddDate(0, 4, 0),
-				PaymentAmount:  1,
+				PaymentAmount:  idr(1),
 				PaymentStatus:  enum.PaymentStatusPending,
 				IsMissPayment:  false,
 			},
@@ -85,52 +101,124 @@ var _ = Describe("Service", func() {
 				LoanID:         randUUID,
 				PaymentNo:      5,
 				PaymentDueDate: timeNow.AddDate(0, 5, 0),
-				PaymentAmount:  1,
+				PaymentAmount:  idr(1),
 				PaymentStatus:  enum.PaymentStatusPending,
 				IsMissPayment:  false,
 			},
 		}
 
+		// a weekly loan at 10.4% a year is charged 10% over its 50 installments
 		mockLoan = domain.Loan{
-			LoanID:          randUUID,
-			CustomerID:      uuid.New(),
-			PrincipalAmount: 5000000,
-			InterestRate:    0.1,
-			StartDate:       timeNow,
-			EndDate:         timeNow.AddDate(0, 5, 0),
-			AuditLog:        domain.AuditLog{},
+			LoanID:           randUUID,
+			CustomerID:       uuid.New(),
+			PrincipalAmount:  idr(5000000),
+			InterestRate:     0.104,
+			Tenor:            12,
+			InstallmentCount: 50,
+			Frequency:        enum.FrequencyWeekly,
+			StartDate:        timeNow,
+			EndDate:          timeNow.AddDate(0, 5, 0),
+			AuditLog:         domain.AuditLog{},
+		}
+
+		mockProduct = domain.Product{
+			ProductID:        randUUID,
+			Name:             "Weekly Group Loan",
+			InterestRate:     0.104,
+			Tenor:            12,
+			InstallmentCount: 50,
+			Frequency:        enum.FrequencyWeekly,
+			MinPrincipal:     idr(1000000),
+			MaxPrincipal:     idr(10000000),
 		}
 	})
 
 	Describe("CreateLoan", func() {
 		payload := model.CreateLoanPayload{
 			CustomerID: randUUID,
-			LoanAmount: 5000000,
+			ProductID:  randUUID,
+			LoanAmount: idr(5000000),
 		}
 
 		Describe("Positive case", func() {
-			It("should return correct loan response", func() {
+			It("should approve an eligible application and wait for disbursement", func() {
 				repo.EXPECT().GetCustomerByID(ctx, payload.CustomerID).Return(&domain.Customer{}, nil)
-				mockLoan.Schedules = mockSchedule
-				repo.EXPECT().CreateLoan(ctx, gomock.Any()).Return(&mockLoan, nil)
-				producer.EXPECT().SendMessage(gomock.Any(), gomock.Any()).Return(nil)
+				repo.EXPECT().GetProductByID(ctx, payload.ProductID).Return(&mockProduct, nil)
+				repo.EXPECT().GetOutstandingPrincipal(ctx, gomock.Any(), "IDR").Return(int64(0), nil)
+				repo.EXPECT().LockCustomer(ctx, payload.CustomerID).Return(nil)
+				repo.EXPECT().CreateLoan(ctx, gomock.Any()).DoAndReturn(func(_ any, loan domain.Loan) (*domain.Loan, error) {
+					Expect(loan.Status).To(Equal(enum.LoanStatusPendingApproval))
+					Expect(loan.Schedules).To(BeEmpty())
+					Expect(loan.StatusHistory).To(HaveLen(1))
+					Expect(loan.StatusHistory[0].ToStatus).To(Equal(enum.LoanStatusPendingApproval))
+					loan.LoanID = randUUID
+					return &loan, nil
+				})
+				repo.EXPECT().UpdateLoanStatus(ctx, gomock.Any()).DoAndReturn(func(_ any, history domain.LoanStatusHistory) (bool, error) {
+					Expect(history.ToStatus).To(Equal(enum.LoanStatusApproved))
+					Expect(history.Reason).To(Equal("auto-approved"))
+					return true, nil
+				})
+				repo.EXPECT().CreateOutboxMessage(gomock.Any(), gomock.Any()).DoAndReturn(func(_ any, message pkgProducer.Message) error {
+					Expect(message.EventName).To(Equal(pkgProducer.EVENT_NAME_LOAN_STATUS_CHANGED))
+					return nil
+				})
 
 				response, err := svc.CreateLoan(ctx, payload)
 				Expect(err).To(BeNil())
-				Expect(len(response.Schedules)).To(Equal(len(mockSchedule)))
-				Expect(response.LoanAmount).To(Equal(float64(5500000)))
+				Expect(response.LoanID).To(Equal(randUUID))
+				Expect(response.Status).To(Equal(enum.LoanStatusApproved))
+				Expect(response.Schedules).To(BeEmpty())
+				Expect(response.Eligibility).To(HaveLen(1))
+			})
 
-				for i, val := range response.Schedules {
-					Expect(val.PaymentNo).To(Equal(mockSchedule[i].PaymentNo))
-					Expect(val.PaymentDueDate).To(Equal(mockSchedule[i].PaymentDueDate.Format("2006-01-02")))
-					Expect(val.PaymentAmount).To(Equal(mockSchedule[i].PaymentAmount))
-					Expect(val.PaymentStatus).To(Equal(mockSchedule[i].PaymentStatus))
-					Expect(val.IsMissPayment).To(Equal(mockSchedule[i].IsMissPayment))
-				}
+			It("should leave an eligible application for an approver without auto approval", func() {
+				svc.eligibility = eligibility.Policy{Rules: eligibility.DefaultPolicy().Rules}
+				repo.EXPECT().GetCustomerByID(ctx, payload.CustomerID).Return(&domain.Customer{}, nil)
+				repo.EXPECT().GetProductByID(ctx, payload.ProductID).Return(&mockProduct, nil)
+				repo.EXPECT().GetOutstandingPrincipal(ctx, gomock.Any(), "IDR").Return(int64(0), nil)
+				repo.EXPECT().LockCustomer(ctx, payload.CustomerID).Return(nil)
+				repo.EXPECT().CreateLoan(ctx, gomock.Any()).DoAndReturn(func(_ any, loan domain.Loan) (*domain.Loan, error) {
+					return &loan, nil
+				})
+
+				response, err := svc.CreateLoan(ctx, payload)
+				Expect(err).To(BeNil())
+				Expect(response.Status).To(Equal(enum.LoanStatusPendingApproval))
+				Expect(response.Schedules).To(BeEmpty())
 			})
 		})
 
 		Describe("Negative case", func() {
+			It("when loan amount is outside the product range", func() {
+				mockProduct.MaxPrincipal = idr(1000000)
+				repo.EXPECT().GetCustomerByID(ctx, payload.CustomerID).Return(&domain.Customer{}, nil)
+				repo.EXPECT().GetProductByID(ctx, payload.ProductID).Return(&mockProduct, nil)
+				_, err := svc.CreateLoan(ctx, payload)
+
+				var errs *apperror.CustomError
+				ok := errors.As(err, &errs)
+				Expect(ok).To(BeTrue())
+				Expect(errs.Cause).To(Equal(apperror.InvalidInput))
+			})
+
+			It("when loan amount is not positive and no eligibility rule checks the range", func() {
+				svc.eligibility = eligibility.Policy{AutoApprove: true}
+				mockProduct.MinPrincipal = idr(0)
+				repo.EXPECT().GetCustomerByID(ctx, payload.CustomerID).Return(&domain.Customer{}, nil)
+				repo.EXPECT().GetProductByID(ctx, payload.ProductID).Return(&mockProduct, nil)
+				_, err := svc.CreateLoan(ctx, model.CreateLoanPayload{
+					CustomerID: payload.CustomerID,
+					ProductID:  payload.ProductID,
+					LoanAmount: idr(0),
+				})
+
+				var errs *apperror.CustomError
+				ok := errors.As(err, &errs)
+				Expect(ok).To(BeTrue())
+				Expect(errs.Cause).To(Equal(apperror.InvalidInput))
+			})
+
 			It("when customer not found", func() {
 				repo.EXPECT().GetCustomerByID(ctx, payload.CustomerID).Return(nil, nil)
 				_, err := svc.CreateLoan(ctx, payload)
@@ -147,8 +235,36 @@ var _ = Describe("Service", func() {
 				Expect(err).To(Equal(someErr))
 			})
 
+			It("when product not found", func() {
+				repo.EXPECT().GetCustomerByID(ctx, payload.CustomerID).Return(&domain.Customer{}, nil)
+				repo.EXPECT().GetProductByID(ctx, payload.ProductID).Return(nil, nil)
+				_, err := svc.CreateLoan(ctx, payload)
+
+				var errs *apperror.CustomError
+				ok := errors.As(err, &errs)
+				Expect(ok).To(BeTrue())
+				Expect(errs.Cause).To(Equal(apperror.NotFound))
+			})
+
+			It("when the loan exceeds the credit limit", func() {
+				customer := domain.Customer{CreditLimit: idr(8000000)}
+				repo.EXPECT().GetCustomerByID(ctx, payload.CustomerID).Return(&customer, nil)
+				repo.EXPECT().GetProductByID(ctx, payload.ProductID).Return(&mockProduct, nil)
+				repo.EXPECT().GetOutstandingPrincipal(ctx, gomock.Any(), "IDR").Return(int64(3500000), nil)
+				repo.EXPECT().LockCustomer(ctx, payload.CustomerID).Return(nil)
+				_, err := svc.CreateLoan(ctx, payload)
+
+				var errs *apperror.CustomError
+				ok := errors.As(err, &errs)
+				Expect(ok).To(BeTrue())
+				Expect(errs.Cause).To(Equal(apperror.LimitExceeded))
+			})
+
 			It("when error on create loan", func() {
 				repo.EXPECT().GetCustomerByID(ctx, payload.CustomerID).Return(&domain.Customer{}, nil)
+				repo.EXPECT().GetProductByID(ctx, payload.ProductID).Return(&mockProduct, nil)
+				repo.EXPECT().GetOutstandingPrincipal(ctx, gomock.Any(), "IDR").Return(int64(0), nil)
+				repo.EXPECT().LockCustomer(ctx, payload.CustomerID).Return(nil)
 				repo.EXPECT().CreateLoan(ctx, gomock.Any()).Return(nil, someErr)
 				_, err := svc.CreateLoan(ctx, payload)
 				Expect(err).To(Equal(someErr))
@@ -156,9 +272,14 @@ var _ = Describe("Service", func() {
 
 			It("when error on produce message", func() {
 				repo.EXPECT().GetCustomerByID(ctx, payload.CustomerID).Return(&domain.Customer{}, nil)
-				mockLoan.Schedules = mockSchedule
-				repo.EXPECT().CreateLoan(ctx, gomock.Any()).Return(&mockLoan, nil)
-				producer.EXPECT().SendMessage(gomock.Any(), gomock.Any()).Return(someErr)
+				repo.EXPECT().GetProductByID(ctx, payload.ProductID).Return(&mockProduct, nil)
+				repo.EXPECT().GetOutstandingPrincipal(ctx, gomock.Any(), "IDR").Return(int64(0), nil)
+				repo.EXPECT().LockCustomer(ctx, payload.CustomerID).Return(nil)
+				repo.EXPECT().CreateLoan(ctx, gomock.Any()).DoAndReturn(func(_ any, loan domain.Loan) (*domain.Loan, error) {
+					return &loan, nil
+				})
+				repo.EXPECT().UpdateLoanStatus(ctx, gomock.Any()).Return(true, nil)
+				repo.EXPECT().CreateOutboxMessage(gomock.Any(), gomock.Any()).Return(someErr)
 
 				_, err := svc.CreateLoan(ctx, payload)
 				Expect(err).To(Equal(someErr))
@@ -168,48 +289,97 @@ var _ = Describe("Service", func() {
 
 	Describe("SchemaMaker", func() {
 		It("should return correct total loan and schedule with even number in total amount", func() {
-			totalLoan, schedules := svc.paymentSchemaMaker(mockLoan)
+			totalLoan, schedules, err := svc.paymentSchemaMaker(mockLoan)
+			Expect(err).To(BeNil())
 
-			Expect(totalLoan).To(Equal(float64(5500000)))
-			Expect(len(schedules)).To(Equal(constant.MAX_PAYMENT))
+			Expect(totalLoan).To(Equal(idr(5500000)))
+			Expect(len(schedules)).To(Equal(mockProduct.InstallmentCount))
 
 			for i, val := range schedules {
 				Expect(val.PaymentNo).To(Equal(i + 1))
-				Expect(val.PaymentAmount).To(Equal(float64(110000)))
+				Expect(val.PaymentAmount).To(Equal(idr(110000)))
+				Expect(val.PrincipalAmount).To(Equal(idr(100000)))
+				Expect(val.InterestAmount).To(Equal(idr(10000)))
+				Expect(val.RemainingPrincipal).To(Equal(idr(int64(5000000 - 100000*(i+1)))))
 				Expect(val.PaymentStatus).To(Equal(enum.PaymentStatusPending))
 				Expect(val.IsMissPayment).To(BeFalse())
 			}
 		})
 
 		It("should return correct total loan and schedule with odd number in total amount", func() {
-			mockLoan.PrincipalAmount = 5000001
-			totalLoan, schedules := svc.paymentSchemaMaker(mockLoan)
+			mockLoan.PrincipalAmount = idr(5000001)
+			totalLoan, schedules, err := svc.paymentSchemaMaker(mockLoan)
+			Expect(err).To(BeNil())
 
-			Expect(totalLoan).To(Equal(float64(5500001)))
-			Expect(len(schedules)).To(Equal(constant.MAX_PAYMENT))
+			Expect(totalLoan).To(Equal(idr(5500001)))
+			Expect(len(schedules)).To(Equal(mockProduct.InstallmentCount))
+			// the final installment repays whatever principal is left
+			Expect(schedules[len(schedules)-1].PrincipalAmount).To(Equal(idr(100001)))
+			Expect(schedules[len(schedules)-1].RemainingPrincipal).To(Equal(idr(0)))
 
-			for i, val := range schedules {
+			for i, val := range schedules[:len(schedules)-1] {
 				Expect(val.PaymentNo).To(Equal(i + 1))
-				Expect(val.PaymentAmount).To(Equal(float64(110000)))
+				Expect(val.PaymentAmount).To(Equal(idr(110000)))
 				Expect(val.PaymentStatus).To(Equal(enum.PaymentStatusPending))
 				Expect(val.IsMissPayment).To(BeFalse())
 			}
 		})
 
 		It("should return correct total loan and schedule with more weird odd number in total amount", func() {
-			mockLoan.PrincipalAmount = 1234569
-			totalLoan, schedules := svc.paymentSchemaMaker(mockLoan)
+			mockLoan.PrincipalAmount = idr(1234569)
+			totalLoan, schedules, err := svc.paymentSchemaMaker(mockLoan)
+			Expect(err).To(BeNil())
 
-			Expect(totalLoan).To(Equal(float64(1358026)))
-			Expect(len(schedules)).To(Equal(constant.MAX_PAYMENT))
+			// the schedule adds up exactly to principal plus interest, the final installment takes the residual
+			Expect(totalLoan).To(Equal(idr(1358026)))
+			Expect(len(schedules)).To(Equal(mockProduct.InstallmentCount))
+			Expect(schedules[len(schedules)-1].PaymentAmount).To(Equal(idr(27186)))
 
-			for i, val := range schedules {
+			for i, val := range schedules[:len(schedules)-1] {
 				Expect(val.PaymentNo).To(Equal(i + 1))
-				Expect(val.PaymentAmount).To(Equal(float64(27161)))
+				Expect(val.PaymentAmount).To(Equal(idr(27160)))
 				Expect(val.PaymentStatus).To(Equal(enum.PaymentStatusPending))
 				Expect(val.IsMissPayment).To(BeFalse())
 			}
 		})
+
+		It("should reject an unknown amortization method", func() {
+			mockLoan.AmortizationMethod = "BALLOON"
+			_, _, err := svc.paymentSchemaMaker(mockLoan)
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("should space weekly installments seven days apart from the start date", func() {
+			mockLoan.Frequency = enum.FrequencyWeekly
+			_, schedules, err := svc.paymentSchemaMaker(mockLoan)
+			Expect(err).To(BeNil())
+
+			for i, val := range schedules {
+				Expect(val.PaymentDueDate).To(Equal(mockLoan.StartDate.AddDate(0, 0, 7*(i+1))))
+			}
+			Expect(schedules[len(schedules)-1].PaymentDueDate).To(Equal(mockLoan.StartDate.AddDate(0, 0, 350)))
+		})
+
+		It("should use the interval days for custom frequency", func() {
+			mockLoan.Frequency = enum.FrequencyCustom
+			mockLoan.IntervalDays = 10
+			_, schedules, err := svc.paymentSchemaMaker(mockLoan)
+			Expect(err).To(BeNil())
+
+			Expect(schedules[0].PaymentDueDate).To(Equal(mockLoan.StartDate.AddDate(0, 0, 10)))
+			Expect(schedules[2].PaymentDueDate).To(Equal(mockLoan.StartDate.AddDate(0, 0, 30)))
+		})
+
+		It("should clamp monthly installments to the end of shorter months", func() {
+			mockLoan.Frequency = enum.FrequencyMonthly
+			mockLoan.StartDate = time.Date(2024, time.January, 31, 0, 0, 0, 0, time.UTC)
+			_, schedules, err := svc.paymentSchemaMaker(mockLoan)
+			Expect(err).To(BeNil())
+
+			Expect(schedules[0].PaymentDueDate).To(Equal(time.Date(2024, time.February, 29, 0, 0, 0, 0, time.UTC)))
+			Expect(schedules[1].PaymentDueDate).To(Equal(time.Date(2024, time.March, 31, 0, 0, 0, 0, time.UTC)))
+			Expect(schedules[2].PaymentDueDate).To(Equal(time.Date(2024, time.April, 30, 0, 0, 0, 0, time.UTC)))
+		})
 	})
 
 	Describe("GetPaymentSchedule", func() {
@@ -272,7 +442,7 @@ var _ = Describe("Service", func() {
 				cache.EXPECT().Set(ctx, gomock.Any(), gomock.Any()).Return(nil)
 
 				repo.EXPECT().GetCustomerByID(ctx, gomock.Any()).Return(&domain.Customer{}, nil)
-				repo.EXPECT().GetUnpaidAndMissPaymentUntil(ctx, gomock.Any(), gomock.Any()).Return([]domain.Schedule{
+				repo.EXPECT().GetMissedSchedules(ctx, gomock.Any()).Return([]domain.Schedule{
 					{
 						PaymentNo: 1,
 					},
@@ -286,7 +456,7 @@ var _ = Describe("Service", func() {
 						PaymentNo: 27,
 					},
 				}, nil)
-				repo.EXPECT().LastActiveLoan(ctx, gomock.Any()).Return(&domain.Loan{}, nil)
+				repo.EXPECT().GetActiveLoans(ctx, gomock.Any()).Return([]domain.Loan{{}}, nil)
 
 				response, err := svc.IsCustomerDelinquency(ctx, uuid.New())
 				Expect(err).To(BeNil())
@@ -298,7 +468,7 @@ var _ = Describe("Service", func() {
 				cache.EXPECT().Set(ctx, gomock.Any(), gomock.Any()).Return(nil)
 
 				repo.EXPECT().GetCustomerByID(ctx, gomock.Any()).Return(&domain.Customer{}, nil)
-				repo.EXPECT().GetUnpaidAndMissPaymentUntil(ctx, gomock.Any(), gomock.Any()).Return([]domain.Schedule{
+				repo.EXPECT().GetMissedSchedules(ctx, gomock.Any()).Return([]domain.Schedule{
 					{
 						PaymentNo: 1,
 					},
@@ -312,20 +482,61 @@ var _ = Describe("Service", func() {
 						PaymentNo: 26,
 					},
 				}, nil)
-				repo.EXPECT().LastActiveLoan(ctx, gomock.Any()).Return(&domain.Loan{}, nil)
+				repo.EXPECT().GetActiveLoans(ctx, gomock.Any()).Return([]domain.Loan{{}}, nil)
+
+				response, err := svc.IsCustomerDelinquency(ctx, uuid.New())
+				Expect(err).To(BeNil())
+				Expect(response.IsDelinquent).To(BeTrue())
+				Expect(response.MatchedRule.Type).To(Equal(string(delinquency.RuleConsecutiveMisses)))
+				Expect(response.OldestOverdueSchedule.PaymentNo).To(Equal(1))
+			})
+
+			It("when only one of several loans is delinquent", func() {
+				current, delinquent := domain.Loan{LoanID: uuid.New()}, domain.Loan{LoanID: uuid.New()}
+
+				cache.EXPECT().Get(ctx, gomock.Any()).Return(nil, nil)
+				cache.EXPECT().Set(ctx, gomock.Any(), gomock.Any()).Return(nil)
+				repo.EXPECT().GetCustomerByID(ctx, gomock.Any()).Return(&domain.Customer{}, nil)
+				repo.EXPECT().GetActiveLoans(ctx, gomock.Any()).Return([]domain.Loan{current, delinquent}, nil)
+				repo.EXPECT().GetMissedSchedules(ctx, current.LoanID).Return([]domain.Schedule{
+					{PaymentNo: 4, PaymentDueDate: timeNow.AddDate(0, 0, -50)},
+				}, nil)
+				repo.EXPECT().GetMissedSchedules(ctx, delinquent.LoanID).Return([]domain.Schedule{
+					{PaymentNo: 1, PaymentDueDate: timeNow.AddDate(0, 0, -20)},
+					{PaymentNo: 2, PaymentDueDate: timeNow.AddDate(0, 0, -10)},
+				}, nil)
 
 				response, err := svc.IsCustomerDelinquency(ctx, uuid.New())
 				Expect(err).To(BeNil())
 				Expect(response.IsDelinquent).To(BeTrue())
+				Expect(response.DaysPastDue).To(Equal(20))
+				Expect(response.Loans).To(HaveLen(2))
+				Expect(response.Loans[0].LoanID).To(Equal(current.LoanID))
+				Expect(response.Loans[0].IsDelinquent).To(BeFalse())
+				Expect(response.Loans[0].DPDBucket).To(Equal(string(delinquency.Bucket31To60)))
+				Expect(response.Loans[1].IsDelinquent).To(BeTrue())
+			})
+
+			It("when customer has no active loan", func() {
+				cache.EXPECT().Get(ctx, gomock.Any()).Return(nil, nil)
+				cache.EXPECT().Set(ctx, gomock.Any(), gomock.Any()).Return(nil)
+				repo.EXPECT().GetCustomerByID(ctx, gomock.Any()).Return(&domain.Customer{}, nil)
+				repo.EXPECT().GetActiveLoans(ctx, gomock.Any()).Return(nil, nil)
+
+				response, err := svc.IsCustomerDelinquency(ctx, uuid.New())
+				Expect(err).To(BeNil())
+				Expect(response.IsDelinquent).To(BeFalse())
+				Expect(response.DPDBucket).To(Equal(string(delinquency.BucketCurrent)))
+				Expect(response.Loans).To(BeEmpty())
 			})
 
 			It("when customer only have 1 unpaid / missing payment", func() {
 				cache.EXPECT().Get(ctx, gomock.Any()).Return(nil, nil)
 				cache.EXPECT().Set(ctx, gomock.Any(), gomock.Any()).Return(nil)
-				repo.EXPECT().LastActiveLoan(ctx, gomock.Any()).Return(&domain.Loan{}, nil)
+				repo.EXPECT().GetActiveLoans(ctx, gomock.Any()).Return([]domain.Loan{{}}, nil)
 
 				repo.EXPECT().GetCustomerByID(ctx, gomock.Any()).Return(&domain.Customer{}, nil)
-				repo.EXPECT().GetUnpaidAndMissPaymentUntil(ctx, gomock.Any(), gomock.Any()).Return([]domain.Schedule{
+				repo.EXPECT().GetMissedSchedules(ctx, gomock.Any()).Return([]domain.Schedule{
 					{
 						PaymentNo: 1,
 					},
@@ -354,8 +565,8 @@ var _ = Describe("Service", func() {
 			It("when error getting unpaid and miss payment until", func() {
 				cache.EXPECT().Get(ctx, gomock.Any()).Return(nil, nil)
 				repo.EXPECT().GetCustomerByID(ctx, gomock.Any()).Return(&domain.Customer{}, nil)
-				repo.EXPECT().GetUnpaidAndMissPaymentUntil(ctx, gomock.Any(), gomock.Any()).Return(nil, someErr)
-				repo.EXPECT().LastActiveLoan(ctx, gomock.Any()).Return(&domain.Loan{}, nil)
+				repo.EXPECT().GetMissedSchedules(ctx, gomock.Any()).Return(nil, someErr)
+				repo.EXPECT().GetActiveLoans(ctx, gomock.Any()).Return([]domain.Loan{{}}, nil)
 
 				_, err := svc.IsCustomerDelinquency(ctx, uuid.New())
 				Expect(err).To(Equal(someErr))
@@ -368,31 +579,102 @@ var _ = Describe("Service", func() {
 		Describe("Positive case", func() {
 			It("should return correct total unpaid payment without cache", func() {
 				customerID := uuid.New()
-				totalUnpaid := 5000000.0
+				totalUnpaid := int64(5000000)
 
 				cache.EXPECT().Get(ctx, gomock.Any()).Return(nil, nil)
 				repo.EXPECT().GetCustomerByID(ctx, customerID).Return(&domain.Customer{}, nil)
 				repo.EXPECT().GetTotalUnpaidPaymentOnActiveLoan(ctx, customerID).Return(totalUnpaid, nil)
+				repo.EXPECT().GetTotalUnpaidPenaltyOnActiveLoan(ctx, customerID).Return(int64(15000), nil)
 				cache.EXPECT().Set(ctx, gomock.Any(), gomock.Any()).Return(nil)
-				repo.EXPECT().LastActiveLoan(ctx, customerID).Return(&domain.Loan{
-					LoanID: customerID,
+				repo.EXPECT().GetClosedLoansWithCredit(ctx, customerID).Return(nil, nil)
+				repo.EXPECT().GetActiveLoans(ctx, customerID).Return([]domain.Loan{{
+					LoanID:          customerID,
+					PrincipalAmount: idr(5000000),
+				}}, nil)
+
+				response, err := svc.GetOutstandingBalance(ctx, customerID)
+				Expect(err).To(BeNil())
+				Expect(response.OutstandingBalance).To(Equal(idr(totalUnpaid + 15000)))
+				Expect(response.PenaltyBalance).To(Equal(idr(15000)))
+			})
+
+			It("should add up the balances of every active loan", func() {
+				customerID := uuid.New()
+				first, second := uuid.New(), uuid.New()
+
+				cache.EXPECT().Get(ctx, gomock.Any()).Return(nil, nil)
+				cache.EXPECT().Set(ctx, gomock.Any(), gomock.Any()).Return(nil)
+				repo.EXPECT().GetCustomerByID(ctx, customerID).Return(&domain.Customer{}, nil)
+				repo.EXPECT().GetClosedLoansWithCredit(ctx, customerID).Return(nil, nil)
+				repo.EXPECT().GetActiveLoans(ctx, customerID).Return([]domain.Loan{
+					{LoanID: first, PrincipalAmount: idr(5000000), Status: enum.LoanStatusActive},
+					{LoanID: second, PrincipalAmount: idr(2000000), Status: enum.LoanStatusRestructured},
 				}, nil)
+				repo.EXPECT().GetTotalUnpaidPaymentOnActiveLoan(ctx, first).Return(int64(3000000), nil)
+				repo.EXPECT().GetTotalUnpaidPenaltyOnActiveLoan(ctx, first).Return(int64(10000), nil)
+				repo.EXPECT().GetTotalUnpaidPaymentOnActiveLoan(ctx, second).Return(int64(1500000), nil)
+				repo.EXPECT().GetTotalUnpaidPenaltyOnActiveLoan(ctx, second).Return(int64(0), nil)
 
 				response, err := svc.GetOutstandingBalance(ctx, customerID)
 				Expect(err).To(BeNil())
-				Expect(response.OutstandingBalance).To(Equal(totalUnpaid))
+				Expect(response.OutstandingBalance).To(Equal(idr(4510000)))
+				Expect(response.PenaltyBalance).To(Equal(idr(10000)))
+				Expect(response.Loans).To(HaveLen(2))
+				Expect(response.Loans[0].OutstandingBalance).To(Equal(idr(3010000)))
+				Expect(response.Loans[1].Status).To(Equal(enum.LoanStatusRestructured))
+			})
+
+			It("should list the credit of a paid off loan", func() {
+				customerID := uuid.New()
+				active, paidOff := uuid.New(), uuid.New()
+
+				cache.EXPECT().Get(ctx, gomock.Any()).Return(nil, nil)
+				cache.EXPECT().Set(ctx, gomock.Any(), gomock.Any()).Return(nil)
+				repo.EXPECT().GetCustomerByID(ctx, customerID).Return(&domain.Customer{}, nil)
+				repo.EXPECT().GetActiveLoans(ctx, customerID).Return([]domain.Loan{
+					{LoanID: active, PrincipalAmount: idr(5000000), Status: enum.LoanStatusActive, CreditBalance: idr(2000)},
+				}, nil)
+				repo.EXPECT().GetClosedLoansWithCredit(ctx, customerID).Return([]domain.Loan{
+					{LoanID: paidOff, PrincipalAmount: idr(1000000), Status: enum.LoanStatusPaidOff, CreditBalance: idr(30000)},
+				}, nil)
+				repo.EXPECT().GetTotalUnpaidPaymentOnActiveLoan(ctx, active).Return(int64(3000000), nil)
+				repo.EXPECT().GetTotalUnpaidPenaltyOnActiveLoan(ctx, active).Return(int64(0), nil)
+
+				response, err := svc.GetOutstandingBalance(ctx, customerID)
+				Expect(err).To(BeNil())
+				Expect(response.OutstandingBalance).To(Equal(idr(3000000)))
+				Expect(response.CreditBalance).To(Equal(idr(32000)))
+				Expect(response.Loans).To(HaveLen(2))
+				Expect(response.Loans[0].CreditBalance).To(Equal(idr(2000)))
+				Expect(response.Loans[1].LoanID).To(Equal(paidOff))
+				Expect(response.Loans[1].OutstandingBalance.IsZero()).To(BeTrue())
+				Expect(response.Loans[1].CreditBalance).To(Equal(idr(30000)))
+			})
+
+			It("should return a zero balance when customer has no active loan", func() {
+				customerID := uuid.New()
+
+				cache.EXPECT().Get(ctx, gomock.Any()).Return(nil, nil)
+				cache.EXPECT().Set(ctx, gomock.Any(), gomock.Any()).Return(nil)
+				repo.EXPECT().GetCustomerByID(ctx, customerID).Return(&domain.Customer{}, nil)
+				repo.EXPECT().GetClosedLoansWithCredit(ctx, customerID).Return(nil, nil)
+				repo.EXPECT().GetActiveLoans(ctx, customerID).Return(nil, nil)
+
+				response, err := svc.GetOutstandingBalance(ctx, customerID)
+				Expect(err).To(BeNil())
+				Expect(response.OutstandingBalance.IsZero()).To(BeTrue())
+				Expect(response.Loans).To(BeEmpty())
 			})
 
 			It("should return correct total unpaid payment with cache", func() {
 				customerID := uuid.New()
-				totalUnpaid := 5000000.0
-				cacheRes := "{\"outstanding_balance\":5000000}"
+				cacheRes := "{\"outstanding_balance\":{\"amount\":5000000,\"currency\":\"IDR\"}}"
 
 				cache.EXPECT().Get(ctx, gomock.Any()).Return(cacheRes, nil)
 
 				response, err := svc.GetOutstandingBalance(ctx, customerID)
 				Expect(err).To(BeNil())
-				Expect(response.OutstandingBalance).To(Equal(totalUnpaid))
+				Expect(response.OutstandingBalance).To(Equal(idr(5000000)))
 			})
 
 		})
@@ -417,21 +699,23 @@ var _ = Describe("Service", func() {
 				customerID := uuid.New()
 				cache.EXPECT().Get(ctx, gomock.Any()).Return(nil, nil)
 				repo.EXPECT().GetCustomerByID(ctx, customerID).Return(&domain.Customer{}, nil)
-				repo.EXPECT().GetTotalUnpaidPaymentOnActiveLoan(ctx, customerID).Return(float64(0), someErr)
-				repo.EXPECT().LastActiveLoan(ctx, customerID).Return(&domain.Loan{
-					LoanID: customerID,
-				}, nil)
+				repo.EXPECT().GetTotalUnpaidPaymentOnActiveLoan(ctx, customerID).Return(int64(0), someErr)
+				repo.EXPECT().GetClosedLoansWithCredit(ctx, customerID).Return(nil, nil)
+				repo.EXPECT().GetActiveLoans(ctx, customerID).Return([]domain.Loan{{
+					LoanID:          customerID,
+					PrincipalAmount: idr(5000000),
+				}}, nil)
 
 				_, err := svc.GetOutstandingBalance(ctx, customerID)
 				Expect(err).To(Equal(someErr))
 			})
 
-			It("when error getting last active loan", func() {
+			It("when error getting active loans", func() {
 				customerID := uuid.New()
 
 				cache.EXPECT().Get(ctx, gomock.Any()).Return(nil, nil)
 				repo.EXPECT().GetCustomerByID(ctx, customerID).Return(&domain.Customer{}, nil)
-				repo.EXPECT().LastActiveLoan(ctx, customerID).Return(nil, someErr)
+				repo.EXPECT().GetActiveLoans(ctx, customerID).Return(nil, someErr)
 
 				_, err := svc.GetOutstandingBalance(ctx, customerID)
 				Expect(err).To(Equal(someErr))
@@ -0,0 +1,308 @@
+package service
+
+import (
+	"billing-engine/internal/billing/domain"
+	"billing-engine/internal/billing/lifecycle"
+	"billing-engine/internal/billing/model"
+	"billing-engine/internal/billing/repository"
+	apperror "billing-engine/pkg/customerror"
+	"billing-engine/pkg/enum"
+	"billing-engine/pkg/money"
+	"billing-engine/pkg/producer"
+	"context"
+	"fmt"
+	"github.com/google/uuid"
+	"time"
+)
+
+// TopUpLoan refinances a loan in good standing into a new, larger loan on the current terms of its product.
+// The new loan is approved right away and only the difference, less the admin fee and the payoff amount of the
+// old loan, is paid out when it is disbursed. The old loan is only settled and closed as REFINANCED when the
+// top-up is activated, so a top-up that is cancelled before it is paid out leaves the old loan as it was.
+func (b BillingService) TopUpLoan(ctx context.Context, payload model.TopUpLoanPayload) (*model.TopUpLoanResponse, error) {
+	b.log.WithField("loan_id", payload.LoanID).
+		WithField("amount", payload.Amount).Info("[TopUpLoan] topping up loan")
+
+	loan, err := b.repo.GetLoanByID(ctx, payload.LoanID)
+	if err != nil {
+		b.log.WithField("loan_id", payload.LoanID).
+			WithField("error", err.Error()).Error("[TopUpLoan] Unexpected error when getting loan")
+		return nil, err
+	}
+
+	if loan == nil {
+		b.log.WithField("loan_id", payload.LoanID).Info("[TopUpLoan] loan not found")
+		return nil, apperror.New(apperror.NotFound, "loan not found")
+	}
+
+	now := time.Now()
+	settlement, err := b.quoteRefinance(ctx, *loan, now)
+	if err != nil {
+		return nil, err
+	}
+
+	customer, err := b.getCustomer(ctx, loan.CustomerID)
+	if err != nil {
+		return nil, err
+	}
+
+	if customer.DeactivatedAt != nil {
+		b.log.WithField("customer_id", loan.CustomerID).Info("[TopUpLoan] customer is deactivated")
+		return nil, apperror.New(apperror.InvalidInput, "customer is deactivated")
+	}
+
+	product, err := b.repo.GetProductByID(ctx, loan.ProductID)
+	if err != nil {
+		b.log.WithField("product_id", loan.ProductID).
+			WithField("error", err.Error()).Error("[TopUpLoan] Unexpected error when getting product")
+		return nil, err
+	}
+
+	if product == nil {
+		b.log.WithField("product_id", loan.ProductID).Error("[TopUpLoan] product not found")
+		return nil, apperror.New(apperror.NotFound, "product not found")
+	}
+
+	if payload.Amount.Currency != loan.PrincipalAmount.Currency {
+		return nil, apperror.New(apperror.InvalidInput,
+			fmt.Sprintf("top-up amount must be in %s", loan.PrincipalAmount.Currency))
+	}
+
+	if !money.SameCurrency(payload.Amount, product.MinPrincipal, product.MaxPrincipal) {
+		return nil, apperror.New(apperror.InvalidInput,
+			fmt.Sprintf("top-up amount must be in %s", product.MinPrincipal.Currency))
+	}
+
+	if payload.Amount.Amount < product.MinPrincipal.Amount || payload.Amount.Amount > product.MaxPrincipal.Amount {
+		return nil, apperror.New(apperror.InvalidInput,
+			fmt.Sprintf("top-up amount must be between %s and %s", product.MinPrincipal, product.MaxPrincipal))
+	}
+
+	kept, err := money.Sum(product.AdminFee, settlement.SettlementAmount)
+	if err != nil {
+		b.log.WithField("loan_id", payload.LoanID).
+			WithField("error", err.Error()).Error("[TopUpLoan] failed to add up the amount kept back")
+		return nil, err
+	}
+
+	payout, err := payload.Amount.Sub(kept)
+	if err != nil {
+		b.log.WithField("loan_id", payload.LoanID).
+			WithField("error", err.Error()).Error("[TopUpLoan] failed to compute the payout")
+		return nil, err
+	}
+
+	if !payout.IsPositive() {
+		return nil, apperror.New(apperror.InvalidInput,
+			fmt.Sprintf("top-up amount must exceed the settlement of %s plus the admin fee of %s",
+				settlement.SettlementAmount, product.AdminFee))
+	}
+
+	newLoan := loanFromProduct(loan.CustomerID, *product, payload.Amount, enum.LoanStatusApproved, now)
+	newLoan.RefinancedLoanID = &loan.LoanID
+	newLoan.RefinancedAmount = settlement.SettlementAmount
+	newLoan.StatusHistory = []domain.LoanStatusHistory{
+		{ToStatus: enum.LoanStatusApproved, Reason: fmt.Sprintf("top-up of loan %s", loan.LoanID), ChangedAt: now},
+	}
+
+	var created *domain.Loan
+	err = b.repo.WithTransaction(ctx, func(repo repository.BillingRepositoryProvider) error {
+		tx := b.withRepo(repo)
+
+		// the customer stays locked until the top-up is stored, so a concurrent loan or top-up waits and counts it
+		err := tx.repo.LockCustomer(ctx, loan.CustomerID)
+		if err != nil {
+			b.log.WithField("customer_id", loan.CustomerID).
+				WithField("error", err.Error()).Error("[TopUpLoan] Unexpected error when locking customer")
+			return err
+		}
+
+		pending, err := tx.repo.GetPendingTopUp(ctx, payload.LoanID)
+		if err != nil {
+			b.log.WithField("loan_id", payload.LoanID).
+				WithField("error", err.Error()).Error("[TopUpLoan] Unexpected error when getting pending top-up")
+			return err
+		}
+
+		if pending != nil {
+			b.log.WithField("loan_id", payload.LoanID).
+				WithField("top_up_loan_id", pending.LoanID).Info("[TopUpLoan] loan already has a pending top-up")
+			return apperror.New(apperror.InvalidInput,
+				fmt.Sprintf("loan already has top-up %s waiting to be disbursed", pending.LoanID))
+		}
+
+		// the principal of the old loan is settled by the new one, so only the increase counts against the limit
+		limit, err := tx.creditLimitResponse(ctx, *customer)
+		if err != nil {
+			return err
+		}
+
+		increase, err := payload.Amount.Sub(settlement.PrincipalAmount)
+		if err != nil {
+			b.log.WithField("loan_id", payload.LoanID).
+				WithField("error", err.Error()).Error("[TopUpLoan] failed to compute the increase")
+			return err
+		}
+
+		if limit.CreditLimit.Currency == increase.Currency && increase.Amount > limit.Headroom.Amount {
+			b.log.WithField("loan_id", payload.LoanID).
+				WithField("headroom", limit.Headroom).Info("[TopUpLoan] credit limit exceeded")
+			return apperror.New(apperror.LimitExceeded,
+				fmt.Sprintf("top-up of %s exceeds the remaining credit limit of %s", increase, limit.Headroom))
+		}
+
+		created, err = tx.repo.CreateLoan(ctx, newLoan)
+		if err != nil {
+			b.log.WithField("loan_id", payload.LoanID).
+				WithField("error", err.Error()).Error("[TopUpLoan] Unexpected error when creating top-up")
+			return err
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = b.flushCache(ctx, loan.CustomerID)
+	if err != nil {
+		b.log.WithField("loan_id", payload.LoanID).
+			WithField("error", err.Error()).Error("[TopUpLoan] failed to flush cache")
+		return nil, err
+	}
+
+	b.log.WithField("loan_id", created.LoanID).
+		WithField("refinanced_loan_id", loan.LoanID).Info("[TopUpLoan] loan topped up")
+	return &model.TopUpLoanResponse{
+		LoanID:           created.LoanID,
+		RefinancedLoanID: loan.LoanID,
+		CustomerID:       created.CustomerID,
+		Status:           created.Status,
+		PrincipalAmount:  created.PrincipalAmount,
+		AdminFee:         created.AdminFee,
+		SettledAmount:    created.RefinancedAmount,
+		NetDisbursement:  payout,
+	}, nil
+}
+
+// quoteRefinance checks that the loan can still be settled by a top-up and quotes what it takes to settle it.
+// Only loans being repaid without missed installments can be topped up.
+func (b BillingService) quoteRefinance(ctx context.Context, loan domain.Loan, now time.Time) (domain.PayoffQuote, error) {
+	err := lifecycle.Validate(loan.Status, enum.LoanStatusRefinanced)
+	if err != nil {
+		b.log.WithField("loan_id", loan.LoanID).
+			WithField("error", err.Error()).Info("[quoteRefinance] loan cannot be refinanced")
+		return domain.PayoffQuote{}, apperror.New(apperror.InvalidInput, err.Error())
+	}
+
+	missed, err := b.repo.GetMissedSchedules(ctx, loan.LoanID)
+	if err != nil {
+		b.log.WithField("loan_id", loan.LoanID).
+			WithField("error", err.Error()).Error("[quoteRefinance] Unexpected error when getting missed schedules")
+		return domain.PayoffQuote{}, err
+	}
+
+	if len(missed) > 0 {
+		b.log.WithField("loan_id", loan.LoanID).Info("[quoteRefinance] loan has missed installments")
+		return domain.PayoffQuote{}, apperror.New(apperror.InvalidInput, "only loans without missed installments can be topped up")
+	}
+
+	schedules, err := b.repo.GetOpenSchedules(ctx, loan.LoanID)
+	if err != nil {
+		b.log.WithField("loan_id", loan.LoanID).
+			WithField("error", err.Error()).Error("[quoteRefinance] Unexpected error when getting open schedules")
+		return domain.PayoffQuote{}, err
+	}
+
+	quote, err := buildPayoffQuote(loan, schedules, now)
+	if err != nil {
+		b.log.WithField("loan_id", loan.LoanID).
+			WithField("error", err.Error()).Error("[quoteRefinance] failed to build payoff quote")
+		return domain.PayoffQuote{}, err
+	}
+
+	return quote, nil
+}
+
+// getRefinancedLoan returns the loan the top-up refinances.
+func (b BillingService) getRefinancedLoan(ctx context.Context, topUp domain.Loan) (*domain.Loan, error) {
+	loan, err := b.repo.GetLoanByID(ctx, *topUp.RefinancedLoanID)
+	if err != nil {
+		b.log.WithField("loan_id", *topUp.RefinancedLoanID).
+			WithField("error", err.Error()).Error("[getRefinancedLoan] Unexpected error when getting refinanced loan")
+		return nil, err
+	}
+
+	if loan == nil {
+		b.log.WithField("loan_id", *topUp.RefinancedLoanID).Error("[getRefinancedLoan] refinanced loan not found")
+		return nil, apperror.New(apperror.NotFound, "refinanced loan not found")
+	}
+
+	return loan, nil
+}
+
+// settleRefinancedLoan closes the loan the top-up refinances as REFINANCED, settled by the amount kept back of
+// the top-up. Call it in the transaction that activates the top-up.
+func (b BillingService) settleRefinancedLoan(ctx context.Context, topUp domain.Loan, now time.Time) error {
+	loan, err := b.getRefinancedLoan(ctx, topUp)
+	if err != nil {
+		return err
+	}
+
+	history := domain.LoanStatusHistory{
+		LoanID:     loan.LoanID,
+		FromStatus: loan.Status,
+		ToStatus:   enum.LoanStatusRefinanced,
+		Reason:     fmt.Sprintf("settled by top-up %s", topUp.LoanID),
+		ChangedAt:  now,
+	}
+
+	refinanced, err := b.repo.RefinanceLoan(ctx, history)
+	if err != nil {
+		b.log.WithField("loan_id", loan.LoanID).
+			WithField("error", err.Error()).Error("[settleRefinancedLoan] Unexpected error when refinancing loan")
+		return err
+	}
+
+	if !refinanced {
+		b.log.WithField("loan_id", loan.LoanID).Error("[settleRefinancedLoan] loan status changed concurrently")
+		return apperror.New(apperror.InvalidInput, "refinanced loan status was changed by another request")
+	}
+
+	err = b.publishStatusChange(ctx, *loan, history)
+	if err != nil {
+		return err
+	}
+
+	producerMessage := producer.Message{
+		EventID:   uuid.New().String(),
+		EventName: producer.EVENT_NAME_LOAN_REFINANCED,
+		Data: model.LoanRefinancedEventPayload{
+			LoanID:        loan.LoanID,
+			CustomerID:    loan.CustomerID,
+			NewLoanID:     topUp.LoanID,
+			SettledAmount: topUp.RefinancedAmount,
+			RefinancedAt:  now,
+		},
+	}
+
+	err = b.repo.CreateOutboxMessage(ctx, producerMessage)
+	if err != nil {
+		b.log.WithField("loan_id", loan.LoanID).
+			WithField("error", err.Error()).Error("[settleRefinancedLoan] failed to write message to outbox")
+		return err
+	}
+
+	return nil
+}
+
+// netDisbursement is what is paid out for the loan, the admin fee and the balance of a loan it refinanced are
+// kept back.
+func netDisbursement(loan domain.Loan) (money.Money, error) {
+	kept, err := money.Sum(loan.AdminFee, loan.RefinancedAmount)
+	if err != nil {
+		return money.Money{}, err
+	}
+
+	return loan.PrincipalAmount.Sub(kept)
+}
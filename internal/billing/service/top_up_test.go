@@ -0,0 +1,176 @@
+package service
+
+import (
+	"billing-engine/internal/billing/domain"
+	"billing-engine/internal/billing/mocks"
+	"billing-engine/internal/billing/model"
+	apperror "billing-engine/pkg/customerror"
+	"billing-engine/pkg/enum"
+	"errors"
+	"github.com/google/uuid"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"go.uber.org/mock/gomock"
+	"time"
+)
+
+var _ = Describe("TopUp", func() {
+	var (
+		svc       *BillingService
+		repo      *mocks.MockBillingRepositoryProvider
+		cache     *mocks.MockBillingCacheProvider
+		loan      domain.Loan
+		customer  domain.Customer
+		product   domain.Product
+		schedules []domain.Schedule
+		payload   model.TopUpLoanPayload
+	)
+
+	BeforeEach(func() {
+		svc, repo, cache = newTestService()
+
+		now := time.Now()
+		customer = domain.Customer{CustomerID: uuid.New(), CreditLimit: idr(10000000)}
+		product = domain.Product{
+			ProductID:        uuid.New(),
+			InterestRate:     0.1,
+			Tenor:            12,
+			InstallmentCount: 12,
+			Frequency:        enum.FrequencyMonthly,
+			AdminFee:         idr(100000),
+			MinPrincipal:     idr(1000000),
+			MaxPrincipal:     idr(10000000),
+		}
+		loan = domain.Loan{
+			LoanID:          uuid.New(),
+			CustomerID:      customer.CustomerID,
+			ProductID:       product.ProductID,
+			PrincipalAmount: idr(5000000),
+			Status:          enum.LoanStatusActive,
+		}
+		schedules = []domain.Schedule{
+			{
+				ScheduleID:      uuid.New(),
+				PaymentNo:       4,
+				PaymentDueDate:  now.AddDate(0, 1, 0),
+				PrincipalAmount: idr(1000000),
+				InterestAmount:  idr(100000),
+				PaymentStatus:   enum.PaymentStatusPending,
+			},
+			{
+				ScheduleID:      uuid.New(),
+				PaymentNo:       5,
+				PaymentDueDate:  now.AddDate(0, 2, 0),
+				PrincipalAmount: idr(1000000),
+				InterestAmount:  idr(100000),
+				PaymentStatus:   enum.PaymentStatusPending,
+			},
+		}
+		payload = model.TopUpLoanPayload{LoanID: loan.LoanID, Amount: idr(6000000)}
+	})
+
+	Describe("TopUpLoan", func() {
+		It("should approve the top-up and leave the old loan open until it is disbursed", func() {
+			repo.EXPECT().GetLoanByID(ctx, loan.LoanID).Return(&loan, nil)
+			repo.EXPECT().GetMissedSchedules(ctx, loan.LoanID).Return(nil, nil)
+			repo.EXPECT().GetOpenSchedules(ctx, loan.LoanID).Return(schedules, nil)
+			repo.EXPECT().GetCustomerByID(ctx, customer.CustomerID).Return(&customer, nil)
+			repo.EXPECT().GetProductByID(ctx, product.ProductID).Return(&product, nil)
+			repo.EXPECT().LockCustomer(ctx, customer.CustomerID).Return(nil)
+			repo.EXPECT().GetPendingTopUp(ctx, loan.LoanID).Return(nil, nil)
+			repo.EXPECT().GetOutstandingPrincipal(ctx, customer.CustomerID, "IDR").Return(int64(2000000), nil)
+			repo.EXPECT().CreateLoan(ctx, gomock.Any()).DoAndReturn(func(_ any, newLoan domain.Loan) (*domain.Loan, error) {
+				Expect(newLoan.Status).To(Equal(enum.LoanStatusApproved))
+				Expect(*newLoan.RefinancedLoanID).To(Equal(loan.LoanID))
+				Expect(newLoan.RefinancedAmount).To(Equal(idr(2000000)))
+				newLoan.LoanID = uuid.New()
+				return &newLoan, nil
+			})
+			cache.EXPECT().Get(ctx, gomock.Any()).Return(nil, nil).Times(2)
+
+			response, err := svc.TopUpLoan(ctx, payload)
+			Expect(err).To(BeNil())
+			Expect(response.RefinancedLoanID).To(Equal(loan.LoanID))
+			Expect(response.Status).To(Equal(enum.LoanStatusApproved))
+			Expect(response.SettledAmount).To(Equal(idr(2000000)))
+			Expect(response.NetDisbursement).To(Equal(idr(3900000)))
+		})
+
+		It("when the loan already has a pending top-up", func() {
+			repo.EXPECT().GetLoanByID(ctx, loan.LoanID).Return(&loan, nil)
+			repo.EXPECT().GetMissedSchedules(ctx, loan.LoanID).Return(nil, nil)
+			repo.EXPECT().GetOpenSchedules(ctx, loan.LoanID).Return(schedules, nil)
+			repo.EXPECT().GetCustomerByID(ctx, customer.CustomerID).Return(&customer, nil)
+			repo.EXPECT().GetProductByID(ctx, product.ProductID).Return(&product, nil)
+			repo.EXPECT().LockCustomer(ctx, customer.CustomerID).Return(nil)
+			repo.EXPECT().GetPendingTopUp(ctx, loan.LoanID).Return(&domain.Loan{LoanID: uuid.New()}, nil)
+
+			_, err := svc.TopUpLoan(ctx, payload)
+
+			var errs *apperror.CustomError
+			Expect(errors.As(err, &errs)).To(BeTrue())
+			Expect(errs.Cause).To(Equal(apperror.InvalidInput))
+		})
+
+		It("when the loan has missed installments", func() {
+			repo.EXPECT().GetLoanByID(ctx, loan.LoanID).Return(&loan, nil)
+			repo.EXPECT().GetMissedSchedules(ctx, loan.LoanID).Return(schedules[:1], nil)
+
+			_, err := svc.TopUpLoan(ctx, payload)
+
+			var errs *apperror.CustomError
+			Expect(errors.As(err, &errs)).To(BeTrue())
+			Expect(errs.Cause).To(Equal(apperror.InvalidInput))
+		})
+
+		It("when the amount does not cover the settlement", func() {
+			payload.Amount = idr(2000000)
+			repo.EXPECT().GetLoanByID(ctx, loan.LoanID).Return(&loan, nil)
+			repo.EXPECT().GetMissedSchedules(ctx, loan.LoanID).Return(nil, nil)
+			repo.EXPECT().GetCustomerByID(ctx, customer.CustomerID).Return(&customer, nil)
+			repo.EXPECT().GetProductByID(ctx, product.ProductID).Return(&product, nil)
+			repo.EXPECT().GetOpenSchedules(ctx, loan.LoanID).Return(schedules, nil)
+
+			_, err := svc.TopUpLoan(ctx, payload)
+
+			var errs *apperror.CustomError
+			Expect(errors.As(err, &errs)).To(BeTrue())
+			Expect(errs.Cause).To(Equal(apperror.InvalidInput))
+		})
+
+		It("when the increase exceeds the credit limit", func() {
+			repo.EXPECT().GetLoanByID(ctx, loan.LoanID).Return(&loan, nil)
+			repo.EXPECT().GetMissedSchedules(ctx, loan.LoanID).Return(nil, nil)
+			repo.EXPECT().GetCustomerByID(ctx, customer.CustomerID).Return(&customer, nil)
+			repo.EXPECT().GetProductByID(ctx, product.ProductID).Return(&product, nil)
+			repo.EXPECT().GetOpenSchedules(ctx, loan.LoanID).Return(schedules, nil)
+			repo.EXPECT().LockCustomer(ctx, customer.CustomerID).Return(nil)
+			repo.EXPECT().GetPendingTopUp(ctx, loan.LoanID).Return(nil, nil)
+			repo.EXPECT().GetOutstandingPrincipal(ctx, customer.CustomerID, "IDR").Return(int64(8000000), nil)
+
+			_, err := svc.TopUpLoan(ctx, payload)
+
+			var errs *apperror.CustomError
+			Expect(errors.As(err, &errs)).To(BeTrue())
+			Expect(errs.Cause).To(Equal(apperror.LimitExceeded))
+		})
+
+		It("when the loan is not being repaid", func() {
+			loan.Status = enum.LoanStatusApproved
+			repo.EXPECT().GetLoanByID(ctx, loan.LoanID).Return(&loan, nil)
+
+			_, err := svc.TopUpLoan(ctx, payload)
+
+			var errs *apperror.CustomError
+			Expect(errors.As(err, &errs)).To(BeTrue())
+			Expect(errs.Cause).To(Equal(apperror.InvalidInput))
+		})
+	})
+
+	Describe("netDisbursement", func() {
+		It("should keep the settled balance of a refinanced loan back", func() {
+			topUp := domain.Loan{PrincipalAmount: idr(6000000), AdminFee: idr(100000), RefinancedAmount: idr(2000000)}
+			Expect(netDisbursement(topUp)).To(Equal(idr(3900000)))
+		})
+	})
+})
@@ -0,0 +1,179 @@
+package service
+
+import (
+	"billing-engine/internal/billing/constant"
+	"billing-engine/internal/billing/delinquency"
+	"billing-engine/internal/billing/domain"
+	"billing-engine/internal/billing/lifecycle"
+	"billing-engine/internal/billing/model"
+	"billing-engine/internal/billing/repository"
+	apperror "billing-engine/pkg/customerror"
+	"billing-engine/pkg/enum"
+	"billing-engine/pkg/money"
+	"context"
+	"fmt"
+	"time"
+)
+
+// WriteOffLoan takes a loan that is long overdue out of the active portfolio. The open schedules are left as
+// they are, so the written-off balance can still be traced, but nothing accrues on them anymore and later
+// payments are booked as recoveries.
+func (b BillingService) WriteOffLoan(ctx context.Context, payload model.WriteOffLoanPayload) (*model.WriteOffLoanResponse, error) {
+	b.log.WithField("loan_id", payload.LoanID).
+		WithField("approved_by", payload.ApprovedBy).Info("[WriteOffLoan] writing off loan")
+
+	loan, err := b.repo.GetLoanByID(ctx, payload.LoanID)
+	if err != nil {
+		b.log.WithField("loan_id", payload.LoanID).
+			WithField("error", err.Error()).Error("[WriteOffLoan] Unexpected error when getting loan")
+		return nil, err
+	}
+
+	if loan == nil {
+		b.log.WithField("loan_id", payload.LoanID).Info("[WriteOffLoan] loan not found")
+		return nil, apperror.New(apperror.NotFound, "loan not found")
+	}
+
+	err = lifecycle.Validate(loan.Status, enum.LoanStatusWrittenOff)
+	if err != nil {
+		b.log.WithField("loan_id", payload.LoanID).
+			WithField("error", err.Error()).Info("[WriteOffLoan] loan cannot be written off")
+		return nil, apperror.New(apperror.InvalidInput, err.Error())
+	}
+
+	schedules, err := b.repo.GetOpenSchedules(ctx, payload.LoanID)
+	if err != nil {
+		b.log.WithField("loan_id", payload.LoanID).
+			WithField("error", err.Error()).Error("[WriteOffLoan] Unexpected error when getting open schedules")
+		return nil, err
+	}
+
+	writeOff, err := planWriteOff(*loan, schedules, time.Now())
+	if err != nil {
+		b.log.WithField("loan_id", payload.LoanID).
+			WithField("error", err.Error()).Error("[WriteOffLoan] failed to plan write-off")
+		return nil, err
+	}
+
+	if writeOff.DaysPastDue < constant.WRITE_OFF_MIN_DAYS_PAST_DUE {
+		b.log.WithField("loan_id", payload.LoanID).
+			WithField("days_past_due", writeOff.DaysPastDue).Info("[WriteOffLoan] loan is not overdue long enough")
+		return nil, apperror.New(apperror.InvalidInput, fmt.Sprintf("loan has to be at least %d days past due to be written off, it is %d",
+			constant.WRITE_OFF_MIN_DAYS_PAST_DUE, writeOff.DaysPastDue))
+	}
+
+	writeOff.Reason = payload.Reason
+	writeOff.ApprovedBy = payload.ApprovedBy
+	var stored *domain.WriteOff
+	err = b.repo.WithTransaction(ctx, func(repo repository.BillingRepositoryProvider) error {
+		tx := b.withRepo(repo)
+
+		var err error
+		stored, err = tx.repo.CreateWriteOff(ctx, writeOff)
+		if err != nil {
+			b.log.WithField("loan_id", payload.LoanID).
+				WithField("error", err.Error()).Error("[WriteOffLoan] Unexpected error when creating write-off")
+			return err
+		}
+
+		return tx.changeLoanStatus(ctx, *loan, enum.LoanStatusWrittenOff,
+			fmt.Sprintf("%s, approved by %s", payload.Reason, payload.ApprovedBy))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = b.flushCache(ctx, loan.CustomerID)
+	if err != nil {
+		b.log.WithField("loan_id", payload.LoanID).
+			WithField("error", err.Error()).Error("[WriteOffLoan] failed to flush cache")
+		return nil, err
+	}
+
+	b.log.WithField("write_off_id", stored.WriteOffID).Info("[WriteOffLoan] loan written off")
+	return &model.WriteOffLoanResponse{
+		WriteOffID:   stored.WriteOffID,
+		LoanID:       loan.LoanID,
+		Status:       enum.LoanStatusWrittenOff,
+		DaysPastDue:  stored.DaysPastDue,
+		Principal:    stored.Principal,
+		Interest:     stored.Interest,
+		Penalty:      stored.Penalty,
+		Total:        stored.Total,
+		ApprovedBy:   stored.ApprovedBy,
+		WrittenOffAt: stored.WrittenOffAt,
+	}, nil
+}
+
+// planWriteOff sums what the open schedules still owe. DaysPastDue is counted from the oldest open
+// installment, the one a collector would chase first.
+func planWriteOff(loan domain.Loan, schedules []domain.Schedule, now time.Time) (domain.WriteOff, error) {
+	currency := loan.PrincipalAmount.Currency
+	writeOff := domain.WriteOff{
+		LoanID:       loan.LoanID,
+		WrittenOffAt: now,
+	}
+
+	principal := []money.Money{money.Zero(currency)}
+	interest := []money.Money{money.Zero(currency)}
+	penalties := []money.Money{money.Zero(currency)}
+	for _, schedule := range schedules {
+		unpaid, err := payoffQuoteLine(schedule, currency)
+		if err != nil {
+			return domain.WriteOff{}, err
+		}
+
+		principal = append(principal, unpaid.PrincipalAmount)
+		interest = append(interest, unpaid.InterestAmount)
+		penalties = append(penalties, unpaid.PenaltyAmount)
+		dpd := delinquency.DaysPastDue(schedule.PaymentDueDate, now)
+		if dpd > writeOff.DaysPastDue {
+			writeOff.DaysPastDue = dpd
+		}
+	}
+
+	var err error
+	if writeOff.Principal, err = money.Sum(principal...); err != nil {
+		return domain.WriteOff{}, err
+	}
+
+	if writeOff.Interest, err = money.Sum(interest...); err != nil {
+		return domain.WriteOff{}, err
+	}
+
+	if writeOff.Penalty, err = money.Sum(penalties...); err != nil {
+		return domain.WriteOff{}, err
+	}
+
+	writeOff.Total, err = money.Sum(writeOff.Principal, writeOff.Interest, writeOff.Penalty)
+	if err != nil {
+		return domain.WriteOff{}, err
+	}
+
+	return writeOff, nil
+}
+
+// bookRecovery records a payment on a written-off loan as recovery income. The schedule balances of the
+// event are ignored, the written-off balance stays as it was at the write-off.
+func (b BillingService) bookRecovery(ctx context.Context, loan domain.Loan, payload model.PaymentEventPayload) error {
+	recoveredAt := payload.PaymentDate
+	if recoveredAt.IsZero() {
+		recoveredAt = time.Now()
+	}
+
+	err := b.repo.CreateRecovery(ctx, domain.Recovery{
+		LoanID:      loan.LoanID,
+		PaymentID:   payload.PaymentID,
+		Amount:      payload.AmountPaid,
+		RecoveredAt: recoveredAt,
+	})
+	if err != nil {
+		b.log.WithField("payment_id", payload.PaymentID).
+			WithField("error", err.Error()).Error("[bookRecovery] Unexpected error when creating recovery")
+		return err
+	}
+
+	b.log.WithField("loan_id", loan.LoanID).
+		WithField("payment_id", payload.PaymentID).Info("[bookRecovery] recovery booked")
+	return nil
+}
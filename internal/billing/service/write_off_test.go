@@ -0,0 +1,161 @@
+package service
+
+import (
+	"billing-engine/internal/billing/domain"
+	"billing-engine/internal/billing/mocks"
+	"billing-engine/internal/billing/model"
+	apperror "billing-engine/pkg/customerror"
+	"billing-engine/pkg/enum"
+	"billing-engine/pkg/producer"
+	"errors"
+	"github.com/google/uuid"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"go.uber.org/mock/gomock"
+	"time"
+)
+
+var _ = Describe("WriteOff", func() {
+	var (
+		svc       *BillingService
+		repo      *mocks.MockBillingRepositoryProvider
+		cache     *mocks.MockBillingCacheProvider
+		now       time.Time
+		loan      domain.Loan
+		schedules []domain.Schedule
+		payload   model.WriteOffLoanPayload
+	)
+
+	BeforeEach(func() {
+		svc, repo, cache = newTestService()
+
+		now = time.Now()
+		loan = domain.Loan{
+			LoanID:          uuid.New(),
+			CustomerID:      uuid.New(),
+			PrincipalAmount: idr(200000),
+			Status:          enum.LoanStatusActive,
+		}
+		schedules = []domain.Schedule{
+			{
+				ScheduleID:      uuid.New(),
+				PaymentNo:       1,
+				PaymentDueDate:  now.AddDate(0, 0, -200),
+				PrincipalAmount: idr(100000),
+				InterestAmount:  idr(10000),
+				InterestPaid:    idr(4000),
+				Penalties:       []domain.Penalty{{Type: enum.PenaltyLateFee, Amount: idr(5000)}},
+			},
+			{
+				ScheduleID:      uuid.New(),
+				PaymentNo:       2,
+				PaymentDueDate:  now.AddDate(0, 0, -170),
+				PrincipalAmount: idr(100000),
+				InterestAmount:  idr(10000),
+			},
+		}
+		payload = model.WriteOffLoanPayload{
+			LoanID:     loan.LoanID,
+			Reason:     "customer unreachable",
+			ApprovedBy: "head of collections",
+		}
+	})
+
+	Describe("planWriteOff", func() {
+		It("should sum what the open schedules still owe", func() {
+			writeOff, err := planWriteOff(loan, schedules, now)
+
+			Expect(err).To(BeNil())
+			Expect(writeOff.DaysPastDue).To(Equal(200))
+			Expect(writeOff.Principal).To(Equal(idr(200000)))
+			Expect(writeOff.Interest).To(Equal(idr(16000)))
+			Expect(writeOff.Penalty).To(Equal(idr(5000)))
+			Expect(writeOff.Total).To(Equal(idr(221000)))
+		})
+	})
+
+	Describe("WriteOffLoan", func() {
+		It("should write off a loan that is 180 days past due", func() {
+			repo.EXPECT().GetLoanByID(ctx, loan.LoanID).Return(&loan, nil)
+			repo.EXPECT().GetOpenSchedules(ctx, loan.LoanID).Return(schedules, nil)
+			repo.EXPECT().CreateWriteOff(ctx, gomock.Any()).DoAndReturn(func(_ any, writeOff domain.WriteOff) (*domain.WriteOff, error) {
+				Expect(writeOff.Reason).To(Equal(payload.Reason))
+				Expect(writeOff.ApprovedBy).To(Equal(payload.ApprovedBy))
+				writeOff.WriteOffID = uuid.New()
+				return &writeOff, nil
+			})
+			repo.EXPECT().UpdateLoanStatus(ctx, gomock.Any()).DoAndReturn(func(_ any, history domain.LoanStatusHistory) (bool, error) {
+				Expect(history.ToStatus).To(Equal(enum.LoanStatusWrittenOff))
+				return true, nil
+			})
+			repo.EXPECT().CreateOutboxMessage(ctx, gomock.Any()).DoAndReturn(func(_ any, message producer.Message) error {
+				Expect(message.EventName).To(Equal(producer.EVENT_NAME_LOAN_STATUS_CHANGED))
+				return nil
+			})
+			cache.EXPECT().Get(ctx, gomock.Any()).Return(nil, nil).Times(2)
+
+			response, err := svc.WriteOffLoan(ctx, payload)
+			Expect(err).To(BeNil())
+			Expect(response.Status).To(Equal(enum.LoanStatusWrittenOff))
+			Expect(response.Total).To(Equal(idr(221000)))
+			Expect(response.DaysPastDue).To(Equal(200))
+		})
+
+		It("when the loan is not overdue long enough", func() {
+			repo.EXPECT().GetLoanByID(ctx, loan.LoanID).Return(&loan, nil)
+			repo.EXPECT().GetOpenSchedules(ctx, loan.LoanID).Return(schedules[1:], nil)
+
+			_, err := svc.WriteOffLoan(ctx, payload)
+
+			var errs *apperror.CustomError
+			Expect(errors.As(err, &errs)).To(BeTrue())
+			Expect(errs.Cause).To(Equal(apperror.InvalidInput))
+		})
+
+		It("when loan is paid off", func() {
+			loan.Status = enum.LoanStatusPaidOff
+			repo.EXPECT().GetLoanByID(ctx, loan.LoanID).Return(&loan, nil)
+
+			_, err := svc.WriteOffLoan(ctx, payload)
+
+			var errs *apperror.CustomError
+			Expect(errors.As(err, &errs)).To(BeTrue())
+			Expect(errs.Cause).To(Equal(apperror.InvalidInput))
+		})
+
+		It("when loan not found", func() {
+			repo.EXPECT().GetLoanByID(ctx, gomock.Any()).Return(nil, nil)
+
+			_, err := svc.WriteOffLoan(ctx, payload)
+
+			var errs *apperror.CustomError
+			Expect(errors.As(err, &errs)).To(BeTrue())
+			Expect(errs.Cause).To(Equal(apperror.NotFound))
+		})
+	})
+
+	Describe("UpdatePayment", func() {
+		It("should book a payment on a written-off loan as a recovery", func() {
+			loan.Status = enum.LoanStatusWrittenOff
+			event := model.PaymentEventPayload{
+				LoanID:      loan.LoanID,
+				PaymentID:   uuid.New(),
+				AmountPaid:  idr(50000),
+				PaymentType: enum.PaymentTypeRecovery,
+				PaymentDate: now,
+			}
+
+			repo.EXPECT().GetLoanByID(ctx, loan.LoanID).Return(&loan, nil)
+			repo.EXPECT().CreateRecovery(ctx, gomock.Any()).DoAndReturn(func(_ any, recovery domain.Recovery) error {
+				Expect(recovery.LoanID).To(Equal(loan.LoanID))
+				Expect(recovery.PaymentID).To(Equal(event.PaymentID))
+				Expect(recovery.Amount).To(Equal(idr(50000)))
+				Expect(recovery.RecoveredAt).To(Equal(now))
+				return nil
+			})
+			cache.EXPECT().Get(ctx, gomock.Any()).Return(nil, nil).Times(2)
+
+			Expect(svc.UpdatePayment(ctx, event)).To(Succeed())
+		})
+	})
+})
@@ -0,0 +1,168 @@
+package allocation
+
+import (
+	"billing-engine/internal/payment/domain"
+	"billing-engine/pkg/enum"
+	"billing-engine/pkg/money"
+	"fmt"
+)
+
+// Waterfall is the order in which a payment covers the components of an installment.
+type Waterfall []enum.AllocationComponent
+
+// DefaultWaterfall settles penalties first, then interest, then principal.
+func DefaultWaterfall() Waterfall {
+	return Waterfall{enum.AllocationPenalty, enum.AllocationInterest, enum.AllocationPrincipal}
+}
+
+// NewWaterfall builds the waterfall from configuration, every component has to appear exactly once.
+// It falls back to DefaultWaterfall when no order is configured.
+func NewWaterfall(order []string) (Waterfall, error) {
+	if len(order) == 0 {
+		return DefaultWaterfall(), nil
+	}
+
+	seen := map[enum.AllocationComponent]bool{}
+	var waterfall Waterfall
+	for _, val := range order {
+		component := enum.AllocationComponent(val)
+		switch component {
+		case enum.AllocationPenalty, enum.AllocationInterest, enum.AllocationPrincipal:
+		default:
+			return nil, fmt.Errorf("unknown waterfall component %q", val)
+		}
+
+		if seen[component] {
+			return nil, fmt.Errorf("waterfall component %q is listed twice", val)
+		}
+
+		seen[component] = true
+		waterfall = append(waterfall, component)
+	}
+
+	if len(waterfall) != len(DefaultWaterfall()) {
+		return nil, fmt.Errorf("waterfall must list %v", DefaultWaterfall())
+	}
+
+	return waterfall, nil
+}
+
+// Allocate spends amount on the schedules, which must be sorted oldest first. Every schedule is settled
+// component by component in waterfall order before moving to the next one. The schedules are updated in
+// place and whatever could not be allocated is returned as the remainder.
+func (w Waterfall) Allocate(amount money.Money, schedules []domain.PaymentSchedule) ([]domain.PaymentAllocation, money.Money, error) {
+	var allocations []domain.PaymentAllocation
+	remaining := amount
+
+	for i := range schedules {
+		if !remaining.IsPositive() {
+			break
+		}
+
+		schedule := &schedules[i]
+		for _, component := range w {
+			due, err := Due(*schedule, component)
+			if err != nil {
+				return nil, money.Money{}, err
+			}
+
+			share, err := due.Min(remaining)
+			if err != nil {
+				return nil, money.Money{}, err
+			}
+
+			if !share.IsPositive() {
+				continue
+			}
+
+			paid := paidOf(schedule, component)
+			*paid, err = paid.Add(share)
+			if err != nil {
+				return nil, money.Money{}, err
+			}
+
+			remaining, err = remaining.Sub(share)
+			if err != nil {
+				return nil, money.Money{}, err
+			}
+
+			allocations = append(allocations, domain.PaymentAllocation{
+				ScheduleID: schedule.ScheduleID,
+				PaymentNo:  schedule.PaymentNo,
+				Component:  component,
+				Amount:     share,
+			})
+		}
+
+		status, err := Status(*schedule)
+		if err != nil {
+			return nil, money.Money{}, err
+		}
+
+		schedule.PaymentStatus = status
+	}
+
+	return allocations, remaining, nil
+}
+
+// Due returns what is still owed on one component of the schedule.
+func Due(schedule domain.PaymentSchedule, component enum.AllocationComponent) (money.Money, error) {
+	switch component {
+	case enum.AllocationPenalty:
+		return schedule.PenaltyAmount.Sub(schedule.PenaltyPaid)
+	case enum.AllocationInterest:
+		return schedule.InterestAmount.Sub(schedule.InterestPaid)
+	case enum.AllocationPrincipal:
+		return schedule.PrincipalAmount.Sub(schedule.PrincipalPaid)
+	default:
+		return money.Zero(schedule.PaymentAmount.Currency), nil
+	}
+}
+
+// Outstanding returns what is still owed on the schedules, penalties included.
+func Outstanding(schedules []domain.PaymentSchedule) (money.Money, error) {
+	total := money.Money{}
+	for _, schedule := range schedules {
+		for _, component := range DefaultWaterfall() {
+			due, err := Due(schedule, component)
+			if err != nil {
+				return money.Money{}, err
+			}
+
+			total, err = total.Add(due)
+			if err != nil {
+				return money.Money{}, err
+			}
+		}
+	}
+
+	return total, nil
+}
+
+// Status derives the payment status from what has been paid on the schedule.
+func Status(schedule domain.PaymentSchedule) (enum.PaymentStatus, error) {
+	outstanding, err := Outstanding([]domain.PaymentSchedule{schedule})
+	if err != nil {
+		return "", err
+	}
+
+	switch {
+	case !outstanding.IsPositive():
+		return enum.PaymentStatusPaid, nil
+	case schedule.PrincipalPaid.IsPositive() || schedule.InterestPaid.IsPositive() || schedule.PenaltyPaid.IsPositive():
+		return enum.PaymentStatusPartiallyPaid, nil
+	default:
+		return enum.PaymentStatusPending, nil
+	}
+}
+
+func paidOf(schedule *domain.PaymentSchedule, component enum.AllocationComponent) *money.Money {
+	switch component {
+	case enum.AllocationPenalty:
+		return &schedule.PenaltyPaid
+	case enum.AllocationInterest:
+		return &schedule.InterestPaid
+	default:
+		return &schedule.PrincipalPaid
+	}
+}
@@ -0,0 +1,82 @@
+package allocation
+
+import (
+	"billing-engine/internal/payment/domain"
+	"billing-engine/pkg/enum"
+	"billing-engine/pkg/money"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func idr(amount int64) money.Money {
+	return money.New(amount, "IDR")
+}
+
+var _ = Describe("Waterfall", func() {
+	var schedules []domain.PaymentSchedule
+
+	BeforeEach(func() {
+		schedules = []domain.PaymentSchedule{
+			{PaymentNo: 1, PrincipalAmount: idr(100000), InterestAmount: idr(10000), PenaltyAmount: idr(5000)},
+			{PaymentNo: 2, PrincipalAmount: idr(100000), InterestAmount: idr(10000)},
+		}
+	})
+
+	Describe("NewWaterfall", func() {
+		It("should fall back to the default order", func() {
+			waterfall, err := NewWaterfall(nil)
+			Expect(err).To(BeNil())
+			Expect(waterfall).To(Equal(DefaultWaterfall()))
+		})
+
+		It("should reject unknown, repeated or missing components", func() {
+			_, err := NewWaterfall([]string{"PENALTY", "FEE", "PRINCIPAL"})
+			Expect(err).To(HaveOccurred())
+			_, err = NewWaterfall([]string{"PENALTY", "PENALTY", "PRINCIPAL"})
+			Expect(err).To(HaveOccurred())
+			_, err = NewWaterfall([]string{"PENALTY", "PRINCIPAL"})
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Describe("Allocate", func() {
+		It("should settle penalty, interest and principal of the oldest schedule first", func() {
+			allocations, remaining, err := DefaultWaterfall().Allocate(idr(20000), schedules)
+			Expect(err).To(BeNil())
+			Expect(remaining.IsZero()).To(BeTrue())
+			Expect(allocations).To(HaveLen(3))
+			Expect(allocations[0].Component).To(Equal(enum.AllocationPenalty))
+			Expect(allocations[0].Amount).To(Equal(idr(5000)))
+			Expect(allocations[1].Component).To(Equal(enum.AllocationInterest))
+			Expect(allocations[2].Amount).To(Equal(idr(5000)))
+			Expect(schedules[0].PaymentStatus).To(Equal(enum.PaymentStatusPartiallyPaid))
+			Expect(schedules[1].PaymentStatus).To(BeEmpty())
+		})
+
+		It("should follow a configured order", func() {
+			waterfall, err := NewWaterfall([]string{"PRINCIPAL", "INTEREST", "PENALTY"})
+			Expect(err).To(BeNil())
+
+			allocations, _, err := waterfall.Allocate(idr(100000), schedules)
+			Expect(err).To(BeNil())
+			Expect(allocations).To(HaveLen(1))
+			Expect(allocations[0].Component).To(Equal(enum.AllocationPrincipal))
+			Expect(Due(schedules[0], enum.AllocationPenalty)).To(Equal(idr(5000)))
+		})
+
+		It("should spill over to the next schedule and return what is left", func() {
+			allocations, remaining, err := DefaultWaterfall().Allocate(idr(300000), schedules)
+			Expect(err).To(BeNil())
+			Expect(remaining).To(Equal(idr(75000)))
+			Expect(allocations).To(HaveLen(5))
+			Expect(schedules[0].PaymentStatus).To(Equal(enum.PaymentStatusPaid))
+			Expect(schedules[1].PaymentStatus).To(Equal(enum.PaymentStatusPaid))
+			Expect(Outstanding(schedules)).To(Equal(idr(0)))
+		})
+
+		It("should refuse a payment in another currency", func() {
+			_, _, err := DefaultWaterfall().Allocate(money.New(100, "USD"), schedules)
+			Expect(err).To(MatchError(money.ErrCurrencyMismatch))
+		})
+	})
+})
@@ -29,6 +29,8 @@ func (s *PaymentHandler) ProcessPaymentHandler(c echo.Context) error {
 		return err
 	}
 
+	payload.IdempotencyKey = c.Request().Header.Get("Idempotency-Key")
+
 	if err := c.Validate(payload); err != nil {
 		return err
 	}
@@ -40,3 +42,23 @@ func (s *PaymentHandler) ProcessPaymentHandler(c echo.Context) error {
 
 	return c.JSON(http.StatusOK, response.NewSuccessResponse(result))
 }
+
+func (s *PaymentHandler) ProcessSettlementHandler(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	payload := model.SettlementPayload{}
+	if err := c.Bind(&payload); err != nil {
+		return err
+	}
+
+	if err := c.Validate(payload); err != nil {
+		return err
+	}
+
+	result, err := s.BillingService.ProcessSettlement(ctx, payload)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, response.NewSuccessResponse(result))
+}
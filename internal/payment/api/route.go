@@ -5,4 +5,5 @@ import "github.com/labstack/echo/v4"
 func (s *PaymentHandler) AddRoutes(e *echo.Echo) {
 	paymentGroup := e.Group("/payment")
 	paymentGroup.POST("", s.ProcessPaymentHandler)
+	paymentGroup.POST("/settlement", s.ProcessSettlementHandler)
 }
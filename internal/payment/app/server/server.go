@@ -1,14 +1,16 @@
 package server
 
 import (
+	"billing-engine/internal/payment/allocation"
 	"billing-engine/internal/payment/api"
 	"billing-engine/internal/payment/domain"
 	"billing-engine/internal/payment/repository"
 	"billing-engine/internal/payment/service"
 	"billing-engine/pkg/config"
 	"billing-engine/pkg/database"
+	"billing-engine/pkg/inbox"
 	"billing-engine/pkg/logger"
-	"billing-engine/pkg/producer"
+	"billing-engine/pkg/outbox"
 	"context"
 	"github.com/go-playground/validator/v10"
 	"github.com/labstack/echo/v4"
@@ -37,23 +39,25 @@ func NewServer(log logger.Logger, cfg *config.Config) (*Server, error) {
 		return nil, err
 	}
 
-	err = gorm.AutoMigrate(&domain.Loan{}, &domain.PaymentSchedule{}, &domain.Payment{})
+	err = gorm.AutoMigrate(&domain.Loan{}, &domain.PaymentSchedule{}, &domain.Payment{}, &domain.PaymentAllocation{},
+		&domain.PayoffQuote{}, &domain.PayoffQuoteLine{}, &domain.IdempotencyKey{}, &outbox.Message{},
+		&inbox.ProcessedEvent{})
 	if err != nil {
 		return nil, err
 	}
 
-	producerConfig := producer.Config{
-		Brokers:      cfg.Kafka.Broker,
-		Topic:        cfg.Kafka.PaymentTopic,
-		WriteTimeout: cfg.Kafka.Timeout,
-	}
-	newProducer, err := producer.NewProducer(producerConfig, log)
+	err = repository.MigrateLoanStatus(gorm)
 	if err != nil {
 		return nil, err
 	}
 
 	paymentRepository := repository.NewPaymentRepository(gorm)
-	paymentService := service.NewPaymentService(paymentRepository, newProducer, log)
+	waterfall, err := allocation.NewWaterfall(cfg.Payment.Waterfall)
+	if err != nil {
+		return nil, err
+	}
+
+	paymentService := service.NewPaymentService(paymentRepository, waterfall, log)
 	paymentHandler := api.NewPaymentHandler(paymentService, log)
 
 	e := echo.New()
@@ -0,0 +1,25 @@
+package domain
+
+import (
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"time"
+)
+
+// IdempotencyKey remembers a payment request by the key the client sent with it, so a retry gets the first
+// response back instead of paying twice. CompletedAt stays empty while the first request is being processed.
+// ClaimedAt is when the request being processed took the key, a claim that is not completed within its lease
+// is stale and the next request takes the key over.
+type IdempotencyKey struct {
+	Base
+	Key         string     `json:"key" gorm:"primaryKey"`
+	RequestHash string     `json:"request_hash"`
+	ClaimedAt   time.Time  `json:"claimed_at"`
+	PaymentID   *uuid.UUID `json:"payment_id" gorm:"type:uuid"`
+	Response    []byte     `json:"response"`
+	CompletedAt *time.Time `json:"completed_at"`
+}
+
+func (key *IdempotencyKey) BeforeCreate(tx *gorm.DB) (err error) {
+	return key.Base.BeforeCreate(tx)
+}
@@ -1,14 +1,20 @@
 package domain
 
 import (
+	"billing-engine/pkg/enum"
 	"github.com/google/uuid"
 	"gorm.io/gorm"
+	"time"
 )
 
 type Loan struct {
 	Base
-	LoanID     uuid.UUID `json:"loan_id" gorm:"type:uuid;primaryKey"`
-	CustomerID uuid.UUID `json:"customer_id" gorm:"type:uuid"`
+	LoanID     uuid.UUID       `json:"loan_id" gorm:"type:uuid;primaryKey"`
+	CustomerID uuid.UUID       `json:"customer_id" gorm:"type:uuid"`
+	Status     enum.LoanStatus `json:"status"`
+	// StatusChangedAt is when the billing service moved the loan to Status, a status event that is older is
+	// left out so events that arrive out of order cannot move the loan back.
+	StatusChangedAt time.Time `json:"status_changed_at"`
 
 	PaymentSchedules []PaymentSchedule `json:"payment_schedules" gorm:"foreignKey:LoanID"`
 }
@@ -2,20 +2,26 @@ package domain
 
 import (
 	"billing-engine/pkg/enum"
+	"billing-engine/pkg/money"
 	"github.com/google/uuid"
 	"gorm.io/gorm"
 	"time"
 )
 
+// Payment Credit is the part of AmountPaid that was more than the loan owed, it is kept as credit of the
+// borrower instead of being allocated.
 type Payment struct {
 	Base
 	PaymentID     uuid.UUID          `json:"payment_id" gorm:"type:uuid;primaryKey"`
 	LoanID        uuid.UUID          `json:"loan_id" gorm:"type:uuid"`
-	ScheduleID    uuid.UUID          `json:"schedule_id" gorm:"type:uuid"`
 	PaymentDate   time.Time          `json:"payment_date"`
-	AmountPaid    float64            `json:"amount_paid"`
+	AmountPaid    money.Money        `json:"amount_paid" gorm:"embedded;embeddedPrefix:amount_paid_"`
+	Credit        money.Money        `json:"credit" gorm:"embedded;embeddedPrefix:credit_"`
 	PaymentMethod string             `json:"payment_method"`
 	PaymentStatus enum.PaymentStatus `json:"payment_status"`
+	PaymentType   enum.PaymentType   `json:"payment_type" gorm:"index"`
+
+	Allocations []PaymentAllocation `json:"allocations" gorm:"foreignKey:PaymentID"`
 }
 
 func (payment *Payment) BeforeCreate(tx *gorm.DB) (err error) {
@@ -0,0 +1,24 @@
+package domain
+
+import (
+	"billing-engine/pkg/enum"
+	"billing-engine/pkg/money"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// PaymentAllocation is the part of a payment that went to one component of one installment.
+type PaymentAllocation struct {
+	Base
+	AllocationID uuid.UUID                `json:"allocation_id" gorm:"type:uuid;primaryKey"`
+	PaymentID    uuid.UUID                `json:"payment_id" gorm:"type:uuid;index"`
+	ScheduleID   uuid.UUID                `json:"schedule_id" gorm:"type:uuid;index"`
+	PaymentNo    int                      `json:"payment_no"`
+	Component    enum.AllocationComponent `json:"component"`
+	Amount       money.Money              `json:"amount" gorm:"embedded;embeddedPrefix:allocated_"`
+}
+
+func (allocation *PaymentAllocation) BeforeCreate(tx *gorm.DB) (err error) {
+	allocation.AllocationID = uuid.New()
+	return allocation.Base.BeforeCreate(tx)
+}
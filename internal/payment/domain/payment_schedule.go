@@ -2,6 +2,7 @@ package domain
 
 import (
 	"billing-engine/pkg/enum"
+	"billing-engine/pkg/money"
 	"github.com/google/uuid"
 	"gorm.io/gorm"
 	"time"
@@ -9,14 +10,23 @@ import (
 
 type PaymentSchedule struct {
 	Base
-	ScheduleID     uuid.UUID          `json:"schedule_id" gorm:"type:uuid;primaryKey"`
-	LoanID         uuid.UUID          `json:"loan_id" gorm:"type:uuid"`
-	PaymentNo      int                `json:"payment_no"`
-	PaymentDueDate time.Time          `json:"payment_due_date"`
-	PaymentAmount  float64            `json:"payment_amount"`
-	PaymentStatus  enum.PaymentStatus `json:"payment_status"`
+	ScheduleID      uuid.UUID          `json:"schedule_id" gorm:"type:uuid;primaryKey"`
+	LoanID          uuid.UUID          `json:"loan_id" gorm:"type:uuid"`
+	PaymentNo       int                `json:"payment_no"`
+	PaymentDueDate  time.Time          `json:"payment_due_date"`
+	PaymentAmount   money.Money        `json:"payment_amount" gorm:"embedded;embeddedPrefix:payment_"`
+	PrincipalAmount money.Money        `json:"principal_amount" gorm:"embedded;embeddedPrefix:principal_"`
+	InterestAmount  money.Money        `json:"interest_amount" gorm:"embedded;embeddedPrefix:interest_"`
+	PaymentStatus   enum.PaymentStatus `json:"payment_status"`
+	// PenaltyAmount mirrors the penalties billing accrued on this installment, collected with it
+	PenaltyAmount money.Money `json:"penalty_amount" gorm:"embedded;embeddedPrefix:penalty_"`
 
-	Payment Payment `json:"payments" gorm:"foreignKey:ScheduleID"`
+	// the parts of the installment paid so far, an installment is PAID once all of them are covered
+	PrincipalPaid money.Money `json:"principal_paid" gorm:"embedded;embeddedPrefix:principal_paid_"`
+	InterestPaid  money.Money `json:"interest_paid" gorm:"embedded;embeddedPrefix:interest_paid_"`
+	PenaltyPaid   money.Money `json:"penalty_paid" gorm:"embedded;embeddedPrefix:penalty_paid_"`
+
+	Allocations []PaymentAllocation `json:"allocations" gorm:"foreignKey:ScheduleID"`
 }
 
 func (paymentSchedule *PaymentSchedule) BeforeCreate(tx *gorm.DB) (err error) {
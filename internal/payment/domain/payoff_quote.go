@@ -0,0 +1,44 @@
+package domain
+
+import (
+	"billing-engine/pkg/enum"
+	"billing-engine/pkg/money"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"time"
+)
+
+// PayoffQuote is the copy of a billing payoff quote that a settlement payment has to match.
+type PayoffQuote struct {
+	Base
+	QuoteID uuid.UUID `json:"quote_id" gorm:"type:uuid;primaryKey"`
+	LoanID  uuid.UUID `json:"loan_id" gorm:"type:uuid;index"`
+	// QuoteDate is when billing worked the quote out, a payment after it changes what is left to pay
+	QuoteDate        time.Time        `json:"quote_date"`
+	ValidUntil       time.Time        `json:"valid_until"`
+	SettlementFee    money.Money      `json:"settlement_fee" gorm:"embedded;embeddedPrefix:settlement_fee_"`
+	SettlementAmount money.Money      `json:"settlement_amount" gorm:"embedded;embeddedPrefix:settlement_"`
+	Status           enum.QuoteStatus `json:"status"`
+
+	Lines []PayoffQuoteLine `json:"lines" gorm:"foreignKey:QuoteID"`
+}
+
+func (quote *PayoffQuote) BeforeCreate(tx *gorm.DB) (err error) {
+	return quote.Base.BeforeCreate(tx)
+}
+
+// PayoffQuoteLine is what the settlement pays on one open installment.
+type PayoffQuoteLine struct {
+	Base
+	LineID          uuid.UUID   `json:"line_id" gorm:"type:uuid;primaryKey"`
+	QuoteID         uuid.UUID   `json:"quote_id" gorm:"type:uuid;index"`
+	ScheduleID      uuid.UUID   `json:"schedule_id" gorm:"type:uuid"`
+	PaymentNo       int         `json:"payment_no"`
+	PrincipalAmount money.Money `json:"principal_amount" gorm:"embedded;embeddedPrefix:principal_"`
+	InterestAmount  money.Money `json:"interest_amount" gorm:"embedded;embeddedPrefix:interest_"`
+	PenaltyAmount   money.Money `json:"penalty_amount" gorm:"embedded;embeddedPrefix:penalty_"`
+}
+
+func (line *PayoffQuoteLine) BeforeCreate(tx *gorm.DB) (err error) {
+	return line.Base.BeforeCreate(tx)
+}
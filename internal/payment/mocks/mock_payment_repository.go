@@ -11,9 +11,13 @@ package mocks
 
 import (
 	domain "billing-engine/internal/payment/domain"
+	repository "billing-engine/internal/payment/repository"
 	enum "billing-engine/pkg/enum"
+	money "billing-engine/pkg/money"
+	producer "billing-engine/pkg/producer"
 	context "context"
 	reflect "reflect"
+	time "time"
 
 	uuid "github.com/google/uuid"
 	gomock "go.uber.org/mock/gomock"
@@ -42,6 +46,50 @@ func (m *MockPaymentRepositoryProvider) EXPECT() *MockPaymentRepositoryProviderM
 	return m.recorder
 }
 
+// CloseLoan mocks base method.
+func (m *MockPaymentRepositoryProvider) CloseLoan(arg0 context.Context, arg1 uuid.UUID, arg2 enum.LoanStatus, arg3 time.Time) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CloseLoan", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CloseLoan indicates an expected call of CloseLoan.
+func (mr *MockPaymentRepositoryProviderMockRecorder) CloseLoan(arg0, arg1, arg2, arg3 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CloseLoan", reflect.TypeOf((*MockPaymentRepositoryProvider)(nil).CloseLoan), arg0, arg1, arg2, arg3)
+}
+
+// CompleteIdempotencyKey mocks base method.
+func (m *MockPaymentRepositoryProvider) CompleteIdempotencyKey(arg0 context.Context, arg1 string, arg2 time.Time, arg3 uuid.UUID, arg4 []byte) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CompleteIdempotencyKey", arg0, arg1, arg2, arg3, arg4)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CompleteIdempotencyKey indicates an expected call of CompleteIdempotencyKey.
+func (mr *MockPaymentRepositoryProviderMockRecorder) CompleteIdempotencyKey(arg0, arg1, arg2, arg3, arg4 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CompleteIdempotencyKey", reflect.TypeOf((*MockPaymentRepositoryProvider)(nil).CompleteIdempotencyKey), arg0, arg1, arg2, arg3, arg4)
+}
+
+// CreateIdempotencyKey mocks base method.
+func (m *MockPaymentRepositoryProvider) CreateIdempotencyKey(arg0 context.Context, arg1 domain.IdempotencyKey, arg2 time.Time) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateIdempotencyKey", arg0, arg1, arg2)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateIdempotencyKey indicates an expected call of CreateIdempotencyKey.
+func (mr *MockPaymentRepositoryProviderMockRecorder) CreateIdempotencyKey(arg0, arg1, arg2 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateIdempotencyKey", reflect.TypeOf((*MockPaymentRepositoryProvider)(nil).CreateIdempotencyKey), arg0, arg1, arg2)
+}
+
 // CreateLoan mocks base method.
 func (m *MockPaymentRepositoryProvider) CreateLoan(arg0 context.Context, arg1 domain.Loan) (domain.Loan, error) {
 	m.ctrl.T.Helper()
@@ -57,6 +105,20 @@ func (mr *MockPaymentRepositoryProviderMockRecorder) CreateLoan(arg0, arg1 any)
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateLoan", reflect.TypeOf((*MockPaymentRepositoryProvider)(nil).CreateLoan), arg0, arg1)
 }
 
+// CreateOutboxMessage mocks base method.
+func (m *MockPaymentRepositoryProvider) CreateOutboxMessage(arg0 context.Context, arg1 producer.Message) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateOutboxMessage", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreateOutboxMessage indicates an expected call of CreateOutboxMessage.
+func (mr *MockPaymentRepositoryProviderMockRecorder) CreateOutboxMessage(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateOutboxMessage", reflect.TypeOf((*MockPaymentRepositoryProvider)(nil).CreateOutboxMessage), arg0, arg1)
+}
+
 // CreatePayment mocks base method.
 func (m *MockPaymentRepositoryProvider) CreatePayment(arg0 context.Context, arg1 domain.Payment) (domain.Payment, error) {
 	m.ctrl.T.Helper()
@@ -72,47 +134,233 @@ func (mr *MockPaymentRepositoryProviderMockRecorder) CreatePayment(arg0, arg1 an
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreatePayment", reflect.TypeOf((*MockPaymentRepositoryProvider)(nil).CreatePayment), arg0, arg1)
 }
 
-// IsCustomerHasLoan mocks base method.
-func (m *MockPaymentRepositoryProvider) IsCustomerHasLoan(arg0 context.Context, arg1, arg2 uuid.UUID) (bool, error) {
+// CreatePayoffQuote mocks base method.
+func (m *MockPaymentRepositoryProvider) CreatePayoffQuote(arg0 context.Context, arg1 domain.PayoffQuote) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "IsCustomerHasLoan", arg0, arg1, arg2)
-	ret0, _ := ret[0].(bool)
+	ret := m.ctrl.Call(m, "CreatePayoffQuote", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreatePayoffQuote indicates an expected call of CreatePayoffQuote.
+func (mr *MockPaymentRepositoryProviderMockRecorder) CreatePayoffQuote(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreatePayoffQuote", reflect.TypeOf((*MockPaymentRepositoryProvider)(nil).CreatePayoffQuote), arg0, arg1)
+}
+
+// DeleteIdempotencyKey mocks base method.
+func (m *MockPaymentRepositoryProvider) DeleteIdempotencyKey(arg0 context.Context, arg1 string, arg2 time.Time) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteIdempotencyKey", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteIdempotencyKey indicates an expected call of DeleteIdempotencyKey.
+func (mr *MockPaymentRepositoryProviderMockRecorder) DeleteIdempotencyKey(arg0, arg1, arg2 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteIdempotencyKey", reflect.TypeOf((*MockPaymentRepositoryProvider)(nil).DeleteIdempotencyKey), arg0, arg1, arg2)
+}
+
+// GetCustomerLoan mocks base method.
+func (m *MockPaymentRepositoryProvider) GetCustomerLoan(arg0 context.Context, arg1, arg2 uuid.UUID) (*domain.Loan, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetCustomerLoan", arg0, arg1, arg2)
+	ret0, _ := ret[0].(*domain.Loan)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetCustomerLoan indicates an expected call of GetCustomerLoan.
+func (mr *MockPaymentRepositoryProviderMockRecorder) GetCustomerLoan(arg0, arg1, arg2 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetCustomerLoan", reflect.TypeOf((*MockPaymentRepositoryProvider)(nil).GetCustomerLoan), arg0, arg1, arg2)
+}
+
+// GetIdempotencyKey mocks base method.
+func (m *MockPaymentRepositoryProvider) GetIdempotencyKey(arg0 context.Context, arg1 string) (*domain.IdempotencyKey, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetIdempotencyKey", arg0, arg1)
+	ret0, _ := ret[0].(*domain.IdempotencyKey)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetIdempotencyKey indicates an expected call of GetIdempotencyKey.
+func (mr *MockPaymentRepositoryProviderMockRecorder) GetIdempotencyKey(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetIdempotencyKey", reflect.TypeOf((*MockPaymentRepositoryProvider)(nil).GetIdempotencyKey), arg0, arg1)
+}
+
+// GetPayoffQuote mocks base method.
+func (m *MockPaymentRepositoryProvider) GetPayoffQuote(arg0 context.Context, arg1 uuid.UUID) (*domain.PayoffQuote, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetPayoffQuote", arg0, arg1)
+	ret0, _ := ret[0].(*domain.PayoffQuote)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// IsCustomerHasLoan indicates an expected call of IsCustomerHasLoan.
-func (mr *MockPaymentRepositoryProviderMockRecorder) IsCustomerHasLoan(arg0, arg1, arg2 any) *gomock.Call {
+// GetPayoffQuote indicates an expected call of GetPayoffQuote.
+func (mr *MockPaymentRepositoryProviderMockRecorder) GetPayoffQuote(arg0, arg1 any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IsCustomerHasLoan", reflect.TypeOf((*MockPaymentRepositoryProvider)(nil).IsCustomerHasLoan), arg0, arg1, arg2)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPayoffQuote", reflect.TypeOf((*MockPaymentRepositoryProvider)(nil).GetPayoffQuote), arg0, arg1)
 }
 
-// IsLoanScheduleExist mocks base method.
-func (m *MockPaymentRepositoryProvider) IsLoanScheduleExist(arg0 context.Context, arg1, arg2 uuid.UUID) (bool, error) {
+// GetTotalRecovered mocks base method.
+func (m *MockPaymentRepositoryProvider) GetTotalRecovered(arg0 context.Context, arg1 uuid.UUID) (int64, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "IsLoanScheduleExist", arg0, arg1, arg2)
+	ret := m.ctrl.Call(m, "GetTotalRecovered", arg0, arg1)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetTotalRecovered indicates an expected call of GetTotalRecovered.
+func (mr *MockPaymentRepositoryProviderMockRecorder) GetTotalRecovered(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTotalRecovered", reflect.TypeOf((*MockPaymentRepositoryProvider)(nil).GetTotalRecovered), arg0, arg1)
+}
+
+// HasPaymentSince mocks base method.
+func (m *MockPaymentRepositoryProvider) HasPaymentSince(arg0 context.Context, arg1 uuid.UUID, arg2 time.Time) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "HasPaymentSince", arg0, arg1, arg2)
 	ret0, _ := ret[0].(bool)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// IsLoanScheduleExist indicates an expected call of IsLoanScheduleExist.
-func (mr *MockPaymentRepositoryProviderMockRecorder) IsLoanScheduleExist(arg0, arg1, arg2 any) *gomock.Call {
+// HasPaymentSince indicates an expected call of HasPaymentSince.
+func (mr *MockPaymentRepositoryProviderMockRecorder) HasPaymentSince(arg0, arg1, arg2 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "HasPaymentSince", reflect.TypeOf((*MockPaymentRepositoryProvider)(nil).HasPaymentSince), arg0, arg1, arg2)
+}
+
+// LockOpenSchedules mocks base method.
+func (m *MockPaymentRepositoryProvider) LockOpenSchedules(arg0 context.Context, arg1 uuid.UUID) ([]domain.PaymentSchedule, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "LockOpenSchedules", arg0, arg1)
+	ret0, _ := ret[0].([]domain.PaymentSchedule)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// LockOpenSchedules indicates an expected call of LockOpenSchedules.
+func (mr *MockPaymentRepositoryProviderMockRecorder) LockOpenSchedules(arg0, arg1 any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IsLoanScheduleExist", reflect.TypeOf((*MockPaymentRepositoryProvider)(nil).IsLoanScheduleExist), arg0, arg1, arg2)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LockOpenSchedules", reflect.TypeOf((*MockPaymentRepositoryProvider)(nil).LockOpenSchedules), arg0, arg1)
 }
 
-// UpdatePaymentScheduleStatus mocks base method.
-func (m *MockPaymentRepositoryProvider) UpdatePaymentScheduleStatus(arg0 context.Context, arg1, arg2 uuid.UUID, arg3 enum.PaymentStatus) (*domain.PaymentSchedule, error) {
+// MarkEventProcessed mocks base method.
+func (m *MockPaymentRepositoryProvider) MarkEventProcessed(arg0 context.Context, arg1, arg2 string) (bool, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "UpdatePaymentScheduleStatus", arg0, arg1, arg2, arg3)
-	ret0, _ := ret[0].(*domain.PaymentSchedule)
+	ret := m.ctrl.Call(m, "MarkEventProcessed", arg0, arg1, arg2)
+	ret0, _ := ret[0].(bool)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// UpdatePaymentScheduleStatus indicates an expected call of UpdatePaymentScheduleStatus.
-func (mr *MockPaymentRepositoryProviderMockRecorder) UpdatePaymentScheduleStatus(arg0, arg1, arg2, arg3 any) *gomock.Call {
+// MarkEventProcessed indicates an expected call of MarkEventProcessed.
+func (mr *MockPaymentRepositoryProviderMockRecorder) MarkEventProcessed(arg0, arg1, arg2 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MarkEventProcessed", reflect.TypeOf((*MockPaymentRepositoryProvider)(nil).MarkEventProcessed), arg0, arg1, arg2)
+}
+
+// ReplaceSchedules mocks base method.
+func (m *MockPaymentRepositoryProvider) ReplaceSchedules(arg0 context.Context, arg1 uuid.UUID, arg2 []uuid.UUID, arg3 []domain.PaymentSchedule) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReplaceSchedules", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ReplaceSchedules indicates an expected call of ReplaceSchedules.
+func (mr *MockPaymentRepositoryProviderMockRecorder) ReplaceSchedules(arg0, arg1, arg2, arg3 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReplaceSchedules", reflect.TypeOf((*MockPaymentRepositoryProvider)(nil).ReplaceSchedules), arg0, arg1, arg2, arg3)
+}
+
+// SettlePayoffQuote mocks base method.
+func (m *MockPaymentRepositoryProvider) SettlePayoffQuote(arg0 context.Context, arg1 uuid.UUID) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SettlePayoffQuote", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SettlePayoffQuote indicates an expected call of SettlePayoffQuote.
+func (mr *MockPaymentRepositoryProviderMockRecorder) SettlePayoffQuote(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SettlePayoffQuote", reflect.TypeOf((*MockPaymentRepositoryProvider)(nil).SettlePayoffQuote), arg0, arg1)
+}
+
+// UpdateLoanStatus mocks base method.
+func (m *MockPaymentRepositoryProvider) UpdateLoanStatus(arg0 context.Context, arg1 uuid.UUID, arg2 enum.LoanStatus, arg3 time.Time) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateLoanStatus", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateLoanStatus indicates an expected call of UpdateLoanStatus.
+func (mr *MockPaymentRepositoryProviderMockRecorder) UpdateLoanStatus(arg0, arg1, arg2, arg3 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateLoanStatus", reflect.TypeOf((*MockPaymentRepositoryProvider)(nil).UpdateLoanStatus), arg0, arg1, arg2, arg3)
+}
+
+// UpdatePaymentSchedules mocks base method.
+func (m *MockPaymentRepositoryProvider) UpdatePaymentSchedules(arg0 context.Context, arg1 []domain.PaymentSchedule) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdatePaymentSchedules", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdatePaymentSchedules indicates an expected call of UpdatePaymentSchedules.
+func (mr *MockPaymentRepositoryProviderMockRecorder) UpdatePaymentSchedules(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdatePaymentSchedules", reflect.TypeOf((*MockPaymentRepositoryProvider)(nil).UpdatePaymentSchedules), arg0, arg1)
+}
+
+// UpdatePenaltyAmount mocks base method.
+func (m *MockPaymentRepositoryProvider) UpdatePenaltyAmount(arg0 context.Context, arg1, arg2 uuid.UUID, arg3 money.Money) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdatePenaltyAmount", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdatePenaltyAmount indicates an expected call of UpdatePenaltyAmount.
+func (mr *MockPaymentRepositoryProviderMockRecorder) UpdatePenaltyAmount(arg0, arg1, arg2, arg3 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdatePenaltyAmount", reflect.TypeOf((*MockPaymentRepositoryProvider)(nil).UpdatePenaltyAmount), arg0, arg1, arg2, arg3)
+}
+
+// UpdateScheduleDueDates mocks base method.
+func (m *MockPaymentRepositoryProvider) UpdateScheduleDueDates(arg0 context.Context, arg1 uuid.UUID, arg2 []domain.PaymentSchedule) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateScheduleDueDates", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateScheduleDueDates indicates an expected call of UpdateScheduleDueDates.
+func (mr *MockPaymentRepositoryProviderMockRecorder) UpdateScheduleDueDates(arg0, arg1, arg2 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateScheduleDueDates", reflect.TypeOf((*MockPaymentRepositoryProvider)(nil).UpdateScheduleDueDates), arg0, arg1, arg2)
+}
+
+// WithTransaction mocks base method.
+func (m *MockPaymentRepositoryProvider) WithTransaction(arg0 context.Context, arg1 func(repository.PaymentRepositoryProvider) error) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "WithTransaction", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// WithTransaction indicates an expected call of WithTransaction.
+func (mr *MockPaymentRepositoryProviderMockRecorder) WithTransaction(arg0, arg1 any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdatePaymentScheduleStatus", reflect.TypeOf((*MockPaymentRepositoryProvider)(nil).UpdatePaymentScheduleStatus), arg0, arg1, arg2, arg3)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "WithTransaction", reflect.TypeOf((*MockPaymentRepositoryProvider)(nil).WithTransaction), arg0, arg1)
 }
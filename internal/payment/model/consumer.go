@@ -2,21 +2,91 @@ package model
 
 import (
 	"billing-engine/pkg/enum"
+	"billing-engine/pkg/money"
 	"github.com/google/uuid"
 	"time"
 )
 
 type LoanCreatedPayload struct {
-	LoanID     uuid.UUID `json:"loan_id"`
-	CustomerID uuid.UUID `json:"customer_id"`
+	LoanID          uuid.UUID       `json:"loan_id"`
+	CustomerID      uuid.UUID       `json:"customer_id"`
+	Status          enum.LoanStatus `json:"status"`
+	StatusChangedAt time.Time       `json:"status_changed_at"`
 
 	Schedules []LoanSchedule `json:"schedules"`
 }
 
 type LoanSchedule struct {
-	ScheduleID     uuid.UUID          `json:"schedule_id"`
-	PaymentNo      int                `json:"payment_no"`
-	PaymentDueDate time.Time          `json:"payment_due_date"`
-	PaymentAmount  float64            `json:"payment_amount"`
-	PaymentStatus  enum.PaymentStatus `json:"payment_status"`
+	ScheduleID      uuid.UUID          `json:"schedule_id"`
+	PaymentNo       int                `json:"payment_no"`
+	PaymentDueDate  time.Time          `json:"payment_due_date"`
+	PaymentAmount   money.Money        `json:"payment_amount"`
+	PrincipalAmount money.Money        `json:"principal_amount"`
+	InterestAmount  money.Money        `json:"interest_amount"`
+	PaymentStatus   enum.PaymentStatus `json:"payment_status"`
+}
+
+type PenaltyAccruedPayload struct {
+	LoanID       uuid.UUID   `json:"loan_id"`
+	ScheduleID   uuid.UUID   `json:"schedule_id"`
+	TotalPenalty money.Money `json:"total_penalty"`
+}
+
+type PayoffQuotedPayload struct {
+	QuoteID          uuid.UUID          `json:"quote_id"`
+	LoanID           uuid.UUID          `json:"loan_id"`
+	QuoteDate        time.Time          `json:"quote_date"`
+	ValidUntil       time.Time          `json:"valid_until"`
+	SettlementFee    money.Money        `json:"settlement_fee"`
+	SettlementAmount money.Money        `json:"settlement_amount"`
+	Status           enum.QuoteStatus   `json:"status"`
+	Lines            []PayoffQuoteLines `json:"lines"`
+}
+
+type PayoffQuoteLines struct {
+	LineID          uuid.UUID   `json:"line_id"`
+	ScheduleID      uuid.UUID   `json:"schedule_id"`
+	PaymentNo       int         `json:"payment_no"`
+	PrincipalAmount money.Money `json:"principal_amount"`
+	InterestAmount  money.Money `json:"interest_amount"`
+	PenaltyAmount   money.Money `json:"penalty_amount"`
+}
+
+type LoanStatusChangedPayload struct {
+	LoanID     uuid.UUID       `json:"loan_id"`
+	FromStatus enum.LoanStatus `json:"from_status"`
+	ToStatus   enum.LoanStatus `json:"to_status"`
+	ChangedAt  time.Time       `json:"changed_at"`
+}
+
+type LoanRefinancedPayload struct {
+	LoanID       uuid.UUID `json:"loan_id"`
+	NewLoanID    uuid.UUID `json:"new_loan_id"`
+	RefinancedAt time.Time `json:"refinanced_at"`
+}
+
+type LoanCancelledPayload struct {
+	LoanID         uuid.UUID `json:"loan_id"`
+	CancellationID uuid.UUID `json:"cancellation_id"`
+	CancelledAt    time.Time `json:"cancelled_at"`
+}
+
+type LoanRestructuredPayload struct {
+	LoanID               uuid.UUID      `json:"loan_id"`
+	RestructureID        uuid.UUID      `json:"restructure_id"`
+	CancelledScheduleIDs []uuid.UUID    `json:"cancelled_schedule_ids"`
+	Schedules            []LoanSchedule `json:"schedules"`
+}
+
+type PaymentHolidayGrantedPayload struct {
+	LoanID    uuid.UUID         `json:"loan_id"`
+	HolidayID uuid.UUID         `json:"holiday_id"`
+	Schedules []ScheduleDueDate `json:"schedules"`
+}
+
+// ScheduleDueDate PenaltyAmount is the penalty left on the schedule once the holiday waived it.
+type ScheduleDueDate struct {
+	ScheduleID     uuid.UUID   `json:"schedule_id"`
+	PaymentDueDate time.Time   `json:"payment_due_date"`
+	PenaltyAmount  money.Money `json:"penalty_amount"`
 }
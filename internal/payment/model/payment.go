@@ -2,28 +2,75 @@ package model
 
 import (
 	"billing-engine/pkg/enum"
+	"billing-engine/pkg/money"
 	"github.com/google/uuid"
 	"time"
 )
 
+// ProcessPaymentPayload Amount can be any positive amount, it is spread over the open installments by the
+// allocation waterfall and what is left beyond the outstanding balance is kept as credit on the loan. On a
+// written-off loan it is taken as a recovery and not allocated. IdempotencyKey comes from the Idempotency-Key header, a retry with the same key
+// and body gets the response of the first request.
 type ProcessPaymentPayload struct {
-	Amount     float64   `json:"amount"`
-	LoanID     uuid.UUID `json:"loan_id"`
-	ScheduleID uuid.UUID `json:"schedule_id"`
-	CustomerID uuid.UUID `json:"customer_id"`
+	Amount         money.Money `json:"amount"`
+	LoanID         uuid.UUID   `json:"loan_id" validate:"required"`
+	CustomerID     uuid.UUID   `json:"customer_id" validate:"required"`
+	IdempotencyKey string      `json:"-" validate:"max=255"`
 }
 
+// ProcessPaymentResponse Credit is what was paid beyond the outstanding balance of the loan.
 type ProcessPaymentResponse struct {
-	AmountPaid    float64            `json:"amount_paid"`
-	PaymentID     uuid.UUID          `json:"payment_id"`
-	PaymentStatus enum.PaymentStatus `json:"payment_status"`
-	PaymentDate   time.Time          `json:"payment_date"`
+	AmountPaid    money.Money          `json:"amount_paid"`
+	Credit        money.Money          `json:"credit"`
+	PaymentID     uuid.UUID            `json:"payment_id"`
+	PaymentStatus enum.PaymentStatus   `json:"payment_status"`
+	PaymentType   enum.PaymentType     `json:"payment_type"`
+	PaymentDate   time.Time            `json:"payment_date"`
+	Allocations   []AllocationResponse `json:"allocations"`
 }
 
-type PaymentEventPayload struct {
-	LoanID        uuid.UUID          `json:"loan_id"`
+type AllocationResponse struct {
+	ScheduleID uuid.UUID                `json:"schedule_id"`
+	PaymentNo  int                      `json:"payment_no"`
+	Component  enum.AllocationComponent `json:"component"`
+	Amount     money.Money              `json:"amount"`
+}
+
+// ScheduleBalance is the state of an installment after a payment, so consumers can overwrite their copy.
+type ScheduleBalance struct {
 	ScheduleID    uuid.UUID          `json:"schedule_id"`
-	PaymentID     uuid.UUID          `json:"payment_id"`
+	PaymentNo     int                `json:"payment_no"`
 	PaymentStatus enum.PaymentStatus `json:"payment_status"`
-	PaymentDate   time.Time          `json:"payment_date"`
+	PrincipalPaid money.Money        `json:"principal_paid"`
+	InterestPaid  money.Money        `json:"interest_paid"`
+	PenaltyPaid   money.Money        `json:"penalty_paid"`
+}
+
+type PaymentEventPayload struct {
+	LoanID        uuid.UUID            `json:"loan_id"`
+	PaymentID     uuid.UUID            `json:"payment_id"`
+	AmountPaid    money.Money          `json:"amount_paid"`
+	Credit        money.Money          `json:"credit"`
+	PaymentStatus enum.PaymentStatus   `json:"payment_status"`
+	PaymentType   enum.PaymentType     `json:"payment_type"`
+	PaymentDate   time.Time            `json:"payment_date"`
+	Allocations   []AllocationResponse `json:"allocations"`
+	Schedules     []ScheduleBalance    `json:"schedules"`
+}
+
+// SettlementPayload settles a loan with a payoff quote, Amount has to be the quoted settlement amount.
+type SettlementPayload struct {
+	QuoteID    uuid.UUID   `json:"quote_id" validate:"required"`
+	LoanID     uuid.UUID   `json:"loan_id" validate:"required"`
+	CustomerID uuid.UUID   `json:"customer_id" validate:"required"`
+	Amount     money.Money `json:"amount"`
+}
+
+type LoanSettledEventPayload struct {
+	LoanID     uuid.UUID         `json:"loan_id"`
+	QuoteID    uuid.UUID         `json:"quote_id"`
+	PaymentID  uuid.UUID         `json:"payment_id"`
+	AmountPaid money.Money       `json:"amount_paid"`
+	SettledAt  time.Time         `json:"settled_at"`
+	Schedules  []ScheduleBalance `json:"schedules"`
 }
@@ -0,0 +1,30 @@
+package repository
+
+import (
+	"billing-engine/internal/payment/domain"
+	"billing-engine/pkg/enum"
+	"gorm.io/gorm"
+)
+
+// MigrateLoanStatus moves the loan copies stored before the status lifecycle onto it: a finished loan is
+// PAID_OFF and any other loan ACTIVE. The status is dated when the copy was created, so every status event the
+// billing service sends from now on is newer and applies. It drops the is_finish column once the loans are
+// moved, so it does nothing when it runs again. Run it after AutoMigrate added the status columns.
+func MigrateLoanStatus(db *gorm.DB) error {
+	if !db.Migrator().HasColumn(&domain.Loan{}, "is_finish") {
+		return nil
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Exec(`UPDATE loans
+			SET status = CASE WHEN is_finish THEN ? ELSE ? END,
+				status_changed_at = COALESCE(created_at, NOW())
+			WHERE status IS NULL OR status = ''`,
+			enum.LoanStatusPaidOff, enum.LoanStatusActive).Error
+		if err != nil {
+			return err
+		}
+
+		return tx.Migrator().DropColumn(&domain.Loan{}, "is_finish")
+	})
+}
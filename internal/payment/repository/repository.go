@@ -3,75 +3,174 @@ package repository
 import (
 	"billing-engine/internal/payment/domain"
 	"billing-engine/pkg/enum"
+	"billing-engine/pkg/inbox"
+	"billing-engine/pkg/money"
+	"billing-engine/pkg/outbox"
+	"billing-engine/pkg/producer"
 	"context"
+	"errors"
 	"github.com/google/uuid"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"time"
 )
 
 //go:generate mockgen -destination=../mocks/mock_payment_repository.go -package=mocks billing-engine/internal/payment/repository PaymentRepositoryProvider
 type PaymentRepositoryProvider interface {
-	IsLoanScheduleExist(ctx context.Context, loanID uuid.UUID, scheduleID uuid.UUID) (bool, error)
-	IsCustomerHasLoan(ctx context.Context, customerID uuid.UUID, loanID uuid.UUID) (bool, error)
-	UpdatePaymentScheduleStatus(ctx context.Context, loanID uuid.UUID, scheduleID uuid.UUID, status enum.PaymentStatus) (*domain.PaymentSchedule, error)
+	// WithTransaction runs fn as one unit of work, every call on the repository given to fn is part of the
+	// transaction. It is committed when fn returns nil and rolled back otherwise.
+	WithTransaction(ctx context.Context, fn func(repo PaymentRepositoryProvider) error) error
+	// CreateOutboxMessage stores an event for the outbox relay, call it inside WithTransaction so the event
+	// is only sent when the change it announces is committed.
+	CreateOutboxMessage(ctx context.Context, message producer.Message) error
+	// MarkEventProcessed records a consumed event and reports whether it was new, call it inside WithTransaction
+	// so the event counts as processed exactly when its change is committed.
+	MarkEventProcessed(ctx context.Context, eventID, eventName string) (bool, error)
+
+	GetCustomerLoan(ctx context.Context, customerID uuid.UUID, loanID uuid.UUID) (*domain.Loan, error)
+	LockOpenSchedules(ctx context.Context, loanID uuid.UUID) ([]domain.PaymentSchedule, error)
+	UpdatePaymentSchedules(ctx context.Context, schedules []domain.PaymentSchedule) error
 	CreatePayment(ctx context.Context, payment domain.Payment) (domain.Payment, error)
+	UpdatePenaltyAmount(ctx context.Context, loanID uuid.UUID, scheduleID uuid.UUID, penalty money.Money) error
+	ReplaceSchedules(ctx context.Context, loanID uuid.UUID, cancelledIDs []uuid.UUID, schedules []domain.PaymentSchedule) error
+	UpdateScheduleDueDates(ctx context.Context, loanID uuid.UUID, schedules []domain.PaymentSchedule) error
 
 	CreateLoan(ctx context.Context, loan domain.Loan) (domain.Loan, error)
+	UpdateLoanStatus(ctx context.Context, loanID uuid.UUID, status enum.LoanStatus, changedAt time.Time) error
+	CloseLoan(ctx context.Context, loanID uuid.UUID, status enum.LoanStatus, changedAt time.Time) error
+	HasPaymentSince(ctx context.Context, loanID uuid.UUID, since time.Time) (bool, error)
+	GetTotalRecovered(ctx context.Context, loanID uuid.UUID) (int64, error)
+
+	CreatePayoffQuote(ctx context.Context, quote domain.PayoffQuote) error
+	GetPayoffQuote(ctx context.Context, quoteID uuid.UUID) (*domain.PayoffQuote, error)
+	SettlePayoffQuote(ctx context.Context, quoteID uuid.UUID) error
+
+	CreateIdempotencyKey(ctx context.Context, key domain.IdempotencyKey, staleBefore time.Time) (bool, error)
+	GetIdempotencyKey(ctx context.Context, key string) (*domain.IdempotencyKey, error)
+	CompleteIdempotencyKey(ctx context.Context, key string, claimedAt time.Time, paymentID uuid.UUID, response []byte) (bool, error)
+	DeleteIdempotencyKey(ctx context.Context, key string, claimedAt time.Time) error
 }
 
+// openStatuses are the statuses of a schedule that still expects money.
+var openStatuses = []enum.PaymentStatus{enum.PaymentStatusPending, enum.PaymentStatusPartiallyPaid}
+
 type impl struct {
 	db *gorm.DB
 }
 
-func (i impl) IsLoanScheduleExist(ctx context.Context, loanID uuid.UUID, scheduleID uuid.UUID) (bool, error) {
-	var count int64
-	err := i.db.WithContext(ctx).Model(&domain.PaymentSchedule{}).
-		Where("loan_id = ? AND schedule_id = ? AND payment_status = ?", loanID, scheduleID, enum.PaymentStatusPending).
-		Count(&count).Error
+func (i impl) WithTransaction(ctx context.Context, fn func(repo PaymentRepositoryProvider) error) error {
+	return i.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return fn(impl{db: tx})
+	})
+}
 
-	if err != nil {
-		return false, err
-	}
+func (i impl) CreateOutboxMessage(ctx context.Context, message producer.Message) error {
+	return outbox.Write(i.db.WithContext(ctx), message)
+}
 
-	return count > 0, nil
+func (i impl) MarkEventProcessed(ctx context.Context, eventID, eventName string) (bool, error) {
+	return inbox.Record(i.db.WithContext(ctx), eventID, eventName)
 }
 
-func (i impl) IsCustomerHasLoan(ctx context.Context, customerID uuid.UUID, loanID uuid.UUID) (bool, error) {
-	var count int64
-	err := i.db.WithContext(ctx).Model(&domain.Loan{}).
+func (i impl) GetCustomerLoan(ctx context.Context, customerID uuid.UUID, loanID uuid.UUID) (*domain.Loan, error) {
+	var loan domain.Loan
+	err := i.db.WithContext(ctx).
 		Where("customer_id = ? AND loan_id = ?", customerID, loanID).
-		Count(&count).Error
-
-	if err != nil {
-		return false, err
+		First(&loan).Error
+	if err != nil && errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
 	}
 
-	return count > 0, nil
+	return &loan, nil
 }
 
-func (i impl) UpdatePaymentScheduleStatus(ctx context.Context, loanID uuid.UUID, scheduleID uuid.UUID, status enum.PaymentStatus) (*domain.PaymentSchedule, error) {
-	var payment domain.PaymentSchedule
-
-	// Retrieve the payment schedule record
-	err := i.db.WithContext(ctx).Model(&domain.PaymentSchedule{}).
-		Where("loan_id = ? AND schedule_id = ?", loanID, scheduleID).
-		First(&payment).Error
-
+// LockOpenSchedules returns the schedules of the loan that are not fully paid, oldest first. They stay locked
+// until the transaction ends, so concurrent payments on the loan wait for each other instead of paying the
+// same installment twice. The lock only holds when called inside WithTransaction.
+func (i impl) LockOpenSchedules(ctx context.Context, loanID uuid.UUID) ([]domain.PaymentSchedule, error) {
+	var schedules []domain.PaymentSchedule
+	err := i.db.WithContext(ctx).
+		Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("loan_id = ? AND payment_status IN ?", loanID, openStatuses).
+		Order("payment_no asc").
+		Find(&schedules).Error
 	if err != nil {
 		return nil, err
 	}
 
-	payment.PaymentStatus = status
+	return schedules, nil
+}
 
-	err = i.db.WithContext(ctx).Save(&payment).Error
-	if err != nil {
-		return nil, err
+func (i impl) UpdatePaymentSchedules(ctx context.Context, schedules []domain.PaymentSchedule) error {
+	for _, schedule := range schedules {
+		err := i.db.WithContext(ctx).Model(&domain.PaymentSchedule{}).
+			Where("schedule_id = ?", schedule.ScheduleID).
+			Updates(map[string]interface{}{
+				"payment_status":          schedule.PaymentStatus,
+				"principal_paid_amount":   schedule.PrincipalPaid.Amount,
+				"principal_paid_currency": schedule.PrincipalPaid.Currency,
+				"interest_paid_amount":    schedule.InterestPaid.Amount,
+				"interest_paid_currency":  schedule.InterestPaid.Currency,
+				"penalty_paid_amount":     schedule.PenaltyPaid.Amount,
+				"penalty_paid_currency":   schedule.PenaltyPaid.Currency,
+			}).Error
+		if err != nil {
+			return err
+		}
 	}
 
-	return &payment, nil
+	return nil
+}
+
+func (i impl) UpdatePenaltyAmount(ctx context.Context, loanID uuid.UUID, scheduleID uuid.UUID, penalty money.Money) error {
+	return i.db.WithContext(ctx).Model(&domain.PaymentSchedule{}).
+		Where("loan_id = ? AND schedule_id = ? AND payment_status IN ?", loanID, scheduleID, openStatuses).
+		Updates(map[string]interface{}{"penalty_amount": penalty.Amount, "penalty_currency": penalty.Currency}).Error
 }
 
+// ReplaceSchedules cancels the schedules replaced by a restructure and adds the new ones. Schedules it already
+// has are left alone, so a replayed event is harmless.
+func (i impl) ReplaceSchedules(ctx context.Context, loanID uuid.UUID, cancelledIDs []uuid.UUID, schedules []domain.PaymentSchedule) error {
+	return i.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&domain.PaymentSchedule{}).
+			Where("loan_id = ? AND schedule_id IN ? AND payment_status IN ?", loanID, cancelledIDs, openStatuses).
+			Update("payment_status", enum.PaymentStatusCancelled).Error
+		if err != nil {
+			return err
+		}
+
+		return tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&schedules).Error
+	})
+}
+
+// UpdateScheduleDueDates moves the schedules to their new due dates and copies the penalty left on them. A
+// schedule without a penalty currency comes from an event that did not carry it, its penalty is kept.
+func (i impl) UpdateScheduleDueDates(ctx context.Context, loanID uuid.UUID, schedules []domain.PaymentSchedule) error {
+	return i.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for _, schedule := range schedules {
+			updates := map[string]interface{}{"payment_due_date": schedule.PaymentDueDate}
+			if schedule.PenaltyAmount.Currency != "" {
+				updates["penalty_amount"] = schedule.PenaltyAmount.Amount
+				updates["penalty_currency"] = schedule.PenaltyAmount.Currency
+			}
+
+			err := tx.Model(&domain.PaymentSchedule{}).
+				Where("loan_id = ? AND schedule_id = ?", loanID, schedule.ScheduleID).
+				Updates(updates).Error
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// CreateLoan stores the loan copy with its schedules, a loan that is already stored is left as it is.
 func (i impl) CreateLoan(ctx context.Context, loan domain.Loan) (domain.Loan, error) {
-	err := i.db.WithContext(ctx).Create(&loan).Error
+	err := i.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(&loan).Error
 	if err != nil {
 		return domain.Loan{}, err
 	}
@@ -88,6 +187,139 @@ func (i impl) CreatePayment(ctx context.Context, payment domain.Payment) (domain
 	return payment, nil
 }
 
+// UpdateLoanStatus moves the loan to the status it got at changedAt, unless the loan already has a status
+// that was set later. Running it again changes nothing.
+func (i impl) UpdateLoanStatus(ctx context.Context, loanID uuid.UUID, status enum.LoanStatus, changedAt time.Time) error {
+	return i.db.WithContext(ctx).Model(&domain.Loan{}).
+		Where("loan_id = ? AND (status_changed_at IS NULL OR status_changed_at < ?)", loanID, changedAt).
+		Updates(map[string]interface{}{"status": status, "status_changed_at": changedAt}).Error
+}
+
+// CloseLoan moves the loan to a final status and closes its open schedules, so nothing can be paid on it
+// anymore. Running it again changes nothing.
+func (i impl) CloseLoan(ctx context.Context, loanID uuid.UUID, status enum.LoanStatus, changedAt time.Time) error {
+	return i.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&domain.Loan{}).
+			Where("loan_id = ? AND (status_changed_at IS NULL OR status_changed_at < ?)", loanID, changedAt).
+			Updates(map[string]interface{}{"status": status, "status_changed_at": changedAt}).Error
+		if err != nil {
+			return err
+		}
+
+		return tx.Model(&domain.PaymentSchedule{}).
+			Where("loan_id = ? AND payment_status IN ?", loanID, openStatuses).
+			Update("payment_status", enum.PaymentStatusCancelled).Error
+	})
+}
+
+func (i impl) HasPaymentSince(ctx context.Context, loanID uuid.UUID, since time.Time) (bool, error) {
+	var count int64
+	err := i.db.WithContext(ctx).Model(&domain.Payment{}).
+		Where("loan_id = ? AND created_at > ?", loanID, since).
+		Count(&count).Error
+
+	if err != nil {
+		return false, err
+	}
+
+	return count > 0, nil
+}
+
+// GetTotalRecovered sums the recovery payments of a written-off loan.
+func (i impl) GetTotalRecovered(ctx context.Context, loanID uuid.UUID) (int64, error) {
+	var total int64
+	err := i.db.WithContext(ctx).Model(&domain.Payment{}).
+		Select("COALESCE(SUM(amount_paid_amount), 0)").
+		Where("loan_id = ? AND payment_type = ?", loanID, enum.PaymentTypeRecovery).
+		Scan(&total).Error
+	if err != nil {
+		return 0, err
+	}
+
+	return total, nil
+}
+
+// CreatePayoffQuote ignores a quote it already has, so a replayed event is harmless.
+func (i impl) CreatePayoffQuote(ctx context.Context, quote domain.PayoffQuote) error {
+	return i.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(&quote).Error
+}
+
+func (i impl) GetPayoffQuote(ctx context.Context, quoteID uuid.UUID) (*domain.PayoffQuote, error) {
+	var quote domain.PayoffQuote
+	err := i.db.WithContext(ctx).Preload("Lines").Where("quote_id = ?", quoteID).First(&quote).Error
+	if err != nil && errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	return &quote, nil
+}
+
+func (i impl) SettlePayoffQuote(ctx context.Context, quoteID uuid.UUID) error {
+	return i.db.WithContext(ctx).Model(&domain.PayoffQuote{}).
+		Where("quote_id = ?", quoteID).
+		Update("status", enum.QuoteStatusSettled).Error
+}
+
+// CreateIdempotencyKey stores the key unless it is already taken, it reports whether this call claimed it. A
+// claim that was never completed and was taken before staleBefore is taken over, its request is gone.
+func (i impl) CreateIdempotencyKey(ctx context.Context, key domain.IdempotencyKey, staleBefore time.Time) (bool, error) {
+	result := i.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "key"}},
+		DoUpdates: clause.AssignmentColumns([]string{"request_hash", "claimed_at", "updated_at"}),
+		Where: clause.Where{Exprs: []clause.Expression{
+			clause.Expr{
+				SQL:  "idempotency_keys.completed_at IS NULL AND COALESCE(idempotency_keys.claimed_at, idempotency_keys.created_at) < ?",
+				Vars: []interface{}{staleBefore},
+			},
+		}},
+	}).Create(&key)
+	if result.Error != nil {
+		return false, result.Error
+	}
+
+	return result.RowsAffected > 0, nil
+}
+
+func (i impl) GetIdempotencyKey(ctx context.Context, key string) (*domain.IdempotencyKey, error) {
+	var idempotencyKey domain.IdempotencyKey
+	err := i.db.WithContext(ctx).Where("key = ?", key).First(&idempotencyKey).Error
+	if err != nil && errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	return &idempotencyKey, nil
+}
+
+// CompleteIdempotencyKey stores the response with the claim taken at claimedAt, it reports whether the claim
+// was still held. A claim that was taken over by a later request is left alone.
+func (i impl) CompleteIdempotencyKey(ctx context.Context, key string, claimedAt time.Time, paymentID uuid.UUID, response []byte) (bool, error) {
+	result := i.db.WithContext(ctx).Model(&domain.IdempotencyKey{}).
+		Where("key = ? AND claimed_at = ? AND completed_at IS NULL", key, claimedAt).
+		Updates(map[string]interface{}{
+			"payment_id":   paymentID,
+			"response":     response,
+			"completed_at": time.Now(),
+			"updated_at":   time.Now(),
+		})
+	if result.Error != nil {
+		return false, result.Error
+	}
+
+	return result.RowsAffected > 0, nil
+}
+
+// DeleteIdempotencyKey frees the claim taken at claimedAt of a request that failed, only claims that were never
+// completed are removed.
+func (i impl) DeleteIdempotencyKey(ctx context.Context, key string, claimedAt time.Time) error {
+	return i.db.WithContext(ctx).
+		Where("key = ? AND claimed_at = ? AND completed_at IS NULL", key, claimedAt).
+		Delete(&domain.IdempotencyKey{}).Error
+}
+
 func NewPaymentRepository(db *gorm.DB) PaymentRepositoryProvider {
 	return &impl{
 		db: db,
@@ -0,0 +1,130 @@
+package service
+
+import (
+	"billing-engine/internal/payment/domain"
+	"billing-engine/internal/payment/model"
+	apperror "billing-engine/pkg/customerror"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+)
+
+// idempotencyLease is how long a claimed key is held for the request processing it. A request that crashed
+// between the claim and the payment never completes nor frees its key, the next request takes it over once
+// the lease has run out.
+const idempotencyLease = 5 * time.Minute
+
+// claimIdempotencyKey takes the idempotency key of the payment before it is made, so a concurrent retry sees
+// it taken. When the key was already taken by the same payment it returns the stored response with replayed
+// set, and the payment must not be made again.
+func (i impl) claimIdempotencyKey(ctx context.Context, payload model.ProcessPaymentPayload, claimedAt time.Time) (model.ProcessPaymentResponse, bool, error) {
+	hash, err := requestHash(payload)
+	if err != nil {
+		return model.ProcessPaymentResponse{}, false, err
+	}
+
+	key := domain.IdempotencyKey{Key: payload.IdempotencyKey, RequestHash: hash, ClaimedAt: claimedAt}
+	created, err := i.repo.CreateIdempotencyKey(ctx, key, claimedAt.Add(-idempotencyLease))
+	if err != nil {
+		i.log.WithField("idempotency_key", payload.IdempotencyKey).
+			WithField("error", err).Error("[ProcessPayment] failed to create idempotency key")
+		return model.ProcessPaymentResponse{}, false, err
+	}
+
+	if created {
+		return model.ProcessPaymentResponse{}, false, nil
+	}
+
+	response, err := i.replayPayment(ctx, payload.IdempotencyKey, hash)
+	if err != nil {
+		return model.ProcessPaymentResponse{}, false, err
+	}
+
+	return response, true, nil
+}
+
+// completeIdempotencyKey stores the response with the key. It runs in the transaction of the payment, so a
+// key is completed exactly when its payment is stored. When the lease ran out and a retry took the key over,
+// the payment is rolled back so only one of the two is stored.
+func (i impl) completeIdempotencyKey(ctx context.Context, key string, claimedAt time.Time, response model.ProcessPaymentResponse) error {
+	stored, err := json.Marshal(response)
+	if err != nil {
+		return err
+	}
+
+	held, err := i.repo.CompleteIdempotencyKey(ctx, key, claimedAt, response.PaymentID, stored)
+	if err != nil {
+		i.log.WithField("idempotency_key", key).
+			WithField("error", err).Error("[ProcessPayment] failed to store idempotent response")
+		return err
+	}
+
+	if !held {
+		i.log.WithField("idempotency_key", key).Info("[ProcessPayment] idempotency key was taken over by a retry")
+		return apperror.New(apperror.AlreadyExists, "a request with this idempotency key is still being processed")
+	}
+
+	return nil
+}
+
+// releaseIdempotencyKey frees the key of a payment that was not stored, so the client can retry it. The
+// request may have failed because it was cancelled, the key is freed regardless.
+func (i impl) releaseIdempotencyKey(ctx context.Context, key string, claimedAt time.Time) {
+	err := i.repo.DeleteIdempotencyKey(context.WithoutCancel(ctx), key, claimedAt)
+	if err != nil {
+		i.log.WithField("idempotency_key", key).
+			WithField("error", err).Error("[ProcessPayment] failed to release idempotency key")
+	}
+}
+
+// replayPayment returns the stored response of the request that took the key first.
+func (i impl) replayPayment(ctx context.Context, key, hash string) (model.ProcessPaymentResponse, error) {
+	existing, err := i.repo.GetIdempotencyKey(ctx, key)
+	if err != nil {
+		i.log.WithField("idempotency_key", key).
+			WithField("error", err).Error("[ProcessPayment] failed to get idempotency key")
+		return model.ProcessPaymentResponse{}, err
+	}
+
+	// the first request failed and freed the key in between
+	if existing == nil {
+		return model.ProcessPaymentResponse{}, apperror.New(apperror.AlreadyExists,
+			"the first request with this idempotency key failed, retry it")
+	}
+
+	if existing.RequestHash != hash {
+		i.log.WithField("idempotency_key", key).Info("[ProcessPayment] idempotency key reused for another request")
+		return model.ProcessPaymentResponse{}, apperror.New(apperror.InvalidInput,
+			"idempotency key was already used for a different payment")
+	}
+
+	if existing.CompletedAt == nil {
+		return model.ProcessPaymentResponse{}, apperror.New(apperror.AlreadyExists,
+			"a request with this idempotency key is still being processed")
+	}
+
+	var response model.ProcessPaymentResponse
+	err = json.Unmarshal(existing.Response, &response)
+	if err != nil {
+		i.log.WithField("idempotency_key", key).
+			WithField("error", err).Error("[ProcessPayment] failed to unmarshal stored response")
+		return model.ProcessPaymentResponse{}, err
+	}
+
+	i.log.WithField("idempotency_key", key).
+		WithField("payment_id", response.PaymentID).Info("[ProcessPayment] replaying stored payment response")
+	return response, nil
+}
+
+// requestHash fingerprints the payment request so a key cannot be replayed for a different payment.
+func requestHash(payload model.ProcessPaymentPayload) (string, error) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:]), nil
+}
@@ -0,0 +1,108 @@
+package service
+
+import (
+	"billing-engine/internal/payment/allocation"
+	"billing-engine/internal/payment/domain"
+	"billing-engine/internal/payment/model"
+	apperror "billing-engine/pkg/customerror"
+	"billing-engine/pkg/enum"
+	"billing-engine/pkg/money"
+	"billing-engine/pkg/producer"
+	"context"
+	"fmt"
+	"github.com/google/uuid"
+	"time"
+)
+
+// recordRecovery takes a payment on a written-off loan. The open schedules are what was written off, so they
+// are left untouched and only cap what can still be recovered. They are locked all the same, so concurrent
+// recoveries cannot both fit under the cap.
+func (i impl) recordRecovery(ctx context.Context, payload model.ProcessPaymentPayload) (model.ProcessPaymentResponse, error) {
+	schedules, err := i.repo.LockOpenSchedules(ctx, payload.LoanID)
+	if err != nil {
+		i.log.WithField("error", err).Error("[recordRecovery] failed to get open schedules")
+		return model.ProcessPaymentResponse{}, err
+	}
+
+	if len(schedules) == 0 {
+		return model.ProcessPaymentResponse{}, apperror.New(apperror.NotFound, "loan has no outstanding schedule")
+	}
+
+	currency := schedules[0].PaymentAmount.Currency
+	if payload.Amount.Currency != currency {
+		return model.ProcessPaymentResponse{}, apperror.New(apperror.InvalidInput,
+			fmt.Sprintf("amount must be in %s", currency))
+	}
+
+	recovered, err := i.repo.GetTotalRecovered(ctx, payload.LoanID)
+	if err != nil {
+		i.log.WithField("error", err).Error("[recordRecovery] failed to get total recovered")
+		return model.ProcessPaymentResponse{}, err
+	}
+
+	outstanding, err := allocation.Outstanding(schedules)
+	if err != nil {
+		i.log.WithField("error", err).Error("[recordRecovery] failed to sum the outstanding balance")
+		return model.ProcessPaymentResponse{}, err
+	}
+
+	remaining, err := outstanding.Sub(money.New(recovered, currency))
+	if err != nil {
+		i.log.WithField("error", err).Error("[recordRecovery] failed to compute the unrecovered balance")
+		return model.ProcessPaymentResponse{}, err
+	}
+
+	exceeds, err := payload.Amount.GreaterThan(remaining)
+	if err != nil {
+		return model.ProcessPaymentResponse{}, err
+	}
+
+	if exceeds {
+		return model.ProcessPaymentResponse{}, apperror.New(apperror.InvalidInput,
+			fmt.Sprintf("amount exceeds the unrecovered balance of %s", remaining))
+	}
+
+	payment, err := i.repo.CreatePayment(ctx, domain.Payment{
+		LoanID:        payload.LoanID,
+		PaymentDate:   time.Now(),
+		AmountPaid:    payload.Amount,
+		PaymentMethod: "Virtual Account",
+		PaymentStatus: enum.PaymentStatusPaid,
+		PaymentType:   enum.PaymentTypeRecovery,
+	})
+	if err != nil {
+		i.log.WithField("error", err).Error("[recordRecovery] failed to create payment")
+		return model.ProcessPaymentResponse{}, err
+	}
+
+	producerMessage := producer.Message{
+		EventID:   uuid.New().String(),
+		EventName: producer.EVENT_NAME_PAYMENT_PAID,
+		Data: model.PaymentEventPayload{
+			LoanID:        payload.LoanID,
+			PaymentID:     payment.PaymentID,
+			AmountPaid:    payment.AmountPaid,
+			PaymentStatus: payment.PaymentStatus,
+			PaymentType:   payment.PaymentType,
+			PaymentDate:   payment.PaymentDate,
+			Allocations:   []model.AllocationResponse{},
+			Schedules:     []model.ScheduleBalance{},
+		},
+	}
+
+	err = i.repo.CreateOutboxMessage(ctx, producerMessage)
+	if err != nil {
+		i.log.WithField("error", err).Error("[recordRecovery] failed to write message to outbox")
+		return model.ProcessPaymentResponse{}, err
+	}
+
+	i.log.WithField("payload", payload).Info("[recordRecovery] recovery recorded")
+	return model.ProcessPaymentResponse{
+		AmountPaid:    payment.AmountPaid,
+		PaymentID:     payment.PaymentID,
+		PaymentStatus: payment.PaymentStatus,
+		PaymentType:   payment.PaymentType,
+		PaymentDate:   payment.PaymentDate,
+		Allocations:   []model.AllocationResponse{},
+	}, nil
+}
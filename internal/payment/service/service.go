@@ -1,6 +1,7 @@
 package service
 
 import (
+	"billing-engine/internal/payment/allocation"
 	"billing-engine/internal/payment/domain"
 	"billing-engine/internal/payment/model"
 	"billing-engine/internal/payment/repository"
@@ -10,57 +11,145 @@ import (
 	"billing-engine/pkg/producer"
 	"context"
 	"encoding/json"
+	"fmt"
 	"github.com/google/uuid"
+	"time"
 )
 
 type PaymentServiceProvider interface {
 	ProcessPayment(ctx context.Context, payload model.ProcessPaymentPayload) (model.ProcessPaymentResponse, error)
 	ProcessLoanEvent(ctx context.Context, payloads model.LoanCreatedPayload) error
+	ProcessPenaltyEvent(ctx context.Context, payload model.PenaltyAccruedPayload) error
+	ProcessPayoffQuoteEvent(ctx context.Context, payload model.PayoffQuotedPayload) error
+	ProcessLoanStatusEvent(ctx context.Context, payload model.LoanStatusChangedPayload) error
+	ProcessRestructureEvent(ctx context.Context, payload model.LoanRestructuredPayload) error
+	ProcessPaymentHolidayEvent(ctx context.Context, payload model.PaymentHolidayGrantedPayload) error
+	ProcessCancellationEvent(ctx context.Context, payload model.LoanCancelledPayload) error
+	ProcessRefinanceEvent(ctx context.Context, payload model.LoanRefinancedPayload) error
+	ProcessSettlement(ctx context.Context, payload model.SettlementPayload) (model.ProcessPaymentResponse, error)
 	ProcessMessage(ctx context.Context, payload []byte) error
 }
 
 type impl struct {
-	repo     repository.PaymentRepositoryProvider
-	producer producer.ProducerProvider
-	log      logger.Logger
+	repo      repository.PaymentRepositoryProvider
+	waterfall allocation.Waterfall
+	log       logger.Logger
 }
 
+// ProcessPayment records the payment in one transaction that locks the open schedules of the loan, so
+// concurrent payments cannot allocate to the same installment. The event is written to the outbox in the
+// same transaction and sent by the relay once it is committed.
 func (i impl) ProcessPayment(ctx context.Context, payload model.ProcessPaymentPayload) (model.ProcessPaymentResponse, error) {
 	i.log.WithField("payload", payload).Info("[ProcessPayment] processing payment")
 
-	isExist, err := i.repo.IsCustomerHasLoan(ctx, payload.CustomerID, payload.LoanID)
+	// postgres keeps microseconds, the claim is matched by the time it was taken
+	claimedAt := time.Now().Truncate(time.Microsecond)
+	if payload.IdempotencyKey != "" {
+		response, replayed, err := i.claimIdempotencyKey(ctx, payload, claimedAt)
+		if err != nil || replayed {
+			return response, err
+		}
+	}
+
+	var response model.ProcessPaymentResponse
+	err := i.repo.WithTransaction(ctx, func(repo repository.PaymentRepositoryProvider) error {
+		tx := i.withRepo(repo)
+
+		var err error
+		response, err = tx.recordPayment(ctx, payload)
+		if err != nil {
+			return err
+		}
+
+		if payload.IdempotencyKey != "" {
+			return tx.completeIdempotencyKey(ctx, payload.IdempotencyKey, claimedAt, response)
+		}
+
+		return nil
+	})
+	if err != nil {
+		// nothing of the payment was stored, so the key is free for a retry
+		if payload.IdempotencyKey != "" {
+			i.releaseIdempotencyKey(ctx, payload.IdempotencyKey, claimedAt)
+		}
+
+		return model.ProcessPaymentResponse{}, err
+	}
+
+	i.log.WithField("payload", payload).Info("[ProcessPayment] payment processed")
+	return response, nil
+}
+
+// recordPayment allocates the payment over the open installments and stores it together with its event. What
+// is left once every open installment is paid is kept as credit of the borrower.
+func (i impl) recordPayment(ctx context.Context, payload model.ProcessPaymentPayload) (model.ProcessPaymentResponse, error) {
+	loan, err := i.repo.GetCustomerLoan(ctx, payload.CustomerID, payload.LoanID)
 	if err != nil {
-		i.log.WithField("error", err).Error("[ProcessPayment] failed to check customer has loan")
+		i.log.WithField("error", err).Error("[ProcessPayment] failed to get customer loan")
 		return model.ProcessPaymentResponse{}, err
 	}
 
-	if !isExist {
+	if loan == nil {
 		return model.ProcessPaymentResponse{}, apperror.New(apperror.NotFound, "customer has no loan")
 	}
 
-	isExist, err = i.repo.IsLoanScheduleExist(ctx, payload.LoanID, payload.ScheduleID)
+	if !payload.Amount.IsPositive() {
+		return model.ProcessPaymentResponse{}, apperror.New(apperror.InvalidInput, "amount must be positive")
+	}
+
+	switch loan.Status {
+	case enum.LoanStatusCancelled:
+		return model.ProcessPaymentResponse{}, apperror.New(apperror.InvalidInput, "loan was cancelled")
+	case enum.LoanStatusRefinanced:
+		return model.ProcessPaymentResponse{}, apperror.New(apperror.InvalidInput, "loan was refinanced by a top-up")
+	}
+
+	if loan.Status == enum.LoanStatusWrittenOff {
+		return i.recordRecovery(ctx, payload)
+	}
+
+	schedules, err := i.repo.LockOpenSchedules(ctx, payload.LoanID)
 	if err != nil {
-		i.log.WithField("error", err).Error("[ProcessPayment] failed to check loan schedule exist")
+		i.log.WithField("error", err).Error("[ProcessPayment] failed to get open schedules")
 		return model.ProcessPaymentResponse{}, err
 	}
 
-	if !isExist {
-		return model.ProcessPaymentResponse{}, apperror.New(apperror.NotFound, "loan schedule does not exist")
+	if len(schedules) == 0 {
+		return model.ProcessPaymentResponse{}, apperror.New(apperror.NotFound, "loan has no outstanding schedule")
+	}
+
+	if payload.Amount.Currency != schedules[0].PaymentAmount.Currency {
+		return model.ProcessPaymentResponse{}, apperror.New(apperror.InvalidInput,
+			fmt.Sprintf("amount must be in %s", schedules[0].PaymentAmount.Currency))
 	}
 
-	paymentSchedule, err := i.repo.UpdatePaymentScheduleStatus(ctx, payload.LoanID, payload.ScheduleID, enum.PaymentStatusPaid)
+	allocations, credit, err := i.waterfall.Allocate(payload.Amount, schedules)
 	if err != nil {
-		i.log.WithField("error", err).Error("[ProcessPayment] failed to update payment schedule status")
+		i.log.WithField("error", err).Error("[ProcessPayment] failed to allocate payment")
+		return model.ProcessPaymentResponse{}, err
+	}
+
+	if credit.IsPositive() {
+		i.log.WithField("loan_id", payload.LoanID).
+			WithField("credit", credit.String()).Info("[ProcessPayment] payment exceeds the outstanding balance, keeping the rest as credit")
+	}
+
+	touched := touchedSchedules(schedules, allocations)
+	err = i.repo.UpdatePaymentSchedules(ctx, touched)
+	if err != nil {
+		i.log.WithField("error", err).Error("[ProcessPayment] failed to update payment schedules")
 		return model.ProcessPaymentResponse{}, err
 	}
 
 	newPayment := domain.Payment{
 		LoanID:        payload.LoanID,
-		ScheduleID:    payload.ScheduleID,
-		PaymentDate:   paymentSchedule.PaymentDueDate,
-		AmountPaid:    paymentSchedule.PaymentAmount,
+		PaymentDate:   time.Now(),
+		AmountPaid:    payload.Amount,
+		Credit:        credit,
 		PaymentMethod: "Virtual Account",
 		PaymentStatus: enum.PaymentStatusPaid,
+		PaymentType:   enum.PaymentTypeInstallment,
+		Allocations:   allocations,
 	}
 
 	payment, err := i.repo.CreatePayment(ctx, newPayment)
@@ -69,62 +158,228 @@ func (i impl) ProcessPayment(ctx context.Context, payload model.ProcessPaymentPa
 		return model.ProcessPaymentResponse{}, err
 	}
 
-	paymentEvent := model.PaymentEventPayload{
-		LoanID:        payload.LoanID,
-		ScheduleID:    payload.ScheduleID,
-		PaymentID:     payment.PaymentID,
-		PaymentStatus: payment.PaymentStatus,
-		PaymentDate:   payment.PaymentDate,
-	}
-
+	allocationResponse := mapAllocations(allocations)
 	producerMessage := producer.Message{
 		EventID:   uuid.New().String(),
 		EventName: producer.EVENT_NAME_PAYMENT_PAID,
-		Data:      paymentEvent,
+		Data: model.PaymentEventPayload{
+			LoanID:        payload.LoanID,
+			PaymentID:     payment.PaymentID,
+			AmountPaid:    payment.AmountPaid,
+			Credit:        payment.Credit,
+			PaymentStatus: payment.PaymentStatus,
+			PaymentType:   payment.PaymentType,
+			PaymentDate:   payment.PaymentDate,
+			Allocations:   allocationResponse,
+			Schedules:     mapScheduleBalances(touched),
+		},
 	}
 
-	err = i.producer.SendMessage(ctx, producerMessage)
+	err = i.repo.CreateOutboxMessage(ctx, producerMessage)
 	if err != nil {
-		i.log.WithField("error", err).Error("[ProcessPayment] failed to send message to producer")
+		i.log.WithField("error", err).Error("[ProcessPayment] failed to write message to outbox")
 		return model.ProcessPaymentResponse{}, err
 	}
 
-	i.log.WithField("payload", payload).Info("[ProcessPayment] payment processed")
 	return model.ProcessPaymentResponse{
 		AmountPaid:    payment.AmountPaid,
+		Credit:        payment.Credit,
 		PaymentID:     payment.PaymentID,
 		PaymentStatus: payment.PaymentStatus,
+		PaymentType:   payment.PaymentType,
 		PaymentDate:   payment.PaymentDate,
+		Allocations:   allocationResponse,
 	}, nil
+}
 
+// withRepo returns a copy of the service working on the given repository, used to run the service logic
+// inside a unit of work.
+func (i impl) withRepo(repo repository.PaymentRepositoryProvider) impl {
+	i.repo = repo
+	return i
+}
+
+// touchedSchedules returns the schedules that received part of the payment, oldest first.
+func touchedSchedules(schedules []domain.PaymentSchedule, allocations []domain.PaymentAllocation) []domain.PaymentSchedule {
+	touched := map[uuid.UUID]bool{}
+	for _, val := range allocations {
+		touched[val.ScheduleID] = true
+	}
+
+	var result []domain.PaymentSchedule
+	for _, val := range schedules {
+		if touched[val.ScheduleID] {
+			result = append(result, val)
+		}
+	}
+
+	return result
+}
+
+func mapAllocations(allocations []domain.PaymentAllocation) []model.AllocationResponse {
+	var result []model.AllocationResponse
+	for _, val := range allocations {
+		result = append(result, model.AllocationResponse{
+			ScheduleID: val.ScheduleID,
+			PaymentNo:  val.PaymentNo,
+			Component:  val.Component,
+			Amount:     val.Amount,
+		})
+	}
+
+	return result
+}
+
+func mapScheduleBalances(schedules []domain.PaymentSchedule) []model.ScheduleBalance {
+	var result []model.ScheduleBalance
+	for _, val := range schedules {
+		result = append(result, model.ScheduleBalance{
+			ScheduleID:    val.ScheduleID,
+			PaymentNo:     val.PaymentNo,
+			PaymentStatus: val.PaymentStatus,
+			PrincipalPaid: val.PrincipalPaid,
+			InterestPaid:  val.InterestPaid,
+			PenaltyPaid:   val.PenaltyPaid,
+		})
+	}
+
+	return result
 }
 
 func (i impl) ProcessLoanEvent(ctx context.Context, payloads model.LoanCreatedPayload) error {
 	i.log.WithField("payload", payloads).Info("[ProcessLoanEvent] processing loan event")
 
 	newLoan := domain.Loan{
-		LoanID:     payloads.LoanID,
-		CustomerID: payloads.CustomerID,
+		LoanID:          payloads.LoanID,
+		CustomerID:      payloads.CustomerID,
+		Status:          payloads.Status,
+		StatusChangedAt: payloads.StatusChangedAt,
 	}
 
-	for _, val := range payloads.Schedules {
-		newLoan.PaymentSchedules = append(newLoan.PaymentSchedules, domain.PaymentSchedule{
+	newLoan.PaymentSchedules = mapLoanSchedules(payloads.LoanID, payloads.Schedules)
+
+	_, err := i.repo.CreateLoan(ctx, newLoan)
+	if err != nil {
+		i.log.WithField("error", err).Error("[ProcessLoanEvent] failed to create loan")
+		return err
+	}
+
+	i.log.WithField("payload", payloads).Info("[ProcessLoanEvent] loan event processed")
+	return nil
+}
+
+// ProcessRestructureEvent swaps the schedules replaced by a restructure for the new ones.
+func (i impl) ProcessRestructureEvent(ctx context.Context, payload model.LoanRestructuredPayload) error {
+	i.log.WithField("restructure_id", payload.RestructureID).Info("[ProcessRestructureEvent] processing restructure event")
+
+	schedules := mapLoanSchedules(payload.LoanID, payload.Schedules)
+	err := i.repo.ReplaceSchedules(ctx, payload.LoanID, payload.CancelledScheduleIDs, schedules)
+	if err != nil {
+		i.log.WithField("error", err).Error("[ProcessRestructureEvent] failed to replace schedules")
+		return err
+	}
+
+	i.log.WithField("restructure_id", payload.RestructureID).Info("[ProcessRestructureEvent] restructure event processed")
+	return nil
+}
+
+// ProcessPaymentHolidayEvent moves the deferred schedules to their new due dates.
+func (i impl) ProcessPaymentHolidayEvent(ctx context.Context, payload model.PaymentHolidayGrantedPayload) error {
+	i.log.WithField("holiday_id", payload.HolidayID).Info("[ProcessPaymentHolidayEvent] processing payment holiday event")
+
+	var schedules []domain.PaymentSchedule
+	for _, val := range payload.Schedules {
+		schedules = append(schedules, domain.PaymentSchedule{
 			ScheduleID:     val.ScheduleID,
-			LoanID:         payloads.LoanID,
-			PaymentNo:      val.PaymentNo,
 			PaymentDueDate: val.PaymentDueDate,
-			PaymentAmount:  val.PaymentAmount,
-			PaymentStatus:  val.PaymentStatus,
+			PenaltyAmount:  val.PenaltyAmount,
 		})
 	}
 
-	_, err := i.repo.CreateLoan(ctx, newLoan)
+	err := i.repo.UpdateScheduleDueDates(ctx, payload.LoanID, schedules)
 	if err != nil {
-		i.log.WithField("error", err).Error("[ProcessLoanEvent] failed to create loan")
+		i.log.WithField("error", err).Error("[ProcessPaymentHolidayEvent] failed to update schedule due dates")
 		return err
 	}
 
-	i.log.WithField("payload", payloads).Info("[ProcessLoanEvent] loan event processed")
+	i.log.WithField("holiday_id", payload.HolidayID).Info("[ProcessPaymentHolidayEvent] payment holiday event processed")
+	return nil
+}
+
+// ProcessCancellationEvent blocks further payments on a cancelled loan. Whatever the borrower has to return
+// or gets refunded is settled by billing, not through the schedules.
+func (i impl) ProcessCancellationEvent(ctx context.Context, payload model.LoanCancelledPayload) error {
+	i.log.WithField("cancellation_id", payload.CancellationID).Info("[ProcessCancellationEvent] processing cancellation event")
+
+	err := i.repo.CloseLoan(ctx, payload.LoanID, enum.LoanStatusCancelled, payload.CancelledAt)
+	if err != nil {
+		i.log.WithField("error", err).Error("[ProcessCancellationEvent] failed to cancel loan")
+		return err
+	}
+
+	i.log.WithField("cancellation_id", payload.CancellationID).Info("[ProcessCancellationEvent] cancellation event processed")
+	return nil
+}
+
+// ProcessRefinanceEvent closes a loan settled by a top-up. The top-up itself arrives with LOAN_CREATED once
+// it is disbursed.
+func (i impl) ProcessRefinanceEvent(ctx context.Context, payload model.LoanRefinancedPayload) error {
+	i.log.WithField("loan_id", payload.LoanID).Info("[ProcessRefinanceEvent] processing refinance event")
+
+	err := i.repo.CloseLoan(ctx, payload.LoanID, enum.LoanStatusRefinanced, payload.RefinancedAt)
+	if err != nil {
+		i.log.WithField("error", err).Error("[ProcessRefinanceEvent] failed to close refinanced loan")
+		return err
+	}
+
+	i.log.WithField("loan_id", payload.LoanID).Info("[ProcessRefinanceEvent] refinance event processed")
+	return nil
+}
+
+func mapLoanSchedules(loanID uuid.UUID, schedules []model.LoanSchedule) []domain.PaymentSchedule {
+	var result []domain.PaymentSchedule
+	for _, val := range schedules {
+		result = append(result, domain.PaymentSchedule{
+			ScheduleID:      val.ScheduleID,
+			LoanID:          loanID,
+			PaymentNo:       val.PaymentNo,
+			PaymentDueDate:  val.PaymentDueDate,
+			PaymentAmount:   val.PaymentAmount,
+			PrincipalAmount: val.PrincipalAmount,
+			InterestAmount:  val.InterestAmount,
+			PaymentStatus:   val.PaymentStatus,
+		})
+	}
+
+	return result
+}
+
+func (i impl) ProcessPenaltyEvent(ctx context.Context, payload model.PenaltyAccruedPayload) error {
+	i.log.WithField("payload", payload).Info("[ProcessPenaltyEvent] processing penalty event")
+
+	// the event carries the running total, so replaying it leaves the schedule unchanged
+	err := i.repo.UpdatePenaltyAmount(ctx, payload.LoanID, payload.ScheduleID, payload.TotalPenalty)
+	if err != nil {
+		i.log.WithField("error", err).Error("[ProcessPenaltyEvent] failed to update penalty amount")
+		return err
+	}
+
+	i.log.WithField("payload", payload).Info("[ProcessPenaltyEvent] penalty event processed")
+	return nil
+}
+
+// ProcessLoanStatusEvent keeps the status of the loan copy in line with the billing service. An event that is
+// older than the status the loan has is left out, so a redelivered event cannot move the loan back.
+func (i impl) ProcessLoanStatusEvent(ctx context.Context, payload model.LoanStatusChangedPayload) error {
+	i.log.WithField("payload", payload).Info("[ProcessLoanStatusEvent] processing loan status event")
+
+	err := i.repo.UpdateLoanStatus(ctx, payload.LoanID, payload.ToStatus, payload.ChangedAt)
+	if err != nil {
+		i.log.WithField("error", err).Error("[ProcessLoanStatusEvent] failed to update loan status")
+		return err
+	}
+
+	i.log.WithField("payload", payload).Info("[ProcessLoanStatusEvent] loan status event processed")
 	return nil
 }
 
@@ -138,6 +393,33 @@ func (i impl) ProcessMessage(ctx context.Context, payload []byte) error {
 		return err
 	}
 
+	// the relay sends an event at least once. It is marked processed in the transaction that handles it, so a
+	// repeat is skipped, an event without an id cannot be told apart and is always handled.
+	if message.EventID == "" {
+		return i.handleMessage(ctx, message)
+	}
+
+	return i.repo.WithTransaction(ctx, func(repo repository.PaymentRepositoryProvider) error {
+		tx := i.withRepo(repo)
+		processed, err := tx.repo.MarkEventProcessed(ctx, message.EventID, message.EventName)
+		if err != nil {
+			i.log.WithField("event_id", message.EventID).
+				WithField("error", err).Error("[ProcessMessage] failed to mark event processed")
+			return err
+		}
+
+		if !processed {
+			i.log.WithField("event_id", message.EventID).
+				WithField("event_name", message.EventName).Info("[ProcessMessage] event was already processed")
+			return nil
+		}
+
+		return tx.handleMessage(ctx, message)
+	})
+}
+
+// handleMessage applies the event to the service.
+func (i impl) handleMessage(ctx context.Context, message producer.Message) error {
 	switch message.EventName {
 	case producer.EVENT_NAME_LOAN_CREATED:
 		var parseData model.LoanCreatedPayload
@@ -158,20 +440,158 @@ func (i impl) ProcessMessage(ctx context.Context, payload []byte) error {
 			i.log.WithField("error", err).Error("[ProcessMessage] failed to process loan event")
 			return err
 		}
+	case producer.EVENT_NAME_PENALTY_ACCRUED:
+		var parseData model.PenaltyAccruedPayload
+
+		dataByte, err := json.Marshal(message.Data)
+		if err != nil {
+			i.log.WithField("error", err).Error("[ProcessMessage] failed to marshal message.Data")
+			return err
+		}
+		err = json.Unmarshal(dataByte, &parseData)
+		if err != nil {
+			i.log.WithField("error", err).Error("[ProcessMessage] failed to assert message.Data to model")
+			return err
+		}
+
+		err = i.ProcessPenaltyEvent(ctx, parseData)
+		if err != nil {
+			i.log.WithField("error", err).Error("[ProcessMessage] failed to process penalty event")
+			return err
+		}
+	case producer.EVENT_NAME_PAYOFF_QUOTED:
+		var parseData model.PayoffQuotedPayload
+
+		dataByte, err := json.Marshal(message.Data)
+		if err != nil {
+			i.log.WithField("error", err).Error("[ProcessMessage] failed to marshal message.Data")
+			return err
+		}
+		err = json.Unmarshal(dataByte, &parseData)
+		if err != nil {
+			i.log.WithField("error", err).Error("[ProcessMessage] failed to assert message.Data to model")
+			return err
+		}
+
+		err = i.ProcessPayoffQuoteEvent(ctx, parseData)
+		if err != nil {
+			i.log.WithField("error", err).Error("[ProcessMessage] failed to process payoff quote event")
+			return err
+		}
+	case producer.EVENT_NAME_LOAN_STATUS_CHANGED:
+		var parseData model.LoanStatusChangedPayload
+
+		dataByte, err := json.Marshal(message.Data)
+		if err != nil {
+			i.log.WithField("error", err).Error("[ProcessMessage] failed to marshal message.Data")
+			return err
+		}
+		err = json.Unmarshal(dataByte, &parseData)
+		if err != nil {
+			i.log.WithField("error", err).Error("[ProcessMessage] failed to assert message.Data to model")
+			return err
+		}
+
+		err = i.ProcessLoanStatusEvent(ctx, parseData)
+		if err != nil {
+			i.log.WithField("error", err).Error("[ProcessMessage] failed to process loan status event")
+			return err
+		}
+	case producer.EVENT_NAME_LOAN_RESTRUCTURED:
+		var parseData model.LoanRestructuredPayload
+
+		dataByte, err := json.Marshal(message.Data)
+		if err != nil {
+			i.log.WithField("error", err).Error("[ProcessMessage] failed to marshal message.Data")
+			return err
+		}
+		err = json.Unmarshal(dataByte, &parseData)
+		if err != nil {
+			i.log.WithField("error", err).Error("[ProcessMessage] failed to assert message.Data to model")
+			return err
+		}
+
+		err = i.ProcessRestructureEvent(ctx, parseData)
+		if err != nil {
+			i.log.WithField("error", err).Error("[ProcessMessage] failed to process restructure event")
+			return err
+		}
+	case producer.EVENT_NAME_PAYMENT_HOLIDAY_GRANTED:
+		var parseData model.PaymentHolidayGrantedPayload
+
+		dataByte, err := json.Marshal(message.Data)
+		if err != nil {
+			i.log.WithField("error", err).Error("[ProcessMessage] failed to marshal message.Data")
+			return err
+		}
+		err = json.Unmarshal(dataByte, &parseData)
+		if err != nil {
+			i.log.WithField("error", err).Error("[ProcessMessage] failed to assert message.Data to model")
+			return err
+		}
+
+		err = i.ProcessPaymentHolidayEvent(ctx, parseData)
+		if err != nil {
+			i.log.WithField("error", err).Error("[ProcessMessage] failed to process payment holiday event")
+			return err
+		}
+	case producer.EVENT_NAME_LOAN_CANCELLED:
+		var parseData model.LoanCancelledPayload
+
+		dataByte, err := json.Marshal(message.Data)
+		if err != nil {
+			i.log.WithField("error", err).Error("[ProcessMessage] failed to marshal message.Data")
+			return err
+		}
+		err = json.Unmarshal(dataByte, &parseData)
+		if err != nil {
+			i.log.WithField("error", err).Error("[ProcessMessage] failed to assert message.Data to model")
+			return err
+		}
+
+		err = i.ProcessCancellationEvent(ctx, parseData)
+		if err != nil {
+			i.log.WithField("error", err).Error("[ProcessMessage] failed to process cancellation event")
+			return err
+		}
+	case producer.EVENT_NAME_LOAN_REFINANCED:
+		var parseData model.LoanRefinancedPayload
+
+		dataByte, err := json.Marshal(message.Data)
+		if err != nil {
+			i.log.WithField("error", err).Error("[ProcessMessage] failed to marshal message.Data")
+			return err
+		}
+		err = json.Unmarshal(dataByte, &parseData)
+		if err != nil {
+			i.log.WithField("error", err).Error("[ProcessMessage] failed to assert message.Data to model")
+			return err
+		}
+
+		err = i.ProcessRefinanceEvent(ctx, parseData)
+		if err != nil {
+			i.log.WithField("error", err).Error("[ProcessMessage] failed to process refinance event")
+			return err
+		}
+	case producer.EVENT_NAME_SCHEDULE_MISSED, producer.EVENT_NAME_LOAN_DISBURSED:
+		// overdue installments stay payable and a disbursed loan arrives with LOAN_CREATED, so there is
+		// nothing to sync on the payment side
+		i.log.WithField("event_name", message.EventName).Info("[ProcessMessage] event ignored")
 	default:
 		i.log.WithField("event_name", message.EventName).
 			WithField("payload", message).Error("[ProcessMessage] unknown event name")
 	}
 
-	i.log.WithField("payload", string(payload)).Info("[ProcessMessage] message processed")
+	i.log.WithField("event_id", message.EventID).
+		WithField("event_name", message.EventName).Info("[ProcessMessage] message processed")
 	return nil
 }
 
-func NewPaymentService(repo repository.PaymentRepositoryProvider,
-	producer producer.ProducerProvider, log logger.Logger) PaymentServiceProvider {
+func NewPaymentService(repo repository.PaymentRepositoryProvider, waterfall allocation.Waterfall,
+	log logger.Logger) PaymentServiceProvider {
 	return &impl{
-		repo:     repo,
-		log:      log,
-		producer: producer,
+		repo:      repo,
+		log:       log,
+		waterfall: waterfall,
 	}
 }
@@ -1,118 +1,245 @@
 package service_test
 
 import (
+	"billing-engine/internal/payment/allocation"
 	"billing-engine/internal/payment/domain"
 	"billing-engine/internal/payment/mocks"
 	"billing-engine/internal/payment/model"
+	"billing-engine/internal/payment/repository"
+	apperror "billing-engine/pkg/customerror"
+	"billing-engine/pkg/enum"
 	"billing-engine/pkg/logger"
-	pkgMock "billing-engine/pkg/mocks"
+	"billing-engine/pkg/money"
+	"billing-engine/pkg/producer"
+	"context"
 	"errors"
+	"github.com/google/uuid"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 	"go.uber.org/mock/gomock"
+	"time"
 
 	"billing-engine/internal/payment/service"
 )
 
 var someErr = errors.New("some error")
 
+func idr(amount int64) money.Money {
+	return money.New(amount, "IDR")
+}
+
 var _ = Describe("Service", func() {
 	var (
 		svc      service.PaymentServiceProvider
 		mockCtrl *gomock.Controller
 		repo     *mocks.MockPaymentRepositoryProvider
 		log      logger.Logger
-		producer *pkgMock.MockProducerProvider
 	)
 
 	BeforeEach(func() {
 		mockCtrl = gomock.NewController(GinkgoT())
 		log = logger.NewZeroLogger("tests")
 		repo = mocks.NewMockPaymentRepositoryProvider(mockCtrl)
-		producer = pkgMock.NewMockProducerProvider(mockCtrl)
-		svc = service.NewPaymentService(repo, producer, log)
+		svc = service.NewPaymentService(repo, allocation.DefaultWaterfall(), log)
+		repo.EXPECT().WithTransaction(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, fn func(repository.PaymentRepositoryProvider) error) error {
+				return fn(repo)
+			}).AnyTimes()
 	})
 
 	Describe("ProcessPayment", func() {
-		payload := model.ProcessPaymentPayload{}
-		mockPayment := &domain.Payment{}
+		var (
+			payload   model.ProcessPaymentPayload
+			schedules []domain.PaymentSchedule
+		)
+
+		BeforeEach(func() {
+			payload = model.ProcessPaymentPayload{Amount: idr(110000)}
+			schedules = []domain.PaymentSchedule{
+				{
+					ScheduleID:      uuid.New(),
+					PaymentNo:       1,
+					PaymentAmount:   idr(110000),
+					PrincipalAmount: idr(100000),
+					InterestAmount:  idr(10000),
+					PenaltyAmount:   idr(5000),
+					PaymentStatus:   enum.PaymentStatusPending,
+				},
+				{
+					ScheduleID:      uuid.New(),
+					PaymentNo:       2,
+					PaymentAmount:   idr(110000),
+					PrincipalAmount: idr(100000),
+					InterestAmount:  idr(10000),
+					PaymentStatus:   enum.PaymentStatusPending,
+				},
+			}
+		})
 
 		Describe("Positive Case", func() {
 			It("when payment is successful", func() {
-				repo.EXPECT().IsCustomerHasLoan(gomock.Any(), gomock.Any(), gomock.Any()).Return(true, nil)
-				repo.EXPECT().IsLoanScheduleExist(gomock.Any(), gomock.Any(), gomock.Any()).Return(true, nil)
-				repo.EXPECT().UpdatePaymentScheduleStatus(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(mockPayment, nil)
-				producer.EXPECT().SendMessage(gomock.Any(), gomock.Any()).Return(nil)
+				repo.EXPECT().GetCustomerLoan(gomock.Any(), gomock.Any(), gomock.Any()).Return(&domain.Loan{Status: enum.LoanStatusActive}, nil)
+				repo.EXPECT().LockOpenSchedules(gomock.Any(), gomock.Any()).Return(schedules, nil)
+				repo.EXPECT().UpdatePaymentSchedules(gomock.Any(), gomock.Any()).Return(nil)
+				repo.EXPECT().CreatePayment(gomock.Any(), gomock.Any()).Return(domain.Payment{}, nil)
+				repo.EXPECT().CreateOutboxMessage(gomock.Any(), gomock.Any()).Return(nil)
 
 				_, err := svc.ProcessPayment(nil, payload)
 				Expect(err).To(BeNil())
 			})
+
+			It("when payment covers more than one installment", func() {
+				payload.Amount = idr(150000)
+				repo.EXPECT().GetCustomerLoan(gomock.Any(), gomock.Any(), gomock.Any()).Return(&domain.Loan{Status: enum.LoanStatusActive}, nil)
+				repo.EXPECT().LockOpenSchedules(gomock.Any(), gomock.Any()).Return(schedules, nil)
+				repo.EXPECT().UpdatePaymentSchedules(gomock.Any(), gomock.Any()).
+					DoAndReturn(func(_ any, updated []domain.PaymentSchedule) error {
+						Expect(updated).To(HaveLen(2))
+						Expect(updated[0].PaymentStatus).To(Equal(enum.PaymentStatusPaid))
+						Expect(updated[1].PaymentStatus).To(Equal(enum.PaymentStatusPartiallyPaid))
+						Expect(updated[1].InterestPaid).To(Equal(idr(10000)))
+						Expect(updated[1].PrincipalPaid).To(Equal(idr(25000)))
+						return nil
+					})
+				repo.EXPECT().CreatePayment(gomock.Any(), gomock.Any()).
+					DoAndReturn(func(_ any, payment domain.Payment) (domain.Payment, error) {
+						return payment, nil
+					})
+				repo.EXPECT().CreateOutboxMessage(gomock.Any(), gomock.Any()).Return(nil)
+
+				response, err := svc.ProcessPayment(nil, payload)
+				Expect(err).To(BeNil())
+				Expect(response.AmountPaid).To(Equal(idr(150000)))
+				Expect(response.Allocations).To(HaveLen(5))
+				Expect(response.Allocations[0].Component).To(Equal(enum.AllocationPenalty))
+			})
+
+			It("when amount exceeds the outstanding balance the rest is kept as credit", func() {
+				payload.Amount = idr(235000)
+				repo.EXPECT().GetCustomerLoan(gomock.Any(), gomock.Any(), gomock.Any()).Return(&domain.Loan{Status: enum.LoanStatusActive}, nil)
+				repo.EXPECT().LockOpenSchedules(gomock.Any(), gomock.Any()).Return(schedules, nil)
+				repo.EXPECT().UpdatePaymentSchedules(gomock.Any(), gomock.Any()).
+					DoAndReturn(func(_ any, updated []domain.PaymentSchedule) error {
+						Expect(updated).To(HaveLen(2))
+						Expect(updated[0].PaymentStatus).To(Equal(enum.PaymentStatusPaid))
+						Expect(updated[1].PaymentStatus).To(Equal(enum.PaymentStatusPaid))
+						return nil
+					})
+				repo.EXPECT().CreatePayment(gomock.Any(), gomock.Any()).
+					DoAndReturn(func(_ any, payment domain.Payment) (domain.Payment, error) {
+						Expect(payment.AmountPaid).To(Equal(idr(235000)))
+						Expect(payment.Credit).To(Equal(idr(10000)))
+						return payment, nil
+					})
+				repo.EXPECT().CreateOutboxMessage(gomock.Any(), gomock.Any()).DoAndReturn(func(_ any, message producer.Message) error {
+					data := message.Data.(model.PaymentEventPayload)
+					Expect(data.Credit).To(Equal(idr(10000)))
+					return nil
+				})
+
+				response, err := svc.ProcessPayment(nil, payload)
+				Expect(err).To(BeNil())
+				Expect(response.Credit).To(Equal(idr(10000)))
+				Expect(response.Allocations).To(HaveLen(5))
+			})
+
+			It("when the loan is written off the payment is a recovery", func() {
+				payload.Amount = idr(50000)
+				repo.EXPECT().GetCustomerLoan(gomock.Any(), gomock.Any(), gomock.Any()).Return(&domain.Loan{Status: enum.LoanStatusWrittenOff}, nil)
+				repo.EXPECT().LockOpenSchedules(gomock.Any(), gomock.Any()).Return(schedules, nil)
+				repo.EXPECT().GetTotalRecovered(gomock.Any(), gomock.Any()).Return(int64(100000), nil)
+				repo.EXPECT().CreatePayment(gomock.Any(), gomock.Any()).
+					DoAndReturn(func(_ any, payment domain.Payment) (domain.Payment, error) {
+						Expect(payment.PaymentType).To(Equal(enum.PaymentTypeRecovery))
+						Expect(payment.Allocations).To(BeEmpty())
+						return payment, nil
+					})
+				repo.EXPECT().CreateOutboxMessage(gomock.Any(), gomock.Any()).Return(nil)
+
+				response, err := svc.ProcessPayment(nil, payload)
+				Expect(err).To(BeNil())
+				Expect(response.PaymentType).To(Equal(enum.PaymentTypeRecovery))
+				Expect(response.Allocations).To(BeEmpty())
+			})
 		})
 
 		Describe("Negative Case", func() {
+			It("when a recovery exceeds the unrecovered balance", func() {
+				payload.Amount = idr(30000)
+				repo.EXPECT().GetCustomerLoan(gomock.Any(), gomock.Any(), gomock.Any()).Return(&domain.Loan{Status: enum.LoanStatusWrittenOff}, nil)
+				repo.EXPECT().LockOpenSchedules(gomock.Any(), gomock.Any()).Return(schedules, nil)
+				repo.EXPECT().GetTotalRecovered(gomock.Any(), gomock.Any()).Return(int64(200000), nil)
+
+				_, err := svc.ProcessPayment(nil, payload)
+
+				var errs *apperror.CustomError
+				Expect(errors.As(err, &errs)).To(BeTrue())
+				Expect(errs.Cause).To(Equal(apperror.InvalidInput))
+			})
+
 			It("when customer has no loan", func() {
-				repo.EXPECT().IsCustomerHasLoan(gomock.Any(), gomock.Any(), gomock.Any()).Return(false, nil)
+				repo.EXPECT().GetCustomerLoan(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, nil)
 
 				_, err := svc.ProcessPayment(nil, payload)
 				Expect(err).ToNot(BeNil())
 			})
 
-			It("when loan schedule does not exist", func() {
-				repo.EXPECT().IsCustomerHasLoan(gomock.Any(), gomock.Any(), gomock.Any()).Return(true, nil)
-				repo.EXPECT().IsLoanScheduleExist(gomock.Any(), gomock.Any(), gomock.Any()).Return(false, nil)
+			It("when the loan was cancelled", func() {
+				repo.EXPECT().GetCustomerLoan(gomock.Any(), gomock.Any(), gomock.Any()).Return(&domain.Loan{Status: enum.LoanStatusCancelled}, nil)
 
 				_, err := svc.ProcessPayment(nil, payload)
-				Expect(err).ToNot(BeNil())
+
+				var errs *apperror.CustomError
+				Expect(errors.As(err, &errs)).To(BeTrue())
+				Expect(errs.Cause).To(Equal(apperror.InvalidInput))
 			})
 
-			It("when update payment schedule status failed", func() {
-				repo.EXPECT().IsCustomerHasLoan(gomock.Any(), gomock.Any(), gomock.Any()).Return(true, nil)
-				repo.EXPECT().IsLoanScheduleExist(gomock.Any(), gomock.Any(), gomock.Any()).Return(true, nil)
-				repo.EXPECT().UpdatePaymentScheduleStatus(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, someErr)
+			It("when loan has no open schedule", func() {
+				repo.EXPECT().GetCustomerLoan(gomock.Any(), gomock.Any(), gomock.Any()).Return(&domain.Loan{Status: enum.LoanStatusActive}, nil)
+				repo.EXPECT().LockOpenSchedules(gomock.Any(), gomock.Any()).Return(nil, nil)
 
 				_, err := svc.ProcessPayment(nil, payload)
-				Expect(err).To(HaveOccurred())
+				Expect(err).ToNot(BeNil())
 			})
 
-			It("when sending message to kafka failed", func() {
-				repo.EXPECT().IsCustomerHasLoan(gomock.Any(), gomock.Any(), gomock.Any()).Return(true, nil)
-				repo.EXPECT().IsLoanScheduleExist(gomock.Any(), gomock.Any(), gomock.Any()).Return(true, nil)
-				repo.EXPECT().UpdatePaymentScheduleStatus(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(mockPayment, nil)
-				producer.EXPECT().SendMessage(gomock.Any(), gomock.Any()).Return(nil)
+			It("when amount is not positive", func() {
+				payload.Amount = idr(0)
+				repo.EXPECT().GetCustomerLoan(gomock.Any(), gomock.Any(), gomock.Any()).Return(&domain.Loan{Status: enum.LoanStatusActive}, nil)
 
 				_, err := svc.ProcessPayment(nil, payload)
-				Expect(err).To(BeNil())
+				Expect(err).To(HaveOccurred())
 			})
 
 			It("when error getting customer loan", func() {
-				repo.EXPECT().IsCustomerHasLoan(gomock.Any(), gomock.Any(), gomock.Any()).Return(false, someErr)
+				repo.EXPECT().GetCustomerLoan(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, someErr)
 
 				_, err := svc.ProcessPayment(nil, payload)
 				Expect(err).To(HaveOccurred())
 			})
 
-			It("when error getting loan schedule", func() {
-				repo.EXPECT().IsCustomerHasLoan(gomock.Any(), gomock.Any(), gomock.Any()).Return(true, nil)
-				repo.EXPECT().IsLoanScheduleExist(gomock.Any(), gomock.Any(), gomock.Any()).Return(false, someErr)
+			It("when error getting open schedules", func() {
+				repo.EXPECT().GetCustomerLoan(gomock.Any(), gomock.Any(), gomock.Any()).Return(&domain.Loan{Status: enum.LoanStatusActive}, nil)
+				repo.EXPECT().LockOpenSchedules(gomock.Any(), gomock.Any()).Return(nil, someErr)
 
 				_, err := svc.ProcessPayment(nil, payload)
 				Expect(err).To(HaveOccurred())
 			})
 
-			It("when error updating payment schedule status", func() {
-				repo.EXPECT().IsCustomerHasLoan(gomock.Any(), gomock.Any(), gomock.Any()).Return(true, nil)
-				repo.EXPECT().IsLoanScheduleExist(gomock.Any(), gomock.Any(), gomock.Any()).Return(true, nil)
-				repo.EXPECT().UpdatePaymentScheduleStatus(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, someErr)
+			It("when error updating payment schedules", func() {
+				repo.EXPECT().GetCustomerLoan(gomock.Any(), gomock.Any(), gomock.Any()).Return(&domain.Loan{Status: enum.LoanStatusActive}, nil)
+				repo.EXPECT().LockOpenSchedules(gomock.Any(), gomock.Any()).Return(schedules, nil)
+				repo.EXPECT().UpdatePaymentSchedules(gomock.Any(), gomock.Any()).Return(someErr)
 
 				_, err := svc.ProcessPayment(nil, payload)
 				Expect(err).To(HaveOccurred())
 			})
 
-			It("when sending message to producer failed", func() {
-				repo.EXPECT().IsCustomerHasLoan(gomock.Any(), gomock.Any(), gomock.Any()).Return(true, nil)
-				repo.EXPECT().IsLoanScheduleExist(gomock.Any(), gomock.Any(), gomock.Any()).Return(true, nil)
-				repo.EXPECT().UpdatePaymentScheduleStatus(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(mockPayment, nil)
-				producer.EXPECT().SendMessage(gomock.Any(), gomock.Any()).Return(someErr)
+			It("when writing the message to the outbox failed", func() {
+				repo.EXPECT().GetCustomerLoan(gomock.Any(), gomock.Any(), gomock.Any()).Return(&domain.Loan{Status: enum.LoanStatusActive}, nil)
+				repo.EXPECT().LockOpenSchedules(gomock.Any(), gomock.Any()).Return(schedules, nil)
+				repo.EXPECT().UpdatePaymentSchedules(gomock.Any(), gomock.Any()).Return(nil)
+				repo.EXPECT().CreatePayment(gomock.Any(), gomock.Any()).Return(domain.Payment{}, nil)
+				repo.EXPECT().CreateOutboxMessage(gomock.Any(), gomock.Any()).Return(someErr)
 
 				_, err := svc.ProcessPayment(nil, payload)
 				Expect(err).To(HaveOccurred())
@@ -120,6 +247,299 @@ var _ = Describe("Service", func() {
 		})
 	})
 
+	Describe("ProcessPayment with an idempotency key", func() {
+		var (
+			payload   model.ProcessPaymentPayload
+			schedules []domain.PaymentSchedule
+		)
+
+		BeforeEach(func() {
+			payload = model.ProcessPaymentPayload{
+				Amount:         idr(110000),
+				LoanID:         uuid.New(),
+				CustomerID:     uuid.New(),
+				IdempotencyKey: "retry-1",
+			}
+			schedules = []domain.PaymentSchedule{
+				{
+					ScheduleID:      uuid.New(),
+					PaymentNo:       1,
+					PaymentAmount:   idr(110000),
+					PrincipalAmount: idr(100000),
+					InterestAmount:  idr(10000),
+					PaymentStatus:   enum.PaymentStatusPending,
+				},
+			}
+		})
+
+		Describe("Positive Case", func() {
+			It("when a retry replays the stored response", func() {
+				var stored domain.IdempotencyKey
+				paymentID := uuid.New()
+				repo.EXPECT().CreateIdempotencyKey(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(func(_ any, key domain.IdempotencyKey, _ time.Time) (bool, error) {
+					stored = key
+					return true, nil
+				})
+				repo.EXPECT().GetCustomerLoan(gomock.Any(), gomock.Any(), gomock.Any()).Return(&domain.Loan{Status: enum.LoanStatusActive}, nil)
+				repo.EXPECT().LockOpenSchedules(gomock.Any(), gomock.Any()).Return(schedules, nil)
+				repo.EXPECT().UpdatePaymentSchedules(gomock.Any(), gomock.Any()).Return(nil)
+				repo.EXPECT().CreatePayment(gomock.Any(), gomock.Any()).Return(domain.Payment{PaymentID: paymentID, AmountPaid: idr(110000)}, nil)
+				repo.EXPECT().CreateOutboxMessage(gomock.Any(), gomock.Any()).Return(nil)
+				repo.EXPECT().CompleteIdempotencyKey(gomock.Any(), "retry-1", gomock.Any(), paymentID, gomock.Any()).
+					DoAndReturn(func(_ any, _ string, claimedAt time.Time, id uuid.UUID, response []byte) (bool, error) {
+						Expect(claimedAt).To(Equal(stored.ClaimedAt))
+						completedAt := time.Now()
+						stored.PaymentID = &id
+						stored.Response = response
+						stored.CompletedAt = &completedAt
+						return true, nil
+					})
+
+				first, err := svc.ProcessPayment(context.Background(), payload)
+				Expect(err).To(BeNil())
+
+				repo.EXPECT().CreateIdempotencyKey(gomock.Any(), gomock.Any(), gomock.Any()).Return(false, nil)
+				repo.EXPECT().GetIdempotencyKey(gomock.Any(), "retry-1").Return(&stored, nil)
+
+				replayed, err := svc.ProcessPayment(context.Background(), payload)
+				Expect(err).To(BeNil())
+				Expect(replayed.PaymentID).To(Equal(first.PaymentID))
+				Expect(replayed.AmountPaid).To(Equal(first.AmountPaid))
+			})
+
+			It("when a refused payment frees the key", func() {
+				repo.EXPECT().CreateIdempotencyKey(gomock.Any(), gomock.Any(), gomock.Any()).Return(true, nil)
+				repo.EXPECT().GetCustomerLoan(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, nil)
+				repo.EXPECT().DeleteIdempotencyKey(gomock.Any(), "retry-1", gomock.Any()).Return(nil)
+
+				_, err := svc.ProcessPayment(context.Background(), payload)
+
+				var errs *apperror.CustomError
+				Expect(errors.As(err, &errs)).To(BeTrue())
+				Expect(errs.Cause).To(Equal(apperror.NotFound))
+			})
+		})
+
+		Describe("Negative Case", func() {
+			It("when the key was used for a different payment", func() {
+				repo.EXPECT().CreateIdempotencyKey(gomock.Any(), gomock.Any(), gomock.Any()).Return(false, nil)
+				repo.EXPECT().GetIdempotencyKey(gomock.Any(), "retry-1").Return(&domain.IdempotencyKey{Key: "retry-1", RequestHash: "other"}, nil)
+
+				_, err := svc.ProcessPayment(context.Background(), payload)
+
+				var errs *apperror.CustomError
+				Expect(errors.As(err, &errs)).To(BeTrue())
+				Expect(errs.Cause).To(Equal(apperror.InvalidInput))
+			})
+
+			It("when the first request is still being processed", func() {
+				var stored domain.IdempotencyKey
+				repo.EXPECT().CreateIdempotencyKey(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(func(_ any, key domain.IdempotencyKey, _ time.Time) (bool, error) {
+					stored = key
+					return false, nil
+				})
+				repo.EXPECT().GetIdempotencyKey(gomock.Any(), "retry-1").DoAndReturn(func(_ any, _ string) (*domain.IdempotencyKey, error) {
+					return &stored, nil
+				})
+
+				_, err := svc.ProcessPayment(context.Background(), payload)
+
+				var errs *apperror.CustomError
+				Expect(errors.As(err, &errs)).To(BeTrue())
+				Expect(errs.Cause).To(Equal(apperror.AlreadyExists))
+			})
+
+			It("when the payment could not be stored the key is freed", func() {
+				repo.EXPECT().CreateIdempotencyKey(gomock.Any(), gomock.Any(), gomock.Any()).Return(true, nil)
+				repo.EXPECT().GetCustomerLoan(gomock.Any(), gomock.Any(), gomock.Any()).Return(&domain.Loan{Status: enum.LoanStatusActive}, nil)
+				repo.EXPECT().LockOpenSchedules(gomock.Any(), gomock.Any()).Return(schedules, nil)
+				repo.EXPECT().UpdatePaymentSchedules(gomock.Any(), gomock.Any()).Return(nil)
+				repo.EXPECT().CreatePayment(gomock.Any(), gomock.Any()).Return(domain.Payment{}, someErr)
+				repo.EXPECT().DeleteIdempotencyKey(gomock.Any(), "retry-1", gomock.Any()).Return(nil)
+
+				_, err := svc.ProcessPayment(context.Background(), payload)
+				Expect(err).To(Equal(someErr))
+			})
+
+			It("when a stale claim is taken over", func() {
+				repo.EXPECT().CreateIdempotencyKey(gomock.Any(), gomock.Any(), gomock.Any()).
+					DoAndReturn(func(_ any, key domain.IdempotencyKey, staleBefore time.Time) (bool, error) {
+						Expect(key.ClaimedAt.Sub(staleBefore)).To(Equal(5 * time.Minute))
+						return false, nil
+					})
+				repo.EXPECT().GetIdempotencyKey(gomock.Any(), "retry-1").Return(nil, nil)
+
+				_, err := svc.ProcessPayment(context.Background(), payload)
+
+				var errs *apperror.CustomError
+				Expect(errors.As(err, &errs)).To(BeTrue())
+				Expect(errs.Cause).To(Equal(apperror.AlreadyExists))
+			})
+
+			It("when a retry took the key over the payment is rolled back", func() {
+				repo.EXPECT().CreateIdempotencyKey(gomock.Any(), gomock.Any(), gomock.Any()).Return(true, nil)
+				repo.EXPECT().GetCustomerLoan(gomock.Any(), gomock.Any(), gomock.Any()).Return(&domain.Loan{Status: enum.LoanStatusActive}, nil)
+				repo.EXPECT().LockOpenSchedules(gomock.Any(), gomock.Any()).Return(schedules, nil)
+				repo.EXPECT().UpdatePaymentSchedules(gomock.Any(), gomock.Any()).Return(nil)
+				repo.EXPECT().CreatePayment(gomock.Any(), gomock.Any()).Return(domain.Payment{}, nil)
+				repo.EXPECT().CreateOutboxMessage(gomock.Any(), gomock.Any()).Return(nil)
+				repo.EXPECT().CompleteIdempotencyKey(gomock.Any(), "retry-1", gomock.Any(), gomock.Any(), gomock.Any()).Return(false, nil)
+				repo.EXPECT().DeleteIdempotencyKey(gomock.Any(), "retry-1", gomock.Any()).Return(nil)
+
+				_, err := svc.ProcessPayment(context.Background(), payload)
+
+				var errs *apperror.CustomError
+				Expect(errors.As(err, &errs)).To(BeTrue())
+				Expect(errs.Cause).To(Equal(apperror.AlreadyExists))
+			})
+
+			It("when the request is cancelled the key is still freed", func() {
+				cancelled, cancel := context.WithCancel(context.Background())
+				cancel()
+				repo.EXPECT().CreateIdempotencyKey(gomock.Any(), gomock.Any(), gomock.Any()).Return(true, nil)
+				repo.EXPECT().GetCustomerLoan(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, cancelled.Err())
+				repo.EXPECT().DeleteIdempotencyKey(gomock.Any(), "retry-1", gomock.Any()).
+					DoAndReturn(func(ctx context.Context, _ string, _ time.Time) error {
+						Expect(ctx.Err()).To(BeNil())
+						return nil
+					})
+
+				_, err := svc.ProcessPayment(cancelled, payload)
+				Expect(err).To(MatchError(context.Canceled))
+			})
+
+			It("when the event cannot be written the payment is rolled back and the key freed", func() {
+				repo.EXPECT().CreateIdempotencyKey(gomock.Any(), gomock.Any(), gomock.Any()).Return(true, nil)
+				repo.EXPECT().GetCustomerLoan(gomock.Any(), gomock.Any(), gomock.Any()).Return(&domain.Loan{Status: enum.LoanStatusActive}, nil)
+				repo.EXPECT().LockOpenSchedules(gomock.Any(), gomock.Any()).Return(schedules, nil)
+				repo.EXPECT().UpdatePaymentSchedules(gomock.Any(), gomock.Any()).Return(nil)
+				repo.EXPECT().CreatePayment(gomock.Any(), gomock.Any()).Return(domain.Payment{}, nil)
+				repo.EXPECT().CreateOutboxMessage(gomock.Any(), gomock.Any()).Return(someErr)
+				repo.EXPECT().DeleteIdempotencyKey(gomock.Any(), "retry-1", gomock.Any()).Return(nil)
+
+				_, err := svc.ProcessPayment(context.Background(), payload)
+				Expect(err).To(Equal(someErr))
+			})
+		})
+	})
+
+	Describe("ProcessSettlement", func() {
+		var (
+			payload   model.SettlementPayload
+			quote     *domain.PayoffQuote
+			schedules []domain.PaymentSchedule
+		)
+
+		BeforeEach(func() {
+			schedules = []domain.PaymentSchedule{
+				{ScheduleID: uuid.New(), PaymentNo: 1, PrincipalAmount: idr(100000), InterestAmount: idr(10000)},
+				{ScheduleID: uuid.New(), PaymentNo: 2, PrincipalAmount: idr(100000), InterestAmount: idr(10000)},
+			}
+			quote = &domain.PayoffQuote{
+				QuoteID:          uuid.New(),
+				LoanID:           uuid.New(),
+				QuoteDate:        time.Now().Add(-time.Minute),
+				ValidUntil:       time.Now().Add(time.Hour),
+				SettlementFee:    idr(1000),
+				SettlementAmount: idr(211000),
+				Status:           enum.QuoteStatusActive,
+				Lines: []domain.PayoffQuoteLine{
+					{ScheduleID: schedules[0].ScheduleID, PrincipalAmount: idr(100000), InterestAmount: idr(10000)},
+					{ScheduleID: schedules[1].ScheduleID, PrincipalAmount: idr(100000)},
+				},
+			}
+			payload = model.SettlementPayload{QuoteID: quote.QuoteID, LoanID: quote.LoanID, Amount: idr(211000)}
+		})
+
+		It("when settlement closes every schedule", func() {
+			repo.EXPECT().GetCustomerLoan(gomock.Any(), gomock.Any(), gomock.Any()).Return(&domain.Loan{Status: enum.LoanStatusActive}, nil)
+			repo.EXPECT().GetPayoffQuote(gomock.Any(), quote.QuoteID).Return(quote, nil)
+			repo.EXPECT().HasPaymentSince(gomock.Any(), quote.LoanID, quote.QuoteDate).Return(false, nil)
+			repo.EXPECT().LockOpenSchedules(gomock.Any(), quote.LoanID).Return(schedules, nil)
+			repo.EXPECT().UpdatePaymentSchedules(gomock.Any(), gomock.Any()).
+				DoAndReturn(func(_ any, updated []domain.PaymentSchedule) error {
+					for _, val := range updated {
+						Expect(val.PaymentStatus).To(Equal(enum.PaymentStatusPaid))
+					}
+					Expect(updated[1].InterestPaid.IsZero()).To(BeTrue())
+					return nil
+				})
+			repo.EXPECT().CreatePayment(gomock.Any(), gomock.Any()).
+				DoAndReturn(func(_ any, payment domain.Payment) (domain.Payment, error) {
+					return payment, nil
+				})
+			repo.EXPECT().SettlePayoffQuote(gomock.Any(), quote.QuoteID).Return(nil)
+			repo.EXPECT().UpdateLoanStatus(gomock.Any(), quote.LoanID, enum.LoanStatusPaidOff, gomock.Any()).Return(nil)
+			repo.EXPECT().CreateOutboxMessage(gomock.Any(), gomock.Any()).Return(nil)
+
+			response, err := svc.ProcessSettlement(nil, payload)
+			Expect(err).To(BeNil())
+			Expect(response.Allocations).To(HaveLen(4))
+			Expect(response.Allocations[3].Component).To(Equal(enum.AllocationSettlementFee))
+		})
+
+		It("when quote has expired", func() {
+			quote.ValidUntil = time.Now().Add(-time.Hour)
+			repo.EXPECT().GetCustomerLoan(gomock.Any(), gomock.Any(), gomock.Any()).Return(&domain.Loan{Status: enum.LoanStatusActive}, nil)
+			repo.EXPECT().LockOpenSchedules(gomock.Any(), quote.LoanID).Return(schedules, nil)
+			repo.EXPECT().GetPayoffQuote(gomock.Any(), quote.QuoteID).Return(quote, nil)
+
+			_, err := svc.ProcessSettlement(nil, payload)
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("when amount does not match the quote", func() {
+			payload.Amount = idr(200000)
+			repo.EXPECT().GetCustomerLoan(gomock.Any(), gomock.Any(), gomock.Any()).Return(&domain.Loan{Status: enum.LoanStatusActive}, nil)
+			repo.EXPECT().LockOpenSchedules(gomock.Any(), quote.LoanID).Return(schedules, nil)
+			repo.EXPECT().GetPayoffQuote(gomock.Any(), quote.QuoteID).Return(quote, nil)
+
+			_, err := svc.ProcessSettlement(nil, payload)
+
+			var errs *apperror.CustomError
+			Expect(errors.As(err, &errs)).To(BeTrue())
+			Expect(errs.Cause).To(Equal(apperror.InvalidInput))
+		})
+
+		It("when loan was paid after the quote", func() {
+			repo.EXPECT().GetCustomerLoan(gomock.Any(), gomock.Any(), gomock.Any()).Return(&domain.Loan{Status: enum.LoanStatusActive}, nil)
+			repo.EXPECT().LockOpenSchedules(gomock.Any(), quote.LoanID).Return(schedules, nil)
+			repo.EXPECT().GetPayoffQuote(gomock.Any(), quote.QuoteID).Return(quote, nil)
+			repo.EXPECT().HasPaymentSince(gomock.Any(), quote.LoanID, quote.QuoteDate).Return(true, nil)
+
+			_, err := svc.ProcessSettlement(nil, payload)
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("when an open schedule is not on the quote", func() {
+			schedules = append(schedules, domain.PaymentSchedule{ScheduleID: uuid.New(), PaymentNo: 3, PrincipalAmount: idr(100000)})
+			repo.EXPECT().GetCustomerLoan(gomock.Any(), gomock.Any(), gomock.Any()).Return(&domain.Loan{Status: enum.LoanStatusActive}, nil)
+			repo.EXPECT().LockOpenSchedules(gomock.Any(), quote.LoanID).Return(schedules, nil)
+			repo.EXPECT().GetPayoffQuote(gomock.Any(), quote.QuoteID).Return(quote, nil)
+			repo.EXPECT().HasPaymentSince(gomock.Any(), quote.LoanID, quote.QuoteDate).Return(false, nil)
+
+			_, err := svc.ProcessSettlement(nil, payload)
+
+			var errs *apperror.CustomError
+			Expect(errors.As(err, &errs)).To(BeTrue())
+			Expect(errs.Cause).To(Equal(apperror.InvalidInput))
+		})
+
+		It("when penalties accrued after the quote", func() {
+			schedules[0].PenaltyAmount = idr(5000)
+			repo.EXPECT().GetCustomerLoan(gomock.Any(), gomock.Any(), gomock.Any()).Return(&domain.Loan{Status: enum.LoanStatusActive}, nil)
+			repo.EXPECT().LockOpenSchedules(gomock.Any(), quote.LoanID).Return(schedules, nil)
+			repo.EXPECT().GetPayoffQuote(gomock.Any(), quote.QuoteID).Return(quote, nil)
+			repo.EXPECT().HasPaymentSince(gomock.Any(), quote.LoanID, quote.QuoteDate).Return(false, nil)
+
+			_, err := svc.ProcessSettlement(nil, payload)
+
+			var errs *apperror.CustomError
+			Expect(errors.As(err, &errs)).To(BeTrue())
+			Expect(errs.Cause).To(Equal(apperror.InvalidInput))
+		})
+	})
+
 	Describe("ProcessLoanEvent", func() {
 		payload := model.LoanCreatedPayload{}
 		mockLoan := domain.Loan{}
@@ -142,4 +562,162 @@ var _ = Describe("Service", func() {
 			})
 		})
 	})
+
+	Describe("ProcessPenaltyEvent", func() {
+		payload := model.PenaltyAccruedPayload{TotalPenalty: idr(15000)}
+
+		Describe("Positive Case", func() {
+			It("when penalty event is successfully processed", func() {
+				repo.EXPECT().UpdatePenaltyAmount(gomock.Any(), payload.LoanID, payload.ScheduleID, payload.TotalPenalty).Return(nil)
+
+				err := svc.ProcessPenaltyEvent(nil, payload)
+				Expect(err).To(BeNil())
+			})
+		})
+
+		Describe("Negative Case", func() {
+			It("when error updating penalty amount", func() {
+				repo.EXPECT().UpdatePenaltyAmount(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(someErr)
+
+				err := svc.ProcessPenaltyEvent(nil, payload)
+				Expect(err).To(HaveOccurred())
+			})
+		})
+	})
+
+	Describe("ProcessLoanStatusEvent", func() {
+		payload := model.LoanStatusChangedPayload{
+			LoanID:     uuid.New(),
+			FromStatus: enum.LoanStatusActive,
+			ToStatus:   enum.LoanStatusPaidOff,
+			ChangedAt:  time.Now(),
+		}
+
+		Describe("Positive Case", func() {
+			It("when loan status event is successfully processed", func() {
+				repo.EXPECT().UpdateLoanStatus(gomock.Any(), payload.LoanID, enum.LoanStatusPaidOff, payload.ChangedAt).Return(nil)
+
+				err := svc.ProcessLoanStatusEvent(nil, payload)
+				Expect(err).To(BeNil())
+			})
+		})
+
+		Describe("Negative Case", func() {
+			It("when error updating loan status", func() {
+				repo.EXPECT().UpdateLoanStatus(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(someErr)
+
+				err := svc.ProcessLoanStatusEvent(nil, payload)
+				Expect(err).To(HaveOccurred())
+			})
+		})
+	})
+
+	Describe("ProcessRestructureEvent", func() {
+		payload := model.LoanRestructuredPayload{
+			LoanID:               uuid.New(),
+			CancelledScheduleIDs: []uuid.UUID{uuid.New()},
+			Schedules: []model.LoanSchedule{
+				{ScheduleID: uuid.New(), PaymentNo: 4, PaymentAmount: idr(50000), PaymentStatus: enum.PaymentStatusPending},
+			},
+		}
+
+		Describe("Positive Case", func() {
+			It("when restructure event is successfully processed", func() {
+				repo.EXPECT().ReplaceSchedules(gomock.Any(), payload.LoanID, payload.CancelledScheduleIDs, gomock.Any()).
+					DoAndReturn(func(_ any, _ uuid.UUID, _ []uuid.UUID, schedules []domain.PaymentSchedule) error {
+						Expect(schedules).To(HaveLen(1))
+						Expect(schedules[0].LoanID).To(Equal(payload.LoanID))
+						Expect(schedules[0].PaymentNo).To(Equal(4))
+						return nil
+					})
+
+				err := svc.ProcessRestructureEvent(nil, payload)
+				Expect(err).To(BeNil())
+			})
+		})
+
+		Describe("Negative Case", func() {
+			It("when error replacing schedules", func() {
+				repo.EXPECT().ReplaceSchedules(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(someErr)
+
+				err := svc.ProcessRestructureEvent(nil, payload)
+				Expect(err).To(HaveOccurred())
+			})
+		})
+	})
+
+	Describe("ProcessPaymentHolidayEvent", func() {
+		dueDate := time.Date(2024, time.June, 10, 0, 0, 0, 0, time.UTC)
+		payload := model.PaymentHolidayGrantedPayload{
+			LoanID:    uuid.New(),
+			Schedules: []model.ScheduleDueDate{{ScheduleID: uuid.New(), PaymentDueDate: dueDate}},
+		}
+
+		Describe("Positive Case", func() {
+			It("when payment holiday event is successfully processed", func() {
+				repo.EXPECT().UpdateScheduleDueDates(gomock.Any(), payload.LoanID, gomock.Any()).
+					DoAndReturn(func(_ any, _ uuid.UUID, schedules []domain.PaymentSchedule) error {
+						Expect(schedules).To(HaveLen(1))
+						Expect(schedules[0].PaymentDueDate).To(Equal(dueDate))
+						return nil
+					})
+
+				err := svc.ProcessPaymentHolidayEvent(nil, payload)
+				Expect(err).To(BeNil())
+			})
+		})
+
+		Describe("Negative Case", func() {
+			It("when error updating due dates", func() {
+				repo.EXPECT().UpdateScheduleDueDates(gomock.Any(), gomock.Any(), gomock.Any()).Return(someErr)
+
+				err := svc.ProcessPaymentHolidayEvent(nil, payload)
+				Expect(err).To(HaveOccurred())
+			})
+		})
+	})
+
+	Describe("ProcessCancellationEvent", func() {
+		payload := model.LoanCancelledPayload{LoanID: uuid.New(), CancellationID: uuid.New(), CancelledAt: time.Now()}
+
+		Describe("Positive Case", func() {
+			It("when cancellation event is successfully processed", func() {
+				repo.EXPECT().CloseLoan(gomock.Any(), payload.LoanID, enum.LoanStatusCancelled, payload.CancelledAt).Return(nil)
+
+				err := svc.ProcessCancellationEvent(nil, payload)
+				Expect(err).To(BeNil())
+			})
+		})
+
+		Describe("Negative Case", func() {
+			It("when error cancelling loan", func() {
+				repo.EXPECT().CloseLoan(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(someErr)
+
+				err := svc.ProcessCancellationEvent(nil, payload)
+				Expect(err).To(HaveOccurred())
+			})
+		})
+	})
+
+	Describe("ProcessRefinanceEvent", func() {
+		payload := model.LoanRefinancedPayload{LoanID: uuid.New(), NewLoanID: uuid.New(), RefinancedAt: time.Now()}
+
+		Describe("Positive Case", func() {
+			It("when refinance event is successfully processed", func() {
+				repo.EXPECT().CloseLoan(gomock.Any(), payload.LoanID, enum.LoanStatusRefinanced, payload.RefinancedAt).Return(nil)
+
+				err := svc.ProcessRefinanceEvent(nil, payload)
+				Expect(err).To(BeNil())
+			})
+		})
+
+		Describe("Negative Case", func() {
+			It("when error closing loan", func() {
+				repo.EXPECT().CloseLoan(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(someErr)
+
+				err := svc.ProcessRefinanceEvent(nil, payload)
+				Expect(err).To(HaveOccurred())
+			})
+		})
+	})
 })
@@ -0,0 +1,290 @@
+package service
+
+import (
+	"billing-engine/internal/payment/domain"
+	"billing-engine/internal/payment/model"
+	"billing-engine/internal/payment/repository"
+	apperror "billing-engine/pkg/customerror"
+	"billing-engine/pkg/enum"
+	"billing-engine/pkg/money"
+	"billing-engine/pkg/producer"
+	"context"
+	"fmt"
+	"github.com/google/uuid"
+	"time"
+)
+
+func (i impl) ProcessPayoffQuoteEvent(ctx context.Context, payload model.PayoffQuotedPayload) error {
+	i.log.WithField("quote_id", payload.QuoteID).Info("[ProcessPayoffQuoteEvent] processing payoff quote event")
+
+	quote := domain.PayoffQuote{
+		QuoteID:          payload.QuoteID,
+		LoanID:           payload.LoanID,
+		QuoteDate:        payload.QuoteDate,
+		ValidUntil:       payload.ValidUntil,
+		SettlementFee:    payload.SettlementFee,
+		SettlementAmount: payload.SettlementAmount,
+		Status:           payload.Status,
+	}
+
+	for _, val := range payload.Lines {
+		quote.Lines = append(quote.Lines, domain.PayoffQuoteLine{
+			LineID:          val.LineID,
+			QuoteID:         payload.QuoteID,
+			ScheduleID:      val.ScheduleID,
+			PaymentNo:       val.PaymentNo,
+			PrincipalAmount: val.PrincipalAmount,
+			InterestAmount:  val.InterestAmount,
+			PenaltyAmount:   val.PenaltyAmount,
+		})
+	}
+
+	err := i.repo.CreatePayoffQuote(ctx, quote)
+	if err != nil {
+		i.log.WithField("error", err).Error("[ProcessPayoffQuoteEvent] failed to create payoff quote")
+		return err
+	}
+
+	i.log.WithField("quote_id", payload.QuoteID).Info("[ProcessPayoffQuoteEvent] payoff quote event processed")
+	return nil
+}
+
+// ProcessSettlement pays off a loan with a payoff quote: every open installment is closed with what the
+// quote collects on it and the loan is finished. It runs in one transaction that locks the open schedules, so
+// a concurrent payment cannot slip in between the checks and the settlement.
+func (i impl) ProcessSettlement(ctx context.Context, payload model.SettlementPayload) (model.ProcessPaymentResponse, error) {
+	i.log.WithField("payload", payload).Info("[ProcessSettlement] processing settlement")
+
+	var response model.ProcessPaymentResponse
+	err := i.repo.WithTransaction(ctx, func(repo repository.PaymentRepositoryProvider) error {
+		var err error
+		response, err = i.withRepo(repo).recordSettlement(ctx, payload)
+		return err
+	})
+	if err != nil {
+		return model.ProcessPaymentResponse{}, err
+	}
+
+	i.log.WithField("payload", payload).Info("[ProcessSettlement] settlement processed")
+	return response, nil
+}
+
+func (i impl) recordSettlement(ctx context.Context, payload model.SettlementPayload) (model.ProcessPaymentResponse, error) {
+	loan, err := i.repo.GetCustomerLoan(ctx, payload.CustomerID, payload.LoanID)
+	if err != nil {
+		i.log.WithField("error", err).Error("[ProcessSettlement] failed to get customer loan")
+		return model.ProcessPaymentResponse{}, err
+	}
+
+	if loan == nil {
+		return model.ProcessPaymentResponse{}, apperror.New(apperror.NotFound, "customer has no loan")
+	}
+
+	switch loan.Status {
+	case enum.LoanStatusCancelled:
+		return model.ProcessPaymentResponse{}, apperror.New(apperror.InvalidInput, "loan was cancelled")
+	case enum.LoanStatusRefinanced:
+		return model.ProcessPaymentResponse{}, apperror.New(apperror.InvalidInput, "loan was refinanced by a top-up")
+	}
+
+	// the quote and the payments since are only read once the schedules are locked, so they cannot change
+	// before the settlement is stored
+	schedules, err := i.repo.LockOpenSchedules(ctx, payload.LoanID)
+	if err != nil {
+		i.log.WithField("error", err).Error("[ProcessSettlement] failed to get open schedules")
+		return model.ProcessPaymentResponse{}, err
+	}
+
+	quote, err := i.repo.GetPayoffQuote(ctx, payload.QuoteID)
+	if err != nil {
+		i.log.WithField("error", err).Error("[ProcessSettlement] failed to get payoff quote")
+		return model.ProcessPaymentResponse{}, err
+	}
+
+	if quote == nil || quote.LoanID != payload.LoanID {
+		return model.ProcessPaymentResponse{}, apperror.New(apperror.NotFound, "payoff quote not found")
+	}
+
+	if quote.Status != enum.QuoteStatusActive {
+		return model.ProcessPaymentResponse{}, apperror.New(apperror.InvalidInput, "payoff quote was already settled")
+	}
+
+	now := time.Now()
+	if now.After(quote.ValidUntil) {
+		return model.ProcessPaymentResponse{}, apperror.New(apperror.InvalidInput, "payoff quote has expired")
+	}
+
+	if payload.Amount != quote.SettlementAmount {
+		return model.ProcessPaymentResponse{}, apperror.New(apperror.InvalidInput,
+			fmt.Sprintf("settlement amount must be %s", quote.SettlementAmount))
+	}
+
+	// any payment after the quote changes what is left to pay, so the quote no longer adds up
+	paidSince, err := i.repo.HasPaymentSince(ctx, payload.LoanID, quote.QuoteDate)
+	if err != nil {
+		i.log.WithField("error", err).Error("[ProcessSettlement] failed to check payments since quote")
+		return model.ProcessPaymentResponse{}, err
+	}
+
+	if paidSince {
+		return model.ProcessPaymentResponse{}, apperror.New(apperror.InvalidInput,
+			"loan was paid after the quote was issued, request a new quote")
+	}
+
+	allocations, err := settleSchedules(*quote, schedules)
+	if err != nil {
+		i.log.WithField("quote_id", quote.QuoteID).
+			WithField("error", err).Info("[ProcessSettlement] payoff quote does not cover the open schedules")
+		return model.ProcessPaymentResponse{}, err
+	}
+
+	err = i.repo.UpdatePaymentSchedules(ctx, schedules)
+	if err != nil {
+		i.log.WithField("error", err).Error("[ProcessSettlement] failed to update payment schedules")
+		return model.ProcessPaymentResponse{}, err
+	}
+
+	payment, err := i.repo.CreatePayment(ctx, domain.Payment{
+		LoanID:        payload.LoanID,
+		PaymentDate:   now,
+		AmountPaid:    payload.Amount,
+		PaymentMethod: "Virtual Account",
+		PaymentStatus: enum.PaymentStatusPaid,
+		PaymentType:   enum.PaymentTypeSettlement,
+		Allocations:   allocations,
+	})
+	if err != nil {
+		i.log.WithField("error", err).Error("[ProcessSettlement] failed to create payment")
+		return model.ProcessPaymentResponse{}, err
+	}
+
+	err = i.repo.SettlePayoffQuote(ctx, quote.QuoteID)
+	if err != nil {
+		i.log.WithField("error", err).Error("[ProcessSettlement] failed to settle payoff quote")
+		return model.ProcessPaymentResponse{}, err
+	}
+
+	err = i.repo.UpdateLoanStatus(ctx, payload.LoanID, enum.LoanStatusPaidOff, now)
+	if err != nil {
+		i.log.WithField("error", err).Error("[ProcessSettlement] failed to update loan status")
+		return model.ProcessPaymentResponse{}, err
+	}
+
+	producerMessage := producer.Message{
+		EventID:   uuid.New().String(),
+		EventName: producer.EVENT_NAME_LOAN_SETTLED,
+		Data: model.LoanSettledEventPayload{
+			LoanID:     payload.LoanID,
+			QuoteID:    quote.QuoteID,
+			PaymentID:  payment.PaymentID,
+			AmountPaid: payment.AmountPaid,
+			SettledAt:  payment.PaymentDate,
+			Schedules:  mapScheduleBalances(schedules),
+		},
+	}
+
+	err = i.repo.CreateOutboxMessage(ctx, producerMessage)
+	if err != nil {
+		i.log.WithField("error", err).Error("[ProcessSettlement] failed to write message to outbox")
+		return model.ProcessPaymentResponse{}, err
+	}
+
+	return model.ProcessPaymentResponse{
+		AmountPaid:    payment.AmountPaid,
+		PaymentID:     payment.PaymentID,
+		PaymentStatus: payment.PaymentStatus,
+		PaymentType:   payment.PaymentType,
+		PaymentDate:   payment.PaymentDate,
+		Allocations:   mapAllocations(allocations),
+	}, nil
+}
+
+// settleSchedules closes every open schedule with the amounts of its quote line, the rebated interest is
+// simply never collected. The settlement fee is booked on the last installment. The quote no longer adds up
+// when an open schedule has no line, or owes more principal or penalties than its line collects, and the
+// settlement is refused.
+func settleSchedules(quote domain.PayoffQuote, schedules []domain.PaymentSchedule) ([]domain.PaymentAllocation, error) {
+	lines := map[uuid.UUID]domain.PayoffQuoteLine{}
+	for _, val := range quote.Lines {
+		lines[val.ScheduleID] = val
+	}
+
+	for _, schedule := range schedules {
+		line, ok := lines[schedule.ScheduleID]
+		if !ok {
+			return nil, apperror.New(apperror.InvalidInput,
+				fmt.Sprintf("installment %d is not on the payoff quote, request a new quote", schedule.PaymentNo))
+		}
+
+		principalDue, err := schedule.PrincipalAmount.Sub(schedule.PrincipalPaid)
+		if err != nil {
+			return nil, err
+		}
+
+		penaltyDue, err := schedule.PenaltyAmount.Sub(schedule.PenaltyPaid)
+		if err != nil {
+			return nil, err
+		}
+
+		principalOver, err := principalDue.GreaterThan(line.PrincipalAmount)
+		if err != nil {
+			return nil, err
+		}
+
+		penaltyOver, err := penaltyDue.GreaterThan(line.PenaltyAmount)
+		if err != nil {
+			return nil, err
+		}
+
+		if principalOver || penaltyOver {
+			return nil, apperror.New(apperror.InvalidInput,
+				fmt.Sprintf("installment %d owes more than the payoff quote collects, request a new quote", schedule.PaymentNo))
+		}
+	}
+
+	var allocations []domain.PaymentAllocation
+	for idx := range schedules {
+		schedule := &schedules[idx]
+		line := lines[schedule.ScheduleID]
+		for _, part := range []struct {
+			component enum.AllocationComponent
+			amount    money.Money
+			paid      *money.Money
+		}{
+			{enum.AllocationPenalty, line.PenaltyAmount, &schedule.PenaltyPaid},
+			{enum.AllocationInterest, line.InterestAmount, &schedule.InterestPaid},
+			{enum.AllocationPrincipal, line.PrincipalAmount, &schedule.PrincipalPaid},
+		} {
+			if !part.amount.IsPositive() {
+				continue
+			}
+
+			paid, err := part.paid.Add(part.amount)
+			if err != nil {
+				return nil, err
+			}
+
+			*part.paid = paid
+			allocations = append(allocations, domain.PaymentAllocation{
+				ScheduleID: schedule.ScheduleID,
+				PaymentNo:  schedule.PaymentNo,
+				Component:  part.component,
+				Amount:     part.amount,
+			})
+		}
+
+		schedule.PaymentStatus = enum.PaymentStatusPaid
+	}
+
+	if len(schedules) > 0 && quote.SettlementFee.IsPositive() {
+		last := schedules[len(schedules)-1]
+		allocations = append(allocations, domain.PaymentAllocation{
+			ScheduleID: last.ScheduleID,
+			PaymentNo:  last.PaymentNo,
+			Component:  enum.AllocationSettlementFee,
+			Amount:     quote.SettlementFee,
+		})
+	}
+
+	return allocations, nil
+}
@@ -30,13 +30,92 @@ type Kafka struct {
 	LoanTopic    string `mapstructure:"LoanTopic"`
 	PaymentTopic string `mapstructure:"PaymentTopic"`
 	Timeout      int    `mapstructure:"Timeout"`
+	// ConsumerGroup is the group the consumer of the service commits its offsets under, every instance of
+	// the consumer shares the partitions of the topic.
+	ConsumerGroup string `mapstructure:"ConsumerGroup"`
+}
+
+type Scheduler struct {
+	// Interval is the number of seconds between two runs of the overdue job.
+	Interval int `mapstructure:"Interval"`
+}
+
+type DelinquencyRule struct {
+	Name      string `mapstructure:"Name"`
+	Type      string `mapstructure:"Type"`
+	Threshold int    `mapstructure:"Threshold"`
+}
+
+type Delinquency struct {
+	Rules []DelinquencyRule `mapstructure:"Rules"`
+}
+
+type EligibilityRule struct {
+	Name      string `mapstructure:"Name"`
+	Type      string `mapstructure:"Type"`
+	Threshold int64  `mapstructure:"Threshold"`
+}
+
+type Eligibility struct {
+	// AutoApprove approves eligible applications right away, otherwise they wait for an approver.
+	AutoApprove bool              `mapstructure:"AutoApprove"`
+	Rules       []EligibilityRule `mapstructure:"Rules"`
+}
+
+type Disbursement struct {
+	// MaxAttempts is how often a failed bank transfer is tried, BackoffMs the wait before the first retry,
+	// doubled for every next one.
+	MaxAttempts int `mapstructure:"MaxAttempts"`
+	BackoffMs   int `mapstructure:"BackoffMs"`
+	// SimulatorFile keeps the transfers of the local bank simulator, in memory only when empty.
+	SimulatorFile        string  `mapstructure:"SimulatorFile"`
+	SimulatorFailureRate float64 `mapstructure:"SimulatorFailureRate"`
+}
+
+type Payment struct {
+	// Waterfall is the order in which a payment covers the parts of an installment.
+	Waterfall []string `mapstructure:"Waterfall"`
+}
+
+type Outbox struct {
+	// PollIntervalMs is how long the relay waits for new messages once the outbox is empty, at most
+	// BatchSize messages are sent per transaction.
+	PollIntervalMs int `mapstructure:"PollIntervalMs"`
+	BatchSize      int `mapstructure:"BatchSize"`
+	// BackoffMs is the wait before a failed message is sent again, doubled for every next attempt up to
+	// MaxBackoffMs.
+	BackoffMs    int `mapstructure:"BackoffMs"`
+	MaxBackoffMs int `mapstructure:"MaxBackoffMs"`
+	// MaxAttempts is how often a message is sent before it is parked, a parked message no longer holds back
+	// the messages after it and waits for someone to look at it.
+	MaxAttempts int `mapstructure:"MaxAttempts"`
+}
+
+type Consumer struct {
+	// MaxAttempts is how often a message is processed before it is sent to the dead-letter topic, BackoffMs
+	// the wait before the first retry, doubled for every next one up to MaxBackoffMs.
+	MaxAttempts  int `mapstructure:"MaxAttempts"`
+	BackoffMs    int `mapstructure:"BackoffMs"`
+	MaxBackoffMs int `mapstructure:"MaxBackoffMs"`
+	// RetryableCauses are the error causes worth another attempt, an error without a cause counts as
+	// INTERNAL_ERROR. Any other error goes to the dead-letter topic right away.
+	RetryableCauses []string `mapstructure:"RetryableCauses"`
+	// DeadLetterSuffix is appended to a topic to name its dead-letter topic.
+	DeadLetterSuffix string `mapstructure:"DeadLetterSuffix"`
 }
 
 type Config struct {
-	AppServer AppServer `mapstructure:"AppServer"`
-	Database  Database  `mapstructure:"Database"`
-	Cache     Cache     `mapstructure:"Cache"`
-	Kafka     Kafka     `mapstructure:"Kafka"`
+	AppServer    AppServer    `mapstructure:"AppServer"`
+	Database     Database     `mapstructure:"Database"`
+	Cache        Cache        `mapstructure:"Cache"`
+	Kafka        Kafka        `mapstructure:"Kafka"`
+	Scheduler    Scheduler    `mapstructure:"Scheduler"`
+	Delinquency  Delinquency  `mapstructure:"Delinquency"`
+	Eligibility  Eligibility  `mapstructure:"Eligibility"`
+	Disbursement Disbursement `mapstructure:"Disbursement"`
+	Payment      Payment      `mapstructure:"Payment"`
+	Outbox       Outbox       `mapstructure:"Outbox"`
+	Consumer     Consumer     `mapstructure:"Consumer"`
 }
 
 func NewConfig(service string) (*Config, error) {
@@ -1,8 +1,9 @@
 package consumer
 
 type Config struct {
-	Brokers      string
-	Topic        string
-	Partition    int
-	ConsumerName string
+	Brokers         string
+	Topic           string
+	GroupID         string
+	ConsumerName    string
+	DeadLetterTopic string
 }
@@ -3,87 +3,215 @@ package consumer
 import (
 	"billing-engine/pkg/logger"
 	"context"
+	"errors"
 	"github.com/IBM/sarama"
-	"github.com/segmentio/kafka-go"
-	"os"
-	"os/signal"
-	"sync"
-	"syscall"
+	"strconv"
+	"time"
 )
 
-type Consumer struct {
-	config Config
-	conn   *kafka.Conn
-	log    logger.Logger
-
-	processor MessageProcessor
-}
-
 type MessageProcessor interface {
 	ProcessMessage(ctx context.Context, payload []byte) error
 }
 
-func StartNewConsumer(cfg Config, processor MessageProcessor, log logger.Logger) error {
+// Consumer reads every partition of a topic as a member of a consumer group. The offset of a message is
+// committed once it was processed, so a restarted consumer picks up where the group left off, and a group
+// that has no offset yet starts at the oldest message. A message that keeps failing is retried as the
+// retry policy allows and then sent to the dead-letter topic.
+type Consumer struct {
+	config      Config
+	group       sarama.ConsumerGroup
+	deadLetters sarama.SyncProducer
+	retry       RetryPolicy
+	processor   MessageProcessor
+	log         logger.Logger
+}
+
+func NewConsumer(cfg Config, retry RetryPolicy, processor MessageProcessor, log logger.Logger) (*Consumer, error) {
+	// a message must never go back to the topic it failed on
+	if cfg.DeadLetterTopic == "" || cfg.DeadLetterTopic == cfg.Topic {
+		cfg.DeadLetterTopic = cfg.Topic + ".dlq"
+	}
+
 	saramaConfig := sarama.NewConfig()
+	saramaConfig.Consumer.Return.Errors = true
+	saramaConfig.Consumer.Offsets.Initial = sarama.OffsetOldest
+	saramaConfig.Consumer.Group.Rebalance.GroupStrategies = []sarama.BalanceStrategy{sarama.NewBalanceStrategyRoundRobin()}
 
-	c, err := sarama.NewConsumer([]string{cfg.Brokers}, saramaConfig)
+	saramaConfig.Producer.Return.Successes = true
+	saramaConfig.Producer.RequiredAcks = sarama.WaitForAll
+
+	group, err := sarama.NewConsumerGroup([]string{cfg.Brokers}, cfg.GroupID, saramaConfig)
 	if err != nil {
-		log.WithField("error", err).Error("[NewConsumer] failed to create kafka consumer")
-		return err
+		log.WithField("error", err).Error("[NewConsumer] failed to create kafka consumer group")
+		return nil, err
 	}
 
-	partitions, err := c.Partitions(cfg.Topic)
+	deadLetters, err := sarama.NewSyncProducer([]string{cfg.Brokers}, saramaConfig)
 	if err != nil {
-		log.WithField("error", err).Error("[NewConsumer] failed to get partitions")
-		return err
+		log.WithField("error", err).Error("[NewConsumer] failed to create dead-letter producer")
+		_ = group.Close()
+		return nil, err
 	}
 
-	var (
-		messages = make(chan *sarama.ConsumerMessage, 256)
-		closing  = make(chan struct{})
-		wg       sync.WaitGroup
-	)
+	return &Consumer{
+		config:      cfg,
+		group:       group,
+		deadLetters: deadLetters,
+		retry:       retry,
+		processor:   processor,
+		log:         log,
+	}, nil
+}
 
-	log.Info("[NewConsumer] starting consumer")
+// Run consumes until ctx is done. A rebalance ends the session of the group, Run then joins it again and
+// resumes from the committed offsets of the partitions it is given.
+func (c *Consumer) Run(ctx context.Context) error {
+	defer func() {
+		if err := c.group.Close(); err != nil {
+			c.log.WithField("error", err).Error("[Run] failed to close consumer group")
+		}
+
+		if err := c.deadLetters.Close(); err != nil {
+			c.log.WithField("error", err).Error("[Run] failed to close dead-letter producer")
+		}
+	}()
 
-	for _, partition := range partitions {
-		pc, err := c.ConsumePartition(cfg.Topic, partition, sarama.OffsetNewest)
-		if err != nil {
-			log.WithField("error", err).Error("[NewConsumer] failed to create partition consumer")
+	go func() {
+		for err := range c.group.Errors() {
+			c.log.WithField("error", err).WithField("consumer_name", c.config.ConsumerName).
+				Error("[Run] consumer group error")
+		}
+	}()
+
+	c.log.WithField("group_id", c.config.GroupID).
+		WithField("topic", c.config.Topic).Info("[Run] starting consumer")
+	for {
+		err := c.group.Consume(ctx, []string{c.config.Topic}, c)
+		if errors.Is(err, sarama.ErrClosedConsumerGroup) {
+			return nil
+		} else if err != nil {
+			c.log.WithField("error", err).Error("[Run] failed to consume topic")
 			return err
 		}
 
-		go func(pc sarama.PartitionConsumer) {
-			<-closing
-		}(pc)
+		if ctx.Err() != nil {
+			c.log.Info("[Run] consumer stopped")
+			return nil
+		}
+	}
+}
+
+// Setup runs when the group handed out the partitions of a new session.
+func (c *Consumer) Setup(session sarama.ConsumerGroupSession) error {
+	c.log.WithField("consumer_name", c.config.ConsumerName).
+		WithField("partitions", session.Claims()[c.config.Topic]).Info("[Setup] partitions assigned")
+	return nil
+}
+
+// Cleanup runs when the session ends, the offsets marked so far are committed right after.
+func (c *Consumer) Cleanup(session sarama.ConsumerGroupSession) error {
+	c.log.WithField("consumer_name", c.config.ConsumerName).
+		WithField("partitions", session.Claims()[c.config.Topic]).Info("[Cleanup] partitions released")
+	return nil
+}
 
-		wg.Add(1)
-		go func(pc sarama.PartitionConsumer) {
-			defer wg.Done()
-			for msg := range pc.Messages() {
-				messages <- msg
+// ConsumeClaim processes the messages of one partition in order and marks each once it is handled: processed,
+// or sent to the dead-letter topic after its last attempt. A message is never marked when the session ends
+// while it is handled or when the dead-letter topic cannot be reached, it is read again instead.
+func (c *Consumer) ConsumeClaim(session sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
+	for {
+		select {
+		case msg, ok := <-claim.Messages():
+			if !ok {
+				return nil
+			}
+
+			c.log.WithField("consumer_name", c.config.ConsumerName).
+				WithField("partition", msg.Partition).
+				WithField("offset", msg.Offset).Info("[ConsumeClaim] received message")
+			attempts, err := c.process(session.Context(), msg)
+			if err != nil && session.Context().Err() != nil {
+				return nil
 			}
-		}(pc)
-	}
 
-	go func() {
-		for msg := range messages {
-			err := processor.ProcessMessage(context.Background(), msg.Value)
 			if err != nil {
-				log.WithField("error", err).WithField("consumer_name", cfg.ConsumerName).
-					Error("[Consumer] failed to process message")
+				c.log.WithField("error", err).WithField("consumer_name", c.config.ConsumerName).
+					WithField("attempts", attempts).Error("[ConsumeClaim] failed to process message")
+				err = c.deadLetter(msg, err, attempts)
+				if err != nil {
+					return err
+				}
 			}
+
+			session.MarkMessage(msg, "")
+		case <-session.Context().Done():
+			return nil
 		}
-	}()
+	}
+}
 
-	sigterm := make(chan os.Signal, 1)
-	signal.Notify(sigterm, syscall.SIGINT, syscall.SIGTERM)
-	<-sigterm
+// process hands the message to the processor until it succeeds or the retry policy gives up, it returns the
+// number of attempts made and the error of the last one.
+func (c *Consumer) process(ctx context.Context, msg *sarama.ConsumerMessage) (int, error) {
+	for attempts := 1; ; attempts++ {
+		err := c.processor.ProcessMessage(ctx, msg.Value)
+		if err == nil || !c.retry.ShouldRetry(err, attempts) {
+			return attempts, err
+		}
 
-	if err := c.Close(); err != nil {
-		log.WithField("error", err).Error("[NewConsumer] failed to close consumer")
+		wait := c.retry.RetryAfter(attempts)
+		c.log.WithField("error", err).
+			WithField("offset", msg.Offset).
+			WithField("attempts", attempts).
+			WithField("retry_after", wait.String()).Info("[process] retrying message")
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return attempts, ctx.Err()
+		}
+	}
+}
+
+// deadLetter sends a message that could not be processed to the dead-letter topic as it was received, the
+// headers tell where it came from and why it failed.
+func (c *Consumer) deadLetter(msg *sarama.ConsumerMessage, cause error, attempts int) error {
+	headers := make([]sarama.RecordHeader, 0, len(msg.Headers)+8)
+	for _, header := range msg.Headers {
+		headers = append(headers, *header)
+	}
+
+	headers = append(headers,
+		recordHeader("dlq-original-topic", msg.Topic),
+		recordHeader("dlq-original-partition", strconv.Itoa(int(msg.Partition))),
+		recordHeader("dlq-original-offset", strconv.FormatInt(msg.Offset, 10)),
+		recordHeader("dlq-consumer", c.config.ConsumerName),
+		recordHeader("dlq-error", cause.Error()),
+		recordHeader("dlq-error-cause", string(causeOf(cause))),
+		recordHeader("dlq-attempts", strconv.Itoa(attempts)),
+		recordHeader("dlq-failed-at", time.Now().UTC().Format(time.RFC3339)),
+	)
+
+	deadLetter := &sarama.ProducerMessage{
+		Topic:   c.config.DeadLetterTopic,
+		Value:   sarama.ByteEncoder(msg.Value),
+		Headers: headers,
+	}
+	if msg.Key != nil {
+		deadLetter.Key = sarama.ByteEncoder(msg.Key)
+	}
+
+	_, _, err := c.deadLetters.SendMessage(deadLetter)
+	if err != nil {
+		c.log.WithField("error", err).
+			WithField("topic", c.config.DeadLetterTopic).Error("[deadLetter] failed to send message to dead-letter topic")
 		return err
 	}
 
+	c.log.WithField("topic", c.config.DeadLetterTopic).
+		WithField("offset", msg.Offset).Info("[deadLetter] message sent to dead-letter topic")
 	return nil
 }
+
+func recordHeader(key, value string) sarama.RecordHeader {
+	return sarama.RecordHeader{Key: []byte(key), Value: []byte(value)}
+}
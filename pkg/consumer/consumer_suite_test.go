@@ -0,0 +1,13 @@
+package consumer
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestConsumer(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Consumer Suite")
+}
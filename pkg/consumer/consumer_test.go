@@ -0,0 +1,162 @@
+package consumer
+
+import (
+	apperror "billing-engine/pkg/customerror"
+	"billing-engine/pkg/logger"
+	"context"
+	"errors"
+	"github.com/IBM/sarama"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"time"
+)
+
+type processorFunc func(ctx context.Context, payload []byte) error
+
+func (f processorFunc) ProcessMessage(ctx context.Context, payload []byte) error {
+	return f(ctx, payload)
+}
+
+type fakeSession struct {
+	sarama.ConsumerGroupSession
+	ctx    context.Context
+	marked []int64
+}
+
+func (s *fakeSession) Context() context.Context {
+	return s.ctx
+}
+
+func (s *fakeSession) MarkMessage(msg *sarama.ConsumerMessage, _ string) {
+	s.marked = append(s.marked, msg.Offset)
+}
+
+type fakeClaim struct {
+	sarama.ConsumerGroupClaim
+	messages chan *sarama.ConsumerMessage
+}
+
+func (c fakeClaim) Messages() <-chan *sarama.ConsumerMessage {
+	return c.messages
+}
+
+type fakeProducer struct {
+	sarama.SyncProducer
+	sent []*sarama.ProducerMessage
+	err  error
+}
+
+func (p *fakeProducer) SendMessage(msg *sarama.ProducerMessage) (int32, int64, error) {
+	p.sent = append(p.sent, msg)
+	return 0, 0, p.err
+}
+
+func header(msg *sarama.ProducerMessage, key string) string {
+	for _, header := range msg.Headers {
+		if string(header.Key) == key {
+			return string(header.Value)
+		}
+	}
+
+	return ""
+}
+
+var _ = Describe("Consumer", func() {
+	var (
+		session *fakeSession
+		claim   fakeClaim
+	)
+
+	BeforeEach(func() {
+		session = &fakeSession{ctx: context.Background()}
+		claim = fakeClaim{messages: make(chan *sarama.ConsumerMessage, 3)}
+		for offset, value := range []string{"ok", "broken", "ok"} {
+			claim.messages <- &sarama.ConsumerMessage{Offset: int64(offset), Value: []byte(value)}
+		}
+		close(claim.messages)
+	})
+
+	Describe("ConsumeClaim", func() {
+		var (
+			c           *Consumer
+			deadLetters *fakeProducer
+			attempts    map[string]int
+			failures    map[string]error
+		)
+
+		BeforeEach(func() {
+			deadLetters = &fakeProducer{}
+			attempts = map[string]int{}
+			failures = map[string]error{}
+			c = &Consumer{
+				config:      Config{Topic: "loan-topic", DeadLetterTopic: "loan-topic.dlq", ConsumerName: "consumer-test"},
+				deadLetters: deadLetters,
+				retry: RetryPolicy{
+					MaxAttempts:     3,
+					Backoff:         time.Millisecond,
+					MaxBackoff:      time.Millisecond,
+					RetryableCauses: []apperror.Cause{apperror.InternalError},
+				},
+				processor: processorFunc(func(_ context.Context, payload []byte) error {
+					attempts[string(payload)]++
+					return failures[string(payload)]
+				}),
+				log: logger.NewZeroLogger("test"),
+			}
+		})
+
+		It("should mark every processed message", func() {
+			Expect(c.ConsumeClaim(session, claim)).To(Succeed())
+			Expect(session.marked).To(Equal([]int64{0, 1, 2}))
+			Expect(deadLetters.sent).To(BeEmpty())
+		})
+
+		It("should retry an internal error and send the message to the dead-letter topic at last", func() {
+			failures["broken"] = errors.New("connection refused")
+
+			Expect(c.ConsumeClaim(session, claim)).To(Succeed())
+			Expect(attempts["broken"]).To(Equal(3))
+			Expect(session.marked).To(Equal([]int64{0, 1, 2}))
+			Expect(deadLetters.sent).To(HaveLen(1))
+
+			deadLetter := deadLetters.sent[0]
+			Expect(deadLetter.Topic).To(Equal("loan-topic.dlq"))
+			Expect(header(deadLetter, "dlq-original-offset")).To(Equal("1"))
+			Expect(header(deadLetter, "dlq-error")).To(Equal("connection refused"))
+			Expect(header(deadLetter, "dlq-error-cause")).To(Equal(string(apperror.InternalError)))
+			Expect(header(deadLetter, "dlq-attempts")).To(Equal("3"))
+		})
+
+		It("should not retry an error whose cause is not retryable", func() {
+			failures["broken"] = apperror.New(apperror.InvalidInput, "unknown loan")
+
+			Expect(c.ConsumeClaim(session, claim)).To(Succeed())
+			Expect(attempts["broken"]).To(Equal(1))
+			Expect(deadLetters.sent).To(HaveLen(1))
+			Expect(header(deadLetters.sent[0], "dlq-error-cause")).To(Equal(string(apperror.InvalidInput)))
+		})
+
+		It("should leave the message unmarked when the dead-letter topic cannot be reached", func() {
+			failures["broken"] = apperror.New(apperror.InvalidInput, "unknown loan")
+			deadLetters.err = errors.New("broker down")
+
+			Expect(c.ConsumeClaim(session, claim)).To(MatchError("broker down"))
+			Expect(session.marked).To(Equal([]int64{0}))
+		})
+	})
+
+	Describe("RetryPolicy", func() {
+		It("should double the backoff up to the maximum", func() {
+			policy := RetryPolicy{Backoff: time.Second, MaxBackoff: 5 * time.Second}
+			Expect(policy.RetryAfter(1)).To(Equal(time.Second))
+			Expect(policy.RetryAfter(3)).To(Equal(4 * time.Second))
+			Expect(policy.RetryAfter(10)).To(Equal(5 * time.Second))
+		})
+
+		It("should stop after the last attempt", func() {
+			policy := DefaultRetryPolicy()
+			Expect(policy.ShouldRetry(errors.New("timeout"), 1)).To(BeTrue())
+			Expect(policy.ShouldRetry(errors.New("timeout"), policy.MaxAttempts)).To(BeFalse())
+		})
+	})
+})
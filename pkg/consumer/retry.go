@@ -0,0 +1,88 @@
+package consumer
+
+import (
+	"billing-engine/pkg/config"
+	apperror "billing-engine/pkg/customerror"
+	"errors"
+	"time"
+)
+
+// RetryPolicy decides whether a message that failed is processed again and how long to wait before that.
+type RetryPolicy struct {
+	MaxAttempts     int
+	Backoff         time.Duration
+	MaxBackoff      time.Duration
+	RetryableCauses []apperror.Cause
+}
+
+// DefaultRetryPolicy tries a message three times, waiting half a second and then a second, and only retries
+// internal errors.
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts:     3,
+		Backoff:         500 * time.Millisecond,
+		MaxBackoff:      10 * time.Second,
+		RetryableCauses: []apperror.Cause{apperror.InternalError},
+	}
+}
+
+// NewRetryPolicy applies the settings of cfg, settings that are not set keep the default policy.
+func NewRetryPolicy(cfg config.Consumer) RetryPolicy {
+	policy := DefaultRetryPolicy()
+	if cfg.MaxAttempts > 0 {
+		policy.MaxAttempts = cfg.MaxAttempts
+	}
+
+	if cfg.BackoffMs > 0 {
+		policy.Backoff = time.Duration(cfg.BackoffMs) * time.Millisecond
+	}
+
+	if cfg.MaxBackoffMs > 0 {
+		policy.MaxBackoff = time.Duration(cfg.MaxBackoffMs) * time.Millisecond
+	}
+
+	if len(cfg.RetryableCauses) > 0 {
+		policy.RetryableCauses = make([]apperror.Cause, 0, len(cfg.RetryableCauses))
+		for _, cause := range cfg.RetryableCauses {
+			policy.RetryableCauses = append(policy.RetryableCauses, apperror.Cause(cause))
+		}
+	}
+
+	return policy
+}
+
+// ShouldRetry reports whether a message that failed attempts times with err is worth another attempt.
+func (p RetryPolicy) ShouldRetry(err error, attempts int) bool {
+	if attempts >= p.MaxAttempts {
+		return false
+	}
+
+	cause := causeOf(err)
+	for _, retryable := range p.RetryableCauses {
+		if cause == retryable {
+			return true
+		}
+	}
+
+	return false
+}
+
+// RetryAfter is the wait before the next attempt of a message that failed attempts times.
+func (p RetryPolicy) RetryAfter(attempts int) time.Duration {
+	wait := p.Backoff
+	for i := 1; i < attempts && wait < p.MaxBackoff; i++ {
+		wait *= 2
+	}
+
+	return min(wait, p.MaxBackoff)
+}
+
+// causeOf is the cause of a custom error, any other error is unexpected and counts as an internal error.
+func causeOf(err error) apperror.Cause {
+	var customError *apperror.CustomError
+	if errors.As(err, &customError) {
+		return customError.Cause
+	}
+
+	return apperror.InternalError
+}
@@ -9,6 +9,7 @@ const (
 	InternalError Cause = "INTERNAL_ERROR"
 	NotFound      Cause = "NOT_FOUND"
 	AlreadyExists Cause = "ALREADY_EXISTS"
+	LimitExceeded Cause = "LIMIT_EXCEEDED"
 )
 
 type CustomError struct {
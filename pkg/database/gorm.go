@@ -7,7 +7,10 @@ import (
 )
 
 func NewGormConnection(config *config.Config) (*gorm.DB, error) {
-	db, err := gorm.Open(postgres.Open(config.GetDSN()), &gorm.Config{})
+	db, err := gorm.Open(postgres.Open(config.GetDSN()), &gorm.Config{
+		// unique violations come back as gorm.ErrDuplicatedKey instead of a driver specific error
+		TranslateError: true,
+	})
 	if err != nil {
 		return nil, err
 	}
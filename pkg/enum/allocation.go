@@ -0,0 +1,12 @@
+package enum
+
+// AllocationComponent is the part of an installment a payment is allocated to.
+type AllocationComponent string
+
+const (
+	AllocationPenalty   AllocationComponent = "PENALTY"
+	AllocationInterest  AllocationComponent = "INTEREST"
+	AllocationPrincipal AllocationComponent = "PRINCIPAL"
+	// AllocationSettlementFee is only used by early settlements and never part of the waterfall.
+	AllocationSettlementFee AllocationComponent = "SETTLEMENT_FEE"
+)
@@ -0,0 +1,12 @@
+package enum
+
+type AmortizationMethod string
+
+const (
+	// AmortizationFlat charges interest on the original principal for the whole tenor.
+	AmortizationFlat AmortizationMethod = "FLAT"
+	// AmortizationAnnuity keeps every installment equal and charges interest on the remaining principal.
+	AmortizationAnnuity AmortizationMethod = "ANNUITY"
+	// AmortizationDecliningBalance repays an equal principal portion and charges interest on the remaining principal.
+	AmortizationDecliningBalance AmortizationMethod = "DECLINING_BALANCE"
+)
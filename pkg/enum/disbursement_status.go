@@ -0,0 +1,10 @@
+package enum
+
+type DisbursementStatus string
+
+const (
+	DisbursementStatusPending   DisbursementStatus = "PENDING"
+	DisbursementStatusSucceeded DisbursementStatus = "SUCCEEDED"
+	// DisbursementStatusFailed disbursements ran out of attempts, disbursing the loan again retries them.
+	DisbursementStatusFailed DisbursementStatus = "FAILED"
+)
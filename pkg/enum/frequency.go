@@ -0,0 +1,11 @@
+package enum
+
+type Frequency string
+
+const (
+	FrequencyWeekly   Frequency = "WEEKLY"
+	FrequencyBiWeekly Frequency = "BIWEEKLY"
+	FrequencyMonthly  Frequency = "MONTHLY"
+	// FrequencyCustom repeats every IntervalDays days.
+	FrequencyCustom Frequency = "CUSTOM"
+)
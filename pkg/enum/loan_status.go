@@ -0,0 +1,20 @@
+package enum
+
+type LoanStatus string
+
+const (
+	LoanStatusPendingApproval LoanStatus = "PENDING_APPROVAL"
+	LoanStatusApproved        LoanStatus = "APPROVED"
+	// LoanStatusRejected applications failed the eligibility rules or were turned down by an approver.
+	LoanStatusRejected LoanStatus = "REJECTED"
+	// LoanStatusActive loans are disbursed and being repaid.
+	LoanStatusActive    LoanStatus = "ACTIVE"
+	LoanStatusPaidOff   LoanStatus = "PAID_OFF"
+	LoanStatusCancelled LoanStatus = "CANCELLED"
+	// LoanStatusWrittenOff loans left the portfolio unpaid.
+	LoanStatusWrittenOff LoanStatus = "WRITTEN_OFF"
+	// LoanStatusRestructured loans are repaid on a new schedule agreed with the borrower.
+	LoanStatusRestructured LoanStatus = "RESTRUCTURED"
+	// LoanStatusRefinanced loans were settled by a top-up loan that took over their balance.
+	LoanStatusRefinanced LoanStatus = "REFINANCED"
+)
@@ -3,6 +3,9 @@ package enum
 type PaymentStatus string
 
 const (
-	PaymentStatusPending PaymentStatus = "PENDING"
-	PaymentStatusPaid    PaymentStatus = "PAID"
+	PaymentStatusPending       PaymentStatus = "PENDING"
+	PaymentStatusPartiallyPaid PaymentStatus = "PARTIALLY_PAID"
+	PaymentStatusPaid          PaymentStatus = "PAID"
+	// PaymentStatusCancelled schedules were replaced by a new schedule and are only kept for history.
+	PaymentStatusCancelled PaymentStatus = "CANCELLED"
 )
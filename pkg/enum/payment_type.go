@@ -0,0 +1,11 @@
+package enum
+
+// PaymentType tells what a payment was collected for.
+type PaymentType string
+
+const (
+	PaymentTypeInstallment PaymentType = "INSTALLMENT"
+	PaymentTypeSettlement  PaymentType = "SETTLEMENT"
+	// PaymentTypeRecovery is money collected on a written-off loan, it is not allocated to the installments.
+	PaymentTypeRecovery PaymentType = "RECOVERY"
+)
@@ -0,0 +1,10 @@
+package enum
+
+type PenaltyType string
+
+const (
+	// PenaltyLateFee is the one-off fee charged when an installment is first missed.
+	PenaltyLateFee PenaltyType = "LATE_FEE"
+	// PenaltyDaily is charged every day an installment stays overdue, as a percentage of the overdue amount.
+	PenaltyDaily PenaltyType = "DAILY_PENALTY"
+)
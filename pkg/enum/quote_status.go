@@ -0,0 +1,10 @@
+package enum
+
+type QuoteStatus string
+
+const (
+	// QuoteStatusActive quotes can be settled until they expire.
+	QuoteStatusActive QuoteStatus = "ACTIVE"
+	// QuoteStatusSettled quotes were used to close the loan.
+	QuoteStatusSettled QuoteStatus = "SETTLED"
+)
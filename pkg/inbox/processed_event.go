@@ -0,0 +1,34 @@
+package inbox
+
+import (
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"time"
+)
+
+// ProcessedEvent is an event a consumer has handled. It is written in the transaction of the change the event
+// causes, so an event that is delivered again finds it and is skipped.
+type ProcessedEvent struct {
+	EventID     string    `json:"event_id" gorm:"primaryKey"`
+	EventName   string    `json:"event_name"`
+	ProcessedAt time.Time `json:"processed_at"`
+}
+
+func (ProcessedEvent) TableName() string {
+	return "processed_events"
+}
+
+// Record marks the event processed and reports whether it was new, false means it was handled before. Pass the
+// transaction that handles the event, so the mark is rolled back with it when the handling fails.
+func Record(db *gorm.DB, eventID, eventName string) (bool, error) {
+	result := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&ProcessedEvent{
+		EventID:     eventID,
+		EventName:   eventName,
+		ProcessedAt: time.Now(),
+	})
+	if result.Error != nil {
+		return false, result.Error
+	}
+
+	return result.RowsAffected > 0, nil
+}
@@ -0,0 +1,205 @@
+package money
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// DefaultCurrency is used when a product does not say otherwise.
+const DefaultCurrency = "IDR"
+
+// exponents holds the number of minor-unit digits of every supported ISO 4217 currency.
+var exponents = map[string]int{
+	"IDR": 2,
+	"USD": 2,
+	"SGD": 2,
+	"JPY": 0,
+}
+
+// ErrCurrencyMismatch is returned when amounts in different currencies are added up or compared.
+var ErrCurrencyMismatch = errors.New("money: currency mismatch")
+
+// Money is an exact amount stored as an integer number of minor units (e.g. sen or cents).
+// It is embedded into GORM models with a column prefix, e.g. `gorm:"embedded;embeddedPrefix:payment_"`
+// gives payment_amount and payment_currency.
+type Money struct {
+	Amount   int64  `json:"amount"`
+	Currency string `json:"currency" gorm:"size:3"`
+}
+
+// UnmarshalJSON upper-cases the currency, so an amount is compared by its currency code however the
+// client spelled it.
+func (m *Money) UnmarshalJSON(data []byte) error {
+	type plain Money
+	var decoded plain
+	err := json.Unmarshal(data, &decoded)
+	if err != nil {
+		return err
+	}
+
+	*m = New(decoded.Amount, decoded.Currency)
+	return nil
+}
+
+func New(amount int64, currency string) Money {
+	return Money{Amount: amount, Currency: strings.ToUpper(currency)}
+}
+
+// Zero returns a zero amount in the given currency.
+func Zero(currency string) Money {
+	return New(0, currency)
+}
+
+// IsSupported reports whether currency is a known ISO 4217 code.
+func IsSupported(currency string) bool {
+	_, ok := exponents[strings.ToUpper(currency)]
+	return ok
+}
+
+// SameCurrency reports whether all the amounts share the currency of the first one. Unlike the arithmetic
+// it does not let an amount without a currency pass, it is meant for validating input.
+func SameCurrency(first Money, others ...Money) bool {
+	for _, other := range others {
+		if other.Currency != first.Currency {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Sum adds up the amounts, which must share one currency. The sum of no amounts is Money{}.
+func Sum(amounts ...Money) (Money, error) {
+	total := Money{}
+	for _, amount := range amounts {
+		var err error
+		total, err = total.Add(amount)
+		if err != nil {
+			return Money{}, err
+		}
+	}
+
+	return total, nil
+}
+
+func (m Money) Add(other Money) (Money, error) {
+	err := m.match(other)
+	if err != nil {
+		return Money{}, err
+	}
+
+	return Money{Amount: m.Amount + other.Amount, Currency: m.currencyWith(other)}, nil
+}
+
+func (m Money) Sub(other Money) (Money, error) {
+	err := m.match(other)
+	if err != nil {
+		return Money{}, err
+	}
+
+	return Money{Amount: m.Amount - other.Amount, Currency: m.currencyWith(other)}, nil
+}
+
+// MulRate multiplies by a rate and rounds half away from zero to the nearest minor unit.
+func (m Money) MulRate(rate float64) Money {
+	return Money{Amount: int64(math.Round(float64(m.Amount) * rate)), Currency: m.Currency}
+}
+
+// Split divides m into n parts of equal size. Rounding never loses a minor unit:
+// the last part absorbs whatever remainder is left so the parts always add up to m.
+func (m Money) Split(n int) []Money {
+	if n <= 0 {
+		return nil
+	}
+
+	parts := make([]Money, n)
+	share := m.Amount / int64(n)
+	for i := range parts {
+		parts[i] = Money{Amount: share, Currency: m.Currency}
+	}
+	parts[n-1].Amount += m.Amount - share*int64(n)
+
+	return parts
+}
+
+func (m Money) Min(other Money) (Money, error) {
+	less, err := other.LessThan(m)
+	if err != nil {
+		return Money{}, err
+	}
+
+	if less {
+		return other, nil
+	}
+
+	return m, nil
+}
+
+func (m Money) LessThan(other Money) (bool, error) {
+	err := m.match(other)
+	if err != nil {
+		return false, err
+	}
+
+	return m.Amount < other.Amount, nil
+}
+
+func (m Money) GreaterThan(other Money) (bool, error) {
+	err := m.match(other)
+	if err != nil {
+		return false, err
+	}
+
+	return m.Amount > other.Amount, nil
+}
+
+func (m Money) IsZero() bool {
+	return m.Amount == 0
+}
+
+func (m Money) IsPositive() bool {
+	return m.Amount > 0
+}
+
+func (m Money) IsNegative() bool {
+	return m.Amount < 0
+}
+
+// String formats the amount in major units, e.g. "IDR 5000000.00".
+func (m Money) String() string {
+	exponent := exponents[m.Currency]
+	if exponent == 0 {
+		return fmt.Sprintf("%s %d", m.Currency, m.Amount)
+	}
+
+	sign := ""
+	amount := m.Amount
+	if amount < 0 {
+		sign, amount = "-", -amount
+	}
+
+	unit := int64(math.Pow10(exponent))
+	return fmt.Sprintf("%s %s%d.%0*d", m.Currency, sign, amount/unit, exponent, amount%unit)
+}
+
+// currencyWith lets a zero value without a currency take over the currency of the other operand,
+// so sums can start from Money{}.
+func (m Money) currencyWith(other Money) string {
+	if m.Currency == "" {
+		return other.Currency
+	}
+
+	return m.Currency
+}
+
+// match guards against silently mixing currencies. A zero value without a currency matches any currency.
+func (m Money) match(other Money) error {
+	if m.Currency != other.Currency && m.Currency != "" && other.Currency != "" {
+		return fmt.Errorf("%w: %s and %s", ErrCurrencyMismatch, m.Currency, other.Currency)
+	}
+
+	return nil
+}
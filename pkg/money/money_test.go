@@ -0,0 +1,62 @@
+package money
+
+import (
+	"encoding/json"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Money", func() {
+	Describe("Split", func() {
+		It("should put the remainder on the last part", func() {
+			parts := New(1000, "IDR").Split(3)
+			Expect(parts).To(Equal([]Money{New(333, "IDR"), New(333, "IDR"), New(334, "IDR")}))
+		})
+
+		It("should return nothing for a non-positive count", func() {
+			Expect(New(1000, "IDR").Split(0)).To(BeNil())
+		})
+	})
+
+	Describe("Arithmetic", func() {
+		It("should let a zero value adopt the other currency", func() {
+			Expect(Money{}.Add(New(10, "IDR"))).To(Equal(New(10, "IDR")))
+		})
+
+		It("should round rates half away from zero", func() {
+			Expect(New(15, "IDR").MulRate(0.1)).To(Equal(New(2, "IDR")))
+			Expect(New(-15, "IDR").MulRate(0.1)).To(Equal(New(-2, "IDR")))
+		})
+
+		It("should refuse to mix currencies", func() {
+			_, err := New(1, "IDR").Add(New(1, "USD"))
+			Expect(err).To(MatchError(ErrCurrencyMismatch))
+		})
+
+		It("should sum amounts of one currency", func() {
+			Expect(Sum(New(1, "IDR"), Money{}, New(2, "IDR"))).To(Equal(New(3, "IDR")))
+		})
+	})
+
+	Describe("String", func() {
+		It("should format major units", func() {
+			Expect(New(500000050, "IDR").String()).To(Equal("IDR 5000000.50"))
+			Expect(New(-5, "USD").String()).To(Equal("USD -0.05"))
+			Expect(New(1200, "JPY").String()).To(Equal("JPY 1200"))
+		})
+	})
+
+	Describe("UnmarshalJSON", func() {
+		It("should uppercase the currency", func() {
+			var m Money
+			Expect(json.Unmarshal([]byte(`{"amount":100,"currency":"idr"}`), &m)).To(Succeed())
+			Expect(m).To(Equal(New(100, "IDR")))
+		})
+	})
+
+	It("should know the supported currencies", func() {
+		Expect(IsSupported("idr")).To(BeTrue())
+		Expect(IsSupported("XXX")).To(BeFalse())
+	})
+})
@@ -0,0 +1,64 @@
+package outbox
+
+import (
+	"billing-engine/pkg/producer"
+	"encoding/json"
+	"gorm.io/gorm"
+	"time"
+)
+
+// Message is an event waiting in the outbox. It is written in the transaction of the change it announces
+// and sent to kafka by the relay once that transaction is committed, so an event goes out exactly when its
+// change is stored. A message that keeps failing is parked, the relay skips it from then on.
+type Message struct {
+	// MessageID keeps the order in which the messages were written, the relay sends them in that order.
+	MessageID     int64      `json:"message_id" gorm:"primaryKey;autoIncrement"`
+	EventID       string     `json:"event_id" gorm:"index"`
+	EventName     string     `json:"event_name"`
+	Payload       []byte     `json:"payload"`
+	Attempts      int        `json:"attempts"`
+	LastError     string     `json:"last_error"`
+	NextAttemptAt time.Time  `json:"next_attempt_at"`
+	SentAt        *time.Time `json:"sent_at" gorm:"index:idx_outbox_messages_unsent,where:sent_at IS NULL"`
+	ParkedAt      *time.Time `json:"parked_at"`
+	CreatedAt     time.Time  `json:"created_at"`
+}
+
+func (Message) TableName() string {
+	return "outbox_messages"
+}
+
+// NewMessage turns an event into an outbox message that is due right away.
+func NewMessage(message producer.Message) (Message, error) {
+	payload, err := json.Marshal(message.Data)
+	if err != nil {
+		return Message{}, err
+	}
+
+	return Message{
+		EventID:       message.EventID,
+		EventName:     message.EventName,
+		Payload:       payload,
+		NextAttemptAt: time.Now(),
+	}, nil
+}
+
+// Write adds the event to the outbox. Pass the transaction of the change the event announces, so the two
+// are committed or rolled back together.
+func Write(db *gorm.DB, message producer.Message) error {
+	stored, err := NewMessage(message)
+	if err != nil {
+		return err
+	}
+
+	return db.Create(&stored).Error
+}
+
+// producerMessage is the event as it was handed to Write, the payload is sent as it was stored.
+func (m Message) producerMessage() producer.Message {
+	return producer.Message{
+		EventID:   m.EventID,
+		EventName: m.EventName,
+		Data:      json.RawMessage(m.Payload),
+	}
+}
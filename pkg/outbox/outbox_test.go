@@ -0,0 +1,74 @@
+package outbox
+
+import (
+	"billing-engine/pkg/config"
+	"billing-engine/pkg/logger"
+	"billing-engine/pkg/producer"
+	"encoding/json"
+	"errors"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"time"
+)
+
+var _ = Describe("Outbox", func() {
+	Describe("NewMessage", func() {
+		It("should send the event as it was written", func() {
+			event := producer.Message{
+				EventID:   "event-1",
+				EventName: producer.EVENT_NAME_PAYMENT_PAID,
+				Data:      map[string]interface{}{"amount": 1000},
+			}
+
+			message, err := NewMessage(event)
+			Expect(err).To(BeNil())
+			Expect(message.SentAt).To(BeNil())
+
+			written, err := json.Marshal(event)
+			Expect(err).To(BeNil())
+			sent, err := json.Marshal(message.producerMessage())
+			Expect(err).To(BeNil())
+			Expect(sent).To(MatchJSON(written))
+		})
+	})
+
+	Describe("failure", func() {
+		relay := NewRelay(nil, nil, config.Outbox{BackoffMs: 1000, MaxAttempts: 3}, logger.NewZeroLogger("test"))
+		now := time.Now()
+		sendErr := errors.New("broker unavailable")
+
+		It("should schedule the next attempt", func() {
+			updates, parked := relay.failure(Message{Attempts: 1}, sendErr, now)
+			Expect(parked).To(BeFalse())
+			Expect(updates["attempts"]).To(Equal(2))
+			Expect(updates["last_error"]).To(Equal("broker unavailable"))
+			Expect(updates["next_attempt_at"]).To(Equal(now.Add(2 * time.Second)))
+			Expect(updates).NotTo(HaveKey("parked_at"))
+		})
+
+		It("should park the message once it ran out of attempts", func() {
+			updates, parked := relay.failure(Message{Attempts: 2}, sendErr, now)
+			Expect(parked).To(BeTrue())
+			Expect(updates["attempts"]).To(Equal(3))
+			Expect(updates["parked_at"]).To(Equal(now))
+		})
+	})
+
+	Describe("retryAfter", func() {
+		It("should double the backoff up to the maximum", func() {
+			relay := NewRelay(nil, nil, config.Outbox{BackoffMs: 1000, MaxBackoffMs: 5000}, logger.NewZeroLogger("test"))
+			Expect(relay.retryAfter(1)).To(Equal(time.Second))
+			Expect(relay.retryAfter(3)).To(Equal(4 * time.Second))
+			Expect(relay.retryAfter(4)).To(Equal(5 * time.Second))
+			Expect(relay.retryAfter(100)).To(Equal(5 * time.Second))
+		})
+
+		It("should fall back to the defaults", func() {
+			relay := NewRelay(nil, nil, config.Outbox{}, logger.NewZeroLogger("test"))
+			Expect(relay.batchSize).To(Equal(100))
+			Expect(relay.maxAttempts).To(Equal(10))
+			Expect(relay.retryAfter(1)).To(Equal(time.Second))
+			Expect(relay.retryAfter(10)).To(Equal(time.Minute))
+		})
+	})
+})
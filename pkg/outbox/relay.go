@@ -0,0 +1,180 @@
+package outbox
+
+import (
+	"billing-engine/pkg/config"
+	"billing-engine/pkg/logger"
+	"billing-engine/pkg/producer"
+	"context"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"time"
+)
+
+// Relay sends the messages of the outbox to kafka in the order they were written and marks them sent. A
+// message is sent at least once: when marking it fails it is sent again, and the consumers skip the repeat
+// because they record the event id of every event they processed. A message that failed maxAttempts times
+// is parked, so it no longer holds back the messages after it.
+type Relay struct {
+	db           *gorm.DB
+	producer     producer.ProducerProvider
+	pollInterval time.Duration
+	batchSize    int
+	backoff      time.Duration
+	maxBackoff   time.Duration
+	maxAttempts  int
+	log          logger.Logger
+}
+
+// NewRelay applies the settings of cfg, settings that are not set fall back to a poll every second, batches
+// of 100, a backoff from one second up to a minute and 10 attempts before a message is parked.
+func NewRelay(db *gorm.DB, producer producer.ProducerProvider, cfg config.Outbox, log logger.Logger) Relay {
+	relay := Relay{
+		db:           db,
+		producer:     producer,
+		pollInterval: time.Duration(cfg.PollIntervalMs) * time.Millisecond,
+		batchSize:    cfg.BatchSize,
+		backoff:      time.Duration(cfg.BackoffMs) * time.Millisecond,
+		maxBackoff:   time.Duration(cfg.MaxBackoffMs) * time.Millisecond,
+		maxAttempts:  cfg.MaxAttempts,
+		log:          log,
+	}
+
+	if relay.pollInterval <= 0 {
+		relay.pollInterval = time.Second
+	}
+
+	if relay.batchSize <= 0 {
+		relay.batchSize = 100
+	}
+
+	if relay.backoff <= 0 {
+		relay.backoff = time.Second
+	}
+
+	if relay.maxBackoff < relay.backoff {
+		relay.maxBackoff = max(time.Minute, relay.backoff)
+	}
+
+	if relay.maxAttempts <= 0 {
+		relay.maxAttempts = 10
+	}
+
+	return relay
+}
+
+// Run relays the outbox until ctx is done. A full batch is followed by the next one right away, otherwise
+// the relay waits for the poll interval.
+func (r Relay) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.pollInterval)
+	defer ticker.Stop()
+
+	r.log.WithField("poll_interval", r.pollInterval.String()).Info("[Run] outbox relay started")
+	for {
+		sent, err := r.RelayBatch(ctx)
+		if err != nil {
+			r.log.WithField("error", err).Error("[Run] failed to relay outbox messages")
+		}
+
+		if err == nil && sent == r.batchSize && ctx.Err() == nil {
+			continue
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			r.log.Info("[Run] outbox relay stopped")
+			return
+		}
+	}
+}
+
+// RelayBatch sends the oldest unsent messages and returns how many were sent. The batch is locked while it
+// is sent, so a second relay waits instead of sending the same messages. It stops at the first message that
+// fails or waits for its next attempt: the messages after it are held back to keep the order of the events,
+// until the message is parked.
+func (r Relay) RelayBatch(ctx context.Context) (int, error) {
+	sent := 0
+	var sendErr error
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var messages []Message
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("sent_at IS NULL AND parked_at IS NULL").
+			Order("message_id asc").
+			Limit(r.batchSize).
+			Find(&messages).Error
+		if err != nil {
+			return err
+		}
+
+		for _, message := range messages {
+			now := time.Now()
+			if message.NextAttemptAt.After(now) {
+				return nil
+			}
+
+			sendErr = r.producer.SendMessage(ctx, message.producerMessage())
+			if sendErr != nil {
+				updates, parked := r.failure(message, sendErr, now)
+				err = tx.Model(&message).Updates(updates).Error
+				if err != nil {
+					return err
+				}
+
+				if !parked {
+					return nil
+				}
+
+				sendErr = nil
+				continue
+			}
+
+			err = tx.Model(&message).Update("sent_at", now).Error
+			if err != nil {
+				return err
+			}
+
+			sent++
+		}
+
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return sent, sendErr
+}
+
+// failure is what is stored for a message that could not be sent, it reports whether the message is parked
+// because it ran out of attempts.
+func (r Relay) failure(message Message, sendErr error, now time.Time) (map[string]interface{}, bool) {
+	attempts := message.Attempts + 1
+	updates := map[string]interface{}{
+		"attempts":        attempts,
+		"last_error":      sendErr.Error(),
+		"next_attempt_at": now.Add(r.retryAfter(attempts)),
+	}
+
+	if attempts >= r.maxAttempts {
+		r.log.WithField("event_id", message.EventID).
+			WithField("attempts", attempts).
+			WithField("error", sendErr).Error("[RelayBatch] outbox message parked after too many failed attempts")
+		updates["parked_at"] = now
+		return updates, true
+	}
+
+	r.log.WithField("event_id", message.EventID).
+		WithField("attempts", attempts).
+		WithField("error", sendErr).Error("[RelayBatch] failed to send outbox message")
+	return updates, false
+}
+
+// retryAfter is the wait before the next attempt of a message that failed attempts times.
+func (r Relay) retryAfter(attempts int) time.Duration {
+	wait := r.backoff
+	for i := 1; i < attempts && wait < r.maxBackoff; i++ {
+		wait *= 2
+	}
+
+	return min(wait, r.maxBackoff)
+}
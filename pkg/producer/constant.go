@@ -1,6 +1,17 @@
 package producer
 
 const (
-	EVENT_NAME_LOAN_CREATED = "LOAN_CREATED"
-	EVENT_NAME_PAYMENT_PAID = "PAYMENT_PAID"
+	EVENT_NAME_LOAN_CREATED    = "LOAN_CREATED"
+	EVENT_NAME_PAYMENT_PAID    = "PAYMENT_PAID"
+	EVENT_NAME_SCHEDULE_MISSED = "SCHEDULE_MISSED"
+	EVENT_NAME_PENALTY_ACCRUED = "PENALTY_ACCRUED"
+	EVENT_NAME_PAYOFF_QUOTED   = "PAYOFF_QUOTED"
+	EVENT_NAME_LOAN_SETTLED    = "LOAN_SETTLED"
+
+	EVENT_NAME_LOAN_STATUS_CHANGED     = "LOAN_STATUS_CHANGED"
+	EVENT_NAME_LOAN_RESTRUCTURED       = "LOAN_RESTRUCTURED"
+	EVENT_NAME_PAYMENT_HOLIDAY_GRANTED = "PAYMENT_HOLIDAY_GRANTED"
+	EVENT_NAME_LOAN_DISBURSED          = "LOAN_DISBURSED"
+	EVENT_NAME_LOAN_CANCELLED          = "LOAN_CANCELLED"
+	EVENT_NAME_LOAN_REFINANCED         = "LOAN_REFINANCED"
 )